@@ -11,6 +11,7 @@ import (
 	"github.com/google/btree"
 )
 
+// PeerSource is where a peer address is found.
 type PeerSource int
 
 const (
@@ -18,8 +19,33 @@ const (
 	DHT
 	PEX
 	Manual
+	Resume
+	LSD
+	// Incoming is the source of peers that have connected to us. These addresses are not added to AddrList.
+	Incoming
 )
 
+func (s PeerSource) String() string {
+	switch s {
+	case Tracker:
+		return "tracker"
+	case DHT:
+		return "dht"
+	case PEX:
+		return "pex"
+	case Manual:
+		return "manual"
+	case Resume:
+		return "resume"
+	case LSD:
+		return "lsd"
+	case Incoming:
+		return "incoming"
+	default:
+		return "unknown"
+	}
+}
+
 // AddrList contains peer addresses that are ready to be connected.
 type AddrList struct {
 	peerByTime     []*peerAddr
@@ -59,15 +85,16 @@ func (d *AddrList) LenSource(s PeerSource) int {
 	return d.countBySource[s]
 }
 
-func (d *AddrList) Pop() *net.TCPAddr {
+// Pop removes the address with the highest priority and returns it with its source.
+func (d *AddrList) Pop() (*net.TCPAddr, PeerSource) {
 	item := d.peerByPriority.DeleteMax()
 	if item == nil {
-		return nil
+		return nil, 0
 	}
 	p := item.(*peerAddr)
 	d.peerByTime[p.index] = nil
 	d.countBySource[p.source]--
-	return p.addr
+	return p.addr, p.source
 }
 
 func (d *AddrList) Push(addrs []*net.TCPAddr, source PeerSource) {
@@ -122,6 +149,19 @@ func (d *AddrList) Push(addrs []*net.TCPAddr, source PeerSource) {
 	}
 }
 
+// RemoveSource removes all addresses that are found from source s.
+func (d *AddrList) RemoveSource(s PeerSource) {
+	for i, p := range d.peerByTime {
+		if p == nil || p.source != s {
+			continue
+		}
+		d.peerByPriority.Delete(p)
+		d.peerByTime[i] = nil
+	}
+	d.filterNils()
+	delete(d.countBySource, s)
+}
+
 func (d *AddrList) filterNils() {
 	b := d.peerByTime[:0]
 	for _, x := range d.peerByTime {
@@ -31,7 +31,9 @@ func TestAddrList(t *testing.T) {
 	assert.Equal(t, al.peerByTime[1].index, 1)
 
 	// Pop an addr
-	al.Pop()
+	addr, source := al.Pop()
+	assert.Equal(t, addr.IP.String(), "2.2.2.2")
+	assert.Equal(t, source, Tracker)
 	assert.Equal(t, len(al.peerByTime), 2)
 	assert.Equal(t, al.peerByPriority.Len(), 1)
 	assert.Equal(t, al.peerByTime[1], (*peerAddr)(nil))
@@ -52,6 +54,22 @@ func TestAddrList(t *testing.T) {
 	assert.Equal(t, al.peerByTime[1].index, 1)
 }
 
+func TestRemoveSource(t *testing.T) {
+	clientIP := net.IPv4(1, 2, 3, 4)
+	al := New(10, nil, 5000, &clientIP)
+	al.Push([]*net.TCPAddr{newAddr("1.1.1.1")}, Tracker)
+	al.Push([]*net.TCPAddr{newAddr("2.2.2.2"), newAddr("3.3.3.3")}, DHT)
+
+	al.RemoveSource(DHT)
+	assert.Equal(t, 1, al.Len())
+	assert.Equal(t, 0, al.LenSource(DHT))
+	assert.Equal(t, 1, al.LenSource(Tracker))
+	assert.Equal(t, len(al.peerByTime), al.peerByPriority.Len())
+	addr, source := al.Pop()
+	assert.Equal(t, "1.1.1.1", addr.IP.String())
+	assert.Equal(t, Tracker, source)
+}
+
 func newAddr(ip string) *net.TCPAddr {
 	return &net.TCPAddr{IP: net.ParseIP(ip), Port: 1}
 }
@@ -1,16 +1,41 @@
 package allocator
 
 import (
-	"path/filepath"
+	"errors"
+	"os"
 
 	"github.com/cenkalti/rain/internal/metainfo"
 	"github.com/cenkalti/rain/internal/storage"
 )
 
+// Modes for allocating disk space for new files.
+const (
+	// ModeNone creates sparse files. Disk space is allocated as pieces are written.
+	ModeNone = "none"
+	// ModeFull writes zeros to new files.
+	ModeFull = "full"
+	// ModeFalloc reserves disk space with fallocate without writing. ModeFull is used if it is not supported.
+	ModeFalloc = "falloc"
+)
+
+// Size of the buffer used for writing zeros in ModeFull.
+const zeroBufferSize = 1 << 20
+
+var (
+	errClosed                = errors.New("allocator is closed")
+	errFallocateNotSupported = errors.New("fallocate is not supported")
+)
+
 type Allocator struct {
 	Files         []storage.File
 	NeedHashCheck bool
-	Error         error
+	// Mode that is used for allocating new files.
+	// It is different from the mode given to New if fallocate is not supported.
+	Mode string
+	// Number of existing files that are smaller than expected.
+	// Data in those files is missing so pieces must be verified even if a bitfield is saved before.
+	ShortFiles int
+	Error      error
 
 	closeC chan struct{}
 	doneC  chan struct{}
@@ -20,8 +45,9 @@ type Progress struct {
 	AllocatedSize int64
 }
 
-func New() *Allocator {
+func New(mode string) *Allocator {
 	return &Allocator{
+		Mode:   mode,
 		closeC: make(chan struct{}),
 		doneC:  make(chan struct{}),
 	}
@@ -32,7 +58,9 @@ func (a *Allocator) Close() {
 	<-a.doneC
 }
 
-func (a *Allocator) Run(info *metainfo.Info, sto storage.Storage, progressC chan Progress, resultC chan *Allocator) {
+// Run opens or creates the files in info on sto.
+// paths contains the location of each file relative to storage root, in the same order with info.GetFiles().
+func (a *Allocator) Run(info *metainfo.Info, paths []string, sto storage.Storage, progressC chan Progress, resultC chan *Allocator) {
 	defer close(a.doneC)
 
 	defer func() {
@@ -50,38 +78,69 @@ func (a *Allocator) Run(info *metainfo.Info, sto storage.Storage, progressC chan
 	}()
 
 	var allocatedSize int64
-
-	// Single file in torrent
-	if !info.MultiFile {
-		var f storage.File
-		f, a.NeedHashCheck, a.Error = sto.Open(info.Name, info.Length)
-		if a.Error != nil {
-			return
+	files := info.GetFiles()
+	if l, ok := sto.(storage.Layouter); ok {
+		sizes := make([]int64, len(files))
+		for i, f := range files {
+			sizes[i] = f.Length
 		}
-		a.Files = []storage.File{f}
-		allocatedSize += info.Length
-		a.sendProgress(progressC, allocatedSize)
-		return
+		l.SetLayout(paths, sizes)
 	}
-
-	// Multiple files in torrent grouped in a folder
-	a.Files = make([]storage.File, len(info.Files))
-	for i, f := range info.Files {
-		parts := append([]string{info.Name}, f.Path...)
-		path := filepath.Join(parts...)
+	a.Files = make([]storage.File, len(files))
+	for i, f := range files {
+		size, err := sto.Size(paths[i])
+		if os.IsNotExist(err) {
+			// Deleted files have no data.
+			size, err = 0, nil
+		}
+		if err == nil && size < f.Length {
+			a.ShortFiles++
+		}
 		var exists bool
-		a.Files[i], exists, a.Error = sto.Open(path, f.Length)
+		a.Files[i], exists, a.Error = sto.Open(paths[i], f.Length)
 		if a.Error != nil {
 			return
 		}
 		if exists {
 			a.NeedHashCheck = true
+		} else if a.Mode != ModeNone && f.Length > 0 {
+			a.Error = a.allocate(a.Files[i], f.Length)
+			if a.Error != nil {
+				return
+			}
 		}
 		allocatedSize += f.Length
 		a.sendProgress(progressC, allocatedSize)
 	}
 }
 
+// allocate reserves disk space for a new file that is created with the given size.
+func (a *Allocator) allocate(f storage.File, size int64) error {
+	if a.Mode == ModeFalloc {
+		err := fallocate(f, size)
+		if err != errFallocateNotSupported {
+			return err
+		}
+		a.Mode = ModeFull
+	}
+	buf := make([]byte, zeroBufferSize)
+	for off := int64(0); off < size; off += int64(len(buf)) {
+		select {
+		case <-a.closeC:
+			return errClosed
+		default:
+		}
+		if size-off < int64(len(buf)) {
+			buf = buf[:size-off]
+		}
+		_, err := f.WriteAt(buf, off)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (a *Allocator) sendProgress(progressC chan Progress, size int64) {
 	select {
 	case progressC <- Progress{AllocatedSize: size}:
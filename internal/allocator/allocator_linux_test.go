@@ -0,0 +1,53 @@
+package allocator
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/storage/filestorage"
+)
+
+func TestAllocationModes(t *testing.T) {
+	const size = 3*zeroBufferSize + 100
+	for _, mode := range []string{ModeNone, ModeFull, ModeFalloc} {
+		dir, err := ioutil.TempDir("", "rain-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		sto, err := filestorage.New(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := &metainfo.Info{Name: "file", Length: size}
+		a := New(mode)
+		progressC := make(chan Progress, 1)
+		resultC := make(chan *Allocator, 1)
+		a.Run(info, []string{"file"}, sto, progressC, resultC)
+		if a.Error != nil {
+			t.Fatal(mode, a.Error)
+		}
+		a.Files[0].Close()
+		if mode == ModeFull && a.Mode != ModeFull {
+			t.Fatalf("unexpected mode used: %q", a.Mode)
+		}
+		fi, err := os.Stat(filepath.Join(dir, "file"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Size() != size {
+			t.Fatalf("unexpected size in %q mode: %d", mode, fi.Size())
+		}
+		blocks := fi.Sys().(*syscall.Stat_t).Blocks * 512
+		if mode == ModeNone && blocks >= size {
+			t.Errorf("file is not sparse: %d bytes allocated", blocks)
+		}
+		if mode != ModeNone && blocks < size {
+			t.Errorf("file is not allocated in %q mode: %d bytes allocated", mode, blocks)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package allocator
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/storage"
+	"github.com/cenkalti/rain/internal/storage/containerstorage"
+	"github.com/cenkalti/rain/internal/storage/filestorage"
+)
+
+func runAllocator(t *testing.T, info *metainfo.Info, sto storage.Storage) *Allocator {
+	a := New(ModeFull)
+	progressC := make(chan Progress, 1)
+	resultC := make(chan *Allocator, 1)
+	a.Run(info, []string{"file"}, sto, progressC, resultC)
+	if a.Error != nil {
+		t.Fatal(a.Error)
+	}
+	for _, f := range a.Files {
+		f.Close()
+	}
+	return a
+}
+
+func TestShortFilesDeleted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rain-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		storage string
+		path    string
+		new     func(dest string) (storage.Storage, error)
+	}{
+		{"file", filepath.Join(dir, "file", "file"), func(dest string) (storage.Storage, error) { return filestorage.New(dest) }},
+		{"container", filepath.Join(dir, "container"), func(dest string) (storage.Storage, error) { return containerstorage.New(dest) }},
+	}
+	info := &metainfo.Info{Name: "file", Length: 100}
+	for _, c := range cases {
+		dest := filepath.Join(dir, c.storage)
+		sto, err := c.new(dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		runAllocator(t, info, sto)
+		sto, err = c.new(dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a := runAllocator(t, info, sto); a.ShortFiles != 0 {
+			t.Fatalf("%s: allocated file is counted as short", c.storage)
+		}
+
+		// File is deleted while the session is not running.
+		if err = os.Remove(c.path); err != nil {
+			t.Fatal(err)
+		}
+		sto, err = c.new(dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a := runAllocator(t, info, sto); a.ShortFiles != 1 {
+			t.Fatalf("%s: deleted file is not counted as short", c.storage)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package allocator
+
+import (
+	"syscall"
+
+	"github.com/cenkalti/rain/internal/storage"
+)
+
+func fallocate(f storage.File, size int64) error {
+	of, ok := f.(interface{ Fd() uintptr })
+	if !ok {
+		return errFallocateNotSupported
+	}
+	err := syscall.Fallocate(int(of.Fd()), 0, 0, size)
+	if err == syscall.EOPNOTSUPP || err == syscall.ENOSYS {
+		return errFallocateNotSupported
+	}
+	return err
+}
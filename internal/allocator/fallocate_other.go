@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package allocator
+
+import "github.com/cenkalti/rain/internal/storage"
+
+func fallocate(f storage.File, size int64) error {
+	return errFallocateNotSupported
+}
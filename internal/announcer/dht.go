@@ -10,6 +10,7 @@ type DHTAnnouncer struct {
 	lastAnnounce   time.Time
 	needMorePeers  bool
 	needMorePeersC chan bool
+	announceNowC   chan struct{}
 	closeC         chan struct{}
 	doneC          chan struct{}
 }
@@ -17,6 +18,7 @@ type DHTAnnouncer struct {
 func NewDHTAnnouncer() *DHTAnnouncer {
 	return &DHTAnnouncer{
 		needMorePeersC: make(chan bool),
+		announceNowC:   make(chan struct{}),
 		closeC:         make(chan struct{}),
 		doneC:          make(chan struct{}),
 	}
@@ -34,13 +36,31 @@ func (a *DHTAnnouncer) NeedMorePeers(val bool) {
 	}
 }
 
+// AnnounceNow makes an announce without waiting for the interval.
+// If the last announce is made in min interval, the announce is scheduled at the end of min interval.
+func (a *DHTAnnouncer) AnnounceNow() {
+	select {
+	case a.announceNowC <- struct{}{}:
+	case <-a.doneC:
+	}
+}
+
 func (a *DHTAnnouncer) Run(announceFunc func(), interval, minInterval time.Duration, l logger.Logger) {
 	defer close(a.doneC)
 
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	// Announce scheduled by AnnounceNow.
+	var timer *time.Timer
+	var timerC <-chan time.Time
+
 	announce := func() {
+		if timer != nil {
+			timer.Stop()
+			timer = nil
+			timerC = nil
+		}
 		announceFunc()
 		a.lastAnnounce = time.Now()
 	}
@@ -64,7 +84,23 @@ func (a *DHTAnnouncer) Run(announceFunc func(), interval, minInterval time.Durat
 				}
 			}
 			a.needMorePeers = val
+		case <-a.announceNowC:
+			if wait := time.Until(a.lastAnnounce.Add(minInterval)); wait > 0 {
+				if timer == nil {
+					timer = time.NewTimer(wait)
+					timerC = timer.C
+				}
+				break
+			}
+			announce()
+		case <-timerC:
+			timer = nil
+			timerC = nil
+			announce()
 		case <-a.closeC:
+			if timer != nil {
+				timer.Stop()
+			}
 			return
 		}
 	}
@@ -0,0 +1,49 @@
+package announcer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/logger"
+)
+
+func TestDHTAnnounceNowMinInterval(t *testing.T) {
+	const minInterval = 200 * time.Millisecond
+	announceC := make(chan time.Time, 10)
+	a := NewDHTAnnouncer()
+	go a.Run(func() { announceC <- time.Now() }, time.Hour, minInterval, logger.New("test"))
+	defer a.Close()
+
+	first := <-announceC
+	a.AnnounceNow()
+	a.AnnounceNow()
+	select {
+	case at := <-announceC:
+		if d := at.Sub(first); d < minInterval {
+			t.Fatalf("announced %s after last announce", d)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("scheduled announce is not made")
+	}
+	// Multiple calls in min interval result in a single announce.
+	select {
+	case <-announceC:
+		t.Fatal("unexpected announce")
+	case <-time.After(2 * minInterval):
+	}
+}
+
+func TestDHTAnnounceNow(t *testing.T) {
+	announceC := make(chan time.Time, 10)
+	a := NewDHTAnnouncer()
+	go a.Run(func() { announceC <- time.Now() }, time.Hour, 0, logger.New("test"))
+	defer a.Close()
+
+	<-announceC
+	a.AnnounceNow()
+	select {
+	case <-announceC:
+	case <-time.After(time.Second):
+		t.Fatal("announce is not made")
+	}
+}
@@ -34,9 +34,14 @@ type PeriodicalAnnouncer struct {
 	newPeers       chan []*net.TCPAddr
 	backoff        backoff.BackOff
 	requests       chan *Request
+	statusChangeC  chan StatusChange
+	lastResult     Status
 	lastAnnounce   time.Time
+	lastFailure    time.Time
+	nextAnnounce   time.Time
 	HasAnnounced   bool
 	needMorePeersC chan bool
+	announceNowC   chan struct{}
 	closeC         chan struct{}
 	doneC          chan struct{}
 }
@@ -50,7 +55,17 @@ type Response struct {
 	Torrent tracker.Torrent
 }
 
-func NewPeriodicalAnnouncer(trk tracker.Tracker, numWant int, minInterval time.Duration, requests chan *Request, completedC chan struct{}, newPeers chan []*net.TCPAddr, l logger.Logger) *PeriodicalAnnouncer {
+// StatusChange is sent when the tracker starts working after a failure or fails after working or before it is contacted.
+type StatusChange struct {
+	Tracker tracker.Tracker
+	Status  Status
+	Error   error
+}
+
+// NewPeriodicalAnnouncer returns a new announcer. If statusChangeC is not nil, a StatusChange is sent to it
+// each time the tracker switches between Working and NotWorking. The send does not block; statusChangeC must be buffered.
+// Failed announces are retried with exponential backoff up to maxRetryInterval.
+func NewPeriodicalAnnouncer(trk tracker.Tracker, numWant int, minInterval, maxRetryInterval time.Duration, requests chan *Request, completedC chan struct{}, newPeers chan []*net.TCPAddr, statusChangeC chan StatusChange, l logger.Logger) *PeriodicalAnnouncer {
 	return &PeriodicalAnnouncer{
 		Tracker:        trk,
 		status:         NotContactedYet,
@@ -61,14 +76,17 @@ func NewPeriodicalAnnouncer(trk tracker.Tracker, numWant int, minInterval time.D
 		completedC:     completedC,
 		newPeers:       newPeers,
 		requests:       requests,
+		statusChangeC:  statusChangeC,
+		lastResult:     NotContactedYet,
 		needMorePeersC: make(chan bool),
+		announceNowC:   make(chan struct{}),
 		closeC:         make(chan struct{}),
 		doneC:          make(chan struct{}),
 		backoff: &backoff.ExponentialBackOff{
 			InitialInterval:     5 * time.Second,
 			RandomizationFactor: 0.5,
 			Multiplier:          2,
-			MaxInterval:         30 * time.Minute,
+			MaxInterval:         maxRetryInterval,
 			MaxElapsedTime:      0, // never stop
 			Clock:               backoff.SystemClock,
 		},
@@ -105,6 +123,17 @@ func (a *PeriodicalAnnouncer) NeedMorePeers(val bool) {
 	}
 }
 
+// AnnounceNow makes an announce without waiting for the interval given by the tracker.
+// If the last announce is made in min interval, the announce is scheduled at the end of min interval.
+// A failing tracker is retried immediately without waiting for the backoff.
+// Does nothing if there is an announce in progress.
+func (a *PeriodicalAnnouncer) AnnounceNow() {
+	select {
+	case a.announceNowC <- struct{}{}:
+	case <-a.doneC:
+	}
+}
+
 func (a *PeriodicalAnnouncer) Run() {
 	defer close(a.doneC)
 	a.backoff.Reset()
@@ -123,6 +152,7 @@ func (a *PeriodicalAnnouncer) Run() {
 		}
 		timer = time.NewTimer(d)
 		timerC = timer.C
+		a.nextAnnounce = time.Now().Add(d)
 	}
 
 	var needMorePeers bool
@@ -141,13 +171,18 @@ func (a *PeriodicalAnnouncer) Run() {
 			a.lastAnnounce = time.Now()
 			a.seeders = int(resp.Seeders)
 			a.leechers = int(resp.Leechers)
-			a.interval = resp.Interval
 			if resp.MinInterval > 0 {
 				a.minInterval = resp.MinInterval
 			}
+			// Tracker must not be announced more frequently than min interval even if it returns a shorter interval.
+			a.interval = resp.Interval
+			if a.interval < a.minInterval {
+				a.interval = a.minInterval
+			}
 			a.HasAnnounced = true
 			a.lastError = nil
 			a.status = Working
+			a.notifyStatusChange()
 			a.backoff.Reset()
 			if needMorePeers {
 				setTimer(a.minInterval)
@@ -157,17 +192,40 @@ func (a *PeriodicalAnnouncer) Run() {
 		case a.lastError = <-announcer.ErrorC:
 			announcer.announcing = false
 			a.status = NotWorking
+			a.lastFailure = time.Now()
 			a.log.Debugln("announce error:", a.lastError)
+			a.notifyStatusChange()
 			setTimer(a.backoff.NextBackOff())
 		case needMorePeers = <-a.needMorePeersC:
 			if announcer.announcing {
 				break
 			}
+			if a.status == NotWorking {
+				// Backoff may grow up to max retry interval. Retry sooner if more peers are needed,
+				// but not more frequently than min interval.
+				if needMorePeers {
+					if next := a.lastFailure.Add(a.minInterval); next.Before(a.nextAnnounce) {
+						setTimer(time.Until(next))
+					}
+				}
+				break
+			}
 			if needMorePeers {
 				setTimer(time.Until(a.lastAnnounce.Add(a.minInterval)))
 			} else {
 				setTimer(time.Until(a.lastAnnounce.Add(a.interval)))
 			}
+		case <-a.announceNowC:
+			if announcer.announcing {
+				break
+			}
+			// Trackers may ban clients that announce more frequently than min interval.
+			if wait := time.Until(a.lastAnnounce.Add(a.minInterval)); wait > 0 {
+				setTimer(wait)
+				break
+			}
+			a.status = Contacting
+			announcer.Announce(tracker.EventNone, a.numWant)
 		case <-a.completedC:
 			announcer.Cancel()
 			a.status = Contacting
@@ -184,19 +242,52 @@ func (a *PeriodicalAnnouncer) Run() {
 	}
 }
 
+// notifyStatusChange sends the current status to statusChangeC if it is different than the result of previous announce.
+// Success of the first announce is not reported.
+// The change is dropped if statusChangeC is full and it is sent again after the next announce.
+// Blocking here would deadlock with the torrent loop that may be waiting for Stats, NeedMorePeers or AnnounceNow
+// of this announcer.
+func (a *PeriodicalAnnouncer) notifyStatusChange() {
+	prev := a.lastResult
+	if prev == a.status {
+		return
+	}
+	if a.statusChangeC == nil || (prev == NotContactedYet && a.status == Working) {
+		a.lastResult = a.status
+		return
+	}
+	sc := StatusChange{
+		Tracker: a.Tracker,
+		Status:  a.status,
+		Error:   a.lastError,
+	}
+	select {
+	case a.statusChangeC <- sc:
+		a.lastResult = a.status
+	default:
+		a.log.Debugln("tracker status change dropped:", a.status)
+	}
+}
+
 type Stats struct {
 	Status   Status
 	Error    error
 	Seeders  int
 	Leechers int
+	// Time of the last successful announce. Zero if the tracker has not responded yet.
+	LastAnnounce time.Time
+	// Time of the next scheduled announce. Zero before the first announce is finished.
+	NextAnnounce time.Time
 }
 
 func (a *PeriodicalAnnouncer) stats() Stats {
 	return Stats{
-		Status:   a.status,
-		Error:    a.lastError,
-		Seeders:  a.seeders,
-		Leechers: a.leechers,
+		Status:       a.status,
+		Error:        a.lastError,
+		Seeders:      a.seeders,
+		Leechers:     a.leechers,
+		LastAnnounce: a.lastAnnounce,
+		NextAnnounce: a.nextAnnounce,
 	}
 }
 
@@ -0,0 +1,166 @@
+package announcer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/tracker"
+)
+
+// failingTracker returns an error for every announce and reports the event of each announce to announceC.
+type failingTracker struct {
+	announceC chan tracker.Event
+}
+
+func (t *failingTracker) Announce(ctx context.Context, req tracker.AnnounceRequest) (*tracker.AnnounceResponse, error) {
+	t.announceC <- req.Event
+	return nil, errors.New("tracker is down")
+}
+
+func (t *failingTracker) Scrape(ctx context.Context, infoHashes [][20]byte) (map[[20]byte]tracker.ScrapeResult, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (t *failingTracker) URL() string {
+	return "http://tracker.example.com/announce"
+}
+
+func newFailingAnnouncer(t *testing.T, minInterval time.Duration) (*PeriodicalAnnouncer, *failingTracker) {
+	trk := &failingTracker{announceC: make(chan tracker.Event, 10)}
+	requests := make(chan *Request)
+	go func() {
+		for req := range requests {
+			req.Response <- Response{}
+		}
+	}()
+	a := NewPeriodicalAnnouncer(trk, 50, minInterval, time.Hour, requests, nil, make(chan []*net.TCPAddr), nil, logger.New("test"))
+	go a.Run()
+	waitAnnounce(t, trk)
+	// Wait until the failure is processed.
+	for a.Stats().Status != NotWorking {
+		time.Sleep(time.Millisecond)
+	}
+	return a, trk
+}
+
+func waitAnnounce(t *testing.T, trk *failingTracker) {
+	select {
+	case <-trk.announceC:
+	case <-time.After(time.Second):
+		t.Fatal("tracker is not announced")
+	}
+}
+
+func TestAnnounceNowFailingTracker(t *testing.T) {
+	a, trk := newFailingAnnouncer(t, time.Hour)
+	defer a.Close()
+
+	// Backoff of the first failure is at least 2.5 seconds.
+	a.AnnounceNow()
+	waitAnnounce(t, trk)
+}
+
+func TestNeedMorePeersFailingTracker(t *testing.T) {
+	a, trk := newFailingAnnouncer(t, 100*time.Millisecond)
+	defer a.Close()
+
+	a.NeedMorePeers(true)
+	waitAnnounce(t, trk)
+}
+
+func TestNeedMorePeersDoesNotExtendBackoff(t *testing.T) {
+	a, _ := newFailingAnnouncer(t, time.Hour)
+	defer a.Close()
+
+	next := a.Stats().NextAnnounce
+	a.NeedMorePeers(true)
+	a.NeedMorePeers(false)
+	if s := a.Stats(); !s.NextAnnounce.Equal(next) {
+		t.Fatalf("retry is rescheduled from %s to %s", next, s.NextAnnounce)
+	}
+}
+
+func TestNotifyStatusChangeDropped(t *testing.T) {
+	a := &PeriodicalAnnouncer{
+		status:        NotWorking,
+		lastResult:    NotContactedYet,
+		statusChangeC: make(chan StatusChange),
+		log:           logger.New("test"),
+	}
+	// Nobody receives from the channel so the change is dropped.
+	a.notifyStatusChange()
+	if a.lastResult != NotContactedYet {
+		t.Fatalf("dropped status is saved as last result: %d", a.lastResult)
+	}
+
+	// Same status is reported after the next announce.
+	a.statusChangeC = make(chan StatusChange, 1)
+	a.notifyStatusChange()
+	select {
+	case sc := <-a.statusChangeC:
+		if sc.Status != NotWorking {
+			t.Fatalf("unexpected status: %d", sc.Status)
+		}
+	default:
+		t.Fatal("status change is not sent again")
+	}
+	a.notifyStatusChange()
+	if len(a.statusChangeC) != 0 {
+		t.Fatal("status change is sent twice")
+	}
+}
+
+// workingTracker returns the same response for every announce.
+type workingTracker struct {
+	resp tracker.AnnounceResponse
+}
+
+func (t *workingTracker) Announce(ctx context.Context, req tracker.AnnounceRequest) (*tracker.AnnounceResponse, error) {
+	resp := t.resp
+	return &resp, nil
+}
+
+func (t *workingTracker) Scrape(ctx context.Context, infoHashes [][20]byte) (map[[20]byte]tracker.ScrapeResult, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (t *workingTracker) URL() string {
+	return "http://tracker.example.com/announce"
+}
+
+func TestLastAnnounce(t *testing.T) {
+	trk := &workingTracker{resp: tracker.AnnounceResponse{Interval: time.Hour, Seeders: 3, Leechers: 5}}
+	requests := make(chan *Request)
+	go func() {
+		for req := range requests {
+			req.Response <- Response{}
+		}
+	}()
+	newPeers := make(chan []*net.TCPAddr)
+	go func() {
+		for range newPeers {
+		}
+	}()
+	a := NewPeriodicalAnnouncer(trk, 50, time.Minute, time.Hour, requests, nil, newPeers, nil, logger.New("test"))
+	before := time.Now()
+	go a.Run()
+	defer a.Close()
+	for a.Stats().Status != Working {
+		time.Sleep(time.Millisecond)
+	}
+	s := a.Stats()
+	if s.LastAnnounce.Before(before) || s.LastAnnounce.After(time.Now()) {
+		t.Fatalf("invalid last announce time: %s", s.LastAnnounce)
+	}
+	if !s.NextAnnounce.After(s.LastAnnounce) {
+		t.Fatalf("next announce %s is not after last announce %s", s.NextAnnounce, s.LastAnnounce)
+	}
+	// Swarm counts come from the announce response.
+	if s.Seeders != 3 || s.Leechers != 5 {
+		t.Fatalf("unexpected peer counts: seeders=%d leechers=%d", s.Seeders, s.Leechers)
+	}
+}
@@ -9,24 +9,27 @@ import (
 )
 
 type StopAnnouncer struct {
-	log      logger.Logger
-	timeout  time.Duration
-	trackers []tracker.Tracker
-	torrent  tracker.Torrent
-	resultC  chan struct{}
-	closeC   chan struct{}
-	doneC    chan struct{}
+	log        logger.Logger
+	timeout    time.Duration
+	trackers   []tracker.Tracker
+	torrent    tracker.Torrent
+	resultC    chan struct{}
+	announcedC chan struct{}
+	failed     []string
+	closeC     chan struct{}
+	doneC      chan struct{}
 }
 
 func NewStopAnnouncer(trackers []tracker.Tracker, tra tracker.Torrent, timeout time.Duration, resultC chan struct{}, l logger.Logger) *StopAnnouncer {
 	return &StopAnnouncer{
-		log:      l,
-		timeout:  timeout,
-		trackers: trackers,
-		torrent:  tra,
-		resultC:  resultC,
-		closeC:   make(chan struct{}),
-		doneC:    make(chan struct{}),
+		log:        l,
+		timeout:    timeout,
+		trackers:   trackers,
+		torrent:    tra,
+		resultC:    resultC,
+		announcedC: make(chan struct{}),
+		closeC:     make(chan struct{}),
+		doneC:      make(chan struct{}),
 	}
 }
 
@@ -35,6 +38,17 @@ func (a *StopAnnouncer) Close() {
 	<-a.doneC
 }
 
+// Announced returns a channel that is closed when all announce requests are finished.
+func (a *StopAnnouncer) Announced() <-chan struct{} {
+	return a.announcedC
+}
+
+// Failed returns the URLs of trackers that the stopped event could not be announced to.
+// Must be called after Announced channel is closed or the announcer is closed.
+func (a *StopAnnouncer) Failed() []string {
+	return a.failed
+}
+
 func (a *StopAnnouncer) Run() {
 	defer close(a.doneC)
 
@@ -44,20 +58,27 @@ func (a *StopAnnouncer) Run() {
 		cancel()
 	}()
 
-	doneC := make(chan struct{})
+	doneC := make(chan string)
 	for _, trk := range a.trackers {
 		go func(trk tracker.Tracker) {
 			req := tracker.AnnounceRequest{
 				Torrent: a.torrent,
 				Event:   tracker.EventStopped,
 			}
-			_, _ = trk.Announce(ctx, req)
-			doneC <- struct{}{}
+			_, err := trk.Announce(ctx, req)
+			if err != nil {
+				doneC <- trk.URL()
+				return
+			}
+			doneC <- ""
 		}(trk)
 	}
 	for range a.trackers {
-		<-doneC
+		if u := <-doneC; u != "" {
+			a.failed = append(a.failed, u)
+		}
 	}
+	close(a.announcedC)
 	select {
 	case a.resultC <- struct{}{}:
 	case <-a.closeC:
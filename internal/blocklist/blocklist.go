@@ -12,9 +12,15 @@ import (
 	"github.com/cenkalti/rain/internal/blocklist/stree"
 )
 
+// Blocklist is a set of IPv4 ranges. Ranges can be loaded in CIDR format, e.g. "1.2.3.0/24",
+// or in eMule/PeerGuardian P2P format, e.g. "Some Organization:1.2.3.0-1.2.3.255".
 type Blocklist struct {
 	tree stree.Stree
-	m    sync.RWMutex
+	// Ranges loaded with Reload.
+	ranges []ipRange
+	// Ranges added with Add. They are kept when the list is reloaded.
+	custom []ipRange
+	m      sync.RWMutex
 }
 
 func New() *Blocklist {
@@ -34,22 +40,51 @@ func (b *Blocklist) Blocked(ip net.IP) bool {
 	return b.tree.Contains(stree.ValueType(val))
 }
 
+// Reload replaces the ranges in the list with the ranges read from r. Invalid lines are skipped.
+// Returns the number of ranges loaded.
 func (b *Blocklist) Reload(r io.Reader) (int, error) {
+	ranges, err := load(r)
+	if err != nil {
+		return len(ranges), err
+	}
+
 	b.m.Lock()
 	defer b.m.Unlock()
 
-	tree, n, err := load(r)
+	b.ranges = ranges
+	b.build()
+	return len(ranges), nil
+}
+
+// Add adds a single range to the list in CIDR or P2P format.
+func (b *Blocklist) Add(s string) error {
+	r, err := parseLine([]byte(s))
 	if err != nil {
-		return n, err
+		return err
 	}
 
-	b.tree = tree
-	return n, nil
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	b.custom = append(b.custom, r)
+	b.build()
+	return nil
 }
 
-func load(r io.Reader) (stree.Stree, int, error) {
+func (b *Blocklist) build() {
 	var tree stree.Stree
-	var n int
+	for _, r := range b.ranges {
+		tree.AddRange(stree.ValueType(r.first), stree.ValueType(r.last))
+	}
+	for _, r := range b.custom {
+		tree.AddRange(stree.ValueType(r.first), stree.ValueType(r.last))
+	}
+	tree.Build()
+	b.tree = tree
+}
+
+func load(r io.Reader) ([]ipRange, error) {
+	var ranges []ipRange
 	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		l := bytes.TrimSpace(scanner.Bytes())
@@ -59,21 +94,56 @@ func load(r io.Reader) (stree.Stree, int, error) {
 		if l[0] == '#' {
 			continue
 		}
-		r, err := parseCIDR(l)
+		r, err := parseLine(l)
 		if err != nil {
 			continue
 		}
-		tree.AddRange(stree.ValueType(r.first), stree.ValueType(r.last))
-		n++
+		ranges = append(ranges, r)
 	}
-	tree.Build()
-	return tree, n, scanner.Err()
+	return ranges, scanner.Err()
 }
 
 type ipRange struct {
 	first, last uint32
 }
 
+func parseLine(b []byte) (ipRange, error) {
+	if bytes.IndexByte(b, '-') != -1 {
+		return parseRange(b)
+	}
+	return parseCIDR(b)
+}
+
+// parseRange parses a line in P2P format: "description:first-last". Description is optional.
+func parseRange(b []byte) (r ipRange, err error) {
+	if i := bytes.LastIndexByte(b, ':'); i != -1 {
+		b = b[i+1:]
+	}
+	i := bytes.IndexByte(b, '-')
+	first, err := parseIPv4(bytes.TrimSpace(b[:i]))
+	if err != nil {
+		return
+	}
+	last, err := parseIPv4(bytes.TrimSpace(b[i+1:]))
+	if err != nil {
+		return
+	}
+	if first > last {
+		err = errors.New("invalid range")
+		return
+	}
+	r.first, r.last = first, last
+	return
+}
+
+func parseIPv4(b []byte) (uint32, error) {
+	ip := net.ParseIP(string(b)).To4()
+	if ip == nil {
+		return 0, errors.New("address is not ipv4")
+	}
+	return binary.BigEndian.Uint32(ip), nil
+}
+
 func parseCIDR(b []byte) (r ipRange, err error) {
 	_, ipnet, err := net.ParseCIDR(string(b))
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -40,3 +41,49 @@ func TestContains(t *testing.T) {
 		t.Errorf("must not contain")
 	}
 }
+
+func TestParseRange(t *testing.T) {
+	cases := []struct {
+		line        string
+		first, last uint32
+	}{
+		{"0.0.1.0-0.0.1.255", 256, 511},
+		{"Some Organization:0.0.1.0-0.0.1.255", 256, 511},
+		{"Org: with colon:0.0.0.1 - 0.0.0.1", 1, 1},
+	}
+	for _, c := range cases {
+		r, err := parseLine([]byte(c.line))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.first != c.first || r.last != c.last {
+			t.Errorf("line: %q, range: %d-%d", c.line, r.first, r.last)
+		}
+	}
+	if _, err := parseLine([]byte("Org:0.0.1.255-0.0.1.0")); err == nil {
+		t.Error("reversed range must be invalid")
+	}
+}
+
+func TestAdd(t *testing.T) {
+	b := New()
+	if err := b.Add("10.0.0.0/8"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Add("not an address"); err == nil {
+		t.Error("invalid range must return error")
+	}
+	if !b.Blocked(net.ParseIP("10.1.2.3")) {
+		t.Errorf("must contain")
+	}
+	_, err := b.Reload(strings.NewReader("Org:6.0.0.0-6.255.255.255\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b.Blocked(net.ParseIP("6.1.2.3")) {
+		t.Errorf("must contain")
+	}
+	if !b.Blocked(net.ParseIP("10.1.2.3")) {
+		t.Errorf("added range must be kept after reload")
+	}
+}
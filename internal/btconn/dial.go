@@ -12,6 +12,7 @@ import (
 
 func Dial(
 	addr net.Addr,
+	localIP net.IP,
 	dialTimeout, handshakeTimeout time.Duration,
 	enableEncryption,
 	forceEncryption bool,
@@ -20,6 +21,7 @@ func Dial(
 	ourID [20]byte,
 	stopC chan struct{}) (
 	conn net.Conn, cipher mse.CryptoMethod, peerExtensions [8]byte, peerID [20]byte, err error) {
+	// cipher is zero if the connection is not established with encryption handshake.
 
 	log := logger.New("conn -> " + addr.String())
 	done := make(chan struct{})
@@ -37,6 +39,9 @@ func Dial(
 	// First connection
 	log.Debug("Connecting to peer...")
 	dialer := net.Dialer{Timeout: dialTimeout}
+	if localIP != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: localIP}
+	}
 	conn, err = dialer.DialContext(ctx, addr.Network(), addr.String())
 	if err != nil {
 		return
@@ -93,6 +98,7 @@ func Dial(
 			}
 
 			// Close current connection and try again without encryption
+			cipher = 0
 			conn.Close()
 			log.Debug("Connecting again without encryption...")
 			conn, err = dialer.DialContext(ctx, addr.Network(), addr.String())
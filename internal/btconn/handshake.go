@@ -8,6 +8,9 @@ import (
 
 var errInvalidProtocol = errors.New("invalid protocol")
 
+// HandshakeSize is the number of bytes sent by each side in BitTorrent handshake.
+const HandshakeSize = 20 + 8 + 20 + 20
+
 var pstr = [20]byte{19, 'B', 'i', 't', 'T', 'o', 'r', 'r', 'e', 'n', 't', ' ', 'p', 'r', 'o', 't', 'o', 'c', 'o', 'l'}
 
 func writeHandshake(w io.Writer, ih [20]byte, id [20]byte, extensions [8]byte) error {
@@ -0,0 +1,116 @@
+// Package clientid decodes the client name and version from peer IDs sent in handshake.
+// Azureus-style ("-XX1234-") and Shadow-style ("S58B-----") IDs are supported.
+package clientid
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// Azureus-style client codes.
+var azureusClients = map[string]string{
+	"AG": "Ares",
+	"AZ": "Vuze",
+	"BC": "BitComet",
+	"BI": "BiglyBT",
+	"BT": "BitTorrent",
+	"DE": "Deluge",
+	"FD": "Free Download Manager",
+	"FW": "FrostWire",
+	"KT": "KTorrent",
+	"LT": "libtorrent",
+	"lt": "rTorrent",
+	"PI": "PicoTorrent",
+	"qB": "qBittorrent",
+	"RN": "Rain",
+	"SD": "Thunder",
+	"TL": "Tribler",
+	"TR": "Transmission",
+	"UM": "µTorrent Mac",
+	"UT": "µTorrent",
+	"UW": "µTorrent Web",
+	"WW": "WebTorrent",
+	"XL": "Xunlei",
+}
+
+// Shadow-style client codes.
+var shadowClients = map[byte]string{
+	'A': "ABC",
+	'O': "Osprey Permaseed",
+	'Q': "BTQueue",
+	'R': "Tribler",
+	'S': "Shadow",
+	'T': "BitTornado",
+	'U': "UPnP NAT Bit Torrent",
+}
+
+const shadowAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz.-"
+
+// Parse returns the client name and version encoded in peer ID, e.g. "qBittorrent 4.5.2".
+// If the ID is not recognized, hex encoded first 8 bytes of the ID is returned.
+func Parse(id [20]byte) string {
+	if s, ok := parseAzureus(id); ok {
+		return s
+	}
+	if s, ok := parseShadow(id); ok {
+		return s
+	}
+	return hex.EncodeToString(id[:8])
+}
+
+func parseAzureus(id [20]byte) (string, bool) {
+	if id[0] != '-' || id[7] != '-' {
+		return "", false
+	}
+	code := string(id[1:3])
+	name, ok := azureusClients[code]
+	if !ok {
+		if !isAlnum(id[1]) || !isAlnum(id[2]) {
+			return "", false
+		}
+		name = code
+	}
+	parts := make([]string, 0, 4)
+	for _, c := range id[3:7] {
+		n := strings.IndexByte(shadowAlphabet[:62], c)
+		if n < 0 {
+			return "", false
+		}
+		parts = append(parts, strconv.Itoa(n))
+	}
+	return name + " " + formatVersion(parts), true
+}
+
+func parseShadow(id [20]byte) (string, bool) {
+	name, ok := shadowClients[id[0]]
+	if !ok {
+		return "", false
+	}
+	// Version is up to 5 characters terminated by "---".
+	end := strings.Index(string(id[1:9]), "---")
+	if end < 1 || end > 5 {
+		return "", false
+	}
+	parts := make([]string, 0, end)
+	for _, c := range id[1 : 1+end] {
+		n := strings.IndexByte(shadowAlphabet, c)
+		if n < 0 {
+			return "", false
+		}
+		parts = append(parts, strconv.Itoa(n))
+	}
+	return name + " " + formatVersion(parts), true
+}
+
+// formatVersion joins version numbers with dots. Trailing zeros are removed but at least two numbers are kept.
+func formatVersion(parts []string) string {
+	for len(parts) > 2 && parts[len(parts)-1] == "0" {
+		parts = parts[:len(parts)-1]
+	}
+	return strings.Join(parts, ".")
+}
+
+func isAlnum(c byte) bool {
+	return strings.IndexByte(shadowAlphabet[:62], c) >= 0
+}
@@ -0,0 +1,23 @@
+package clientid
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		id       string
+		expected string
+	}{
+		{"-qB4520-abcdefghijkl", "qBittorrent 4.5.2"},
+		{"-TR2940-abcdefghijkl", "Transmission 2.9.4"},
+		{"-XX1000-abcdefghijkl", "XX 1.0"},
+		{"S58B-----abcdefghijk", "Shadow 5.8.11"},
+		{"M4-3-6--abcdefghijkl", "4d342d332d362d2d"},
+	}
+	for _, c := range cases {
+		var id [20]byte
+		copy(id[:], c.id)
+		if s := Parse(id); s != c.expected {
+			t.Errorf("unexpected client for %q: %q", c.id, s)
+		}
+	}
+}
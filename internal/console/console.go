@@ -154,7 +154,7 @@ func (c *Console) drawDetails(g *gocui.Gui) error {
 				}
 			case trackers:
 				for i, t := range c.trackers {
-					fmt.Fprintf(v, "#%d [%s] Status: %s, Seeders: %d, Leechers: %d\n", i, t.URL, t.Status, t.Seeders, t.Leechers)
+					fmt.Fprintf(v, "#%d Tier: %d [%s] Status: %s, Seeders: %d, Leechers: %d\n", i, t.Tier, t.URL, t.Status, t.Seeders, t.Leechers)
 				}
 			case peers:
 				for i, p := range c.peers {
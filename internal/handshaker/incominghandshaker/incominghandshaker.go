@@ -8,13 +8,16 @@ import (
 	"github.com/cenkalti/rain/internal/bitfield"
 	"github.com/cenkalti/rain/internal/btconn"
 	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/mse"
 )
 
 type IncomingHandshaker struct {
 	Conn       net.Conn
 	PeerID     [20]byte
 	Extensions *bitfield.Bitfield
-	Error      error
+	// Connection is encrypted with RC4 after the encryption handshake.
+	Encrypted bool
+	Error     error
 
 	closeC chan struct{}
 	doneC  chan struct{}
@@ -71,4 +74,5 @@ func (h *IncomingHandshaker) Run(peerID [20]byte, getSKeyFunc func([20]byte) []b
 	h.Conn = conn
 	h.PeerID = peerID
 	h.Extensions = peerbf
+	h.Encrypted = cipher == mse.RC4
 }
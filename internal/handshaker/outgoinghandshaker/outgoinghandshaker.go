@@ -8,6 +8,7 @@ import (
 	"github.com/cenkalti/rain/internal/bitfield"
 	"github.com/cenkalti/rain/internal/btconn"
 	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/mse"
 )
 
 type OutgoingHandshaker struct {
@@ -15,17 +16,22 @@ type OutgoingHandshaker struct {
 	Conn       net.Conn
 	PeerID     [20]byte
 	Extensions *bitfield.Bitfield
-	Error      error
+	// Connection is encrypted with RC4 after the encryption handshake.
+	Encrypted bool
+	Error     error
 
-	closeC chan struct{}
-	doneC  chan struct{}
+	localIP net.IP
+	closeC  chan struct{}
+	doneC   chan struct{}
 }
 
-func New(addr *net.TCPAddr) *OutgoingHandshaker {
+// New returns a new OutgoingHandshaker that connects to addr from localIP. If localIP is nil, the system picks the address.
+func New(addr *net.TCPAddr, localIP net.IP) *OutgoingHandshaker {
 	return &OutgoingHandshaker{
-		Addr:   addr,
-		closeC: make(chan struct{}),
-		doneC:  make(chan struct{}),
+		Addr:    addr,
+		localIP: localIP,
+		closeC:  make(chan struct{}),
+		doneC:   make(chan struct{}),
 	}
 }
 
@@ -41,7 +47,7 @@ func (h *OutgoingHandshaker) Run(dialTimeout, handshakeTimeout time.Duration, pe
 	var ourExtensionsBytes [8]byte
 	copy(ourExtensionsBytes[:], ourExtensions.Bytes())
 
-	conn, cipher, peerExtensions, peerID, err := btconn.Dial(h.Addr, dialTimeout, handshakeTimeout, !disableOutgoingEncryption, forceOutgoingEncryption, ourExtensionsBytes, infoHash, peerID, h.closeC)
+	conn, cipher, peerExtensions, peerID, err := btconn.Dial(h.Addr, h.localIP, dialTimeout, handshakeTimeout, !disableOutgoingEncryption, forceOutgoingEncryption, ourExtensionsBytes, infoHash, peerID, h.closeC)
 	if err != nil {
 		if err == io.EOF {
 			log.Debug("peer has closed the connection: EOF")
@@ -67,6 +73,7 @@ func (h *OutgoingHandshaker) Run(dialTimeout, handshakeTimeout time.Duration, pe
 	h.Conn = conn
 	h.PeerID = peerID
 	h.Extensions = peerbf
+	h.Encrypted = cipher == mse.RC4
 
 	select {
 	case resultC <- h:
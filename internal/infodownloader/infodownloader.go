@@ -2,6 +2,7 @@ package infodownloader
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/cenkalti/rain/internal/peer"
 	"github.com/cenkalti/rain/internal/peerprotocol"
@@ -14,25 +15,46 @@ type InfoDownloader struct {
 	Peer  *peer.Peer
 	Bytes []byte
 
+	// Number of metadata bytes received from the peer so far.
+	BytesReceived int64
+
 	blocks         []block
 	requested      map[uint32]struct{}
 	nextBlockIndex uint32
+
+	timer  *time.Timer
+	closeC chan struct{}
 }
 
 type block struct {
 	size uint32
 }
 
-func New(pe *peer.Peer) *InfoDownloader {
+// New returns a new InfoDownloader for downloading metadata from pe.
+// If the download does not complete in timeout duration, the downloader is sent to timeoutC.
+func New(pe *peer.Peer, timeout time.Duration, timeoutC chan *InfoDownloader) *InfoDownloader {
 	d := &InfoDownloader{
 		Peer:      pe,
 		Bytes:     make([]byte, pe.ExtensionHandshake.MetadataSize),
 		requested: make(map[uint32]struct{}),
+		closeC:    make(chan struct{}),
 	}
 	d.blocks = d.createBlocks()
+	d.timer = time.AfterFunc(timeout, func() {
+		select {
+		case timeoutC <- d:
+		case <-d.closeC:
+		}
+	})
 	return d
 }
 
+// Close stops the download timer.
+func (d *InfoDownloader) Close() {
+	d.timer.Stop()
+	close(d.closeC)
+}
+
 func (d *InfoDownloader) GotBlock(index uint32, data []byte) error {
 	if _, ok := d.requested[index]; !ok {
 		return fmt.Errorf("peer sent unrequested index for metadata message: %q", index)
@@ -45,6 +67,7 @@ func (d *InfoDownloader) GotBlock(index uint32, data []byte) error {
 	begin := index * blockSize
 	end := begin + b.size
 	copy(d.Bytes[begin:end], data)
+	d.BytesReceived += int64(len(data))
 	return nil
 }
 
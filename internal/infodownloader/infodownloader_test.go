@@ -0,0 +1,62 @@
+package infodownloader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+)
+
+func newTestPeer(metadataSize uint32) *peer.Peer {
+	return &peer.Peer{ExtensionHandshake: &peerprotocol.ExtensionHandshakeMessage{MetadataSize: metadataSize}}
+}
+
+func TestTimeout(t *testing.T) {
+	timeoutC := make(chan *InfoDownloader)
+	d := New(newTestPeer(blockSize+1), 10*time.Millisecond, timeoutC)
+	defer d.Close()
+	select {
+	case id := <-timeoutC:
+		if id != d {
+			t.Fatal("unexpected downloader")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("downloader is not timed out")
+	}
+}
+
+func TestCloseStopsTimeout(t *testing.T) {
+	timeoutC := make(chan *InfoDownloader)
+	d := New(newTestPeer(blockSize+1), 10*time.Millisecond, timeoutC)
+	d.Close()
+	select {
+	case <-timeoutC:
+		t.Fatal("closed downloader is timed out")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestGotBlock(t *testing.T) {
+	d := New(newTestPeer(blockSize+1), time.Minute, nil)
+	defer d.Close()
+	if len(d.blocks) != 2 || d.blocks[1].size != 1 {
+		t.Fatalf("unexpected blocks: %v", d.blocks)
+	}
+	if err := d.GotBlock(1, []byte{1}); err == nil {
+		t.Fatal("unrequested block must be rejected")
+	}
+	d.requested[1] = struct{}{}
+	if err := d.GotBlock(1, []byte{1, 2}); err == nil {
+		t.Fatal("block with invalid size must be rejected")
+	}
+	if d.BytesReceived != 0 {
+		t.Fatal("invalid block is counted as received")
+	}
+	if err := d.GotBlock(1, []byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	if d.BytesReceived != 1 {
+		t.Fatalf("unexpected received bytes: %d", d.BytesReceived)
+	}
+}
@@ -0,0 +1,199 @@
+// Package lsd implements Local Service Discovery (BEP 14).
+// Peers in the same local network find each other by sending announce messages to a multicast group.
+package lsd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/cenkalti/rain/internal/logger"
+)
+
+const (
+	multicastHost  = "239.192.152.143"
+	multicastHost6 = "ff15::efc0:988f"
+	multicastPort  = 6771
+
+	maxMessageSize = 1400
+)
+
+// Peer is a peer found in local network.
+type Peer struct {
+	InfoHash [20]byte
+	Addr     *net.TCPAddr
+}
+
+// LSD sends announce messages to the multicast group and listens for announces of other peers.
+type LSD struct {
+	conn   *net.UDPConn
+	group  *net.UDPAddr
+	host   string
+	cookie string
+	peersC chan Peer
+	closeC chan struct{}
+	doneC  chan struct{}
+	log    logger.Logger
+}
+
+// New joins the multicast group and starts listening for announces.
+// If localIP is not nil, the group is joined on the interface that has localIP
+// and IPv6 multicast group is used if localIP is an IPv6 address.
+func New(localIP net.IP, l logger.Logger) (*LSD, error) {
+	network, host := "udp4", multicastHost
+	if localIP != nil && localIP.To4() == nil {
+		network, host = "udp6", multicastHost6
+	}
+	var ifi *net.Interface
+	if localIP != nil && !localIP.IsUnspecified() {
+		var err error
+		ifi, err = interfaceByIP(localIP)
+		if err != nil {
+			return nil, err
+		}
+	}
+	group := &net.UDPAddr{IP: net.ParseIP(host), Port: multicastPort}
+	conn, err := net.ListenMulticastUDP(network, ifi, group)
+	if err != nil {
+		return nil, err
+	}
+	// Cookie is used for ignoring our own announces that are looped back by the multicast group.
+	b := make([]byte, 8)
+	_, err = rand.Read(b)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	d := &LSD{
+		conn:   conn,
+		group:  group,
+		host:   net.JoinHostPort(host, strconv.Itoa(multicastPort)),
+		cookie: hex.EncodeToString(b),
+		peersC: make(chan Peer),
+		closeC: make(chan struct{}),
+		doneC:  make(chan struct{}),
+		log:    l,
+	}
+	go d.run()
+	return d, nil
+}
+
+// Close leaves the multicast group.
+func (d *LSD) Close() {
+	close(d.closeC)
+	d.conn.Close()
+	<-d.doneC
+}
+
+// Peers returns a channel that peers found in local network are sent to.
+func (d *LSD) Peers() <-chan Peer {
+	return d.peersC
+}
+
+// Announce tells the peers in local network that we are accepting connections for the torrent at port.
+func (d *LSD) Announce(infoHash [20]byte, port int) {
+	msg := fmt.Sprintf("BT-SEARCH * HTTP/1.1\r\n"+
+		"Host: %s\r\n"+
+		"Port: %d\r\n"+
+		"Infohash: %x\r\n"+
+		"cookie: %s\r\n"+
+		"\r\n\r\n", d.host, port, infoHash, d.cookie)
+	_, err := d.conn.WriteToUDP([]byte(msg), d.group)
+	if err != nil {
+		d.log.Debugln("cannot send announce:", err.Error())
+	}
+}
+
+func (d *LSD) run() {
+	defer close(d.doneC)
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, from, err := d.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-d.closeC:
+			default:
+				d.log.Errorln("cannot read announce:", err.Error())
+			}
+			return
+		}
+		port, infoHashes, cookie, err := parseMessage(buf[:n])
+		if err != nil {
+			d.log.Debugln("invalid announce from", from.String(), ":", err.Error())
+			continue
+		}
+		if cookie == d.cookie {
+			continue
+		}
+		addr := &net.TCPAddr{IP: from.IP, Port: port}
+		for _, ih := range infoHashes {
+			select {
+			case d.peersC <- Peer{InfoHash: ih, Addr: addr}:
+			case <-d.closeC:
+				return
+			}
+		}
+	}
+}
+
+// interfaceByIP returns the network interface that has the IP address.
+func interfaceByIP(ip net.IP) (*net.Interface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	for i := range ifaces {
+		addrs, err := ifaces[i].Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.Equal(ip) {
+				return &ifaces[i], nil
+			}
+		}
+	}
+	return nil, errors.New("no interface with address " + ip.String())
+}
+
+// parseMessage parses a BT-SEARCH message. A message may contain multiple info hashes.
+func parseMessage(b []byte) (port int, infoHashes [][20]byte, cookie string, err error) {
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(b)))
+	if err != nil {
+		return
+	}
+	if req.Method != "BT-SEARCH" {
+		err = errors.New("invalid method: " + req.Method)
+		return
+	}
+	port, err = strconv.Atoi(req.Header.Get("Port"))
+	if err != nil {
+		return
+	}
+	if port <= 0 || port > 65535 {
+		err = errors.New("invalid port: " + strconv.Itoa(port))
+		return
+	}
+	for _, s := range req.Header["Infohash"] {
+		var ih [20]byte
+		if hex.DecodedLen(len(s)) != len(ih) {
+			continue
+		}
+		if _, err2 := hex.Decode(ih[:], []byte(s)); err2 != nil {
+			continue
+		}
+		infoHashes = append(infoHashes, ih)
+	}
+	if len(infoHashes) == 0 {
+		err = errors.New("no valid info hash")
+		return
+	}
+	cookie = req.Header.Get("Cookie")
+	return
+}
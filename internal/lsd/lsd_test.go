@@ -0,0 +1,59 @@
+package lsd
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseMessage(t *testing.T) {
+	msg := "BT-SEARCH * HTTP/1.1\r\n" +
+		"Host: 239.192.152.143:6771\r\n" +
+		"Port: 6881\r\n" +
+		"Infohash: 0102030405060708090a0b0c0d0e0f1011121314\r\n" +
+		"Infohash: invalid\r\n" +
+		"Infohash: 1112131415161718191a1b1c1d1e1f2021222324\r\n" +
+		"cookie: abc\r\n" +
+		"\r\n\r\n"
+	port, infoHashes, cookie, err := parseMessage([]byte(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port != 6881 {
+		t.Errorf("unexpected port: %d", port)
+	}
+	if len(infoHashes) != 2 {
+		t.Fatalf("unexpected number of info hashes: %d", len(infoHashes))
+	}
+	if infoHashes[0][0] != 0x01 || infoHashes[1][19] != 0x24 {
+		t.Errorf("unexpected info hashes: %x", infoHashes)
+	}
+	if cookie != "abc" {
+		t.Errorf("unexpected cookie: %q", cookie)
+	}
+}
+
+func TestParseMessageInvalid(t *testing.T) {
+	msgs := []string{
+		"GET / HTTP/1.1\r\nPort: 6881\r\nInfohash: 0102030405060708090a0b0c0d0e0f1011121314\r\n\r\n",
+		"BT-SEARCH * HTTP/1.1\r\nPort: 0\r\nInfohash: 0102030405060708090a0b0c0d0e0f1011121314\r\n\r\n",
+		"BT-SEARCH * HTTP/1.1\r\nPort: 6881\r\n\r\n",
+	}
+	for _, msg := range msgs {
+		if _, _, _, err := parseMessage([]byte(msg)); err == nil {
+			t.Errorf("expected error for message: %q", msg)
+		}
+	}
+}
+
+func TestInterfaceByIP(t *testing.T) {
+	ifi, err := interfaceByIP(net.IPv4(127, 0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ifi.Flags&net.FlagLoopback == 0 {
+		t.Fatalf("not a loopback interface: %s", ifi.Name)
+	}
+	if _, err = interfaceByIP(net.ParseIP("192.0.2.1")); err == nil {
+		t.Fatal("expected error for unassigned address")
+	}
+}
@@ -1,4 +1,4 @@
-// Package magnet provides support for parsing magnet links.
+// Package magnet provides support for parsing and generating magnet links.
 package magnet
 
 import (
@@ -43,7 +43,7 @@ func New(s string) (*Magnet, error) {
 
 	var magnet Magnet
 
-	magnet.InfoHash, err = infoHashString(xt)
+	magnet.InfoHash, err = ParseInfoHash(xt)
 	if err != nil {
 		return nil, err
 	}
@@ -58,9 +58,25 @@ func New(s string) (*Magnet, error) {
 	return &magnet, nil
 }
 
-// infoHashString returns a new info hash value from a string.
+// String returns the magnet link with hex encoded info hash. Name and trackers are included if they are not empty.
+func (m *Magnet) String() string {
+	var sb strings.Builder
+	sb.WriteString("magnet:?xt=urn:btih:")
+	sb.WriteString(hex.EncodeToString(m.InfoHash[:]))
+	if m.Name != "" {
+		sb.WriteString("&dn=")
+		sb.WriteString(url.QueryEscape(m.Name))
+	}
+	for _, tr := range m.Trackers {
+		sb.WriteString("&tr=")
+		sb.WriteString(url.QueryEscape(tr))
+	}
+	return sb.String()
+}
+
+// ParseInfoHash returns a new info hash value from a string.
 // s must be 40 (hex encoded) or 32 (base32 encoded) characters, otherwise it returns error.
-func infoHashString(s string) ([20]byte, error) {
+func ParseInfoHash(s string) ([20]byte, error) {
 	var ih [20]byte
 	var b []byte
 	var err error
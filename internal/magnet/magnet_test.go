@@ -2,6 +2,7 @@ package magnet
 
 import (
 	"encoding/hex"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -25,3 +26,40 @@ func TestParse(t *testing.T) {
 		t.Fatal("invalid tracker")
 	}
 }
+
+func TestParseInfoHash(t *testing.T) {
+	ih, err := ParseInfoHash("F60CC95E3566AF84C1AB223FD4CE80FA88E6438A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ih2, err := ParseInfoHash("6YGMSXRVM2XYJQNLEI75JTUA7KEOMQ4K")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ih != ih2 {
+		t.Fatal("hex and base32 encoded info hashes are not equal")
+	}
+	_, err = ParseInfoHash("magnet:?xt=urn:btih:F60CC95E3566AF84C1AB223FD4CE80FA88E6438A")
+	if err == nil {
+		t.Fatal("magnet link is parsed as info hash")
+	}
+}
+
+func TestString(t *testing.T) {
+	m := &Magnet{
+		Name:     "sample torrent&",
+		Trackers: []string{"udp://tracker.rain:2710", "http://tracker.rain/announce?a=b"},
+	}
+	copy(m.InfoHash[:], []byte("12345678901234567890"))
+	m2, err := New(m.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, m2) {
+		t.Fatalf("magnet link does not parse back: %s", m.String())
+	}
+	m = &Magnet{InfoHash: m.InfoHash}
+	if s := m.String(); s != "magnet:?xt=urn:btih:3132333435363738393031323334353637383930" {
+		t.Fatalf("unexpected magnet link: %s", s)
+	}
+}
@@ -0,0 +1,34 @@
+package metainfo
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// decodeString converts s from charset to UTF-8.
+// Strings that are already valid UTF-8 and strings in unsupported charsets are returned unchanged.
+func decodeString(s, charset string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	var enc encoding.Encoding
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "iso8859-1", "latin1", "latin-1":
+		enc = charmap.ISO8859_1
+	case "shift_jis", "shift-jis", "sjis", "cp932", "windows-31j":
+		enc = japanese.ShiftJIS
+	case "euc-jp", "eucjp":
+		enc = japanese.EUCJP
+	default:
+		return s
+	}
+	d, err := enc.NewDecoder().String(s)
+	if err != nil {
+		return s
+	}
+	return d
+}
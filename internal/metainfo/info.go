@@ -16,9 +16,14 @@ type Info struct {
 	Pieces      []byte     `bencode:"pieces" json:"pieces"`
 	Private     byte       `bencode:"private" json:"private"`
 	Name        string     `bencode:"name" json:"name"`
+	NameUTF8    string     `bencode:"name.utf-8" json:"-"`
 	Length      int64      `bencode:"length" json:"length"` // Single File Mode
 	Files       []FileDict `bencode:"files" json:"files"`   // Multiple File mode
 
+	// Keys of BitTorrent v2 (BEP 52). Only used for detecting v2 and hybrid torrents.
+	MetaVersion int                `bencode:"meta version" json:"-"`
+	FileTree    bencode.RawMessage `bencode:"file tree" json:"-"`
+
 	// Calculated fileds
 	Hash        [20]byte `bencode:"-" json:"-"`
 	PieceHashes [][]byte `bencode:"-" json:"-"`
@@ -30,19 +35,38 @@ type Info struct {
 }
 
 type FileDict struct {
-	Length int64    `bencode:"length" json:"length"`
-	Path   []string `bencode:"path" json:"path"`
+	Length   int64    `bencode:"length" json:"length"`
+	Path     []string `bencode:"path" json:"path"`
+	PathUTF8 []string `bencode:"path.utf-8" json:"-"`
 }
 
+// ErrUnsupportedTorrentVersion is returned from NewInfo if the info dict is for BitTorrent v2 only.
+var ErrUnsupportedTorrentVersion = errors.New("only BitTorrent v1 and hybrid torrents are supported")
+
 // NewInfo returns info from bencoded bytes in b.
 func NewInfo(b []byte) (*Info, error) {
 	var i Info
 	if err := bencode.DecodeBytes(b, &i); err != nil {
 		return nil, err
 	}
+	// Hybrid torrents contain v1 keys next to v2 keys. They are downloaded with the v1 piece layout.
+	// Info hash is the SHA-1 of the whole dict so it matches the v1 swarm of a hybrid torrent.
+	if (i.MetaVersion > 1 || len(i.FileTree) > 0) && len(i.Pieces) == 0 {
+		return nil, ErrUnsupportedTorrentVersion
+	}
 	if uint32(len(i.Pieces))%sha1.Size != 0 {
 		return nil, errors.New("invalid piece data")
 	}
+	// Some clients put the names in legacy encoding to "name" and "path" keys.
+	// Prefer UTF-8 variants if they exist.
+	if i.NameUTF8 != "" {
+		i.Name = i.NameUTF8
+	}
+	for idx := range i.Files {
+		if len(i.Files[idx].PathUTF8) > 0 {
+			i.Files[idx].Path = i.Files[idx].PathUTF8
+		}
+	}
 	// ".." is not allowed in file names
 	for _, file := range i.Files {
 		for _, path := range file.Path {
@@ -79,10 +103,63 @@ func NewInfo(b []byte) (*Info, error) {
 	return &i, nil
 }
 
+// DecodeNames converts names of files that are not valid UTF-8 from charset to UTF-8.
+// Charset is the "encoding" field of the torrent file, which is outside of the info dict.
+// ISO-8859-1, Shift_JIS and EUC-JP are supported. Names in other charsets are left unchanged.
+func (i *Info) DecodeNames(charset string) {
+	i.Name = decodeString(i.Name, charset)
+	for idx := range i.Files {
+		for j, p := range i.Files[idx].Path {
+			i.Files[idx].Path[j] = decodeString(p, charset)
+		}
+	}
+}
+
 // GetFiles returns the files in torrent as a slice, even if there is a single file.
 func (i *Info) GetFiles() []FileDict {
 	if i.MultiFile {
 		return i.Files
 	}
-	return []FileDict{{i.Length, []string{i.Name}}}
+	return []FileDict{{Length: i.Length, Path: []string{i.Name}}}
+}
+
+// fileOffset returns the position of the file at index in the concatenated torrent data.
+func (i *Info) fileOffset(index int) (int64, error) {
+	files := i.GetFiles()
+	if index < 0 || index >= len(files) {
+		return 0, errors.New("invalid file index")
+	}
+	var offset int64
+	for _, f := range files[:index] {
+		offset += f.Length
+	}
+	return offset, nil
+}
+
+// PieceForOffset returns the index of the piece that contains the byte at offset in the file at fileIndex.
+func (i *Info) PieceForOffset(fileIndex int, offset int64) (uint32, error) {
+	fileOffset, err := i.fileOffset(fileIndex)
+	if err != nil {
+		return 0, err
+	}
+	if offset < 0 || offset >= i.GetFiles()[fileIndex].Length {
+		return 0, errors.New("offset is out of file bounds")
+	}
+	return uint32((fileOffset + offset) / int64(i.PieceLength)), nil
+}
+
+// PieceRange returns the indexes of the first and last pieces that contain data of the file at fileIndex.
+// Pieces at both ends may also contain data of the neighbouring files.
+func (i *Info) PieceRange(fileIndex int) (first, last uint32, err error) {
+	fileOffset, err := i.fileOffset(fileIndex)
+	if err != nil {
+		return 0, 0, err
+	}
+	length := i.GetFiles()[fileIndex].Length
+	if length == 0 {
+		return 0, 0, errors.New("file is empty")
+	}
+	first = uint32(fileOffset / int64(i.PieceLength))
+	last = uint32((fileOffset + length - 1) / int64(i.PieceLength))
+	return first, last, nil
 }
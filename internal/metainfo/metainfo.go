@@ -4,6 +4,7 @@ package metainfo
 import (
 	"errors"
 	"io"
+	"math/rand"
 
 	"github.com/zeebo/bencode"
 )
@@ -18,6 +19,9 @@ type MetaInfo struct {
 	Comment      string             `bencode:"comment"`
 	CreatedBy    string             `bencode:"created by"`
 	Encoding     string             `bencode:"encoding"`
+	// Web seed URLs (BEP 19). Can be a single string or a list of strings.
+	RawURLList bencode.RawMessage `bencode:"url-list" json:"-"`
+	URLList    []string           `bencode:"-"`
 }
 
 // New returns a torrent from bencoded stream.
@@ -31,19 +35,86 @@ func New(r io.Reader) (*MetaInfo, error) {
 		return nil, errors.New("no info dict in torrent file")
 	}
 	t.Info, err = NewInfo(t.RawInfo)
-	return &t, err
+	if err != nil {
+		return nil, err
+	}
+	if t.Encoding != "" {
+		t.Info.DecodeNames(t.Encoding)
+	}
+	t.URLList = parseURLList(t.RawURLList)
+	return &t, nil
+}
+
+// NewBytes returns a bencoded torrent file that contains the info dict, trackers and web seed URLs.
+// First tracker in first tier is set as announce key for clients that do not support announce-list.
+func NewBytes(info []byte, trackers [][]string, urlList []string) ([]byte, error) {
+	if len(info) == 0 {
+		return nil, errors.New("no info dict")
+	}
+	mi := struct {
+		Info         bencode.RawMessage `bencode:"info"`
+		Announce     string             `bencode:"announce,omitempty"`
+		AnnounceList [][]string         `bencode:"announce-list,omitempty"`
+		URLList      []string           `bencode:"url-list,omitempty"`
+	}{
+		Info:    info,
+		URLList: urlList,
+	}
+	for _, tier := range trackers {
+		if len(tier) == 0 {
+			continue
+		}
+		if mi.Announce == "" {
+			mi.Announce = tier[0]
+		}
+		mi.AnnounceList = append(mi.AnnounceList, tier)
+	}
+	return bencode.EncodeBytes(mi)
 }
 
-func (m *MetaInfo) GetTrackers() []string {
-	var trackers []string
+// parseURLList decodes the url-list key which may contain a single URL or a list of URLs.
+// Invalid values are ignored because web seeds are optional.
+func parseURLList(b bencode.RawMessage) []string {
+	if len(b) == 0 {
+		return nil
+	}
+	var list []string
+	if b[0] == 'l' {
+		if bencode.DecodeBytes(b, &list) != nil {
+			return nil
+		}
+	} else {
+		var s string
+		if bencode.DecodeBytes(b, &s) != nil {
+			return nil
+		}
+		list = []string{s}
+	}
+	urls := list[:0]
+	for _, u := range list {
+		if u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// GetTrackers returns the tiers of tracker URLs in torrent (BEP 12).
+// If there is no announce-list, a single tier that contains the announce URL is returned.
+// Trackers in each tier are shuffled as the BEP suggests.
+func (m *MetaInfo) GetTrackers() [][]string {
+	var tiers [][]string
 	if len(m.AnnounceList) > 0 {
 		for _, t := range m.AnnounceList {
 			if len(t) > 0 {
-				trackers = append(trackers, t[0])
+				tier := make([]string, len(t))
+				copy(tier, t)
+				rand.Shuffle(len(tier), func(i, j int) { tier[i], tier[j] = tier[j], tier[i] })
+				tiers = append(tiers, tier)
 			}
 		}
-	} else {
-		trackers = []string{m.Announce}
+	} else if m.Announce != "" {
+		tiers = [][]string{{m.Announce}}
 	}
-	return trackers
+	return tiers
 }
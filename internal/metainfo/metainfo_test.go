@@ -1,9 +1,13 @@
 package metainfo
 
 import (
+	"bytes"
 	"encoding/hex"
 	"os"
+	"reflect"
 	"testing"
+
+	"github.com/zeebo/bencode"
 )
 
 func TestTorrent(t *testing.T) {
@@ -29,3 +33,273 @@ func TestTorrent(t *testing.T) {
 		t.Errorf("invalid info hash: %q must be '2d066c94480adcf52bfd1185a75eb4ddc1777673'", tor.Info.Hash)
 	}
 }
+
+// "テスト" encoded in Shift-JIS
+const shiftJISName = "\x83\x65\x83\x58\x83\x67"
+
+func TestInfoUTF8Keys(t *testing.T) {
+	b, err := bencode.EncodeBytes(map[string]interface{}{
+		"piece length": 16384,
+		"pieces":       string(make([]byte, 20)),
+		"name":         shiftJISName,
+		"name.utf-8":   "テスト",
+		"files": []map[string]interface{}{
+			{"length": 10, "path": []string{shiftJISName + ".txt"}, "path.utf-8": []string{"テスト.txt"}},
+			{"length": 10, "path": []string{"a.txt"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := NewInfo(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "テスト" {
+		t.Errorf("invalid name: %q", info.Name)
+	}
+	if info.Files[0].Path[0] != "テスト.txt" {
+		t.Errorf("invalid path: %q", info.Files[0].Path[0])
+	}
+	if info.Files[1].Path[0] != "a.txt" {
+		t.Errorf("invalid path: %q", info.Files[1].Path[0])
+	}
+}
+
+func TestEncodingLatin1(t *testing.T) {
+	b, err := bencode.EncodeBytes(map[string]interface{}{
+		"encoding": "ISO-8859-1",
+		"info": map[string]interface{}{
+			"piece length": 16384,
+			"pieces":       string(make([]byte, 20)),
+			"name":         "caf\xe9",
+			"length":       10,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mi, err := New(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mi.Info.Name != "café" {
+		t.Errorf("invalid name: %q", mi.Info.Name)
+	}
+}
+
+func TestPieceForOffset(t *testing.T) {
+	// Piece length is 10 and files are 15, 0 and 17 bytes long.
+	// Piece 1 contains the end of first file and the beginning of third file.
+	info := &Info{
+		PieceLength: 10,
+		MultiFile:   true,
+		Files: []FileDict{
+			{Length: 15, Path: []string{"a"}},
+			{Length: 0, Path: []string{"b"}},
+			{Length: 17, Path: []string{"c"}},
+		},
+	}
+	cases := []struct {
+		file   int
+		offset int64
+		piece  uint32
+	}{
+		{0, 0, 0},
+		{0, 9, 0},
+		{0, 10, 1},
+		{0, 14, 1},
+		{2, 0, 1},
+		{2, 4, 1},
+		{2, 5, 2},
+		{2, 16, 3},
+	}
+	for _, c := range cases {
+		piece, err := info.PieceForOffset(c.file, c.offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if piece != c.piece {
+			t.Errorf("file %d offset %d: expected piece %d, got %d", c.file, c.offset, c.piece, piece)
+		}
+	}
+	if _, err := info.PieceForOffset(2, 17); err == nil {
+		t.Error("expected error for offset at the end of file")
+	}
+	if _, err := info.PieceForOffset(3, 0); err == nil {
+		t.Error("expected error for invalid file index")
+	}
+	first, last, err := info.PieceRange(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != 1 || last != 3 {
+		t.Errorf("expected piece range [1, 3], got [%d, %d]", first, last)
+	}
+	if _, _, err = info.PieceRange(1); err == nil {
+		t.Error("expected error for empty file")
+	}
+}
+
+func TestURLList(t *testing.T) {
+	info := map[string]interface{}{
+		"piece length": 16384,
+		"pieces":       string(make([]byte, 20)),
+		"name":         "a.txt",
+		"length":       10,
+	}
+	cases := []struct {
+		urlList  interface{}
+		expected []string
+	}{
+		{"http://a/", []string{"http://a/"}},
+		{[]string{"http://a/", "", "http://b/"}, []string{"http://a/", "http://b/"}},
+		{1, nil},
+	}
+	for _, c := range cases {
+		b, err := bencode.EncodeBytes(map[string]interface{}{"info": info, "url-list": c.urlList})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mi, err := New(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(mi.URLList, c.expected) {
+			t.Errorf("unexpected url list: %q", mi.URLList)
+		}
+	}
+}
+
+func TestNewBytes(t *testing.T) {
+	f, err := os.Open("testdata/ubuntu-14.04.1-server-amd64.iso.torrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	orig, err := New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trackers := [][]string{{"http://a/announce", "http://b/announce"}, {"udp://c:1337"}}
+	b, err := NewBytes(orig.RawInfo, trackers, []string{"http://d/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mi, err := New(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mi.Info.Hash != orig.Info.Hash {
+		t.Error("info hash changed")
+	}
+	if mi.Announce != "http://a/announce" {
+		t.Errorf("unexpected announce: %q", mi.Announce)
+	}
+	if !reflect.DeepEqual(mi.AnnounceList, trackers) {
+		t.Errorf("unexpected announce list: %q", mi.AnnounceList)
+	}
+	if !reflect.DeepEqual(mi.URLList, []string{"http://d/"}) {
+		t.Errorf("unexpected url list: %q", mi.URLList)
+	}
+}
+
+func TestGetTrackers(t *testing.T) {
+	mi := &MetaInfo{
+		Announce:     "http://a/announce",
+		AnnounceList: [][]string{{"http://a/announce", "http://b/announce"}, {}, {"udp://c:1337"}},
+	}
+	tiers := mi.GetTrackers()
+	if len(tiers) != 2 {
+		t.Fatalf("unexpected number of tiers: %d", len(tiers))
+	}
+	if len(tiers[0]) != 2 || !reflect.DeepEqual(tiers[1], []string{"udp://c:1337"}) {
+		t.Errorf("tiers are not preserved: %q", tiers)
+	}
+	// Shuffling must not modify the announce list of torrent.
+	if mi.AnnounceList[0][0] != "http://a/announce" {
+		t.Error("announce list is modified")
+	}
+
+	mi = &MetaInfo{Announce: "http://a/announce"}
+	if tiers = mi.GetTrackers(); !reflect.DeepEqual(tiers, [][]string{{"http://a/announce"}}) {
+		t.Errorf("unexpected tiers: %q", tiers)
+	}
+}
+
+func TestInfoV2(t *testing.T) {
+	fileTree := map[string]interface{}{
+		"a.txt": map[string]interface{}{"": map[string]interface{}{"length": 10, "pieces root": string(make([]byte, 32))}},
+	}
+	b, err := bencode.EncodeBytes(map[string]interface{}{
+		"meta version": 2,
+		"piece length": 16384,
+		"name":         "test",
+		"file tree":    fileTree,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewInfo(b)
+	if err != ErrUnsupportedTorrentVersion {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Hybrid torrent is parsed with v1 keys.
+	b, err = bencode.EncodeBytes(map[string]interface{}{
+		"meta version": 2,
+		"piece length": 16384,
+		"name":         "test",
+		"file tree":    fileTree,
+		"pieces":       string(make([]byte, 20)),
+		"files": []map[string]interface{}{
+			{"length": 10, "path": []string{"a.txt"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := NewInfo(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.NumPieces != 1 || info.TotalLength != 10 {
+		t.Errorf("unexpected piece layout: %d pieces, %d bytes", info.NumPieces, info.TotalLength)
+	}
+}
+
+func TestEncodingShiftJIS(t *testing.T) {
+	b, err := bencode.EncodeBytes(map[string]interface{}{
+		"encoding": "Shift_JIS",
+		"info": map[string]interface{}{
+			"piece length": 16384,
+			"pieces":       string(make([]byte, 20)),
+			"name":         shiftJISName,
+			"files": []map[string]interface{}{
+				{"length": 10, "path": []string{shiftJISName + ".txt"}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mi, err := New(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mi.Info.Name != "テスト" {
+		t.Errorf("invalid name: %q", mi.Info.Name)
+	}
+	if mi.Info.Files[0].Path[0] != "テスト.txt" {
+		t.Errorf("invalid path: %q", mi.Info.Files[0].Path[0])
+	}
+	// Info bytes do not contain the encoding. Names must be decoded again when info is loaded from resume data.
+	info, err := NewInfo(mi.RawInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info.DecodeNames(mi.Encoding)
+	if info.Name != "テスト" {
+		t.Errorf("invalid name: %q", info.Name)
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"math"
 	"time"
 
+	"github.com/cenkalti/rain/internal/addrlist"
 	"github.com/cenkalti/rain/internal/peerconn"
 	"github.com/cenkalti/rain/internal/peerconn/peerreader"
 	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/rcrowley/go-metrics"
 )
 
 type Peer struct {
@@ -22,11 +24,44 @@ type Peer struct {
 	// Snubbed means peer is sending pieces too slow.
 	Snubbed bool
 
+	// SnubPenalty is the decayed number of times the peer at the same IP has snubbed us.
+	// Peers with lower penalty are preferred when picking a peer for downloading a piece.
+	SnubPenalty float64
+
 	Downloading bool
 
+	// Source is how the connection to the peer is established.
+	Source addrlist.PeerSource
+
+	// Encrypted means the connection is encrypted with RC4.
+	Encrypted bool
+
+	// Rate counters for piece data exchanged with the peer.
+	DownloadSpeed metrics.EWMA
+	UploadSpeed   metrics.EWMA
+
+	// UploadOnly means peer has told that it is not going to download any pieces.
+	UploadOnly bool
+
 	BytesDownlaodedInChokePeriod int64
 	BytesUploadedInChokePeriod   int64
 
+	// Productive means piece data has been exchanged with the peer.
+	Productive bool
+
+	// Last time piece data is exchanged with the peer. Set to the connect time initially.
+	LastDataAt time.Time
+
+	// Upload bandwidth in bytes/sec given to this peer in last unchoke round.
+	// Zero if the peer is choked or upload rate is not limited.
+	UploadAllocation int64
+
+	// Number of metadata pieces sent to the peer.
+	MetadataPiecesServed int
+
+	// Number of Have messages received for pieces that the peer has announced before.
+	RedundantHaves int
+
 	// Messages received while we don't have info yet are saved here.
 	Messages []interface{}
 
@@ -51,17 +86,21 @@ type PieceMessage struct {
 	Piece peerreader.Piece
 }
 
-func New(p *peerconn.Conn, snubTimeout time.Duration) *Peer {
+func New(p *peerconn.Conn, source addrlist.PeerSource, snubTimeout time.Duration) *Peer {
 	t := time.NewTimer(math.MaxInt64)
 	t.Stop()
 	return &Peer{
-		Conn:        p,
-		AmChoking:   true,
-		PeerChoking: true,
-		snubTimeout: snubTimeout,
-		snubTimer:   t,
-		closeC:      make(chan struct{}),
-		doneC:       make(chan struct{}),
+		Conn:          p,
+		AmChoking:     true,
+		PeerChoking:   true,
+		Source:        source,
+		DownloadSpeed: metrics.NewEWMA1(),
+		UploadSpeed:   metrics.NewEWMA1(),
+		LastDataAt:    time.Now(),
+		snubTimeout:   snubTimeout,
+		snubTimer:     t,
+		closeC:        make(chan struct{}),
+		doneC:         make(chan struct{}),
 	}
 }
 
@@ -120,6 +159,29 @@ func (p *Peer) StartPEX(initialPeers map[*Peer]struct{}) {
 	}
 }
 
+// SendHavesLater sends a Have message for each piece index, waiting for interval before each message.
+// It returns immediately. Sending stops when the peer is closed.
+func (p *Peer) SendHavesLater(indexes []uint32, interval time.Duration) {
+	go func() {
+		for _, i := range indexes {
+			select {
+			case <-time.After(interval):
+				p.SendMessage(peerprotocol.HaveMessage{Index: i})
+			case <-p.closeC:
+				return
+			}
+		}
+	}()
+}
+
+// StopPEX stops sending PEX messages to the peer.
+func (p *Peer) StopPEX() {
+	if p.PEX != nil {
+		p.PEX.close()
+		p.PEX = nil
+	}
+}
+
 func (p *Peer) ResetSnubTimer() {
 	p.snubTimer.Reset(p.snubTimeout)
 }
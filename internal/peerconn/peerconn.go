@@ -6,35 +6,65 @@ import (
 	"time"
 
 	"github.com/cenkalti/rain/internal/bitfield"
+	"github.com/cenkalti/rain/internal/clientid"
 	"github.com/cenkalti/rain/internal/logger"
 	"github.com/cenkalti/rain/internal/peerconn/peerreader"
 	"github.com/cenkalti/rain/internal/peerconn/peerwriter"
 	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/cenkalti/rain/internal/ratelimit"
 )
 
 type Conn struct {
 	conn          net.Conn
 	id            [20]byte
+	client        string
 	FastExtension bool
 	reader        *peerreader.PeerReader
 	writer        *peerwriter.PeerWriter
 	messages      chan interface{}
 	log           logger.Logger
+	sent          *peerprotocol.MessageCounter
+	received      *peerprotocol.MessageCounter
 	closeC        chan struct{}
 	doneC         chan struct{}
 }
 
-func New(conn net.Conn, id [20]byte, extensions *bitfield.Bitfield, l logger.Logger, pieceTimeout time.Duration, readBufferSize int) *Conn {
+// Config contains the settings of a peer connection.
+type Config struct {
+	// Timeout for receiving a piece message after it is requested.
+	PieceTimeout time.Duration
+	// Buffer sizes of the underlying reader and writer.
+	ReadBufferSize  int
+	WriteBufferSize int
+	// Count sent and received protocol messages by type.
+	ProtocolStats bool
+	// Protocol overhead bytes are added to these counters atomically. May be nil.
+	OverheadRead    *int64
+	OverheadWritten *int64
+	// Shared limiters for piece data. Nil means unlimited.
+	DownloadLimiter *ratelimit.Limiter
+	UploadLimiter   *ratelimit.Limiter
+}
+
+func New(conn net.Conn, id [20]byte, extensions *bitfield.Bitfield, l logger.Logger, cfg Config) *Conn {
 	fastExtension := extensions.Test(61)
 	extensionProtocol := extensions.Test(43)
+	var sent, received *peerprotocol.MessageCounter
+	if cfg.ProtocolStats {
+		sent = new(peerprotocol.MessageCounter)
+		received = new(peerprotocol.MessageCounter)
+	}
 	return &Conn{
 		conn:          conn,
 		id:            id,
+		client:        clientid.Parse(id),
 		FastExtension: fastExtension,
-		reader:        peerreader.New(conn, l, pieceTimeout, readBufferSize, fastExtension, extensionProtocol),
-		writer:        peerwriter.New(conn, l),
+		reader:        peerreader.New(conn, l, cfg.PieceTimeout, cfg.ReadBufferSize, fastExtension, extensionProtocol, received, cfg.OverheadRead, cfg.DownloadLimiter),
+		writer:        peerwriter.New(conn, l, cfg.WriteBufferSize, sent, cfg.OverheadWritten, cfg.UploadLimiter),
 		messages:      make(chan interface{}),
 		log:           l,
+		sent:          sent,
+		received:      received,
 		closeC:        make(chan struct{}),
 		doneC:         make(chan struct{}),
 	}
@@ -44,6 +74,11 @@ func (p *Conn) ID() [20]byte {
 	return p.id
 }
 
+// Client returns the client name and version decoded from peer ID.
+func (p *Conn) Client() string {
+	return p.client
+}
+
 func (p *Conn) Addr() *net.TCPAddr {
 	return p.conn.RemoteAddr().(*net.TCPAddr)
 }
@@ -56,6 +91,12 @@ func (p *Conn) String() string {
 	return p.conn.RemoteAddr().String()
 }
 
+// SetUploadRate limits the upload speed of this connection in addition to the shared upload limiter.
+// Zero means unlimited.
+func (p *Conn) SetUploadRate(rate int64) {
+	p.writer.SetUploadRate(rate)
+}
+
 func (p *Conn) Close() {
 	close(p.closeC)
 	<-p.doneC
@@ -69,6 +110,18 @@ func (p *Conn) Logger() logger.Logger {
 	return p.log
 }
 
+// MessagesSent returns the number of messages sent to the peer by type.
+// Returns nil if protocol stats are not enabled.
+func (p *Conn) MessagesSent() map[string]int64 {
+	return p.sent.Counts()
+}
+
+// MessagesReceived returns the number of messages received from the peer by type.
+// Returns nil if protocol stats are not enabled.
+func (p *Conn) MessagesReceived() map[string]int64 {
+	return p.received.Counts()
+}
+
 func (p *Conn) Messages() <-chan interface{} {
 	return p.messages
 }
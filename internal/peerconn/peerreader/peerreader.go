@@ -8,11 +8,13 @@ import (
 	"io/ioutil"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cenkalti/rain/internal/logger"
 	"github.com/cenkalti/rain/internal/peerprotocol"
 	"github.com/cenkalti/rain/internal/piece"
+	"github.com/cenkalti/rain/internal/ratelimit"
 )
 
 const (
@@ -36,11 +38,14 @@ type PeerReader struct {
 	messages          chan interface{}
 	fastExtension     bool
 	extensionProtocol bool
+	counter           *peerprotocol.MessageCounter
+	overhead          *int64
+	limiter           *ratelimit.Limiter
 	stopC             chan struct{}
 	doneC             chan struct{}
 }
 
-func New(conn net.Conn, l logger.Logger, pieceTimeout time.Duration, bufferSize int, fastExtension, extensionProtocol bool) *PeerReader {
+func New(conn net.Conn, l logger.Logger, pieceTimeout time.Duration, bufferSize int, fastExtension, extensionProtocol bool, counter *peerprotocol.MessageCounter, overhead *int64, limiter *ratelimit.Limiter) *PeerReader {
 	return &PeerReader{
 		conn:              conn,
 		buf:               bufio.NewReaderSize(conn, bufferSize),
@@ -49,6 +54,9 @@ func New(conn net.Conn, l logger.Logger, pieceTimeout time.Duration, bufferSize
 		messages:          make(chan interface{}),
 		fastExtension:     fastExtension,
 		extensionProtocol: extensionProtocol,
+		counter:           counter,
+		overhead:          overhead,
+		limiter:           limiter,
 		stopC:             make(chan struct{}),
 		doneC:             make(chan struct{}),
 	}
@@ -103,6 +111,7 @@ func (p *PeerReader) Run() {
 		// p.log.Debugf("Received message of length: %d", length)
 
 		if length == 0 { // keep-alive message
+			p.addOverhead(4)
 			p.log.Debug("Received message of type \"keep alive\"")
 			continue
 		}
@@ -113,6 +122,14 @@ func (p *PeerReader) Run() {
 			return
 		}
 		length--
+		p.counter.Inc(id)
+
+		// Block data in piece messages is payload, rest of the message is protocol overhead.
+		if id == peerprotocol.Piece {
+			p.addOverhead(4 + 1 + 8)
+		} else {
+			p.addOverhead(4 + 1 + int64(length))
+		}
 
 		// p.log.Debugf("Received message of type: %q", id)
 
@@ -188,6 +205,10 @@ func (p *PeerReader) Run() {
 			if err != nil {
 				return
 			}
+			// Block data is left in socket buffers until download limit allows reading it.
+			if !p.limiter.Wait(int(length-8), p.stopC) {
+				return
+			}
 			var m, n int
 			b := PiecePool.Get().([]byte)[:length-8]
 			for {
@@ -277,3 +298,10 @@ func (p *PeerReader) Run() {
 		}
 	}
 }
+
+// addOverhead adds n bytes to the protocol overhead counter if there is one.
+func (p *PeerReader) addOverhead(n int64) {
+	if p.overhead != nil {
+		atomic.AddInt64(p.overhead, n)
+	}
+}
@@ -1,40 +1,53 @@
 package peerwriter
 
 import (
+	"bufio"
 	"bytes"
 	"container/list"
 	"encoding/binary"
 	"io"
 	"net"
+	"sync/atomic"
 	"time"
 
 	"github.com/cenkalti/rain/internal/logger"
 	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/cenkalti/rain/internal/ratelimit"
 )
 
 const keepAlivePeriod = 2 * time.Minute
 
 type PeerWriter struct {
 	conn       net.Conn
+	buf        *bufio.Writer
 	queueC     chan peerprotocol.Message
 	cancelC    chan peerprotocol.CancelMessage
 	writeQueue *list.List
 	writeC     chan peerprotocol.Message
 	messages   chan interface{}
 	log        logger.Logger
+	counter    *peerprotocol.MessageCounter
+	overhead   *int64
+	limiter    *ratelimit.Limiter
+	ownLimiter *ratelimit.Limiter
 	stopC      chan struct{}
 	doneC      chan struct{}
 }
 
-func New(conn net.Conn, l logger.Logger) *PeerWriter {
+func New(conn net.Conn, l logger.Logger, bufferSize int, counter *peerprotocol.MessageCounter, overhead *int64, limiter *ratelimit.Limiter) *PeerWriter {
 	return &PeerWriter{
 		conn:       conn,
+		buf:        bufio.NewWriterSize(conn, bufferSize),
 		queueC:     make(chan peerprotocol.Message),
 		cancelC:    make(chan peerprotocol.CancelMessage),
 		writeQueue: list.New(),
 		writeC:     make(chan peerprotocol.Message),
 		messages:   make(chan interface{}),
 		log:        l,
+		counter:    counter,
+		overhead:   overhead,
+		limiter:    limiter,
+		ownLimiter: ratelimit.New(0),
 		stopC:      make(chan struct{}),
 		doneC:      make(chan struct{}),
 	}
@@ -145,40 +158,36 @@ func (p *PeerWriter) messageWriter() {
 	for {
 		select {
 		case msg := <-p.writeC:
-			// p.log.Debugf("writing message of type: %q", msg.ID())
-			payload, err := msg.MarshalBinary()
-			if err != nil {
-				p.log.Errorf("cannot marshal message [%v]: %s", msg.ID(), err.Error())
-				return
-			}
-			buf := bytes.NewBuffer(make([]byte, 0, 4+1+len(payload)))
-			var header = struct {
-				Length uint32
-				ID     peerprotocol.MessageID
-			}{
-				Length: uint32(1 + len(payload)),
-				ID:     msg.ID(),
-			}
-			_ = binary.Write(buf, binary.BigEndian, &header)
-			buf.Write(payload)
-			n, err := p.conn.Write(buf.Bytes())
-			p.countUploadBytes(msg, n)
-			if _, ok := err.(*net.OpError); ok {
-				p.log.Debugf("cannot write message [%v]: %s", msg.ID(), err.Error())
-				return
+			// Keep writing into the buffer while there are messages ready to be sent.
+			// Buffer is flushed when the write queue is drained.
+			for msg != nil {
+				if pi, ok := msg.(Piece); ok && !p.waitUpload(pi.Length) {
+					return
+				}
+				err = p.writeMessage(msg)
+				if err != nil {
+					p.logWriteError(err, "message ["+msg.ID().String()+"]")
+					return
+				}
+				select {
+				case msg = <-p.writeC:
+				default:
+					msg = nil
+				}
 			}
+			err = p.buf.Flush()
 			if err != nil {
-				p.log.Errorf("cannot write message [%v]: %s", msg.ID(), err.Error())
+				p.logWriteError(err, "message")
 				return
 			}
 		case <-keepAliveTicker.C:
-			_, err := p.conn.Write([]byte{0, 0, 0, 0})
-			if _, ok := err.(*net.OpError); ok {
-				p.log.Debugf("cannot write keepalive message: %s", err.Error())
-				return
+			_, err = p.buf.Write([]byte{0, 0, 0, 0})
+			p.addOverhead(4)
+			if err == nil {
+				err = p.buf.Flush()
 			}
 			if err != nil {
-				p.log.Errorf("cannot write keepalive message: %s", err.Error())
+				p.logWriteError(err, "keepalive message")
 				return
 			}
 		case <-p.stopC:
@@ -187,6 +196,64 @@ func (p *PeerWriter) messageWriter() {
 	}
 }
 
+// SetUploadRate sets the upload limit of this connection only. Zero means unlimited.
+func (p *PeerWriter) SetUploadRate(rate int64) {
+	p.ownLimiter.SetRate(rate)
+}
+
+// waitUpload blocks until n bytes of block data can be sent without exceeding upload limits.
+// Buffered messages are flushed before waiting so they are not delayed by the limit.
+func (p *PeerWriter) waitUpload(n uint32) bool {
+	if (p.limiter.Rate() > 0 || p.ownLimiter.Rate() > 0) && p.buf.Buffered() > 0 {
+		err := p.buf.Flush()
+		if err != nil {
+			p.logWriteError(err, "message")
+			return false
+		}
+	}
+	return p.ownLimiter.Wait(int(n), p.stopC) && p.limiter.Wait(int(n), p.stopC)
+}
+
+func (p *PeerWriter) writeMessage(msg peerprotocol.Message) error {
+	// p.log.Debugf("writing message of type: %q", msg.ID())
+	payload, err := msg.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, 4+1+len(payload)))
+	var header = struct {
+		Length uint32
+		ID     peerprotocol.MessageID
+	}{
+		Length: uint32(1 + len(payload)),
+		ID:     msg.ID(),
+	}
+	_ = binary.Write(buf, binary.BigEndian, &header)
+	buf.Write(payload)
+	n, err := p.buf.Write(buf.Bytes())
+	p.countUploadBytes(msg, n)
+	if pi, ok := msg.(Piece); ok {
+		// Block data in piece messages is payload, rest of the message is protocol overhead.
+		n -= int(pi.Length)
+		if n < 0 {
+			n = 0
+		}
+	}
+	p.addOverhead(int64(n))
+	if err == nil {
+		p.counter.Inc(msg.ID())
+	}
+	return err
+}
+
+func (p *PeerWriter) logWriteError(err error, what string) {
+	if _, ok := err.(*net.OpError); ok {
+		p.log.Debugf("cannot write %s: %s", what, err.Error())
+		return
+	}
+	p.log.Errorf("cannot write %s: %s", what, err.Error())
+}
+
 func (p *PeerWriter) countUploadBytes(msg peerprotocol.Message, n int) {
 	if _, ok := msg.(Piece); ok {
 		uploaded := uint32(n) - 13
@@ -201,3 +268,10 @@ func (p *PeerWriter) countUploadBytes(msg peerprotocol.Message, n int) {
 		}
 	}
 }
+
+// addOverhead adds n bytes to the protocol overhead counter if there is one.
+func (p *PeerWriter) addOverhead(n int64) {
+	if p.overhead != nil {
+		atomic.AddInt64(p.overhead, n)
+	}
+}
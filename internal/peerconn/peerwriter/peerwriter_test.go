@@ -0,0 +1,50 @@
+package peerwriter
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/cenkalti/rain/internal/ratelimit"
+)
+
+func TestWriteBufferSmallerThanMessages(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	var counter peerprotocol.MessageCounter
+	var overhead int64
+	// Messages do not fit into the buffer so they are written in multiple parts.
+	w := New(local, logger.New("test"), 16, &counter, &overhead, ratelimit.New(0))
+	go w.Run()
+	defer w.Stop()
+
+	w.SendMessage(peerprotocol.HaveMessage{Index: 1})
+	w.SendMessage(peerprotocol.HaveMessage{Index: 2})
+	w.SendMessage(peerprotocol.HaveMessage{Index: 3})
+
+	err := remote.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 3*9)
+	_, err = io.ReadFull(remote, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{
+		0, 0, 0, 5, byte(peerprotocol.Have), 0, 0, 0, 1,
+		0, 0, 0, 5, byte(peerprotocol.Have), 0, 0, 0, 2,
+		0, 0, 0, 5, byte(peerprotocol.Have), 0, 0, 0, 3,
+	}
+	if !bytes.Equal(b, expected) {
+		t.Fatalf("unexpected data: %v", b)
+	}
+	if n := counter.Counts()["have"]; n != 3 {
+		t.Fatalf("unexpected count of have messages: %d", n)
+	}
+}
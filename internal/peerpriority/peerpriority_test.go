@@ -22,6 +22,23 @@ func TestPeerPriority(t *testing.T) {
 	))
 }
 
+func TestPeerPriorityIPv6(t *testing.T) {
+	assert.Equal(t, Calculate(
+		newAddr("2001:db8:1::1"),
+		newAddr("2001:db8:2::1"),
+	), Calculate(
+		newAddr("2001:db8:2::1"),
+		newAddr("2001:db8:1::1"),
+	))
+	assert.NotEqual(t, Calculate(
+		newAddr("2001:db8:1::1"),
+		newAddr("2001:db8:1::2"),
+	), Calculate(
+		newAddr("2001:db8:1::1"),
+		newAddr("2001:db8:1::3"),
+	))
+}
+
 func newAddr(ip string) *net.TCPAddr {
 	return &net.TCPAddr{IP: net.ParseIP(ip)}
 }
@@ -0,0 +1,32 @@
+package peerprotocol
+
+import "sync/atomic"
+
+// MessageCounter counts messages by their type. It is safe for concurrent use.
+// Methods of a nil MessageCounter do nothing.
+type MessageCounter struct {
+	counts [256]int64
+}
+
+// Inc increments the counter for message type.
+func (c *MessageCounter) Inc(id MessageID) {
+	if c == nil {
+		return
+	}
+	atomic.AddInt64(&c.counts[id], 1)
+}
+
+// Counts returns a map of message type names to counts. Types with zero count are not included.
+func (c *MessageCounter) Counts() map[string]int64 {
+	if c == nil {
+		return nil
+	}
+	m := make(map[string]int64)
+	for i := range c.counts {
+		n := atomic.LoadInt64(&c.counts[i])
+		if n > 0 {
+			m[MessageID(i).String()] = n
+		}
+	}
+	return m
+}
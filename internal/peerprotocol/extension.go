@@ -13,11 +13,13 @@ const (
 	ExtensionIDHandshake = iota
 	ExtensionIDMetadata
 	ExtensionIDPEX
+	ExtensionIDUploadOnly
 )
 
 const (
-	ExtensionKeyMetadata = "ut_metadata"
-	ExtensionKeyPEX      = "ut_pex"
+	ExtensionKeyMetadata   = "ut_metadata"
+	ExtensionKeyPEX        = "ut_pex"
+	ExtensionKeyUploadOnly = "upload_only"
 )
 
 const (
@@ -36,6 +38,15 @@ func (m ExtensionMessage) ID() MessageID { return Extension }
 func (m ExtensionMessage) MarshalBinary() ([]byte, error) {
 	var buf bytes.Buffer
 	buf.WriteByte(m.ExtendedMessageID)
+	// Payload of upload_only message is a single byte, not a bencoded dictionary.
+	if um, ok := m.Payload.(ExtensionUploadOnlyMessage); ok {
+		var b byte
+		if um.UploadOnly {
+			b = 1
+		}
+		buf.WriteByte(b)
+		return buf.Bytes(), nil
+	}
 	err := bencode.NewEncoder(&buf).Encode(m.Payload)
 	if err != nil {
 		return nil, err
@@ -70,6 +81,11 @@ func (m *ExtensionMessage) UnmarshalBinary(data []byte) error {
 		var extMsg ExtensionPEXMessage
 		err = dec.Decode(&extMsg)
 		m.Payload = extMsg
+	case ExtensionIDUploadOnly:
+		if len(payload) != 1 {
+			return fmt.Errorf("peer sent invalid upload_only message length: %d", len(payload))
+		}
+		m.Payload = ExtensionUploadOnlyMessage{UploadOnly: payload[0] != 0}
 	default:
 		return fmt.Errorf("peer sent invalid extension message id: %d", m.ExtendedMessageID)
 	}
@@ -81,18 +97,26 @@ type ExtensionHandshakeMessage struct {
 	V            string           `bencode:"v"`
 	YourIP       string           `bencode:"yourip,omitempty"`
 	MetadataSize uint32           `bencode:"metadata_size,omitempty"`
+	UploadOnly   uint8            `bencode:"upload_only,omitempty"`
+	// Number of outstanding requests the peer accepts without dropping.
+	Reqq int `bencode:"reqq,omitempty"`
 }
 
-func NewExtensionHandshake(metadataSize uint32, version string, yourip net.IP) ExtensionHandshakeMessage {
-	return ExtensionHandshakeMessage{
+func NewExtensionHandshake(metadataSize uint32, version string, yourip net.IP, uploadOnly bool) ExtensionHandshakeMessage {
+	msg := ExtensionHandshakeMessage{
 		M: map[string]uint8{
-			ExtensionKeyMetadata: ExtensionIDMetadata,
-			ExtensionKeyPEX:      ExtensionIDPEX,
+			ExtensionKeyMetadata:   ExtensionIDMetadata,
+			ExtensionKeyPEX:        ExtensionIDPEX,
+			ExtensionKeyUploadOnly: ExtensionIDUploadOnly,
 		},
 		V:            version,
 		YourIP:       string(truncateIP(yourip)),
 		MetadataSize: metadataSize,
 	}
+	if uploadOnly {
+		msg.UploadOnly = 1
+	}
+	return msg
 }
 
 type ExtensionMetadataMessage struct {
@@ -102,6 +126,11 @@ type ExtensionMetadataMessage struct {
 	Data      []byte `bencode:"-"`
 }
 
+// ExtensionUploadOnlyMessage is sent to tell the peer that we are not going to download any more pieces.
+type ExtensionUploadOnlyMessage struct {
+	UploadOnly bool
+}
+
 type ExtensionPEXMessage struct {
 	Added   string `bencode:"added"`
 	Dropped string `bencode:"dropped"`
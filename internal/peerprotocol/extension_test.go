@@ -0,0 +1,32 @@
+package peerprotocol
+
+import "testing"
+
+func TestExtensionUploadOnlyMessage(t *testing.T) {
+	for _, uploadOnly := range []bool{true, false} {
+		msg := ExtensionMessage{
+			ExtendedMessageID: ExtensionIDUploadOnly,
+			Payload:           ExtensionUploadOnlyMessage{UploadOnly: uploadOnly},
+		}
+		b, err := msg.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Payload is a single byte after the extension message id.
+		if len(b) != 2 {
+			t.Fatalf("unexpected message length: %d", len(b))
+		}
+		var decoded ExtensionMessage
+		err = decoded.UnmarshalBinary(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p, ok := decoded.Payload.(ExtensionUploadOnlyMessage); !ok || p.UploadOnly != uploadOnly {
+			t.Fatalf("unexpected payload: %#v", decoded.Payload)
+		}
+	}
+	var decoded ExtensionMessage
+	if err := decoded.UnmarshalBinary([]byte{ExtensionIDUploadOnly, 1, 0}); err == nil {
+		t.Fatal("invalid message length is accepted")
+	}
+}
@@ -29,13 +29,21 @@ func New() *PEXList {
 	}
 }
 
+// Add the address to the added list. Only IPv4 addresses are exchanged, others are ignored.
 func (l *PEXList) Add(addr *net.TCPAddr) {
+	if addr.IP.To4() == nil {
+		return
+	}
 	p := tracker.NewCompactPeer(addr)
 	l.added[p] = struct{}{}
 	delete(l.dropped, p)
 }
 
+// Drop the address by moving it to the dropped list. Only IPv4 addresses are exchanged, others are ignored.
 func (l *PEXList) Drop(addr *net.TCPAddr) {
+	if addr.IP.To4() == nil {
+		return
+	}
 	peer := tracker.NewCompactPeer(addr)
 	l.dropped[peer] = struct{}{}
 	delete(l.added, peer)
@@ -2,16 +2,31 @@ package piececache
 
 import (
 	"container/heap"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Cache keeps values in memory up to a max size. Least recently used values are evicted when the cache is full.
+// Values also expire after TTL if they are not accessed.
 type Cache struct {
 	size, maxSize int64
 	ttl           time.Duration
 	items         map[string]*item
 	accessList    accessList
 	m             sync.Mutex
+
+	// Accessed atomically.
+	hits, misses int64
+}
+
+// Stats contains the size and counters of a Cache.
+type Stats struct {
+	// Number of bytes in cache.
+	Size int64
+	// Number of Get calls that are served from cache or loaded.
+	Hits, Misses int64
 }
 
 type Loader func() ([]byte, error)
@@ -35,6 +50,30 @@ func (c *Cache) Clear() {
 	c.m.Unlock()
 }
 
+// ClearPrefix removes the values with keys starting with prefix.
+func (c *Cache) ClearPrefix(prefix string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	for key, i := range c.items {
+		// Values that are being loaded are not in access list yet.
+		if i.timer != nil && strings.HasPrefix(key, prefix) {
+			c.removeItem(i)
+		}
+	}
+}
+
+// Stats returns the current size and the hit and miss counts since the cache is created.
+func (c *Cache) Stats() Stats {
+	c.m.Lock()
+	size := c.size
+	c.m.Unlock()
+	return Stats{
+		Size:   size,
+		Hits:   atomic.LoadInt64(&c.hits),
+		Misses: atomic.LoadInt64(&c.misses),
+	}
+}
+
 func (c *Cache) Get(key string, loader Loader) ([]byte, error) {
 	i := c.getItem(key)
 	return c.getValue(i, loader)
@@ -60,10 +99,12 @@ func (c *Cache) getValue(i *item, loader Loader) ([]byte, error) {
 		if i.err != nil {
 			return nil, i.err
 		}
+		atomic.AddInt64(&c.hits, 1)
 		c.updateAccessTime(i)
 		return i.value, nil
 	}
 
+	atomic.AddInt64(&c.misses, 1)
 	i.value, i.err = loader()
 	i.loaded = true
 
@@ -119,6 +160,10 @@ func (c *Cache) makeRoom(i *item) {
 }
 
 func (c *Cache) removeItem(i *item) {
+	// Expire timer may fire after the item is removed by another call.
+	if i.index < 0 {
+		return
+	}
 	i.timer.Stop()
 	delete(c.items, i.key)
 	heap.Remove(&c.accessList, i.index)
@@ -256,3 +256,30 @@ func TestClear(t *testing.T) {
 
 	time.Sleep(ttl + 10*time.Millisecond)
 }
+
+func TestClearPrefix(t *testing.T) {
+	c := New(10, time.Minute)
+	loader := func() ([]byte, error) {
+		return []byte("x"), nil
+	}
+	for _, key := range []string{"a1", "a2", "b1"} {
+		_, err := c.Get(key, loader)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := c.Get("a1", loader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := c.Stats(); s.Hits != 1 || s.Misses != 3 || s.Size != 3 {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+	c.ClearPrefix("a")
+	if len(c.items) != 1 || len(c.accessList) != 1 || c.size != 1 {
+		t.Fatal("items are not removed")
+	}
+	if _, ok := c.items["b1"]; !ok {
+		t.Fatal("item with other prefix is removed")
+	}
+}
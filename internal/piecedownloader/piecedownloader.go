@@ -15,6 +15,8 @@ type PieceDownloader struct {
 	unrequested []uint32
 	requested   map[uint32]struct{}
 	done        map[uint32]struct{}
+	// Peers that the blocks are received from, indexed by block index.
+	senders []*peer.Peer
 }
 
 type pieceReaderResult struct {
@@ -34,6 +36,7 @@ func New(pi *piece.Piece, pe *peer.Peer, buf []byte) *PieceDownloader {
 		unrequested: unrequested,
 		requested:   make(map[uint32]struct{}),
 		done:        make(map[uint32]struct{}),
+		senders:     make([]*peer.Peer, len(pi.Blocks)),
 	}
 }
 
@@ -51,6 +54,18 @@ func (d *PieceDownloader) GotBlock(block *piece.Block, data []byte) {
 	copy(d.Buffer[block.Begin:block.Begin+block.Length], data)
 	delete(d.requested, block.Index)
 	d.done[block.Index] = struct{}{}
+	d.senders[block.Index] = d.Peer
+}
+
+// Received returns true if the block at index i is received.
+func (d *PieceDownloader) Received(i uint32) bool {
+	_, ok := d.done[i]
+	return ok
+}
+
+// DoneBlocks returns the number of blocks received from the peer.
+func (d *PieceDownloader) DoneBlocks() int {
+	return len(d.done)
 }
 
 func (d *PieceDownloader) Rejected(block *piece.Block) {
@@ -58,6 +73,46 @@ func (d *PieceDownloader) Rejected(block *piece.Block) {
 	delete(d.requested, block.Index)
 }
 
+// CancelBlock marks the block as received from another peer and copies its data into the buffer.
+// A cancel message is sent if the block has already been requested from this peer.
+func (d *PieceDownloader) CancelBlock(block *piece.Block, data []byte, from *peer.Peer) {
+	if _, ok := d.done[block.Index]; ok {
+		return
+	}
+	copy(d.Buffer[block.Begin:block.Begin+block.Length], data)
+	d.done[block.Index] = struct{}{}
+	d.senders[block.Index] = from
+	if _, ok := d.requested[block.Index]; ok {
+		delete(d.requested, block.Index)
+		msg := peerprotocol.CancelMessage{RequestMessage: peerprotocol.RequestMessage{Index: d.Piece.Index, Begin: block.Begin, Length: block.Length}}
+		d.Peer.SendMessage(msg)
+		return
+	}
+	for i, idx := range d.unrequested {
+		if idx == block.Index {
+			d.unrequested = append(d.unrequested[:i], d.unrequested[i+1:]...)
+			break
+		}
+	}
+}
+
+// Contributors returns the peers that have sent blocks of the piece.
+func (d *PieceDownloader) Contributors() []*peer.Peer {
+	var peers []*peer.Peer
+	seen := make(map[*peer.Peer]struct{})
+	for _, pe := range d.senders {
+		if pe == nil {
+			continue
+		}
+		if _, ok := seen[pe]; ok {
+			continue
+		}
+		seen[pe] = struct{}{}
+		peers = append(peers, pe)
+	}
+	return peers
+}
+
 func (d *PieceDownloader) CancelPending() {
 	for i := range d.requested {
 		b := d.Piece.Blocks[i]
@@ -8,20 +8,28 @@ import (
 	"github.com/cenkalti/rain/internal/piece"
 )
 
+// DefaultPriority is the priority of pieces unless changed with SetPriority.
+const DefaultPriority = 4
+
 type PiecePicker struct {
 	pieces                           []myPiece
 	sortedPieces                     []*myPiece
 	endgameParallelDownloadsPerPiece int
+	endgame                          bool
 	available                        uint32
 	log                              logger.Logger
 }
 
 type myPiece struct {
 	*piece.Piece
+	// Pieces with higher priority are picked first. Pieces with zero priority are never picked.
+	Priority         uint8
 	HavingPeers      map[*peer.Peer]struct{}
 	AllowedFastPeers map[*peer.Peer]struct{}
 	RequestedPeers   map[*peer.Peer]struct{}
 	SnubbedPeers     map[*peer.Peer]struct{}
+	// Piece is being downloaded from a web seed. It is not picked for peers.
+	WebSeeding bool
 }
 
 func (p *myPiece) RunningDownloads() int {
@@ -33,6 +41,7 @@ func New(pieces []piece.Piece, endgameParallelDownloadsPerPiece int, l logger.Lo
 	for i := range pieces {
 		ps[i] = myPiece{
 			Piece:            &pieces[i],
+			Priority:         DefaultPriority,
 			HavingPeers:      make(map[*peer.Peer]struct{}),
 			AllowedFastPeers: make(map[*peer.Peer]struct{}),
 			RequestedPeers:   make(map[*peer.Peer]struct{}),
@@ -55,6 +64,22 @@ func (p *PiecePicker) Available() uint32 {
 	return p.available
 }
 
+// SetPriority changes the priority of piece at index i.
+func (p *PiecePicker) SetPriority(i uint32, priority uint8) {
+	p.pieces[i].Priority = priority
+}
+
+// SetEndgame enables or disables endgame mode.
+// In endgame mode a piece can be downloaded from all peers that have it at the same time.
+func (p *PiecePicker) SetEndgame(value bool) {
+	p.endgame = value
+}
+
+// Endgame returns true if endgame mode is enabled.
+func (p *PiecePicker) Endgame() bool {
+	return p.endgame
+}
+
 func (p *PiecePicker) RequestedPeers(i uint32) map[*peer.Peer]struct{} {
 	return p.pieces[i].RequestedPeers
 }
@@ -64,6 +89,17 @@ func (p *PiecePicker) DoesHave(pe *peer.Peer, i uint32) bool {
 	return ok
 }
 
+// NumHave returns the number of pieces that the peer has.
+func (p *PiecePicker) NumHave(pe *peer.Peer) int {
+	var n int
+	for i := range p.pieces {
+		if _, ok := p.pieces[i].HavingPeers[pe]; ok {
+			n++
+		}
+	}
+	return n
+}
+
 func (p *PiecePicker) HandleHave(pe *peer.Peer, i uint32) {
 	p.pieces[i].HavingPeers[pe] = struct{}{}
 	if len(p.pieces[i].HavingPeers) == 1 {
@@ -95,6 +131,27 @@ func (p *PiecePicker) HandleDisconnect(pe *peer.Peer) {
 	}
 }
 
+// PickWebSeed returns a piece that none of the peers have and marks it as being downloaded from a web seed.
+// Returns nil if there is no such piece.
+func (p *PiecePicker) PickWebSeed() *piece.Piece {
+	for _, pi := range p.sortedPieces {
+		if pi.Done || pi.Writing || pi.WebSeeding || pi.Priority == 0 {
+			continue
+		}
+		if len(pi.HavingPeers) > 0 || len(pi.RequestedPeers) > 0 {
+			continue
+		}
+		pi.WebSeeding = true
+		return pi.Piece
+	}
+	return nil
+}
+
+// HandleWebSeedDone makes the piece at index i available for picking again.
+func (p *PiecePicker) HandleWebSeedDone(i uint32) {
+	p.pieces[i].WebSeeding = false
+}
+
 func (p *PiecePicker) Pick() (*piece.Piece, *peer.Peer) {
 	pi, pe := p.findPieceAndPeer()
 	if pi == nil || pe == nil {
@@ -110,7 +167,14 @@ func (p *PiecePicker) findPieceAndPeer() (*myPiece, *peer.Peer) {
 	if pe != nil && pi != nil {
 		return pe, pi
 	}
-	sort.Slice(p.sortedPieces, func(i, j int) bool { return len(p.sortedPieces[i].HavingPeers) < len(p.sortedPieces[j].HavingPeers) })
+	// Pieces are sorted by priority, then by rarity.
+	sort.Slice(p.sortedPieces, func(i, j int) bool {
+		a, b := p.sortedPieces[i], p.sortedPieces[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		return len(a.HavingPeers) < len(b.HavingPeers)
+	})
 	pe, pi = p.selectPiece(true)
 	if pe != nil && pi != nil {
 		return pe, pi
@@ -132,25 +196,33 @@ func (p *PiecePicker) selectPiece(noDuplicate bool) (*myPiece, *peer.Peer) {
 		if pi.Done {
 			continue
 		}
-		if pi.Writing {
+		if pi.Priority == 0 {
+			continue
+		}
+		if pi.Writing || pi.WebSeeding {
 			continue
 		}
 		if noDuplicate && len(pi.RequestedPeers) > 0 {
 			continue
-		} else if pi.RunningDownloads() >= p.endgameParallelDownloadsPerPiece {
+		} else if !p.endgame && pi.RunningDownloads() >= p.endgameParallelDownloadsPerPiece {
 			continue
 		}
+		// Prefer the peer with the lowest snub penalty.
+		var selected *peer.Peer
 		for pe := range pi.HavingPeers {
 			if pe.Downloading {
 				continue
 			}
-			if !pe.PeerChoking {
-				return pi, pe
+			if _, ok := pi.AllowedFastPeers[pe]; pe.PeerChoking && ok {
+				continue
 			}
-			if _, ok := pi.AllowedFastPeers[pe]; !ok {
-				return pi, pe
+			if selected == nil || pe.SnubPenalty < selected.SnubPenalty {
+				selected = pe
 			}
 		}
+		if selected != nil {
+			return pi, selected
+		}
 	}
 	return nil, nil
 }
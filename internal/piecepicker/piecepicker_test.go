@@ -27,7 +27,7 @@ func newPiecePicker(numPieces uint32, numPeers int) *piecepicker.PiecePicker {
 	pieces := make([]piece.Piece, numPieces)
 	pp := piecepicker.New(pieces, endgameParallelDownloadsPerPiece, nil)
 	for i := 0; i < numPeers; i++ {
-		pe := peer.New(nil, 0)
+		pe := peer.New(nil, 0, 0)
 		if prob(snubRatio) {
 			pe.Snubbed = true
 		}
@@ -58,3 +58,74 @@ func prob(ratio float64) bool {
 	n := rand.Float64()
 	return n < ratio
 }
+
+func TestPickPriority(t *testing.T) {
+	pieces := make([]piece.Piece, 3)
+	for i := range pieces {
+		pieces[i].Index = uint32(i)
+	}
+	// Do not pick same piece again in endgame mode.
+	pp := piecepicker.New(pieces, 1, nil)
+	pp.SetPriority(0, 1)
+	pp.SetPriority(1, 0)
+	pp.SetPriority(2, 7)
+	var picked []uint32
+	for i := 0; i < 3; i++ {
+		pe := peer.New(nil, 0, 0)
+		pe.PeerChoking = false
+		for j := range pieces {
+			pp.HandleHave(pe, uint32(j))
+		}
+		pi, _ := pp.Pick()
+		if pi == nil {
+			break
+		}
+		picked = append(picked, pi.Index)
+	}
+	if len(picked) != 2 || picked[0] != 2 || picked[1] != 0 {
+		t.Errorf("invalid pick order: %v", picked)
+	}
+}
+
+func TestPickLowestSnubPenalty(t *testing.T) {
+	pieces := make([]piece.Piece, 1)
+	pp := piecepicker.New(pieces, 1, nil)
+	var best *peer.Peer
+	for i := 0; i < 5; i++ {
+		pe := peer.New(nil, 0, 0)
+		pe.PeerChoking = false
+		pe.SnubPenalty = float64(5 - i)
+		pp.HandleHave(pe, 0)
+		best = pe
+	}
+	_, pe := pp.Pick()
+	if pe != best {
+		t.Errorf("peer with snub penalty %v is picked", pe.SnubPenalty)
+	}
+}
+
+func TestEndgame(t *testing.T) {
+	pieces := make([]piece.Piece, 1)
+	pp := piecepicker.New(pieces, 1, nil)
+	for i := 0; i < 3; i++ {
+		pp.HandleHave(peer.New(nil, 0, 0), 0)
+	}
+
+	pi, pe := pp.Pick()
+	if pi == nil || pe == nil {
+		t.Fatal("piece is not picked")
+	}
+	pe.Downloading = true
+	if pi, _ = pp.Pick(); pi != nil {
+		t.Fatal("piece is picked for a second peer outside endgame")
+	}
+
+	pp.SetEndgame(true)
+	pi, pe2 := pp.Pick()
+	if pi == nil || pe2 == nil {
+		t.Fatal("piece is not picked for a second peer in endgame")
+	}
+	if pe2 == pe {
+		t.Fatal("piece is picked for the same peer")
+	}
+}
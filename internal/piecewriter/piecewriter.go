@@ -29,10 +29,22 @@ func (w *PieceWriter) Close() {
 	<-w.doneC
 }
 
-func (w *PieceWriter) Run(resultC chan *PieceWriter) {
+// Run writes the piece to disk and sends itself to resultC.
+// If sem is not nil, a slot is taken from sem during the write to limit the number of parallel writes.
+func (w *PieceWriter) Run(resultC chan *PieceWriter, sem chan struct{}) {
 	defer close(w.doneC)
 
+	if sem != nil {
+		select {
+		case sem <- struct{}{}:
+		case <-w.closeC:
+			return
+		}
+	}
 	_, w.Error = w.Piece.Data.Write(w.Buffer[:w.Lenght])
+	if sem != nil {
+		<-sem
+	}
 	select {
 	case resultC <- w:
 	case <-w.closeC:
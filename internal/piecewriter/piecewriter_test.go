@@ -0,0 +1,77 @@
+package piecewriter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/filesection"
+	"github.com/cenkalti/rain/internal/piece"
+)
+
+type file struct {
+	data []byte
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	return copy(p, f.data[off:]), nil
+}
+
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	return copy(f.data[off:], p), nil
+}
+
+func newPiece(f *file) *piece.Piece {
+	return &piece.Piece{
+		Data: filesection.Piece{{File: f, Offset: 0, Length: int64(len(f.data))}},
+	}
+}
+
+func TestRunWaitsForSemaphore(t *testing.T) {
+	f := &file{data: make([]byte, 4)}
+	w := New(newPiece(f), []byte("data"), 4)
+	resultC := make(chan *PieceWriter)
+	sem := make(chan struct{}, 1)
+	sem <- struct{}{}
+	go w.Run(resultC, sem)
+
+	select {
+	case <-resultC:
+		t.Fatal("piece is written while semaphore is full")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if string(f.data) == "data" {
+		t.Fatal("piece is written while semaphore is full")
+	}
+
+	<-sem
+	select {
+	case res := <-resultC:
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("piece is not written after semaphore is released")
+	}
+	if string(f.data) != "data" {
+		t.Fatalf("unexpected data: %q", f.data)
+	}
+	if len(sem) != 0 {
+		t.Fatal("semaphore slot is not released")
+	}
+}
+
+func TestCloseWhileWaitingForSemaphore(t *testing.T) {
+	f := &file{data: make([]byte, 4)}
+	w := New(newPiece(f), []byte("data"), 4)
+	sem := make(chan struct{}, 1)
+	sem <- struct{}{}
+	go w.Run(make(chan *PieceWriter), sem)
+
+	w.Close()
+	if string(f.data) == "data" {
+		t.Fatal("piece is written after close")
+	}
+	if len(sem) != 1 {
+		t.Fatal("semaphore slot is taken after close")
+	}
+}
@@ -0,0 +1,149 @@
+// Package ratelimit provides a token bucket for limiting bandwidth used by peer connections.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// maxWait is the longest duration Wait sleeps before checking the rate again,
+// so changes made with SetRate take effect quickly on waiting connections.
+const maxWait = time.Second
+
+// Limiter is a token bucket that is shared by peer connections of all torrents.
+// It is safe for concurrent use. Methods of a nil Limiter do nothing.
+type Limiter struct {
+	rate      int64
+	users     int64
+	tokens    float64
+	updatedAt time.Time
+	m         sync.Mutex
+}
+
+// New returns a new Limiter that allows rate bytes per second. Zero means unlimited.
+func New(rate int64) *Limiter {
+	return &Limiter{rate: rate}
+}
+
+// Rate returns the current limit in bytes per second.
+func (l *Limiter) Rate() int64 {
+	if l == nil {
+		return 0
+	}
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.rate
+}
+
+// Join registers a new user sharing the limit. Leave must be called when the user is done.
+func (l *Limiter) Join() {
+	if l == nil {
+		return
+	}
+	l.m.Lock()
+	l.users++
+	l.m.Unlock()
+}
+
+// Leave unregisters a user registered with Join.
+func (l *Limiter) Leave() {
+	if l == nil {
+		return
+	}
+	l.m.Lock()
+	l.users--
+	l.m.Unlock()
+}
+
+// Share returns the rate divided evenly among users registered with Join.
+// Zero means unlimited.
+func (l *Limiter) Share() int64 {
+	if l == nil {
+		return 0
+	}
+	l.m.Lock()
+	defer l.m.Unlock()
+	if l.users <= 1 {
+		return l.rate
+	}
+	return l.rate / l.users
+}
+
+// SetRate changes the limit. Zero means unlimited.
+func (l *Limiter) SetRate(rate int64) {
+	if l == nil {
+		return
+	}
+	l.m.Lock()
+	if l.rate == rate {
+		l.m.Unlock()
+		return
+	}
+	l.rate = rate
+	l.tokens = 0
+	l.updatedAt = time.Now()
+	l.m.Unlock()
+}
+
+// Wait blocks until n bytes can be transferred without exceeding the limit.
+// Returns false if stopC is closed before that.
+func (l *Limiter) Wait(n int, stopC chan struct{}) bool {
+	if l == nil {
+		return true
+	}
+	for {
+		d := l.reserve(n)
+		if d == 0 {
+			return true
+		}
+		if d > maxWait {
+			d = maxWait
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-stopC:
+			timer.Stop()
+			return false
+		}
+	}
+}
+
+// Allow takes n tokens and returns true if they are available without waiting.
+// Otherwise, it returns false and the tokens are not taken.
+func (l *Limiter) Allow(n int) bool {
+	if l == nil {
+		return true
+	}
+	return l.reserve(n) == 0
+}
+
+// reserve takes n tokens from the bucket if there are enough.
+// Otherwise, it returns the duration until enough tokens are collected.
+func (l *Limiter) reserve(n int) time.Duration {
+	l.m.Lock()
+	defer l.m.Unlock()
+	if l.rate <= 0 {
+		return 0
+	}
+	// Allow bursts of one second, but at least n bytes so large blocks can pass under small limits.
+	burst := float64(l.rate)
+	if burst < float64(n) {
+		burst = float64(n)
+	}
+	now := time.Now()
+	if l.updatedAt.IsZero() {
+		l.tokens = burst
+	} else {
+		l.tokens += now.Sub(l.updatedAt).Seconds() * float64(l.rate)
+		if l.tokens > burst {
+			l.tokens = burst
+		}
+	}
+	l.updatedAt = now
+	if l.tokens >= float64(n) {
+		l.tokens -= float64(n)
+		return 0
+	}
+	return time.Duration((float64(n) - l.tokens) / float64(l.rate) * float64(time.Second))
+}
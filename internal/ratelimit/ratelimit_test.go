@@ -0,0 +1,61 @@
+package ratelimit
+
+import "testing"
+
+func TestReserve(t *testing.T) {
+	l := New(1000)
+	if d := l.reserve(1000); d != 0 {
+		t.Fatalf("first second must be allowed as burst, got wait %s", d)
+	}
+	if d := l.reserve(500); d <= 0 {
+		t.Fatal("expected wait after burst is consumed")
+	}
+	l.SetRate(0)
+	if d := l.reserve(1 << 20); d != 0 {
+		t.Fatalf("unlimited limiter must not wait, got %s", d)
+	}
+	var nilLimiter *Limiter
+	if !nilLimiter.Wait(100, nil) {
+		t.Fatal("nil limiter must not block")
+	}
+}
+
+func TestShare(t *testing.T) {
+	l := New(1000)
+	if r := l.Share(); r != 1000 {
+		t.Fatalf("unexpected share without users: %d", r)
+	}
+	l.Join()
+	l.Join()
+	if r := l.Share(); r != 500 {
+		t.Fatalf("unexpected share with 2 users: %d", r)
+	}
+	l.Leave()
+	if r := l.Share(); r != 1000 {
+		t.Fatalf("unexpected share with 1 user: %d", r)
+	}
+	l.SetRate(0)
+	if r := l.Share(); r != 0 {
+		t.Fatalf("unlimited share must be zero, got %d", r)
+	}
+}
+
+func TestAllow(t *testing.T) {
+	l := New(1000)
+	if !l.Allow(600) {
+		t.Fatal("first second must be allowed as burst")
+	}
+	if l.Allow(600) {
+		t.Fatal("must not be allowed after burst is consumed")
+	}
+	if !l.Allow(400) {
+		t.Fatal("remaining tokens must be allowed")
+	}
+	if !New(100).Allow(1000) {
+		t.Fatal("burst must be at least n")
+	}
+	var nilLimiter *Limiter
+	if !nilLimiter.Allow(100) {
+		t.Fatal("nil limiter must allow")
+	}
+}
@@ -2,12 +2,14 @@
 package boltdbresumer
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"strconv"
 	"time"
 
 	"github.com/boltdb/bolt"
+	"github.com/cenkalti/rain/internal/metainfo"
 	"github.com/cenkalti/rain/internal/resumer"
 )
 
@@ -15,15 +17,30 @@ var (
 	infoHashKey        = []byte("info_hash")
 	portKey            = []byte("port")
 	nameKey            = []byte("name")
+	nameSetKey         = []byte("name_set")
 	trackersKey        = []byte("trackers")
 	destKey            = []byte("dest")
 	infoKey            = []byte("info")
+	encodingKey        = []byte("encoding")
 	bitfieldKey        = []byte("bitfield")
 	createdAtKey       = []byte("created_at")
 	bytesDownloadedKey = []byte("bytes_downloaded")
 	bytesUploadedKey   = []byte("bytes_uploaded")
 	bytesWastedKey     = []byte("bytes_wasted")
 	seededForKey       = []byte("seeded_for")
+	lastActivityKey    = []byte("last_activity")
+	filePathsKey       = []byte("file_paths")
+	peersKey           = []byte("peers")
+	uploadDisabledKey  = []byte("upload_disabled")
+	piecePrioritiesKey = []byte("piece_priorities")
+	seedOnlyKey        = []byte("seed_only")
+	filePrioritiesKey  = []byte("file_priorities")
+	storageTypeKey     = []byte("storage_type")
+	webSeedsKey        = []byte("webseeds")
+	seedGoalKey        = []byte("seed_goal")
+	maxPeersKey        = []byte("max_peers")
+	pexDisabledKey     = []byte("pex_disabled")
+	startedKey         = []byte("started")
 )
 
 type Resumer struct {
@@ -48,12 +65,26 @@ func New(db *bolt.DB, mainBucket, subBucket []byte) (*Resumer, error) {
 	}, nil
 }
 
-func (r *Resumer) Write(spec *Spec) error {
+func (r *Resumer) Write(spec *resumer.Spec) error {
 	port := strconv.Itoa(spec.Port)
 	trackers, err := json.Marshal(spec.Trackers)
 	if err != nil {
 		return err
 	}
+	var webSeeds []byte
+	if spec.WebSeeds != nil {
+		webSeeds, err = json.Marshal(spec.WebSeeds)
+		if err != nil {
+			return err
+		}
+	}
+	var filePaths []byte
+	if spec.FilePaths != nil {
+		filePaths, err = json.Marshal(spec.FilePaths)
+		if err != nil {
+			return err
+		}
+	}
 	return r.db.Update(func(tx *bolt.Tx) error {
 		b, err := tx.Bucket(r.mainBucket).CreateBucketIfNotExists(r.subBucket)
 		if err != nil {
@@ -62,14 +93,28 @@ func (r *Resumer) Write(spec *Spec) error {
 		b.Put(infoHashKey, spec.InfoHash)
 		b.Put(portKey, []byte(port))
 		b.Put(nameKey, []byte(spec.Name))
+		b.Put(nameSetKey, []byte(strconv.FormatBool(spec.NameSet)))
 		b.Put(destKey, []byte(spec.Dest))
+		b.Put(storageTypeKey, []byte(spec.StorageType))
 		b.Put(trackersKey, trackers)
 		b.Put(infoKey, spec.Info)
+		if spec.Encoding != "" {
+			b.Put(encodingKey, []byte(spec.Encoding))
+		}
 		b.Put(bitfieldKey, spec.Bitfield)
 		b.Put(createdAtKey, []byte(spec.CreatedAt.Format(time.RFC3339)))
+		b.Put(startedKey, formatStarted(spec.Started))
 		b.Put(bytesDownloadedKey, []byte(strconv.FormatInt(spec.BytesDownloaded, 10)))
 		b.Put(bytesUploadedKey, []byte(strconv.FormatInt(spec.BytesUploaded, 10)))
 		b.Put(bytesWastedKey, []byte(strconv.FormatInt(spec.BytesWasted, 10)))
+		if filePaths != nil {
+			b.Put(filePathsKey, filePaths)
+		}
+		if webSeeds != nil {
+			b.Put(webSeedsKey, webSeeds)
+		}
+		b.Put(uploadDisabledKey, []byte(strconv.FormatBool(spec.UploadDisabled)))
+		b.Put(seedOnlyKey, []byte(strconv.FormatBool(spec.SeedOnly)))
 		return nil
 	})
 }
@@ -88,6 +133,124 @@ func (r *Resumer) WriteBitfield(value []byte) error {
 	})
 }
 
+func (r *Resumer) WriteName(value string, set bool) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(r.mainBucket).Bucket(r.subBucket)
+		err := b.Put(nameKey, []byte(value))
+		if err != nil {
+			return err
+		}
+		return b.Put(nameSetKey, []byte(strconv.FormatBool(set)))
+	})
+}
+
+func (r *Resumer) WriteFilePaths(value []string) error {
+	filePaths, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(r.mainBucket).Bucket(r.subBucket)
+		return b.Put(filePathsKey, filePaths)
+	})
+}
+
+func (r *Resumer) WritePeers(value []resumer.Peer) error {
+	peers, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(r.mainBucket).Bucket(r.subBucket)
+		return b.Put(peersKey, peers)
+	})
+}
+
+// WriteStarted saves whether the torrent is running so it is started again when the session is loaded.
+func (r *Resumer) WriteStarted(value bool) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(r.mainBucket).Bucket(r.subBucket)
+		return b.Put(startedKey, formatStarted(value))
+	})
+}
+
+// formatStarted returns the value of started key. It is saved as "1" or "0" for compatibility with older databases.
+func formatStarted(value bool) []byte {
+	if value {
+		return []byte("1")
+	}
+	return []byte("0")
+}
+
+func (r *Resumer) WriteUploadDisabled(value bool) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(r.mainBucket).Bucket(r.subBucket)
+		return b.Put(uploadDisabledKey, []byte(strconv.FormatBool(value)))
+	})
+}
+
+func (r *Resumer) WriteSeedOnly(value bool) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(r.mainBucket).Bucket(r.subBucket)
+		return b.Put(seedOnlyKey, []byte(strconv.FormatBool(value)))
+	})
+}
+
+func (r *Resumer) WritePEXDisabled(value bool) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(r.mainBucket).Bucket(r.subBucket)
+		return b.Put(pexDisabledKey, []byte(strconv.FormatBool(value)))
+	})
+}
+
+// WritePiecePriorities saves the priorities of pieces in run-length encoded form.
+func (r *Resumer) WritePiecePriorities(value []uint8) error {
+	priorities, err := json.Marshal(encodeRunLength(value))
+	if err != nil {
+		return err
+	}
+	return r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(r.mainBucket).Bucket(r.subBucket)
+		return b.Put(piecePrioritiesKey, priorities)
+	})
+}
+
+// WriteFilePriorities saves the priorities of files. Each byte is the priority of a file.
+func (r *Resumer) WriteFilePriorities(value []uint8) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(r.mainBucket).Bucket(r.subBucket)
+		return b.Put(filePrioritiesKey, value)
+	})
+}
+
+func (r *Resumer) WriteSeedGoal(value resumer.SeedGoal) error {
+	seedGoal, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(r.mainBucket).Bucket(r.subBucket)
+		return b.Put(seedGoalKey, seedGoal)
+	})
+}
+
+func (r *Resumer) WriteMaxPeers(value *resumer.MaxPeers) error {
+	if value == nil {
+		return r.db.Update(func(tx *bolt.Tx) error {
+			b := tx.Bucket(r.mainBucket).Bucket(r.subBucket)
+			return b.Delete(maxPeersKey)
+		})
+	}
+	maxPeers, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(r.mainBucket).Bucket(r.subBucket)
+		return b.Put(maxPeersKey, maxPeers)
+	})
+}
+
 func (r *Resumer) WriteStats(s resumer.Stats) error {
 	return r.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(r.mainBucket).Bucket(r.subBucket)
@@ -95,12 +258,15 @@ func (r *Resumer) WriteStats(s resumer.Stats) error {
 		b.Put(bytesUploadedKey, []byte(strconv.FormatInt(s.BytesUploaded, 10)))
 		b.Put(bytesWastedKey, []byte(strconv.FormatInt(s.BytesWasted, 10)))
 		b.Put(seededForKey, []byte(s.SeededFor.String()))
+		if !s.LastActivity.IsZero() {
+			b.Put(lastActivityKey, []byte(s.LastActivity.Format(time.RFC3339)))
+		}
 		return nil
 	})
 }
 
-func (r *Resumer) Read() (*Spec, error) {
-	var spec *Spec
+func (r *Resumer) Read() (*resumer.Spec, error) {
+	var spec *resumer.Spec
 	err := r.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(r.mainBucket).Bucket(r.subBucket)
 		if b == nil {
@@ -112,7 +278,7 @@ func (r *Resumer) Read() (*Spec, error) {
 			return fmt.Errorf("key not found: %q", string(infoHashKey))
 		}
 
-		spec = new(Spec)
+		spec = new(resumer.Spec)
 		spec.InfoHash = make([]byte, len(value))
 		copy(spec.InfoHash, value)
 
@@ -128,23 +294,44 @@ func (r *Resumer) Read() (*Spec, error) {
 			spec.Name = string(value)
 		}
 
+		value = b.Get(nameSetKey)
+		if value != nil {
+			spec.NameSet, err = strconv.ParseBool(string(value))
+			if err != nil {
+				return err
+			}
+		}
+
 		value = b.Get(trackersKey)
 		if value != nil {
 			err = json.Unmarshal(value, &spec.Trackers)
 			if err != nil {
-				return err
+				// Trackers were saved as a flat list before tiers are supported.
+				var trackers []string
+				if json.Unmarshal(value, &trackers) != nil {
+					return err
+				}
+				for _, tr := range trackers {
+					spec.Trackers = append(spec.Trackers, []string{tr})
+				}
 			}
 		}
 
 		value = b.Get(destKey)
 		spec.Dest = string(value)
 
+		value = b.Get(storageTypeKey)
+		spec.StorageType = string(value)
+
 		value = b.Get(infoKey)
 		if value != nil {
 			spec.Info = make([]byte, len(value))
 			copy(spec.Info, value)
 		}
 
+		value = b.Get(encodingKey)
+		spec.Encoding = string(value)
+
 		value = b.Get(bitfieldKey)
 		if value != nil {
 			spec.Bitfield = make([]byte, len(value))
@@ -159,6 +346,9 @@ func (r *Resumer) Read() (*Spec, error) {
 			}
 		}
 
+		value = b.Get(startedKey)
+		spec.Started = bytes.Equal(value, formatStarted(true))
+
 		value = b.Get(bytesDownloadedKey)
 		if value != nil {
 			spec.BytesDownloaded, err = strconv.ParseInt(string(value), 10, 64)
@@ -191,6 +381,100 @@ func (r *Resumer) Read() (*Spec, error) {
 			}
 		}
 
+		value = b.Get(lastActivityKey)
+		if value != nil {
+			spec.LastActivity, err = time.Parse(time.RFC3339, string(value))
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(filePathsKey)
+		if value != nil {
+			err = json.Unmarshal(value, &spec.FilePaths)
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(uploadDisabledKey)
+		if value != nil {
+			spec.UploadDisabled, err = strconv.ParseBool(string(value))
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(seedOnlyKey)
+		if value != nil {
+			spec.SeedOnly, err = strconv.ParseBool(string(value))
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(pexDisabledKey)
+		if value != nil {
+			spec.PEXDisabled, err = strconv.ParseBool(string(value))
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(peersKey)
+		if value != nil {
+			err = json.Unmarshal(value, &spec.Peers)
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(piecePrioritiesKey)
+		if value != nil && spec.Info != nil {
+			var runs [][2]int
+			err = json.Unmarshal(value, &runs)
+			if err != nil {
+				return err
+			}
+			// Invalid info is reported when the torrent is loaded. Priorities are not used in that case.
+			info, err2 := metainfo.NewInfo(spec.Info)
+			if err2 == nil {
+				spec.PiecePriorities = decodeRunLength(runs, int(info.NumPieces))
+			}
+		}
+
+		value = b.Get(webSeedsKey)
+		if value != nil {
+			err = json.Unmarshal(value, &spec.WebSeeds)
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(filePrioritiesKey)
+		if value != nil {
+			spec.FilePriorities = make([]uint8, len(value))
+			copy(spec.FilePriorities, value)
+		}
+
+		value = b.Get(seedGoalKey)
+		if value != nil {
+			spec.SeedGoal = new(resumer.SeedGoal)
+			err = json.Unmarshal(value, spec.SeedGoal)
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(maxPeersKey)
+		if value != nil {
+			spec.MaxPeers = new(resumer.MaxPeers)
+			err = json.Unmarshal(value, spec.MaxPeers)
+			if err != nil {
+				return err
+			}
+		}
+
 		return nil
 	})
 	return spec, err
@@ -0,0 +1,58 @@
+package boltdbresumer
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/cenkalti/rain/internal/resumer"
+)
+
+// Factory creates Resumers that keep resume data of each torrent in a sub-bucket of the main bucket.
+type Factory struct {
+	db         *bolt.DB
+	mainBucket []byte
+}
+
+var _ resumer.Factory = (*Factory)(nil)
+
+// NewFactory returns a new Factory. The main bucket is created if it does not exist.
+// Closing the database is the responsibility of the caller.
+func NewFactory(db *bolt.DB, mainBucket []byte) (*Factory, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err2 := tx.CreateBucketIfNotExists(mainBucket)
+		return err2
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Factory{
+		db:         db,
+		mainBucket: mainBucket,
+	}, nil
+}
+
+// IDs returns the names of sub-buckets in main bucket.
+func (f *Factory) IDs() ([]string, error) {
+	var ids []string
+	err := f.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(f.mainBucket).ForEach(func(k, _ []byte) error {
+			ids = append(ids, string(k))
+			return nil
+		})
+	})
+	return ids, err
+}
+
+// New returns a Resumer for the torrent with id.
+func (f *Factory) New(id string) (resumer.Resumer, error) {
+	return New(f.db, f.mainBucket, []byte(id))
+}
+
+// Delete removes the sub-bucket of the torrent with id.
+func (f *Factory) Delete(id string) error {
+	return f.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(f.mainBucket).DeleteBucket([]byte(id))
+		if err == bolt.ErrBucketNotFound {
+			return nil
+		}
+		return err
+	})
+}
@@ -0,0 +1,70 @@
+package boltdbresumer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/cenkalti/rain/internal/resumer"
+)
+
+func TestFactory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rain-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := bolt.Open(filepath.Join(dir, "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	f, err := NewFactory(db, []byte("torrents"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := f.New("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = res.Write(&resumer.Spec{InfoHash: []byte("12345678901234567890"), Port: 6881, Started: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := f.IDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []string{"foo"}) {
+		t.Fatalf("unexpected ids: %q", ids)
+	}
+	spec, err := res.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !spec.Started || spec.Port != 6881 {
+		t.Fatalf("unexpected spec: %+v", spec)
+	}
+	err = res.WriteStarted(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec, err = res.Read(); err != nil || spec.Started {
+		t.Fatal("started is not saved")
+	}
+	err = f.Delete("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err = f.IDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 0 {
+		t.Fatalf("unexpected ids after delete: %q", ids)
+	}
+}
@@ -0,0 +1,31 @@
+package boltdbresumer
+
+// encodeRunLength encodes values as a list of (count, value) pairs.
+// Most of the pieces usually have the same priority so this keeps the saved data small.
+func encodeRunLength(values []uint8) [][2]int {
+	var runs [][2]int
+	for _, v := range values {
+		if len(runs) > 0 && runs[len(runs)-1][1] == int(v) {
+			runs[len(runs)-1][0]++
+			continue
+		}
+		runs = append(runs, [2]int{1, int(v)})
+	}
+	return runs
+}
+
+// decodeRunLength decodes a list of (count, value) pairs into at most max values.
+// Counts are bounded so corrupt resume data cannot produce more values than the number of pieces.
+func decodeRunLength(runs [][2]int, max int) []uint8 {
+	var values []uint8
+	for _, r := range runs {
+		n := r[0]
+		if n > max-len(values) {
+			n = max - len(values)
+		}
+		for i := 0; i < n; i++ {
+			values = append(values, uint8(r[1]))
+		}
+	}
+	return values
+}
@@ -0,0 +1,26 @@
+package boltdbresumer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRunLength(t *testing.T) {
+	values := []uint8{1, 1, 1, 0, 2, 2, 1}
+	runs := encodeRunLength(values)
+	expected := [][2]int{{3, 1}, {1, 0}, {2, 2}, {1, 1}}
+	if !reflect.DeepEqual(runs, expected) {
+		t.Fatalf("unexpected runs: %v", runs)
+	}
+	if decoded := decodeRunLength(runs, len(values)); !reflect.DeepEqual(decoded, values) {
+		t.Fatalf("unexpected values: %v", decoded)
+	}
+}
+
+func TestDecodeRunLengthBounded(t *testing.T) {
+	// Corrupt counts must not allocate more values than the number of pieces.
+	runs := [][2]int{{2, 1}, {-5, 0}, {1 << 30, 2}, {1, 0}}
+	if decoded := decodeRunLength(runs, 4); !reflect.DeepEqual(decoded, []uint8{1, 1, 2, 2}) {
+		t.Fatalf("unexpected values: %v", decoded)
+	}
+}
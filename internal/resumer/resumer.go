@@ -5,9 +5,69 @@ import "time"
 
 // Resumer provides operations to save and load resume info for a Torrent.
 type Resumer interface {
+	Read() (*Spec, error)
+	Write(*Spec) error
+	WriteStarted(bool) error
 	WriteInfo([]byte) error
 	WriteBitfield([]byte) error
 	WriteStats(Stats) error
+	// WriteName saves the name of the torrent. set is true if the name is set by the user.
+	WriteName(name string, set bool) error
+	WriteFilePaths([]string) error
+	WritePeers([]Peer) error
+	WriteUploadDisabled(bool) error
+	WritePiecePriorities([]uint8) error
+	WriteFilePriorities([]uint8) error
+	WriteSeedOnly(bool) error
+	WritePEXDisabled(bool) error
+	WriteSeedGoal(SeedGoal) error
+	// WriteMaxPeers saves the connection limits of the torrent. Saved limits are removed if value is nil.
+	WriteMaxPeers(*MaxPeers) error
+}
+
+// Factory creates Resumers for torrents in a Session. Implementations must be safe for concurrent use.
+type Factory interface {
+	// IDs returns the IDs of torrents that have resume data.
+	IDs() ([]string, error)
+	// New returns the Resumer for the torrent with id. Resume data is created when Write is called.
+	New(id string) (Resumer, error)
+	// Delete removes the resume data of the torrent with id.
+	Delete(id string) error
+}
+
+// Spec contains all of the resume data of a torrent.
+type Spec struct {
+	InfoHash    []byte
+	Dest        string
+	StorageType string
+	Port        int
+	Name        string
+	// Name is set by the user and differs from the name in Info.
+	NameSet  bool
+	Trackers [][]string
+	WebSeeds []string
+	Info     []byte
+	// Charset of the names in Info, from the "encoding" field of the torrent file.
+	Encoding        string
+	Bitfield        []byte
+	CreatedAt       time.Time
+	Started         bool
+	BytesDownloaded int64
+	BytesUploaded   int64
+	BytesWasted     int64
+	SeededFor       time.Duration
+	LastActivity    time.Time
+	FilePaths       []string
+	Peers           []Peer
+	UploadDisabled  bool
+	PiecePriorities []uint8
+	FilePriorities  []uint8
+	SeedOnly        bool
+	PEXDisabled     bool
+	// Nil if the torrent uses the seeding limits in config.
+	SeedGoal *SeedGoal
+	// Nil if the torrent uses the connection limits in config.
+	MaxPeers *MaxPeers
 }
 
 type Stats struct {
@@ -15,4 +75,23 @@ type Stats struct {
 	BytesUploaded   int64
 	BytesWasted     int64
 	SeededFor       time.Duration
+	LastActivity    time.Time
+}
+
+// SeedGoal is the per-torrent limit of seeding that overrides the limits in config.
+type SeedGoal struct {
+	Ratio    float64
+	Duration time.Duration
+}
+
+// MaxPeers is the per-torrent limit of connections that overrides the limits in config.
+type MaxPeers struct {
+	Accept int
+	Dial   int
+}
+
+// Peer is the address of a peer that data has been exchanged with.
+type Peer struct {
+	Addr   string
+	SeenAt time.Time
 }
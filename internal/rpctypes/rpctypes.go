@@ -9,15 +9,33 @@ type Torrent struct {
 }
 
 type Peer struct {
-	Addr string
+	Addr             string
+	ID               string
+	Client           string
+	Source           string
+	Encrypted        bool
+	DownloadSpeed    uint
+	UploadSpeed      uint
+	AmChoking        bool
+	AmInterested     bool
+	PeerChoking      bool
+	PeerInterested   bool
+	Pieces           int
+	UploadAllocation int64
+	MessagesSent     map[string]int64
+	MessagesReceived map[string]int64
 }
 
 type Tracker struct {
 	URL      string
+	Tier     int
 	Status   string
 	Leechers int
 	Seeders  int
 	Error    *string
+
+	LastAnnounce Time
+	NextAnnounce Time
 }
 
 type Stats struct {
@@ -38,11 +56,19 @@ type Stats struct {
 		Downloaded int64
 		Uploaded   int64
 		Wasted     int64
+
+		DownloadedOverhead int64
+		UploadedOverhead   int64
 	}
 	Peers struct {
-		Total    int
-		Incoming int
-		Outgoing int
+		Total     int
+		Incoming  int
+		Outgoing  int
+		Reaped    int
+		Rejected  int
+		Blocked   int
+		Encrypted int
+		Plaintext int
 	}
 	Handshakes struct {
 		Total    int
@@ -54,6 +80,7 @@ type Stats struct {
 		Tracker int
 		DHT     int
 		PEX     int
+		LSD     int
 	}
 	Downloads struct {
 		Total   int
@@ -65,6 +92,11 @@ type Stats struct {
 		Total   int
 		Snubbed int
 		Running int
+		Wasted  int64
+	}
+	PEX struct {
+		Enabled    bool
+		Discovered int
 	}
 	Name        string
 	Private     bool
@@ -74,7 +106,17 @@ type Stats struct {
 		Download uint
 		Upload   uint
 	}
-	ETA *uint
+	Progress       float64
+	ETA            *uint
+	StatusProgress float64
+	Warnings       []string
+	LastActivity   Time
+	Scrape         struct {
+		Seeders   int
+		Leechers  int
+		Completed int
+		Time      Time
+	}
 }
 
 type ListTorrentsRequest struct {
@@ -85,6 +127,7 @@ type ListTorrentsResponse struct {
 }
 
 type AddTorrentRequest struct {
+	// Contents of the torrent file encoded with standard base64 encoding.
 	Torrent string
 }
 
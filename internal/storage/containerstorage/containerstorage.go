@@ -0,0 +1,227 @@
+// Package containerstorage implements Storage interface that keeps all files of a torrent in a single sparse file.
+package containerstorage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cenkalti/rain/internal/storage"
+)
+
+const StorageType = "container"
+
+// ContainerStorage places the files of a torrent one after another in a single container file on disk.
+// Files are placed in the order given to SetLayout, which is the order they appear in info dict,
+// so each file is mapped to the same region of the container on every run.
+// Files that are not in the layout are placed after the last file when they are opened.
+type ContainerStorage struct {
+	path string
+
+	file  *os.File
+	found bool // container existed before it is opened
+	refs  int
+	next  int64
+	files map[string]section
+	m     sync.Mutex
+}
+
+type section struct {
+	offset int64
+	size   int64
+}
+
+// New returns a new ContainerStorage that saves data to the file at path.
+func New(path string) (*ContainerStorage, error) {
+	var err error
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	return &ContainerStorage{path: path, files: make(map[string]section)}, nil
+}
+
+var (
+	_ storage.Storage  = (*ContainerStorage)(nil)
+	_ storage.Layouter = (*ContainerStorage)(nil)
+)
+
+// SetLayout places the files one after another in the given order.
+func (s *ContainerStorage) SetLayout(names []string, sizes []int64) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.files = make(map[string]section, len(names))
+	s.next = 0
+	for i, name := range names {
+		s.files[filepath.Clean(name)] = section{offset: s.next, size: sizes[i]}
+		s.next += sizes[i]
+	}
+}
+
+// Dest returns the path of the container file.
+func (s *ContainerStorage) Dest() string {
+	return s.path
+}
+
+func (s *ContainerStorage) Open(name string, size int64) (f storage.File, exists bool, err error) {
+	name = filepath.Clean(name)
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.file == nil {
+		err = s.openContainer()
+		if err != nil {
+			return
+		}
+	}
+	sec := s.section(name, size)
+	fi, err := s.file.Stat()
+	if err != nil {
+		s.releaseContainer()
+		return
+	}
+	// Container is extended without writing so unwritten regions do not use disk space.
+	if end := sec.offset + sec.size; fi.Size() < end {
+		err = s.file.Truncate(end)
+		if err != nil {
+			s.releaseContainer()
+			return
+		}
+	}
+	return &file{storage: s, file: s.file, section: sec}, s.found, nil
+}
+
+func (s *ContainerStorage) openContainer() error {
+	err := os.MkdirAll(filepath.Dir(s.path), os.ModeDir|0750)
+	if err != nil {
+		return err
+	}
+	const mode = 0640
+	of, err := os.OpenFile(s.path, os.O_RDWR, mode) // nolint: gosec
+	if os.IsNotExist(err) {
+		of, err = os.OpenFile(s.path, os.O_RDWR|os.O_CREATE, mode) // nolint: gosec
+		if err != nil {
+			return err
+		}
+		s.file, s.found = of, false
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.file, s.found = of, true
+	return nil
+}
+
+// section returns the region of the container for the file. Unknown files are placed after the last file.
+func (s *ContainerStorage) section(name string, size int64) section {
+	sec, ok := s.files[name]
+	if !ok || sec.size != size {
+		sec = section{offset: s.next, size: size}
+		s.next += size
+		s.files[name] = sec
+	}
+	s.refs++
+	return sec
+}
+
+// releaseContainer closes the container when all files are closed. Layout is kept for opening again.
+func (s *ContainerStorage) releaseContainer() {
+	s.refs--
+	if s.refs > 0 {
+		return
+	}
+	_ = s.file.Close()
+	s.file = nil
+}
+
+// Size returns the size of the file's region that is present in the container.
+func (s *ContainerStorage) Size(name string) (int64, error) {
+	name = filepath.Clean(name)
+	s.m.Lock()
+	defer s.m.Unlock()
+	sec, ok := s.files[name]
+	if !ok {
+		return 0, &os.PathError{Op: "size", Path: name, Err: os.ErrNotExist}
+	}
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return 0, err
+	}
+	size := fi.Size() - sec.offset
+	if size < 0 {
+		size = 0
+	}
+	if size > sec.size {
+		size = sec.size
+	}
+	return size, nil
+}
+
+// Rename changes the names of files. Data in the container is not moved.
+// Renamed files must be given with their new names to SetLayout on next run.
+func (s *ContainerStorage) Rename(oldName, newName string) error {
+	oldName = filepath.Clean(oldName)
+	newName = filepath.Clean(newName)
+	s.m.Lock()
+	defer s.m.Unlock()
+	if _, ok := s.files[newName]; ok {
+		return errors.New("file already exists: " + newName)
+	}
+	renamed := make(map[string]section)
+	for name, sec := range s.files {
+		if name == oldName || strings.HasPrefix(name, oldName+string(filepath.Separator)) {
+			delete(s.files, name)
+			renamed[newName+strings.TrimPrefix(name, oldName)] = sec
+		}
+	}
+	for name, sec := range renamed {
+		s.files[name] = sec
+	}
+	return nil
+}
+
+// file is a region of the container.
+type file struct {
+	storage *ContainerStorage
+	file    *os.File
+	section section
+	closed  bool
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 || off >= f.section.size {
+		return 0, io.EOF
+	}
+	var err error
+	if rem := f.section.size - off; int64(len(p)) > rem {
+		p = p[:rem]
+		err = io.EOF
+	}
+	n, rerr := f.file.ReadAt(p, f.section.offset+off)
+	if rerr != nil {
+		err = rerr
+	}
+	return n, err
+}
+
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 || off+int64(len(p)) > f.section.size {
+		return 0, errors.New("write beyond end of file")
+	}
+	return f.file.WriteAt(p, f.section.offset+off)
+}
+
+func (f *file) Close() error {
+	f.storage.m.Lock()
+	defer f.storage.m.Unlock()
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	f.storage.releaseContainer()
+	return nil
+}
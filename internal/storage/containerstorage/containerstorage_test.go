@@ -0,0 +1,145 @@
+package containerstorage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestContainer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rain-container-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := New(filepath.Join(dir, "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f1, exists, err := s.Open("a", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("container must not exist")
+	}
+	f2, _, err := s.Open("b", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f1.WriteAt([]byte("abc"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f2.WriteAt([]byte("de"), 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f1.WriteAt([]byte("xy"), 2); err == nil {
+		t.Fatal("expected error when writing beyond end of file")
+	}
+	f1.Close()
+	f2.Close()
+
+	b, err := ioutil.ReadFile(s.Dest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "abc\x00\x00de" {
+		t.Fatalf("unexpected container content: %q", b)
+	}
+
+	// Files are placed in the same order after reopening.
+	if size, err := s.Size("a"); err != nil || size != 3 {
+		t.Fatalf("unexpected size: %d, %v", size, err)
+	}
+	f1, exists, err = s.Open("a", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Fatal("container must exist")
+	}
+	defer f1.Close()
+	f2, _, err = s.Open("b", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f2.Close()
+	buf := make([]byte, 4)
+	if _, err = f2.ReadAt(buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "\x00\x00de" {
+		t.Fatalf("unexpected file content: %q", buf)
+	}
+}
+
+func TestContainerLayout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rain-container-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := New(filepath.Join(dir, "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"dir/a", "dir/b", "dir/c"}
+	sizes := []int64{3, 4, 5}
+	s.SetLayout(names, sizes)
+
+	// Files are opened in reverse order but placed in layout order.
+	for i := len(names) - 1; i >= 0; i-- {
+		f, _, err := s.Open(names[i], sizes[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.WriteAt([]byte(names[i][len(names[i])-1:]), 0); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	b, err := ioutil.ReadFile(s.Dest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "a\x00\x00b\x00\x00\x00c\x00\x00\x00\x00" {
+		t.Fatalf("unexpected container content: %q", b)
+	}
+
+	for i, name := range names {
+		size, err := s.Size(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != sizes[i] {
+			t.Errorf("unexpected size of %s: %d", name, size)
+		}
+	}
+	if _, err = s.Size("dir/d"); !os.IsNotExist(err) {
+		t.Fatalf("unexpected error for unknown file: %v", err)
+	}
+
+	// Renamed files keep their regions.
+	err = s.Rename("dir", "renamed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Size("dir/b"); !os.IsNotExist(err) {
+		t.Fatal("old name must not exist after rename")
+	}
+	f, _, err := s.Open("renamed/b", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	buf := make([]byte, 1)
+	if _, err = f.ReadAt(buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "b" {
+		t.Fatalf("unexpected content of renamed file: %q", buf)
+	}
+}
@@ -2,6 +2,7 @@
 package filestorage
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 
@@ -77,3 +78,39 @@ func (s *FileStorage) Open(name string, size int64) (f storage.File, exists bool
 	}
 	return
 }
+
+func (s *FileStorage) Size(name string) (int64, error) {
+	name = filepath.Join(s.dest, filepath.Clean(name))
+	fi, err := os.Stat(name)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+func (s *FileStorage) Rename(oldName, newName string) error {
+	oldName = filepath.Join(s.dest, filepath.Clean(oldName))
+	newName = filepath.Join(s.dest, filepath.Clean(newName))
+
+	_, err := os.Stat(oldName)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = os.Stat(newName)
+	if err == nil {
+		return errors.New("file already exists: " + newName)
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	// Create containing dir if not exists.
+	err = os.MkdirAll(filepath.Dir(newName), os.ModeDir|0750)
+	if err != nil {
+		return err
+	}
+	return os.Rename(oldName, newName)
+}
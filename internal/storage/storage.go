@@ -4,7 +4,20 @@ package storage
 import "io"
 
 type Storage interface {
+	// Dest returns the location of torrent data on disk.
+	Dest() string
 	Open(name string, size int64) (f File, exists bool, err error)
+	// Rename moves the file or directory at oldName to newName.
+	// It is not an error if oldName does not exist.
+	Rename(oldName, newName string) error
+	// Size returns the current size of the file. Error is returned if the file does not exist.
+	Size(name string) (int64, error)
+}
+
+// Layouter is implemented by storages that need to know all files of a torrent before any of them is opened.
+type Layouter interface {
+	// SetLayout is called with the names and sizes of files in the order they appear in info.
+	SetLayout(names []string, sizes []int64)
 }
 
 type File interface {
@@ -12,9 +12,11 @@ type CompactPeer struct {
 	Port uint16
 }
 
+// NewCompactPeer returns the compact representation of an IPv4 address.
+// IPv4 addresses in 16-byte form are converted. The IP is left zero if addr is not an IPv4 address.
 func NewCompactPeer(addr *net.TCPAddr) CompactPeer {
 	p := CompactPeer{Port: uint16(addr.Port)}
-	copy(p.IP[:], addr.IP)
+	copy(p.IP[:], addr.IP.To4())
 	return p
 }
 
@@ -103,4 +103,12 @@ func TestHTTPTracker(t *testing.T) {
 		t.Log(addr.String())
 		t.FailNow()
 	}
+
+	results, err := trk.Scrape(ctx, [][20]byte{{6}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := results[[20]byte{6}]; r.Seeders != 1 || r.Leechers != 1 {
+		t.Fatalf("unexpected scrape result: %#v", r)
+	}
 }
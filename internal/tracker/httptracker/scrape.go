@@ -0,0 +1,100 @@
+package httptracker
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/cenkalti/rain/internal/tracker"
+	"github.com/zeebo/bencode"
+)
+
+type scrapeResponse struct {
+	FailureReason string `bencode:"failure reason"`
+	Files         map[string]struct {
+		Complete   int32 `bencode:"complete"`
+		Incomplete int32 `bencode:"incomplete"`
+		Downloaded int32 `bencode:"downloaded"`
+	} `bencode:"files"`
+}
+
+// scrapeURL returns the scrape URL converted from announce URL as described in BEP 48.
+// It returns nil if the last path element of announce URL does not start with "announce".
+func (t *HTTPTracker) scrapeURL() *url.URL {
+	i := strings.LastIndexByte(t.url.Path, '/')
+	if i < 0 || !strings.HasPrefix(t.url.Path[i+1:], "announce") {
+		return nil
+	}
+	u := *t.url
+	u.Path = u.Path[:i+1] + "scrape" + u.Path[i+1+len("announce"):]
+	u.RawPath = ""
+	return &u
+}
+
+// Scrape the tracker for torrents with given info hashes.
+func (t *HTTPTracker) Scrape(ctx context.Context, infoHashes [][20]byte) (map[[20]byte]tracker.ScrapeResult, error) {
+	u := t.scrapeURL()
+	if u == nil {
+		return nil, tracker.ErrScrapeNotSupported
+	}
+	q := u.Query()
+	for _, ih := range infoHashes {
+		q.Add("info_hash", string(ih[:]))
+	}
+	u.RawQuery = q.Encode()
+	t.log.Debugf("making scrape request to: %q", u.String())
+
+	httpReq := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Host:       u.Host,
+	}
+	httpReq = httpReq.WithContext(ctx)
+	httpReq.Header.Set("User-Agent", t.userAgent)
+
+	resp, err := t.http.Do(httpReq)
+	if uerr, ok := err.(*url.Error); ok && uerr.Err == context.Canceled {
+		return nil, context.Canceled
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("status not 200 OK (status: %d body: %q)", resp.StatusCode, string(body))
+	}
+
+	var response scrapeResponse
+	err = bencode.DecodeBytes(body, &response)
+	if err != nil {
+		return nil, err
+	}
+	if response.FailureReason != "" {
+		return nil, tracker.Error(response.FailureReason)
+	}
+	results := make(map[[20]byte]tracker.ScrapeResult, len(response.Files))
+	for key, f := range response.Files {
+		var ih [20]byte
+		if len(key) != len(ih) {
+			continue
+		}
+		copy(ih[:], key)
+		results[ih] = tracker.ScrapeResult{
+			Seeders:   f.Complete,
+			Leechers:  f.Incomplete,
+			Completed: f.Downloaded,
+		}
+	}
+	return results, nil
+}
@@ -5,11 +5,56 @@ import (
 	"errors"
 	"net"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/cenkalti/rain/internal/blocklist"
 )
 
-func ResolveHost(ctx context.Context, addr string, bl *blocklist.Blocklist) (net.IP, int, error) {
+// DNSError is returned when the hostname of a tracker cannot be resolved
+// and there is no cached address for it.
+type DNSError struct {
+	Host string
+	Err  error
+}
+
+func (e *DNSError) Error() string {
+	return "cannot resolve tracker host " + e.Host + ": " + e.Err.Error()
+}
+
+// Unwrap returns the error returned from the resolver.
+func (e *DNSError) Unwrap() error {
+	return e.Err
+}
+
+// Resolver resolves tracker hostnames and checks the addresses against a blocklist.
+// The last successful lookup of each host is cached so that a temporary DNS failure
+// does not stop announces to a tracker that is otherwise reachable.
+type Resolver struct {
+	blocklist *blocklist.Blocklist
+	cacheTTL  time.Duration
+
+	cache map[string]cachedHost
+	m     sync.Mutex
+}
+
+type cachedHost struct {
+	ips        []net.IP
+	resolvedAt time.Time
+}
+
+// NewResolver returns a new Resolver. Cached addresses are used for cacheTTL after the last successful lookup.
+// Caching is disabled if cacheTTL is zero. Blocklist may be nil.
+func NewResolver(bl *blocklist.Blocklist, cacheTTL time.Duration) *Resolver {
+	return &Resolver{
+		blocklist: bl,
+		cacheTTL:  cacheTTL,
+		cache:     make(map[string]cachedHost),
+	}
+}
+
+// ResolveHost returns the IP and port for a "host:port" address.
+func (r *Resolver) ResolveHost(ctx context.Context, addr string) (net.IP, int, error) {
 	host, portStr, err := net.SplitHostPort(addr)
 	if err != nil {
 		return nil, 0, err
@@ -23,20 +68,57 @@ func ResolveHost(ctx context.Context, addr string, bl *blocklist.Blocklist) (net
 	if ip != nil {
 		ips = append(ips, ip)
 	} else {
-		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		ips, err = r.lookup(ctx, host)
 		if err != nil {
 			return nil, 0, err
 		}
-		for _, ia := range addrs {
-			ips = append(ips, ia.IP)
-		}
 	}
-	if bl != nil {
+	if r.blocklist != nil {
 		for _, ip := range ips {
-			if bl.Blocked(ip) {
+			if r.blocklist.Blocked(ip) {
 				return nil, 0, errors.New("ip is blocked")
 			}
 		}
 	}
 	return ips[0], port, nil
 }
+
+func (r *Resolver) lookup(ctx context.Context, host string) ([]net.IP, error) {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err == nil && len(addrs) == 0 {
+		err = errors.New("no addresses found")
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if ips, ok := r.cached(host); ok {
+			return ips, nil
+		}
+		return nil, &DNSError{Host: host, Err: err}
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, ia := range addrs {
+		ips = append(ips, ia.IP)
+	}
+	if r.cacheTTL > 0 {
+		r.m.Lock()
+		r.cache[host] = cachedHost{ips: ips, resolvedAt: time.Now()}
+		r.m.Unlock()
+	}
+	return ips, nil
+}
+
+func (r *Resolver) cached(host string) ([]net.IP, bool) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	c, ok := r.cache[host]
+	if !ok {
+		return nil, false
+	}
+	if time.Since(c.resolvedAt) > r.cacheTTL {
+		delete(r.cache, host)
+		return nil, false
+	}
+	return c.ips, true
+}
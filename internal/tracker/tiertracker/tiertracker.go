@@ -0,0 +1,108 @@
+// Package tiertracker provides a Tracker implementation that announces to tiers of trackers as described in BEP 12.
+package tiertracker
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cenkalti/rain/internal/tracker"
+)
+
+// TierTracker tries trackers in a tier in order and moves to the next tier only if all trackers in the tier fail.
+// When a tracker responds successfully, it is moved to the front of its tier so it is tried first in next announce.
+type TierTracker struct {
+	tiers   [][]tracker.Tracker
+	current tracker.Tracker
+	errors  map[tracker.Tracker]error
+	m       sync.Mutex
+}
+
+var _ tracker.Tracker = (*TierTracker)(nil)
+
+// New returns a new TierTracker. tiers must contain at least one tracker.
+func New(tiers [][]tracker.Tracker) *TierTracker {
+	t := &TierTracker{
+		errors: make(map[tracker.Tracker]error),
+	}
+	for _, tier := range tiers {
+		if len(tier) == 0 {
+			continue
+		}
+		tier2 := make([]tracker.Tracker, len(tier))
+		copy(tier2, tier)
+		t.tiers = append(t.tiers, tier2)
+	}
+	if len(t.tiers) == 0 {
+		panic("no trackers")
+	}
+	t.current = t.tiers[0][0]
+	return t
+}
+
+// URL returns the URL of the tracker that is used in last announce.
+func (t *TierTracker) URL() string {
+	return t.Current().URL()
+}
+
+// Current returns the tracker that is used in last announce.
+func (t *TierTracker) Current() tracker.Tracker {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return t.current
+}
+
+// Tiers returns the trackers in the order they are going to be tried.
+func (t *TierTracker) Tiers() [][]tracker.Tracker {
+	t.m.Lock()
+	defer t.m.Unlock()
+	tiers := make([][]tracker.Tracker, len(t.tiers))
+	for i, tier := range t.tiers {
+		tiers[i] = make([]tracker.Tracker, len(tier))
+		copy(tiers[i], tier)
+	}
+	return tiers
+}
+
+// LastError returns the error of the last announce made to trk. It returns nil if the announce was successful.
+func (t *TierTracker) LastError(trk tracker.Tracker) error {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return t.errors[trk]
+}
+
+// Scrape the tracker that is used in last announce.
+func (t *TierTracker) Scrape(ctx context.Context, infoHashes [][20]byte) (map[[20]byte]tracker.ScrapeResult, error) {
+	return t.Current().Scrape(ctx, infoHashes)
+}
+
+func (t *TierTracker) Announce(ctx context.Context, req tracker.AnnounceRequest) (*tracker.AnnounceResponse, error) {
+	var lastErr error
+	for i := range t.tiers {
+		for j := range t.tiers[i] {
+			t.m.Lock()
+			trk := t.tiers[i][j]
+			t.current = trk
+			t.m.Unlock()
+
+			resp, err := trk.Announce(ctx, req)
+			if err == context.Canceled {
+				return nil, err
+			}
+
+			t.m.Lock()
+			if err != nil {
+				t.errors[trk] = err
+				t.m.Unlock()
+				lastErr = err
+				continue
+			}
+			delete(t.errors, trk)
+			tier := t.tiers[i]
+			copy(tier[1:j+1], tier[:j])
+			tier[0] = trk
+			t.m.Unlock()
+			return resp, nil
+		}
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,69 @@
+package tiertracker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cenkalti/rain/internal/tracker"
+)
+
+type testTracker struct {
+	url   string
+	fails bool
+	calls int
+}
+
+func (t *testTracker) URL() string { return t.url }
+
+func (t *testTracker) Announce(ctx context.Context, req tracker.AnnounceRequest) (*tracker.AnnounceResponse, error) {
+	t.calls++
+	if t.fails {
+		return nil, errors.New("failed")
+	}
+	return &tracker.AnnounceResponse{}, nil
+}
+
+func (t *testTracker) Scrape(ctx context.Context, infoHashes [][20]byte) (map[[20]byte]tracker.ScrapeResult, error) {
+	return nil, nil
+}
+
+func TestAnnounce(t *testing.T) {
+	a1 := &testTracker{url: "a1", fails: true}
+	a2 := &testTracker{url: "a2"}
+	b1 := &testTracker{url: "b1"}
+	tt := New([][]tracker.Tracker{{a1, a2}, {b1}})
+
+	_, err := tt.Announce(context.Background(), tracker.AnnounceRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tt.URL() != "a2" {
+		t.Fatalf("unexpected tracker: %s", tt.URL())
+	}
+	if b1.calls != 0 {
+		t.Fatal("next tier is tried before trying all trackers in the first tier")
+	}
+	if tt.LastError(a1) == nil {
+		t.Fatal("error is not saved")
+	}
+	// Working tracker must be moved to the front of the tier.
+	if tiers := tt.Tiers(); tiers[0][0] != a2 || tiers[0][1] != a1 {
+		t.Fatal("working tracker is not moved to front")
+	}
+
+	a2.fails = true
+	_, err = tt.Announce(context.Background(), tracker.AnnounceRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tt.URL() != "b1" {
+		t.Fatalf("unexpected tracker: %s", tt.URL())
+	}
+
+	b1.fails = true
+	_, err = tt.Announce(context.Background(), tracker.AnnounceRequest{})
+	if err == nil {
+		t.Fatal("error expected when all trackers fail")
+	}
+}
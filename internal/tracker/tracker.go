@@ -3,6 +3,7 @@ package tracker
 
 import (
 	"context"
+	"errors"
 	"net"
 	"time"
 )
@@ -13,6 +14,10 @@ type Tracker interface {
 	// Announce should also be called on specific events.
 	Announce(ctx context.Context, req AnnounceRequest) (*AnnounceResponse, error)
 
+	// Scrape returns the swarm statistics of torrents with given info hashes.
+	// Torrents unknown to the tracker are not included in the result.
+	Scrape(ctx context.Context, infoHashes [][20]byte) (map[[20]byte]ScrapeResult, error)
+
 	// URL of the tracker.
 	URL() string
 }
@@ -32,6 +37,16 @@ type AnnounceResponse struct {
 	Peers       []*net.TCPAddr
 }
 
+// ScrapeResult contains the number of peers in the swarm of a torrent as reported by the tracker.
+type ScrapeResult struct {
+	Seeders   int32
+	Leechers  int32
+	Completed int32
+}
+
+// ErrScrapeNotSupported is returned from Scrape if the tracker does not have a scrape URL.
+var ErrScrapeNotSupported = errors.New("tracker does not support scrape")
+
 // Error is the string that is sent by the tracker from announce or scrape.
 type Error string
 
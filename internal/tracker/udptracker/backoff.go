@@ -2,6 +2,7 @@ package udptracker
 
 import "time"
 
+// udpBackOff waits 15 * 2 ^ n seconds before n'th retransmission, n is capped at 8 (BEP 15).
 type udpBackOff int
 
 func (b *udpBackOff) NextBackOff() time.Duration {
@@ -9,7 +10,7 @@ func (b *udpBackOff) NextBackOff() time.Duration {
 	if *b > 8 {
 		*b = 8
 	}
-	return time.Duration(15*(2^*b)) * time.Second
+	return time.Duration(15*(1<<uint(*b))) * time.Second
 }
 
 func (b *udpBackOff) Reset() { *b = 0 }
@@ -0,0 +1,16 @@
+package udptracker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackOff(t *testing.T) {
+	var b udpBackOff
+	expected := []int{15, 30, 60, 120, 240, 480, 960, 1920, 3840, 3840}
+	for i, sec := range expected {
+		if d := b.NextBackOff(); d != time.Duration(sec)*time.Second {
+			t.Errorf("retransmission %d: expected %ds, got %s", i, sec, d)
+		}
+	}
+}
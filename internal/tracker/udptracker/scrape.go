@@ -0,0 +1,95 @@
+package udptracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/cenkalti/rain/internal/tracker"
+)
+
+// maxScrapeHashes is the maximum number of info hashes that can be scraped in a single request (BEP 15).
+const maxScrapeHashes = 74
+
+type scrapeRequest struct {
+	udpRequestHeader
+	InfoHashes [][20]byte
+}
+
+func (r *scrapeRequest) WriteTo(w io.Writer) (int64, error) {
+	err := binary.Write(w, binary.BigEndian, r.udpRequestHeader)
+	if err != nil {
+		return 0, err
+	}
+	for _, ih := range r.InfoHashes {
+		_, err = w.Write(ih[:])
+		if err != nil {
+			return 0, err
+		}
+	}
+	return 0, nil
+}
+
+type udpScrapeResponse struct {
+	Seeders   int32
+	Completed int32
+	Leechers  int32
+}
+
+// Scrape the tracker for torrents with given info hashes.
+// If there are more info hashes than fits in a single packet, multiple requests are made.
+func (t *UDPTracker) Scrape(ctx context.Context, infoHashes [][20]byte) (map[[20]byte]tracker.ScrapeResult, error) {
+	results := make(map[[20]byte]tracker.ScrapeResult, len(infoHashes))
+	for len(infoHashes) > 0 {
+		n := len(infoHashes)
+		if n > maxScrapeHashes {
+			n = maxScrapeHashes
+		}
+		err := t.scrape(ctx, infoHashes[:n], results)
+		if err != nil {
+			return nil, err
+		}
+		infoHashes = infoHashes[n:]
+	}
+	return results, nil
+}
+
+func (t *UDPTracker) scrape(ctx context.Context, infoHashes [][20]byte, results map[[20]byte]tracker.ScrapeResult) error {
+	request := &scrapeRequest{InfoHashes: infoHashes}
+	request.SetAction(actionScrape)
+	trx := newTransaction(request, t.dest)
+
+	reply, err := t.transport.Do(ctx, trx)
+	if err != nil {
+		return err
+	}
+
+	r := bytes.NewReader(reply)
+	var header udpMessageHeader
+	err = binary.Read(r, binary.BigEndian, &header)
+	if err != nil {
+		return err
+	}
+	if header.Action != actionScrape {
+		return errors.New("invalid action")
+	}
+	// Tracker replies the statistics in the same order with the info hashes in request.
+	for _, ih := range infoHashes {
+		var response udpScrapeResponse
+		err = binary.Read(r, binary.BigEndian, &response)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		results[ih] = tracker.ScrapeResult{
+			Seeders:   response.Seeders,
+			Leechers:  response.Leechers,
+			Completed: response.Completed,
+		}
+	}
+	return nil
+}
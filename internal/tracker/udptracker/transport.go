@@ -13,7 +13,6 @@ import (
 	"time"
 
 	"github.com/cenkalti/backoff"
-	"github.com/cenkalti/rain/internal/blocklist"
 	"github.com/cenkalti/rain/internal/logger"
 	"github.com/cenkalti/rain/internal/tracker"
 )
@@ -22,9 +21,10 @@ const connectionIDMagic = 0x41727101980
 const connectionIDInterval = time.Minute
 
 type Transport struct {
-	blocklist *blocklist.Blocklist
-	conn      *net.UDPConn
-	log       logger.Logger
+	resolver *tracker.Resolver
+	conn     *net.UDPConn
+	localIP  net.IP
+	log      logger.Logger
 
 	connections  map[string]*connection
 	transactions map[int32]*transaction
@@ -39,9 +39,11 @@ type connection struct {
 	m         sync.Mutex
 }
 
-func NewTransport(bl *blocklist.Blocklist) *Transport {
+// NewTransport returns a new Transport that sends packets from localIP. If localIP is nil, all interfaces are used.
+func NewTransport(r *tracker.Resolver, localIP net.IP) *Transport {
 	return &Transport{
-		blocklist:    bl,
+		resolver:     r,
+		localIP:      localIP,
 		log:          logger.New("udp tracker transport"),
 		connections:  make(map[string]*connection),
 		transactions: make(map[int32]*transaction),
@@ -68,8 +70,12 @@ func (t *Transport) listen() error {
 		return nil
 	}
 
-	var laddr net.UDPAddr
-	conn, err := net.ListenUDP("udp4", &laddr)
+	network := "udp4"
+	if t.localIP != nil && t.localIP.To4() == nil {
+		network = "udp6"
+	}
+	laddr := net.UDPAddr{IP: t.localIP}
+	conn, err := net.ListenUDP(network, &laddr)
 	if err != nil {
 		return err
 	}
@@ -84,22 +90,36 @@ func (t *Transport) Do(ctx context.Context, trx *transaction) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	ip, port, err := tracker.ResolveHost(ctx, trx.dest, t.blocklist)
+	ip, port, err := t.resolver.ResolveHost(ctx, trx.dest)
 	if err != nil {
 		return nil, err
 	}
 	trx.addr = &net.UDPAddr{IP: ip, Port: port}
 
-	conn := t.getConnection(trx.addr.String())
+	connID, err := t.connectionID(ctx, trx.addr)
+	if err != nil {
+		return nil, err
+	}
+	trx.request.SetConnectionID(connID)
+	return t.retryTransaction(ctx, t.writeTrx, trx)
+}
+
+// connectionID returns the connection ID for the tracker at addr.
+// A new ID is requested if the last one is older than a minute (BEP 15).
+// Concurrent announces to the same tracker wait for a single connect request.
+func (t *Transport) connectionID(ctx context.Context, addr net.Addr) (int64, error) {
+	conn := t.getConnection(addr.String())
+	conn.m.Lock()
+	defer conn.m.Unlock()
 	if time.Since(conn.timestamp) > connectionIDInterval {
-		conn.id, err = t.connect(ctx, trx.addr)
+		id, err := t.connect(ctx, addr)
 		if err != nil {
-			return nil, err
+			return 0, err
 		}
+		conn.id = id
 		conn.timestamp = time.Now()
 	}
-	trx.request.SetConnectionID(conn.id)
-	return t.retryTransaction(ctx, t.writeTrx, trx)
+	return conn.id, nil
 }
 
 // Close the tracker connection.
@@ -0,0 +1,29 @@
+package udptracker
+
+import (
+	"net"
+	"testing"
+)
+
+func TestListenLocalIP(t *testing.T) {
+	for _, s := range []string{"127.0.0.1", "::1"} {
+		ip := net.ParseIP(s)
+		if ip.To4() == nil {
+			// Skip if the host has no IPv6 support.
+			conn, err := net.ListenUDP("udp6", &net.UDPAddr{IP: ip})
+			if err != nil {
+				t.Log("skipping IPv6:", err)
+				continue
+			}
+			conn.Close()
+		}
+		tr := NewTransport(nil, ip)
+		if err := tr.listen(); err != nil {
+			t.Fatalf("cannot listen on %s: %s", s, err)
+		}
+		if laddr := tr.conn.LocalAddr().(*net.UDPAddr); !laddr.IP.Equal(ip) {
+			t.Errorf("transport is bound to %s, want %s", laddr.IP, ip)
+		}
+		tr.Close()
+	}
+}
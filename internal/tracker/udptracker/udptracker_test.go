@@ -60,7 +60,7 @@ func TestUDPTracker(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	tr := udptracker.NewTransport(nil)
+	tr := udptracker.NewTransport(tracker.NewResolver(nil, 0), nil)
 	trk := udptracker.New(rawURL, u, tr)
 
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
@@ -98,4 +98,12 @@ func TestUDPTracker(t *testing.T) {
 		t.Log(addr.String())
 		t.FailNow()
 	}
+
+	results, err := trk.Scrape(ctx, [][20]byte{{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := results[[20]byte{}]; r.Seeders != 1 || r.Leechers != 1 {
+		t.Fatalf("unexpected scrape result: %#v", r)
+	}
 }
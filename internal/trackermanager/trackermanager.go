@@ -19,17 +19,23 @@ type TrackerManager struct {
 	udpTransport  *udptracker.Transport
 }
 
-func New(bl *blocklist.Blocklist) *TrackerManager {
+// New returns a new TrackerManager. Resolved tracker addresses are kept for dnsCacheTTL to be used when DNS lookup fails.
+// Connections to trackers are made from localIP. If localIP is nil, the system picks the address.
+func New(bl *blocklist.Blocklist, dnsCacheTTL time.Duration, localIP net.IP) *TrackerManager {
+	resolver := tracker.NewResolver(bl, dnsCacheTTL)
 	m := &TrackerManager{
 		httpTransport: new(http.Transport),
-		udpTransport:  udptracker.NewTransport(bl),
+		udpTransport:  udptracker.NewTransport(resolver, localIP),
 	}
 	m.httpTransport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
-		ip, port, err := tracker.ResolveHost(ctx, addr, bl)
+		ip, port, err := resolver.ResolveHost(ctx, addr)
 		if err != nil {
 			return nil, err
 		}
 		var d net.Dialer
+		if localIP != nil {
+			d.LocalAddr = &net.TCPAddr{IP: localIP}
+		}
 		taddr := &net.TCPAddr{IP: ip, Port: port}
 		return d.DialContext(ctx, network, taddr.String())
 	}
@@ -0,0 +1,35 @@
+package trackermanager
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/tracker"
+)
+
+func TestAnnounceDNSError(t *testing.T) {
+	m := New(nil, 0, nil)
+	// Names under .invalid never resolve.
+	for _, s := range []string{"http://tracker.invalid/announce", "udp://tracker.invalid:6969/announce"} {
+		trk, err := m.Get(s, time.Second, "rain")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		_, err = trk.Announce(ctx, tracker.AnnounceRequest{})
+		cancel()
+		// HTTP client wraps errors returned from the dialer.
+		if uerr, ok := err.(*url.Error); ok {
+			err = uerr.Err
+		}
+		dnsErr, ok := err.(*tracker.DNSError)
+		if !ok {
+			t.Fatalf("%s: unexpected error: %#v", s, err)
+		}
+		if dnsErr.Host != "tracker.invalid" {
+			t.Fatalf("%s: unexpected host: %q", s, dnsErr.Host)
+		}
+	}
+}
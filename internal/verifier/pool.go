@@ -0,0 +1,85 @@
+package verifier
+
+import (
+	"crypto/sha1" // nolint: gosec
+	"hash"
+	"sync"
+
+	"github.com/cenkalti/rain/internal/piece"
+)
+
+// Pool is a fixed number of goroutines that calculate piece hashes.
+// A single pool is shared by verifiers of all torrents so hashing does not use more CPUs than allowed.
+type Pool struct {
+	jobs   chan job
+	closeC chan struct{}
+	wg     sync.WaitGroup
+}
+
+type job struct {
+	piece   *piece.Piece
+	buf     []byte
+	resultC chan result
+}
+
+type result struct {
+	piece *piece.Piece
+	buf   []byte
+	ok    bool
+}
+
+// NewPool starts a new Pool with n workers.
+func NewPool(n int) *Pool {
+	p := &Pool{
+		jobs:   make(chan job),
+		closeC: make(chan struct{}),
+	}
+	p.wg.Add(n)
+	for i := 0; i < n; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+// Close stops the workers. Verifiers using the pool must be closed before.
+func (p *Pool) Close() {
+	close(p.closeC)
+	p.wg.Wait()
+}
+
+func (p *Pool) worker() {
+	defer p.wg.Done()
+	h := sha1.New() // nolint: gosec
+	for {
+		select {
+		case j := <-p.jobs:
+			j.resultC <- j.run(h)
+		case <-p.closeC:
+			return
+		}
+	}
+}
+
+// submit sends the job to a free worker. Result is sent to job's result channel.
+// If the pool is nil, hash is calculated in the calling goroutine.
+// Returns false if cancelC is closed before the job is accepted.
+func (p *Pool) submit(j job, cancelC chan struct{}) bool {
+	if p == nil {
+		j.resultC <- j.run(sha1.New()) // nolint: gosec
+		return true
+	}
+	select {
+	case p.jobs <- j:
+		return true
+	case <-cancelC:
+		return false
+	case <-p.closeC:
+		return false
+	}
+}
+
+func (j job) run(h hash.Hash) result {
+	ok := j.piece.VerifyHash(j.buf, h)
+	h.Reset()
+	return result{piece: j.piece, buf: j.buf, ok: ok}
+}
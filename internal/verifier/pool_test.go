@@ -0,0 +1,53 @@
+package verifier
+
+import (
+	"crypto/sha1" // nolint: gosec
+	"testing"
+
+	"github.com/cenkalti/rain/internal/piece"
+)
+
+func newTestPiece(data []byte) *piece.Piece {
+	sum := sha1.Sum(data) // nolint: gosec
+	return &piece.Piece{Length: uint32(len(data)), Hash: sum[:]}
+}
+
+func TestPool(t *testing.T) {
+	p := NewPool(2)
+	data := []byte("piece data")
+	pi := newTestPiece(data)
+	resultC := make(chan result, 2)
+	if !p.submit(job{piece: pi, buf: data, resultC: resultC}, nil) {
+		t.Fatal("job is not accepted")
+	}
+	if !p.submit(job{piece: pi, buf: []byte("corrupt!!!"), resultC: resultC}, nil) {
+		t.Fatal("job is not accepted")
+	}
+	var valid, invalid int
+	for i := 0; i < 2; i++ {
+		if r := <-resultC; r.ok {
+			valid++
+		} else {
+			invalid++
+		}
+	}
+	if valid != 1 || invalid != 1 {
+		t.Fatalf("unexpected results: %d valid, %d invalid", valid, invalid)
+	}
+	p.Close()
+	if p.submit(job{piece: pi, buf: data, resultC: resultC}, nil) {
+		t.Fatal("closed pool must not accept jobs")
+	}
+}
+
+func TestNilPool(t *testing.T) {
+	var p *Pool
+	data := []byte("piece data")
+	resultC := make(chan result, 1)
+	if !p.submit(job{piece: newTestPiece(data), buf: data, resultC: resultC}, nil) {
+		t.Fatal("job is not accepted")
+	}
+	if r := <-resultC; !r.ok {
+		t.Fatal("hash must match")
+	}
+}
@@ -0,0 +1,75 @@
+package verifier
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Queue limits the number of verifiers running at the same time.
+// Torrents waiting for verification are started in the order they entered the queue.
+type Queue struct {
+	limit   int
+	running int
+	waiting list.List
+	m       sync.Mutex
+}
+
+// Ticket is a place in Queue. Verifier may be started after ReadyC is closed.
+// Ticket must be released when the verifier is done or the torrent is stopped.
+type Ticket struct {
+	ReadyC chan struct{}
+
+	queue    *Queue
+	elem     *list.Element
+	released bool
+}
+
+// NewQueue returns a new Queue that allows at most limit verifiers to run at the same time.
+func NewQueue(limit int) *Queue {
+	return &Queue{limit: limit}
+}
+
+// Enter returns a new Ticket. If the queue is nil, the ticket is ready immediately.
+func (q *Queue) Enter() *Ticket {
+	t := &Ticket{ReadyC: make(chan struct{}), queue: q}
+	if q == nil {
+		close(t.ReadyC)
+		return t
+	}
+	q.m.Lock()
+	defer q.m.Unlock()
+	if q.running < q.limit {
+		q.running++
+		close(t.ReadyC)
+		return t
+	}
+	t.elem = q.waiting.PushBack(t)
+	return t
+}
+
+// Release removes the ticket from the queue if it is still waiting.
+// Otherwise, the slot is passed to the next waiting ticket.
+func (t *Ticket) Release() {
+	q := t.queue
+	if q == nil {
+		return
+	}
+	q.m.Lock()
+	defer q.m.Unlock()
+	if t.released {
+		return
+	}
+	t.released = true
+	if t.elem != nil {
+		q.waiting.Remove(t.elem)
+		t.elem = nil
+		return
+	}
+	if e := q.waiting.Front(); e != nil {
+		next := q.waiting.Remove(e).(*Ticket)
+		next.elem = nil
+		close(next.ReadyC)
+		return
+	}
+	q.running--
+}
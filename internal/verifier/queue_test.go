@@ -0,0 +1,38 @@
+package verifier
+
+import "testing"
+
+func isReady(t *Ticket) bool {
+	select {
+	case <-t.ReadyC:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestQueue(t *testing.T) {
+	q := NewQueue(1)
+	t1 := q.Enter()
+	t2 := q.Enter()
+	t3 := q.Enter()
+	t4 := q.Enter()
+	if !isReady(t1) || isReady(t2) || isReady(t3) || isReady(t4) {
+		t.Fatal("only first ticket must be ready")
+	}
+	// Cancelled ticket must not take the slot.
+	t2.Release()
+	t1.Release()
+	if !isReady(t3) || isReady(t4) {
+		t.Fatal("third ticket must be ready")
+	}
+	t3.Release()
+	t3.Release()
+	if !isReady(t4) {
+		t.Fatal("fourth ticket must be ready")
+	}
+	t4.Release()
+	if q.running != 0 || q.waiting.Len() != 0 {
+		t.Fatalf("queue is not empty: running=%d waiting=%d", q.running, q.waiting.Len())
+	}
+}
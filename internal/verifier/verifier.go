@@ -1,12 +1,13 @@
 package verifier
 
 import (
-	"crypto/sha1" // nolint: gosec
-
 	"github.com/cenkalti/rain/internal/bitfield"
 	"github.com/cenkalti/rain/internal/piece"
 )
 
+// Max number of pieces read from disk but not hashed yet.
+const maxPendingPieces = 4
+
 type Verifier struct {
 	Bitfield *bitfield.Bitfield
 	Error    error
@@ -31,7 +32,8 @@ func (v *Verifier) Close() {
 	<-v.doneC
 }
 
-func (v *Verifier) Run(pieces []piece.Piece, progressC chan Progress, resultC chan *Verifier) {
+// Run reads pieces from disk and hashes them in pool. Pool may be nil.
+func (v *Verifier) Run(pieces []piece.Piece, pool *Pool, progressC chan Progress, resultC chan *Verifier) {
 	defer close(v.doneC)
 
 	defer func() {
@@ -42,25 +44,45 @@ func (v *Verifier) Run(pieces []piece.Piece, progressC chan Progress, resultC ch
 	}()
 
 	v.Bitfield = bitfield.New(uint32(len(pieces)))
-	buf := make([]byte, pieces[0].Length)
-	hash := sha1.New() // nolint: gosec
-	var numOK uint32
-	for _, p := range pieces {
-		buf = buf[:p.Length]
-		_, v.Error = p.Data.ReadAt(buf, 0)
-		if v.Error != nil {
-			return
-		}
-		ok := p.VerifyHash(buf, hash)
-		if ok {
-			v.Bitfield.Set(p.Index)
-			numOK++
+
+	// Pieces are read one by one but hashed in parallel.
+	// Results channel has enough capacity so workers never block on it even after verifier is closed.
+	results := make(chan result, maxPendingPieces)
+	bufs := make([][]byte, maxPendingPieces)
+	for i := range bufs {
+		bufs[i] = make([]byte, pieces[0].Length)
+	}
+	var next int
+	var numChecked uint32
+	for numChecked < uint32(len(pieces)) {
+		if next < len(pieces) && len(bufs) > 0 {
+			p := &pieces[next]
+			buf := bufs[len(bufs)-1][:p.Length]
+			bufs = bufs[:len(bufs)-1]
+			_, v.Error = p.Data.ReadAt(buf, 0)
+			if v.Error != nil {
+				return
+			}
+			if !pool.submit(job{piece: p, buf: buf, resultC: results}, v.closeC) {
+				return
+			}
+			next++
+			continue
 		}
 		select {
-		case progressC <- Progress{Checked: p.Index + 1}:
+		case r := <-results:
+			if r.ok {
+				v.Bitfield.Set(r.piece.Index)
+			}
+			bufs = append(bufs, r.buf)
+			numChecked++
+			select {
+			case progressC <- Progress{Checked: numChecked}:
+			case <-v.closeC:
+				return
+			}
 		case <-v.closeC:
 			return
 		}
-		hash.Reset()
 	}
 }
@@ -0,0 +1,155 @@
+// Package webseed implements downloading pieces from HTTP servers as described in BEP 19.
+package webseed
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/piece"
+)
+
+// StatusError is returned when the server responds with an unexpected status code,
+// for example 404 if the file is not found or 416 if the requested range is not satisfiable.
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status from web seed: %d", e.Code)
+}
+
+// Downloader downloads a single piece from a web seed.
+type Downloader struct {
+	URL    string
+	Piece  *piece.Piece
+	Buffer []byte
+	Error  error
+
+	closeC chan struct{}
+	doneC  chan struct{}
+}
+
+// New returns a new Downloader that downloads the piece into buf.
+func New(u string, pi *piece.Piece, buf []byte) *Downloader {
+	return &Downloader{
+		URL:    u,
+		Piece:  pi,
+		Buffer: buf,
+		closeC: make(chan struct{}),
+		doneC:  make(chan struct{}),
+	}
+}
+
+// Close cancels the download and waits for Run to return.
+func (d *Downloader) Close() {
+	close(d.closeC)
+	<-d.doneC
+}
+
+// Run downloads the data of the piece from files on the server and sends the Downloader to resultC when done.
+// Piece hash is not checked.
+func (d *Downloader) Run(client *http.Client, info *metainfo.Info, timeout time.Duration, resultC chan *Downloader) {
+	defer close(d.doneC)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-d.closeC:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	d.Error = d.download(ctx, client, info)
+	select {
+	case resultC <- d:
+	case <-d.closeC:
+	}
+}
+
+func (d *Downloader) download(ctx context.Context, client *http.Client, info *metainfo.Info) error {
+	begin := int64(d.Piece.Index) * int64(info.PieceLength)
+	end := begin + int64(d.Piece.Length)
+	var pos int64
+	for i, f := range info.GetFiles() {
+		fileBegin, fileEnd := pos, pos+f.Length
+		pos = fileEnd
+		if fileEnd <= begin {
+			continue
+		}
+		if fileBegin >= end {
+			break
+		}
+		b, e := max64(begin, fileBegin), min64(end, fileEnd)
+		err := d.downloadRange(ctx, client, fileURL(d.URL, info, i), b-fileBegin, d.Buffer[b-begin:e-begin])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// downloadRange reads len(buf) bytes starting at offset in the file at u.
+func (d *Downloader) downloadRange(ctx context.Context, client *http.Client, u string, offset int64, buf []byte) error {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+int64(len(buf))-1))
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+	case resp.StatusCode == http.StatusOK && offset == 0:
+		// Server does not support ranges. The beginning of the file is still usable.
+	default:
+		return &StatusError{Code: resp.StatusCode}
+	}
+	_, err = io.ReadFull(resp.Body, buf)
+	return err
+}
+
+// fileURL returns the URL of the file at index.
+// For single file torrents, the URL is used as is unless it ends with a slash.
+// Otherwise the name of the torrent and the path of the file is appended to the URL.
+func fileURL(base string, info *metainfo.Info, index int) string {
+	if !info.MultiFile {
+		if strings.HasSuffix(base, "/") {
+			return base + url.PathEscape(info.Name)
+		}
+		return base
+	}
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	parts := []string{url.PathEscape(info.Name)}
+	for _, p := range info.Files[index].Path {
+		parts = append(parts, url.PathEscape(p))
+	}
+	return base + strings.Join(parts, "/")
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
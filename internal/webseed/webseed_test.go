@@ -0,0 +1,76 @@
+package webseed
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/piece"
+	"github.com/zeebo/bencode"
+)
+
+func TestDownloadPieceSpanningFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rain-webseed-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = os.MkdirAll(filepath.Join(dir, "torrent"), 0750)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "torrent", "a"), []byte("abc"), 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "torrent", "b"), []byte("defgh"), 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer srv.Close()
+
+	b, err := bencode.EncodeBytes(map[string]interface{}{
+		"piece length": 4,
+		"pieces":       string(make([]byte, 40)),
+		"name":         "torrent",
+		"files": []map[string]interface{}{
+			{"length": 3, "path": []string{"a"}},
+			{"length": 5, "path": []string{"b"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := metainfo.NewInfo(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"abcd", "efgh"}
+	for i, exp := range expected {
+		d := New(srv.URL, &piece.Piece{Index: uint32(i), Length: 4}, make([]byte, 4))
+		resultC := make(chan *Downloader, 1)
+		d.Run(http.DefaultClient, info, time.Second, resultC)
+		<-resultC
+		if d.Error != nil {
+			t.Fatal(d.Error)
+		}
+		if string(d.Buffer) != exp {
+			t.Errorf("unexpected data for piece #%d: %q", i, d.Buffer)
+		}
+	}
+
+	d := New(srv.URL+"/missing/", &piece.Piece{Index: 0, Length: 4}, make([]byte, 4))
+	resultC := make(chan *Downloader, 1)
+	d.Run(http.DefaultClient, info, time.Second, resultC)
+	<-resultC
+	if serr, ok := d.Error.(*StatusError); !ok || serr.Code != http.StatusNotFound {
+		t.Errorf("unexpected error: %v", d.Error)
+	}
+}
@@ -17,6 +17,7 @@ import (
 	clog "github.com/cenkalti/log"
 	"github.com/cenkalti/rain/internal/console"
 	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/magnet"
 	"github.com/cenkalti/rain/rainrpc"
 	"github.com/cenkalti/rain/session"
 	"github.com/hokaccha/go-prettyjson"
@@ -88,6 +89,11 @@ func main() {
 					Usage: "URL of RPC server",
 					Value: "http://127.0.0.1:" + strconv.Itoa(session.DefaultConfig.RPCPort),
 				},
+				cli.StringFlag{
+					Name:   "token",
+					Usage:  "token for authenticating to RPC server",
+					EnvVar: "RAIN_RPC_TOKEN",
+				},
 			},
 			Before: handleBeforeClient,
 			Subcommands: []cli.Command{
@@ -103,7 +109,7 @@ func main() {
 				},
 				{
 					Name:   "add",
-					Usage:  "add torrent or magnet",
+					Usage:  "add torrent, magnet or info hash",
 					Action: handleAdd,
 				},
 				{
@@ -263,7 +269,11 @@ func handleServer(c *cli.Context) error {
 }
 
 func handleBeforeClient(c *cli.Context) error {
-	clt = rainrpc.NewClient(c.String("url"))
+	if token := c.String("token"); token != "" {
+		clt = rainrpc.NewClientWithToken(c.String("url"), token)
+	} else {
+		clt = rainrpc.NewClient(c.String("url"))
+	}
 	return nil
 }
 
@@ -295,7 +305,8 @@ func handleAdd(c *cli.Context) error {
 	var b []byte
 	var marshalErr error
 	arg := c.Args().Get(0)
-	if strings.HasPrefix(arg, "magnet:") || strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://") {
+	_, ihErr := magnet.ParseInfoHash(arg)
+	if strings.HasPrefix(arg, "magnet:") || strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://") || ihErr == nil {
 		resp, err := clt.AddURI(arg)
 		if err != nil {
 			return err
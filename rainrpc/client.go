@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"io"
 	"io/ioutil"
+	"net/http"
 
 	"github.com/cenkalti/rain/internal/rpctypes"
 	"github.com/powerman/rpc-codec/jsonrpc2"
@@ -18,6 +19,16 @@ func NewClient(addr string) *Client {
 	return &Client{client: jsonrpc2.NewHTTPClient(addr)}
 }
 
+// NewClientWithToken returns a client that sends the token in "Authorization" header of each request.
+// Use it if the server is configured with RPCToken.
+func NewClientWithToken(addr, token string) *Client {
+	doer := jsonrpc2.DoerFunc(func(req *http.Request) (*http.Response, error) {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return http.DefaultClient.Do(req)
+	})
+	return &Client{client: jsonrpc2.NewCustomHTTPClient(addr, doer)}
+}
+
 func (c *Client) Close() error {
 	return c.client.Close()
 }
@@ -0,0 +1,59 @@
+package session
+
+import (
+	"net"
+	"sort"
+)
+
+// ActiveDownload is a piece that is being downloaded from a peer.
+type ActiveDownload struct {
+	// Index of the piece.
+	Piece int
+	// Address of the peer that the piece is being downloaded from.
+	Peer net.Addr
+	// Number of blocks received from the peer.
+	BlocksDone int
+	// Number of blocks in the piece.
+	BlocksTotal int
+	// Peer is sending blocks too slowly.
+	Snubbed bool
+	// Peer has choked us while the piece is being downloaded.
+	Choked bool
+}
+
+type activeDownloadsRequest struct {
+	Response chan []ActiveDownload
+}
+
+// ActiveDownloads returns the pieces that are being downloaded from peers.
+func (t *torrent) ActiveDownloads() []ActiveDownload {
+	var downloads []ActiveDownload
+	req := activeDownloadsRequest{Response: make(chan []ActiveDownload, 1)}
+	select {
+	case t.activeDownloadsCommandC <- req:
+	case <-t.doneC:
+	}
+	select {
+	case downloads = <-req.Response:
+	case <-t.doneC:
+	}
+	return downloads
+}
+
+func (t *torrent) getActiveDownloads() []ActiveDownload {
+	downloads := make([]ActiveDownload, 0, len(t.pieceDownloaders))
+	for pe, pd := range t.pieceDownloaders {
+		_, snubbed := t.pieceDownloadersSnubbed[pe]
+		_, choked := t.pieceDownloadersChoked[pe]
+		downloads = append(downloads, ActiveDownload{
+			Piece:       int(pd.Piece.Index),
+			Peer:        pe.Addr(),
+			BlocksDone:  pd.DoneBlocks(),
+			BlocksTotal: len(pd.Piece.Blocks),
+			Snubbed:     snubbed,
+			Choked:      choked,
+		})
+	}
+	sort.Slice(downloads, func(i, j int) bool { return downloads[i].Piece < downloads[j].Piece })
+	return downloads
+}
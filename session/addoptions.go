@@ -0,0 +1,39 @@
+package session
+
+import (
+	"fmt"
+
+	"github.com/cenkalti/rain/internal/storage"
+	"github.com/cenkalti/rain/internal/storage/containerstorage"
+	"github.com/cenkalti/rain/internal/storage/filestorage"
+)
+
+// Storage backends for torrent data.
+const (
+	// StorageFile saves each file in torrent as a separate file under the torrent's data directory.
+	StorageFile = filestorage.StorageType
+	// StorageContainer saves all files in torrent into a single sparse container file.
+	// Useful for moving or taking snapshots of torrent data atomically.
+	StorageContainer = containerstorage.StorageType
+)
+
+// AddOptions contains options for adding a new torrent to Session.
+type AddOptions struct {
+	// Storage backend for torrent data. Default is StorageFile.
+	Storage string
+	// Do not start the torrent after adding. It can be started later with Torrent.Start.
+	Stopped bool
+}
+
+// newStorage returns a storage of type typ that keeps the data at dest.
+// Empty type means StorageFile for compatibility with torrents added before storage type is saved in resume data.
+func newStorage(typ, dest string) (storage.Storage, error) {
+	switch typ {
+	case "", StorageFile:
+		return filestorage.New(dest)
+	case StorageContainer:
+		return containerstorage.New(dest)
+	default:
+		return nil, fmt.Errorf("unknown storage type: %q", typ)
+	}
+}
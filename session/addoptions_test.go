@@ -0,0 +1,62 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAddStopped(t *testing.T) {
+	s, closeSession := newTestSession(t, nil)
+	// Session is closed and loaded again by the test. Only the temporary directory needs to be removed.
+	defer os.RemoveAll(filepath.Dir(s.config.Database))
+	closed := false
+	defer func() {
+		if !closed {
+			closeSession()
+		}
+	}()
+
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	stopped, err := s.AddTorrentOptions(f, &AddOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stoppedMagnet, err := s.AddURIOptions("magnet:?xt=urn:btih:0102030405060708090a0b0c0d0e0f1011121314", &AddOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	started, err := s.AddURI("magnet:?xt=urn:btih:1102030405060708090a0b0c0d0e0f1011121314")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tor := range []*Torrent{stopped, stoppedMagnet} {
+		if status := tor.Stats().Status; status != Stopped {
+			t.Fatalf("torrent added as stopped is %s", status)
+		}
+	}
+	if status := started.Stats().Status; status == Stopped {
+		t.Fatal("torrent is not started")
+	}
+
+	// Stopped state is saved in the database.
+	closed = true
+	s.Close()
+	s, err = New(s.config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for _, id := range []string{stopped.ID(), stoppedMagnet.ID()} {
+		if status := s.GetTorrent(id).Stats().Status; status != Stopped {
+			t.Fatalf("torrent added as stopped is %s after loading session", status)
+		}
+	}
+	if status := s.GetTorrent(started.ID()).Stats().Status; status == Stopped {
+		t.Fatal("started torrent is not started after loading session")
+	}
+}
@@ -0,0 +1,38 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAddPeers(t *testing.T) {
+	s, closeSession := newTestSession(t, func(cfg *Config) {
+		// Only the first address is dialed, the rest are kept in the list.
+		cfg.DialInterval = time.Hour
+	})
+	defer closeSession()
+
+	tor, err := s.AddURI("magnet:?xt=urn:btih:0102030405060708090a0b0c0d0e0f1011121314")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tor.AddPeers([]string{"192.0.2.1:6881", "invalid"}); err == nil {
+		t.Fatal("invalid address must be rejected")
+	}
+	if n := tor.AddrListStats().Manual; n != 0 {
+		t.Fatalf("addresses are added although one of them is invalid: %d", n)
+	}
+	if err = tor.AddPeers([]string{"192.0.2.1:6881", "198.51.100.1:6881", "203.0.113.1:6881"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := tor.AddrListStats().Manual; n != 2 {
+		t.Fatalf("unexpected number of manual addresses: %d", n)
+	}
+
+	if err = s.RemoveTorrent(tor.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if err = tor.AddPeers([]string{"192.0.2.4:6881"}); err != ErrTorrentClosed {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -20,6 +20,12 @@ func (t *torrent) handleAllocationDone(al *allocator.Allocator) {
 		return
 	}
 
+	if al.Mode != t.config.FileAllocation {
+		t.log.Warningf("%q file allocation is not supported, %q is used", t.config.FileAllocation, al.Mode)
+	} else {
+		t.log.Debugf("files are allocated in %q mode", al.Mode)
+	}
+
 	if t.files != nil {
 		panic("files exist")
 	}
@@ -34,6 +40,15 @@ func (t *torrent) handleAllocationDone(al *allocator.Allocator) {
 		panic("piece picker exists")
 	}
 	t.piecePicker = piecepicker.New(t.pieces, t.config.EndgameParallelDownloadsPerPiece, t.log)
+	t.applyPiecePriorities()
+
+	// Saved bitfield cannot be trusted if some of the files have been truncated after it is written.
+	if t.bitfield != nil && al.ShortFiles > 0 {
+		warning := fmt.Sprintf("%d files are smaller than expected, pieces will be verified", al.ShortFiles)
+		t.log.Warning(warning)
+		t.warnings = append(t.warnings, warning)
+		t.bitfield = nil
+	}
 
 	// If we already have bitfield from resume db, skip verification and start downloading.
 	if t.bitfield != nil {
@@ -0,0 +1,34 @@
+package session
+
+type announceNowRequest struct {
+	Response chan error
+}
+
+// AnnounceNow makes trackers and DHT announce the torrent without waiting for the interval.
+func (t *torrent) AnnounceNow() error {
+	req := announceNowRequest{Response: make(chan error, 1)}
+	select {
+	case t.announceNowCommandC <- req:
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+	select {
+	case err := <-req.Response:
+		return err
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+}
+
+func (t *torrent) announceNow() error {
+	if status := t.status(); status == Stopped || status == Stopping {
+		return ErrTorrentStopped
+	}
+	for _, an := range t.announcers {
+		an.AnnounceNow()
+	}
+	if t.dhtAnnouncer != nil {
+		t.dhtAnnouncer.AnnounceNow()
+	}
+	return nil
+}
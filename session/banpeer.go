@@ -0,0 +1,78 @@
+package session
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/cenkalti/rain/internal/peer"
+)
+
+// banPeer closes the connection to a misbehaving peer and refuses new connections to/from its IP
+// until the torrent is closed.
+func (t *torrent) banPeer(pe *peer.Peer, reason string) {
+	pe.Logger().Errorln("banning peer:", reason)
+	t.bannedPeerIPs[pe.IP()] = struct{}{}
+	t.closePeer(pe)
+}
+
+// minMetadataBanDuration is the minimum duration that peers sending metadata not matching the info hash are banned.
+// It is used if BadPeerBanDuration is shorter.
+const minMetadataBanDuration = time.Hour
+
+// tempBanPeerIP refuses new connections to/from ip for duration d.
+func (t *torrent) tempBanPeerIP(ip string, d time.Duration) {
+	t.tempBannedPeerIPs[ip] = time.Now().Add(d)
+}
+
+// handleBadPiece is called when a downloaded piece fails hash check.
+// Peers that have sent blocks of MaxBadPieces corrupt pieces are disconnected and banned for BadPeerBanDuration.
+// Each IP is counted once per piece even if it appears in contributors more than once.
+func (t *torrent) handleBadPiece(contributors []*peer.Peer) {
+	if t.config.MaxBadPieces <= 0 {
+		return
+	}
+	counted := make(map[string]struct{}, len(contributors))
+	for _, pe := range contributors {
+		ip := pe.IP()
+		if _, ok := counted[ip]; ok {
+			continue
+		}
+		counted[ip] = struct{}{}
+		t.badPieces[ip]++
+		if t.badPieces[ip] < t.config.MaxBadPieces {
+			continue
+		}
+		delete(t.badPieces, ip)
+		pe.Logger().Errorln("banning peer for", t.config.BadPeerBanDuration, "because of sending corrupt pieces")
+		t.tempBanPeerIP(ip, t.config.BadPeerBanDuration)
+		if _, ok := t.peers[pe]; ok {
+			t.closePeer(pe)
+		}
+	}
+}
+
+// isBanned returns true if connections to/from ip must be refused.
+func (t *torrent) isBanned(ip string) bool {
+	if _, ok := t.bannedPeerIPs[ip]; ok {
+		return true
+	}
+	until, ok := t.tempBannedPeerIPs[ip]
+	if !ok {
+		return false
+	}
+	if time.Now().Before(until) {
+		return true
+	}
+	delete(t.tempBannedPeerIPs, ip)
+	return false
+}
+
+// isBannedClient returns true if the peer id starts with one of the prefixes in Config.BannedClients.
+func (t *torrent) isBannedClient(id [20]byte) bool {
+	for _, prefix := range t.config.BannedClients {
+		if prefix != "" && bytes.HasPrefix(id[:], []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
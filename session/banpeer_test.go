@@ -0,0 +1,136 @@
+package session
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/addrlist"
+	"github.com/cenkalti/rain/internal/bitfield"
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/peerconn"
+)
+
+func newTestPeer(t *testing.T) (*peer.Peer, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var id [20]byte
+	pc := peerconn.New(conn, id, bitfield.New(64), logger.New("peer"), peerconn.Config{PieceTimeout: time.Minute})
+	return peer.New(pc, addrlist.Manual, time.Minute), func() { conn.Close() }
+}
+
+func TestHandleBadPiece(t *testing.T) {
+	pe, closeConn := newTestPeer(t)
+	defer closeConn()
+
+	tor := &torrent{
+		config:            DefaultConfig,
+		peers:             make(map[*peer.Peer]struct{}),
+		bannedPeerIPs:     make(map[string]struct{}),
+		badPieces:         make(map[string]int),
+		tempBannedPeerIPs: make(map[string]time.Time),
+	}
+	tor.config.MaxBadPieces = 2
+
+	// Same peer sending multiple blocks of the piece is counted once.
+	tor.handleBadPiece([]*peer.Peer{pe, pe})
+	if n := tor.badPieces[pe.IP()]; n != 1 {
+		t.Fatalf("unexpected bad piece count: %d", n)
+	}
+	if tor.isBanned(pe.IP()) {
+		t.Fatal("peer is banned before reaching MaxBadPieces")
+	}
+	tor.handleBadPiece([]*peer.Peer{pe})
+	if !tor.isBanned(pe.IP()) {
+		t.Fatal("peer is not banned after MaxBadPieces")
+	}
+	if _, ok := tor.badPieces[pe.IP()]; ok {
+		t.Fatal("bad piece count is not reset after ban")
+	}
+
+	// Banning can be disabled.
+	tor.config.MaxBadPieces = 0
+	delete(tor.tempBannedPeerIPs, pe.IP())
+	tor.handleBadPiece([]*peer.Peer{pe})
+	if _, ok := tor.badPieces[pe.IP()]; ok {
+		t.Fatal("bad piece is counted when banning is disabled")
+	}
+	if tor.isBanned(pe.IP()) {
+		t.Fatal("peer is banned when banning is disabled")
+	}
+}
+
+func TestIsBannedClient(t *testing.T) {
+	tor := &torrent{config: DefaultConfig}
+	tor.config.BannedClients = []string{"", "-FB"}
+	var id [20]byte
+	copy(id[:], "-TR2940-")
+	if tor.isBannedClient(id) {
+		t.Fatal("client is banned by empty prefix")
+	}
+	copy(id[:], "-FB0001-")
+	if !tor.isBannedClient(id) {
+		t.Fatal("client with banned prefix is not banned")
+	}
+}
+
+func TestTempBanExpires(t *testing.T) {
+	tor := &torrent{tempBannedPeerIPs: make(map[string]time.Time)}
+	tor.tempBanPeerIP("10.0.0.1", time.Hour)
+	tor.tempBanPeerIP("10.0.0.2", -time.Second)
+	if !tor.isBanned("10.0.0.1") {
+		t.Fatal("peer is not banned")
+	}
+	if tor.isBanned("10.0.0.2") {
+		t.Fatal("ban is not expired")
+	}
+	if _, ok := tor.tempBannedPeerIPs["10.0.0.2"]; ok {
+		t.Fatal("expired ban is not removed")
+	}
+}
+
+func TestStartPeerBannedClient(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var id [20]byte
+	copy(id[:], "-FB0001-")
+	pc := peerconn.New(conn, id, bitfield.New(64), logger.New("peer"), peerconn.Config{PieceTimeout: time.Minute})
+
+	tor := &torrent{
+		config:            DefaultConfig,
+		peers:             make(map[*peer.Peer]struct{}),
+		connectedPeerIPs:  map[string]struct{}{"127.0.0.1": {}},
+		tempBannedPeerIPs: make(map[string]time.Time),
+	}
+	tor.config.BannedClients = []string{"-FB"}
+	tor.config.MaxPeerDial = 0
+	tor.startPeer(pc, tor.peers, addrlist.Incoming, false)
+	if len(tor.peers) != 0 {
+		t.Fatal("peer with banned client is started")
+	}
+	if tor.rejectedPeers != 1 {
+		t.Fatalf("unexpected rejected peers: %d", tor.rejectedPeers)
+	}
+	if !tor.isBanned("127.0.0.1") {
+		t.Fatal("ip of banned client is not banned")
+	}
+	if _, ok := tor.connectedPeerIPs["127.0.0.1"]; ok {
+		t.Fatal("ip of banned client is still connected")
+	}
+}
@@ -96,6 +96,14 @@ func (s *Session) reloadBlocklist() error {
 	})
 }
 
+// AddBlockedRange adds an IPv4 range to the blocklist in CIDR format, e.g. "10.0.0.0/8",
+// or in P2P format, e.g. "Some Organization:10.0.0.0-10.255.255.255".
+// Connections to/from addresses in the range are refused. Existing connections are not closed.
+// Added ranges are kept when the blocklist is reloaded from BlocklistURL but they are not saved.
+func (s *Session) AddBlockedRange(cidr string) error {
+	return s.blocklist.Add(cidr)
+}
+
 func (s *Session) blocklistReloader(d time.Duration) {
 	for {
 		select {
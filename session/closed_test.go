@@ -0,0 +1,67 @@
+package session
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// Methods of a torrent that is removed from Session must return instead of blocking forever.
+var closedTorrentTests = []struct {
+	name string
+	fn   func(*Torrent) error
+	err  error
+}{
+	{"Rename", func(t *Torrent) error { return t.Rename("foo") }, ErrTorrentClosed},
+	{"RenameFile", func(t *Torrent) error { return t.RenameFile(0, "foo") }, ErrTorrentClosed},
+	{"SetName", func(t *Torrent) error { return t.SetName("foo") }, ErrTorrentClosed},
+	{"AddrListStats", func(t *Torrent) error { t.AddrListStats(); return nil }, nil},
+	{"SetUploadEnabled", func(t *Torrent) error { return t.SetUploadEnabled(false) }, ErrTorrentClosed},
+	{"ResetStats", func(t *Torrent) error { return t.ResetStats() }, ErrTorrentClosed},
+	{"SetPiecePriorities", func(t *Torrent) error { return t.SetPiecePriorities(map[int]Priority{0: PriorityHigh}) }, ErrTorrentClosed},
+	{"SetSeedOnly", func(t *Torrent) error { return t.SetSeedOnly(true) }, ErrTorrentClosed},
+	{"PieceForOffset", func(t *Torrent) error { _, err := t.PieceForOffset(0, 0); return err }, ErrTorrentClosed},
+	{"PieceRange", func(t *Torrent) error { _, _, err := t.PieceRange(0); return err }, ErrTorrentClosed},
+	{"Files", func(t *Torrent) error { _, err := t.Files(); return err }, ErrTorrentClosed},
+	{"SetFilePriority", func(t *Torrent) error { return t.SetFilePriority(0, PriorityHigh) }, ErrTorrentClosed},
+	{"ActiveDownloads", func(t *Torrent) error { t.ActiveDownloads(); return nil }, nil},
+	{"WriteTorrent", func(t *Torrent) error { return t.WriteTorrent(ioutil.Discard) }, ErrTorrentClosed},
+	{"SetSeedGoal", func(t *Torrent) error { return t.SetSeedGoal(1, 0) }, ErrTorrentClosed},
+	{"Verify", func(t *Torrent) error { return t.Verify() }, ErrTorrentClosed},
+	{"Magnet", func(t *Torrent) error { t.Magnet(); return nil }, nil},
+	{"SetMaxPeers", func(t *Torrent) error { return t.SetMaxPeers(1, 1) }, ErrTorrentClosed},
+	{"ResetMaxPeers", func(t *Torrent) error { return t.ResetMaxPeers() }, ErrTorrentClosed},
+	{"AnnounceNow", func(t *Torrent) error { return t.AnnounceNow() }, ErrTorrentClosed},
+	{"SetPEX", func(t *Torrent) error { return t.SetPEX(false) }, ErrTorrentClosed},
+}
+
+func TestClosedTorrent(t *testing.T) {
+	s, closeSession := newTestSession(t, nil)
+	defer closeSession()
+
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tor, err := s.AddTorrent(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.RemoveTorrent(tor.ID()); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range closedTorrentTests {
+		errC := make(chan error, 1)
+		go func(fn func(*Torrent) error) { errC <- fn(tor) }(tc.fn)
+		select {
+		case err = <-errC:
+			if err != tc.err {
+				t.Errorf("%s: unexpected error: %v", tc.name, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s blocks after torrent is removed", tc.name)
+		}
+	}
+}
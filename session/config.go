@@ -1,43 +1,118 @@
 package session
 
-import "time"
+import (
+	"time"
+
+	"github.com/cenkalti/rain/internal/allocator"
+)
 
 // Config for Session.
 type Config struct {
 	// Database file to save resume data.
 	Database string
+	// ResumerFactory stores resume data of torrents. If nil, resume data is saved in Database.
+	// Session-wide data such as the blocklist cache is always saved in Database.
+	ResumerFactory ResumerFactory
 	// DataDir is where files are downloaded.
 	DataDir string
 	// New torrents will be listened at selected port in this range.
 	PortBegin, PortEnd uint16
+	// If all ports in range are used, new torrents are listened at a port assigned by the operating system
+	// instead of failing with ErrNoFreePort.
+	AllowEphemeralPorts bool
+	// IP address to listen for peer connections. Connections to peers, trackers and web seeds are made from this address too,
+	// and DHT and LSD are bound to it, so traffic does not leave from other interfaces. Empty means all interfaces.
+	ListenAddress string
 	// At start, client will set max open files limit to this number. (like "ulimit -n" command)
 	MaxOpenFiles uint64
 	// Enable peer exchange protocol.
 	PEXEnabled bool
 	// Bitfield is saved to disk for fast resume without hash checking.
-	// There is an interval to keep IO lower.
+	// There is an interval to keep IO lower. Zero means the default value is used.
 	BitfieldWriteInterval time.Duration
-	// Stats are written at interval to reduce write operations.
+	// Stats are written at interval to reduce write operations. Zero means the default value is used.
 	StatsWriteInterval time.Duration
 	// Peer id is prefixed with this string. See BEP 20. Remaining bytes of peer id will be randomized.
+	// Must be 8 bytes long in Azureus style, e.g. "-RN0100-".
 	PeerIDPrefix string
-	// Client version that is sent in BEP 10 handshake message.
+	// Client version that is sent in BEP 10 handshake message. Default is built from Version, e.g. "Rain 0.1.0".
 	ExtensionHandshakeClientVersion string
-	// URL to the blocklist file in CIDR format.
+	// Omit a few random pieces from the Bitfield message sent to peers and send Have messages for them later.
+	// Upload-only flag of BEP 21 is not sent either. This makes it harder for peers to tell that we are a seed.
+	LazyBitfield bool
+	// URL to the blocklist file in CIDR or eMule/PeerGuardian P2P format.
 	BlocklistURL string
 	// When to refresh blocklist
 	BlocklistUpdateInterval time.Duration
+	// Peers are rejected after handshake if their peer id starts with one of these prefixes, e.g. "-FB".
+	// Their IPs are not accepted or dialed again for BannedClientDuration.
+	BannedClients        []string
+	BannedClientDuration time.Duration
+	// Peers that have sent blocks of this many pieces failing hash check are disconnected and banned for BadPeerBanDuration.
+	// Zero disables banning. Peers sending metadata that does not match the info hash are always banned,
+	// for at least an hour.
+	MaxBadPieces       int
+	BadPeerBanDuration time.Duration
+	// If non-zero, run loops of torrents are checked at this interval.
+	// A stack dump is logged if a torrent does not respond in WatchdogTimeout.
+	WatchdogInterval time.Duration
+	// Time to wait for a torrent to respond to a watchdog check.
+	WatchdogTimeout time.Duration
+	// New torrents are added with uploading disabled. Peers are kept choked and no data is uploaded.
+	// This is harmful to swarms, use only on connections with strictly limited upload.
+	NoUpload bool
+	// Max number of piece data bytes per second downloaded and uploaded by all torrents in Session. Zero means unlimited.
+	// Limits can be changed later with Session.SetDownloadRateLimit and Session.SetUploadRateLimit.
+	// Upload limit is split evenly between uploading torrents and then between unchoked peers of each torrent.
+	DownloadRateLimit int64
+	UploadRateLimit   int64
+	// Torrents are stopped after uploading this many times of the downloaded bytes. Zero means no limit.
+	SeedRatioLimit float64
+	// Torrents are stopped after seeding for this duration. Zero means no limit.
+	SeedTimeLimit time.Duration
+	// Torrent files put into this directory are added automatically and moved to ".added" subdirectory.
+	// Empty value disables watching.
+	WatchDir string
+	// A JSON object with info hash, name, total bytes and location of the torrent on disk is POSTed
+	// to this URL when a download is completed. Empty value disables the notification.
+	CompletionWebhookURL string
+	// Timeout of a single webhook request. The request is retried a few times if it fails.
+	CompletionWebhookTimeout time.Duration
+	// Completed torrents are removed if no data is transferred for this duration. Zero disables removal.
+	// Idle time is counted from the session start, so torrents are not removed because the session was not running.
+	RemoveIdleAfter time.Duration
+	// Do not delete files of torrents removed because of RemoveIdleAfter.
+	RemoveIdleKeepData bool
+	// Save addresses of peers that data has been exchanged with and connect to them again when torrent is started.
+	RememberPeers bool
+	// What to do when a piece cannot be written to disk. Valid values are "stop" and "retry".
+	// With "stop", torrent is stopped on any write error.
+	// With "retry", writes failed with a transient error (e.g. disk full) are retried at DiskErrorRetryInterval
+	// while downloading is paused. Other errors still stop the torrent.
+	DiskErrorPolicy string
+	// Time to wait before retrying a failed piece write.
+	DiskErrorRetryInterval time.Duration
+	// How disk space is allocated for new files. Valid values are "none", "full" and "falloc".
+	// With "none", files are created sparse and space is allocated as pieces are written.
+	// With "full", zeros are written to the files before downloading starts.
+	// With "falloc", space is reserved with fallocate(2) and "full" is used where it is not supported.
+	FileAllocation string
 
 	// Host to listen for RPC server
 	RPCHost string
 	// Listen port for RPC server
 	RPCPort int
+	// If set, RPC requests must have "Authorization: Bearer <token>" header. Requests without a valid token are
+	// rejected with 401 status. Set a token if RPCHost is reachable from other machines.
+	RPCToken string
 	// Time to wait for ongoing requests before shutting down RPC HTTP server.
 	RPCShutdownTimeout time.Duration
+	// Serve a web UI at "/ui/" path of RPC server. Disabled by default.
+	WebUIEnabled bool
 
 	// Enable DHT node.
 	DHTEnabled bool
-	// DHT node will listen on this IP.
+	// DHT node will listen on this IP. ListenAddress is used instead if it is set and DHTAddress is left as default.
 	DHTAddress string
 	// DHT node will listen on this UDP port.
 	DHTPort uint16
@@ -45,20 +120,44 @@ type Config struct {
 	DHTAnnounceInterval time.Duration
 	// Minimum announce interval when announcing to DHT.
 	DHTMinAnnounceInterval time.Duration
+	// Save DHT routing table periodically and load it on next start so the node does not need to bootstrap again.
+	// The table is saved by the DHT library to "~/.taipeitorrent/dht-<DHTPort>". The location cannot be changed.
+	DHTSaveRoutingTable bool
+
+	// Find peers in local network with Local Service Discovery (BEP 14).
+	LSDEnabled bool
+	// Interval of multicast announces sent to local network.
+	LSDAnnounceInterval time.Duration
+	// Minimum interval between announces when more peers are needed.
+	LSDMinAnnounceInterval time.Duration
 
 	// Number of peer addresses to request in announce request.
 	TrackerNumWant int
 	// Time to wait for announcing stopped event.
 	// Stopped event is sent to the tracker when torrent is stopped.
+	// Announce requests still running after this duration are cancelled and counted as failed.
 	TrackerStopTimeout time.Duration
+	// Max duration to wait for the stopped event to be announced to trackers when a torrent is closed.
+	// Close waits for the shorter of TrackerStopTimeout and StopAnnounceTimeout.
+	// Zero values of both timeouts are replaced with the defaults.
+	StopAnnounceTimeout time.Duration
 	// When the client needs new peer addresses to connect, it ask to the tracker.
 	// To prevent spamming the tracker an interval is set to wait before the next announce.
 	TrackerMinAnnounceInterval time.Duration
+	// Failed announces are retried with exponential backoff and jitter. This is the upper limit of wait time between retries.
+	// Zero means the default value.
+	TrackerMaxRetryInterval time.Duration
 	// Total time to wait for response to be read.
 	// This includes ConnectTimeout and TLSHandshakeTimeout.
 	TrackerHTTPTimeout time.Duration
-	// User agent sent when communicating with HTTP trackers.
+	// User agent sent when communicating with HTTP trackers. Default is built from Version, e.g. "Rain/0.1.0".
 	TrackerHTTPUserAgent string
+	// By default, trackers in the next tier are tried only if all trackers in the previous tiers fail (BEP 12).
+	// If set, all tiers are announced in parallel and fallback happens only between trackers in the same tier.
+	TrackerAnnounceToAllTiers bool
+	// Last resolved addresses of tracker hosts are used for this duration if DNS lookup fails.
+	// Set to zero to disable caching.
+	TrackerDNSCacheTTL time.Duration
 
 	// Number of unchoked peers.
 	UnchokedPeers int
@@ -66,35 +165,112 @@ type Config struct {
 	OptimisticUnchokedPeers int
 	// Max number of blocks requested from a peer but not received yet
 	RequestQueueLength int
+	// For fast peers, the request queue is grown to hold this much time worth of data at the download rate of the peer,
+	// up to MaxRequestQueueLength blocks. RequestQueueLength is the minimum.
+	RequestQueueTime      time.Duration
+	MaxRequestQueueLength int
 	// Time to wait for a requested block to be received before marking peer as snubbed
 	RequestTimeout time.Duration
+	// Max duration for downloading a single piece from a web seed.
+	WebseedDownloadTimeout time.Duration
+	// A web seed is not used for this duration after it returns an error or a corrupt piece.
+	WebseedRetryInterval time.Duration
+	// Snubs are remembered by IP address and peers that have snubbed us more are less likely to be picked for
+	// downloading pieces, even after they reconnect. The penalty decreases by half in this duration.
+	// Zero disables the penalty.
+	SnubPenaltyDecay time.Duration
 	// Max number of running downloads on piece in endgame mode, snubbed and choed peers don't count
 	EndgameParallelDownloadsPerPiece int
+	// Endgame mode is entered when the number of missing blocks drops below this value.
+	// In endgame mode remaining blocks are requested from all peers that have them and pending requests to other peers
+	// are cancelled when a block arrives. Zero disables endgame mode.
+	EndgameThreshold int
 	// Max number of outgoing connections to dial
 	MaxPeerDial int
 	// Max number of incoming connections to accept
 	MaxPeerAccept int
+	// Max number of outgoing connections in connecting or handshake state in all torrents. Zero means no limit.
+	// Too many half-open connections may trigger connection rate limits of ISPs and routers.
+	MaxHalfOpenConnections int
+	// Time to wait between dialing new connections to peers. Zero means addresses are dialed without waiting.
+	DialInterval time.Duration
+	// Max number of incoming handshakes running at the same time in all torrents. Zero means no limit.
+	// Connections exceeding the limit are closed before handshake.
+	MaxConcurrentHandshakes int
+	// Limits for dialing and accepting connections while the torrent is downloading.
+	// Zero means MaxPeerDial and MaxPeerAccept are used.
+	DownloadPhaseMaxDial   int
+	DownloadPhaseMaxAccept int
+	// Limits for dialing and accepting connections after the torrent is completed.
+	// Seeds do not dial peers unless SeedPhaseMaxDial is set. Zero SeedPhaseMaxAccept means MaxPeerAccept is used.
+	// Existing connections are not closed when the limits change at completion.
+	SeedPhaseMaxDial   int
+	SeedPhaseMaxAccept int
 	// Running piece downloads, snubbed and choked peers don't count
 	ParallelPieceDownloads int
 	// Running metadata downloads, snubbed peers don't count
 	ParallelMetadataDownloads int
+	// If a peer cannot deliver whole metadata in this duration, the connection is closed.
+	MetadataDownloadTimeout time.Duration
+	// Peers that failed to deliver valid metadata this many times are not asked for metadata again.
+	MaxMetadataFailures int
+	// Max number of metadata bytes per second sent to peers requesting metadata (BEP 9). Zero means no limit.
+	// Requests exceeding the limit are rejected.
+	MetadataUploadRateLimit int64
 	// Time to wait for TCP connection to open.
 	PeerConnectTimeout time.Duration
 	// Time to wait for BitTorrent handshake to complete.
 	PeerHandshakeTimeout time.Duration
 	// When peer has started to send piece block, if it does not send any bytes in PieceTimeout, the connection is closed.
 	PieceTimeout time.Duration
-	// Buffer size for messages read from a single peer
+	// Buffer size for messages read from a single peer.
+	// Each connected peer allocates a buffer of this size so total memory usage is
+	// roughly (PeerReadBufferSize + PeerWriteBufferSize) * number of connected peers.
+	// Smaller values save memory when there are many connections, larger values may increase throughput.
 	PeerReadBufferSize int
+	// Buffer size for messages written to a single peer. Also see PeerReadBufferSize.
+	PeerWriteBufferSize int
+	// Count protocol messages sent to and received from each peer by type. Counts are returned in Torrent.Peers.
+	// Useful for debugging but it adds some overhead to every message.
+	PeerProtocolStats bool
+	// When download completes, connections to peers that are not interested are closed except the fastest this many.
+	KeepUninterestedPeers int
+	// Peers sending more than this many Have messages for pieces they already announced are banned. Zero means no limit.
+	MaxRedundantHaves int
+	// When all peers are disconnected while downloading, announce to trackers and DHT immediately
+	// instead of waiting for the next announce. Repeated announces are limited with exponential backoff.
+	AnnounceOnZeroPeers bool
+	// Connections to peers that no piece data has been exchanged with in this duration are closed to make room for other peers.
+	// Protocol messages such as keep-alives do not count as activity. Zero disables closing idle connections.
+	IdleConnectionTimeout time.Duration
 	// Max number of peer addresses to keep in connect queue.
 	MaxPeerAddresses int
 
+	// Number of goroutines that calculate piece hashes while verifying torrents. Workers are shared by all torrents.
+	// Zero means the number of CPUs.
+	HashWorkers int
+	// Max number of torrents that verify their files at the same time. Other torrents wait in
+	// "Verification Queued" state in the order they are started. Zero means no limit.
+	VerifierConcurrency int
+
 	// Number of bytes to read when a piece is requested by a peer.
 	PieceReadSize int64
-	// Number of cached bytes for piece read requests.
+	// Max number of bytes cached for piece read requests. The cache is shared by all torrents in Session.
+	// Least recently used blocks are evicted when the cache is full.
+	ReadCacheSize int64
+	// Deprecated: Use ReadCacheSize. If non-zero, it overrides ReadCacheSize.
 	PieceCacheSize int64
 	// Read bytes for a piece part expires after duration.
 	PieceCacheTTL time.Duration
+	// Max number of disk reads running at the same time for uploading pieces of a torrent.
+	// Increasing this value may improve seeding speed on SSDs. Must be at least 1.
+	DiskReadConcurrency int
+	// Max number of pieces written to disk at the same time for a torrent.
+	// Increasing this value may improve download speed on fast storage. Must be at least 1.
+	DiskWriteConcurrency int
+	// Max number of pieces written to disk at the same time by all torrents in Session.
+	// Limits disk thrashing when many torrents are downloading. Zero means no limit.
+	ParallelWrites int
 
 	// When the client want to connect a peer, first it tries to do encrypted handshake.
 	// If it does not work, it connects to same peer again and does unencrypted handshake.
@@ -106,6 +282,19 @@ type Config struct {
 	ForceIncomingEncryption bool
 }
 
+// Valid values for Config.FileAllocation.
+const (
+	FileAllocationNone   = allocator.ModeNone
+	FileAllocationFull   = allocator.ModeFull
+	FileAllocationFalloc = allocator.ModeFalloc
+)
+
+// Valid values for Config.DiskErrorPolicy.
+const (
+	DiskErrorPolicyStop  = "stop"
+	DiskErrorPolicyRetry = "retry"
+)
+
 var DefaultConfig = Config{
 	// Session
 	Database:                        "~/rain/session.db",
@@ -116,9 +305,18 @@ var DefaultConfig = Config{
 	PEXEnabled:                      true,
 	BitfieldWriteInterval:           30 * time.Second,
 	StatsWriteInterval:              30 * time.Second,
-	PeerIDPrefix:                    "-RN" + Version + "-",
-	ExtensionHandshakeClientVersion: "Rain " + Version,
+	PeerIDPrefix:                    peerIDPrefix(Version),
+	ExtensionHandshakeClientVersion: clientVersion(Version),
 	BlocklistUpdateInterval:         24 * time.Hour,
+	BannedClientDuration:            time.Hour,
+	MaxBadPieces:                    3,
+	BadPeerBanDuration:              time.Hour,
+	WatchdogTimeout:                 time.Minute,
+	RememberPeers:                   true,
+	DiskErrorPolicy:                 DiskErrorPolicyStop,
+	DiskErrorRetryInterval:          10 * time.Second,
+	FileAllocation:                  FileAllocationNone,
+	CompletionWebhookTimeout:        10 * time.Second,
 
 	// RPC Server
 	RPCHost:            "127.0.0.1",
@@ -128,9 +326,12 @@ var DefaultConfig = Config{
 	// Tracker
 	TrackerNumWant:             100,
 	TrackerStopTimeout:         5 * time.Second,
+	StopAnnounceTimeout:        5 * time.Second,
 	TrackerMinAnnounceInterval: time.Minute,
+	TrackerMaxRetryInterval:    30 * time.Minute,
 	TrackerHTTPTimeout:         10 * time.Second,
-	TrackerHTTPUserAgent:       "Rain/" + Version,
+	TrackerHTTPUserAgent:       userAgent(Version),
+	TrackerDNSCacheTTL:         24 * time.Hour,
 
 	// DHT node
 	DHTEnabled:             true,
@@ -139,24 +340,47 @@ var DefaultConfig = Config{
 	DHTAnnounceInterval:    30 * time.Minute,
 	DHTMinAnnounceInterval: time.Minute,
 
+	// Local Service Discovery
+	LSDEnabled:             false,
+	LSDAnnounceInterval:    5 * time.Minute,
+	LSDMinAnnounceInterval: time.Minute,
+
 	// Peer
 	UnchokedPeers:                    3,
 	OptimisticUnchokedPeers:          1,
 	RequestQueueLength:               50,
+	RequestQueueTime:                 3 * time.Second,
+	MaxRequestQueueLength:            500,
 	RequestTimeout:                   20 * time.Second,
+	SnubPenaltyDecay:                 10 * time.Minute,
+	WebseedDownloadTimeout:           time.Minute,
+	WebseedRetryInterval:             10 * time.Minute,
 	EndgameParallelDownloadsPerPiece: 2,
+	EndgameThreshold:                 50,
 	MaxPeerDial:                      20,
 	MaxPeerAccept:                    20,
+	MaxHalfOpenConnections:           8,
+	DialInterval:                     20 * time.Millisecond,
 	ParallelPieceDownloads:           10,
 	ParallelMetadataDownloads:        2,
+	MetadataDownloadTimeout:          2 * time.Minute,
+	MaxMetadataFailures:              3,
+	MetadataUploadRateLimit:          1024 * 1024,
 	PeerConnectTimeout:               5 * time.Second,
 	PeerHandshakeTimeout:             10 * time.Second,
 	PieceTimeout:                     30 * time.Second,
 	PeerReadBufferSize:               32 * 1024,
+	PeerWriteBufferSize:              32 * 1024,
 	MaxPeerAddresses:                 2000,
+	AnnounceOnZeroPeers:              true,
+	MaxRedundantHaves:                1000,
+	MaxConcurrentHandshakes:          100,
 
 	// Piece cache
-	PieceReadSize:  256 * 1024,
-	PieceCacheSize: 50 * 256 * 1024,
-	PieceCacheTTL:  5 * time.Minute,
+	PieceReadSize: 256 * 1024,
+	ReadCacheSize: 64 * 1024 * 1024,
+	PieceCacheTTL: 5 * time.Minute,
+
+	DiskReadConcurrency:  1,
+	DiskWriteConcurrency: 1,
 }
@@ -0,0 +1,61 @@
+package session
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/cenkalti/rain/internal/piecewriter"
+)
+
+// isTransientDiskError returns true if the write may succeed when it is tried again later,
+// for example after the user frees some disk space.
+func isTransientDiskError(err error) bool {
+	if pe, ok := err.(*os.PathError); ok {
+		err = pe.Err
+	}
+	switch err {
+	case syscall.ENOSPC, syscall.EDQUOT, syscall.EAGAIN, syscall.EINTR:
+		return true
+	}
+	return false
+}
+
+// schedulePieceWriterRetry runs the failed piece writer again after DiskErrorRetryInterval.
+// Writers that fail while the timer is running are retried together with the ones already waiting.
+// Piece messages stay blocked until the writes are retried, so downloading is paused in the meantime.
+func (t *torrent) schedulePieceWriterRetry(pw *piecewriter.PieceWriter) {
+	pw.Piece.Writing = true
+	t.pieceWriterRetries = append(t.pieceWriterRetries, piecewriter.New(pw.Piece, pw.Buffer, pw.Lenght))
+	t.updatePieceMessages()
+	if t.pieceWriterRetryTimer == nil {
+		t.pieceWriterRetryTimer = time.NewTimer(t.config.DiskErrorRetryInterval)
+		t.pieceWriterRetryTimerC = t.pieceWriterRetryTimer.C
+	}
+}
+
+// retryPieceWriters starts the piece writers that are waiting to be retried.
+func (t *torrent) retryPieceWriters() {
+	retries := t.pieceWriterRetries
+	t.pieceWriterRetries = nil
+	t.pieceWriterRetryTimer = nil
+	t.pieceWriterRetryTimerC = nil
+	for _, pw := range retries {
+		t.startPieceWriter(pw)
+	}
+}
+
+func (t *torrent) stopPieceWriterRetry() {
+	if t.pieceWriterRetryTimer == nil {
+		return
+	}
+	t.pieceWriterRetryTimer.Stop()
+	for _, pw := range t.pieceWriterRetries {
+		pw.Piece.Writing = false
+		t.piecePool.Put(pw.Buffer)
+	}
+	t.pieceWriterRetries = nil
+	t.pieceWriterRetryTimer = nil
+	t.pieceWriterRetryTimerC = nil
+	t.updatePieceMessages()
+}
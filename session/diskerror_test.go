@@ -0,0 +1,69 @@
+package session
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/piece"
+	"github.com/cenkalti/rain/internal/piecewriter"
+)
+
+func TestIsTransientDiskError(t *testing.T) {
+	cases := []struct {
+		err       error
+		transient bool
+	}{
+		{&os.PathError{Op: "write", Path: "foo", Err: syscall.ENOSPC}, true},
+		{&os.PathError{Op: "write", Path: "foo", Err: syscall.EDQUOT}, true},
+		{syscall.ENOSPC, true},
+		{&os.PathError{Op: "open", Path: "foo", Err: syscall.EACCES}, false},
+		{syscall.EROFS, false},
+		{errors.New("unknown error"), false},
+	}
+	for _, c := range cases {
+		if isTransientDiskError(c.err) != c.transient {
+			t.Errorf("isTransientDiskError(%v) != %v", c.err, c.transient)
+		}
+	}
+}
+
+func TestSchedulePieceWriterRetry(t *testing.T) {
+	tor := &torrent{
+		config:        DefaultConfig,
+		pieceMessages: make(chan peer.PieceMessage),
+	}
+	tor.config.DiskErrorRetryInterval = time.Hour
+	pieces := []piece.Piece{{Index: 0}, {Index: 1}}
+
+	// Writers failed concurrently are retried together.
+	tor.schedulePieceWriterRetry(piecewriter.New(&pieces[0], make([]byte, 1), 1))
+	timer := tor.pieceWriterRetryTimer
+	tor.schedulePieceWriterRetry(piecewriter.New(&pieces[1], make([]byte, 1), 1))
+	if tor.pieceWriterRetryTimer != timer {
+		t.Fatal("retry timer is restarted")
+	}
+	if len(tor.pieceWriterRetries) != 2 {
+		t.Fatalf("unexpected number of retries: %d", len(tor.pieceWriterRetries))
+	}
+	if !pieces[0].Writing || !pieces[1].Writing {
+		t.Fatal("pieces waiting for retry must be marked as writing")
+	}
+	if tor.pieceMessages != nil {
+		t.Fatal("piece messages are not paused while waiting for retry")
+	}
+
+	tor.stopPieceWriterRetry()
+	if len(tor.pieceWriterRetries) != 0 || tor.pieceWriterRetryTimer != nil {
+		t.Fatal("retries are not cleared")
+	}
+	if pieces[0].Writing || pieces[1].Writing {
+		t.Fatal("pieces are still marked as writing")
+	}
+	if tor.pieceMessages == nil {
+		t.Fatal("piece messages are not resumed")
+	}
+}
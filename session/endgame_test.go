@@ -0,0 +1,139 @@
+package session
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/addrlist"
+	"github.com/cenkalti/rain/internal/bitfield"
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/peerconn"
+	"github.com/cenkalti/rain/internal/peerconn/peerreader"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/cenkalti/rain/internal/piece"
+	"github.com/cenkalti/rain/internal/piecedownloader"
+	"github.com/cenkalti/rain/internal/piecepicker"
+	"github.com/rcrowley/go-metrics"
+)
+
+// newRunningTestPeer returns a running peer and the other end of its connection.
+// Messages sent to the peer can be read from remote.
+func newRunningTestPeer(t *testing.T) (pe *peer.Peer, remote net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err = l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var id [20]byte
+	pc := peerconn.New(conn, id, bitfield.New(64), logger.New("peer"), peerconn.Config{PieceTimeout: time.Minute})
+	pe = peer.New(pc, addrlist.Manual, time.Minute)
+	go pe.Run(make(chan peer.Message, 10), make(chan peer.PieceMessage, 10), make(chan *peer.Peer, 1), make(chan *peer.Peer, 1))
+	return pe, remote
+}
+
+// readCancel reads messages from conn until a cancel message is received.
+func readCancel(t *testing.T, conn net.Conn) peerprotocol.CancelMessage {
+	err := conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		var length uint32
+		err = binary.Read(conn, binary.BigEndian, &length)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, length)
+		_, err = io.ReadFull(conn, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if length == 0 || peerprotocol.MessageID(buf[0]) != peerprotocol.Cancel {
+			continue
+		}
+		var msg peerprotocol.CancelMessage
+		msg.Index = binary.BigEndian.Uint32(buf[1:5])
+		msg.Begin = binary.BigEndian.Uint32(buf[5:9])
+		msg.Length = binary.BigEndian.Uint32(buf[9:13])
+		return msg
+	}
+}
+
+func TestEndgameShareBlock(t *testing.T) {
+	peerA, remoteA := newRunningTestPeer(t)
+	defer remoteA.Close()
+	peerB, remoteB := newRunningTestPeer(t)
+	defer remoteB.Close()
+	defer peerB.Close()
+
+	pieces := []piece.Piece{{
+		Index:  0,
+		Length: 2 * piece.BlockSize,
+		Hash:   make([]byte, 20),
+		Blocks: piece.Blocks{
+			{Index: 0, Begin: 0, Length: piece.BlockSize},
+			{Index: 1, Begin: piece.BlockSize, Length: piece.BlockSize},
+		},
+	}}
+	tor := &torrent{
+		config:           DefaultConfig,
+		info:             &metainfo.Info{PieceLength: 2 * piece.BlockSize, TotalLength: 2 * piece.BlockSize, NumPieces: 1},
+		pieces:           pieces,
+		bitfield:         bitfield.New(1),
+		peers:            map[*peer.Peer]struct{}{peerA: {}, peerB: {}},
+		pieceDownloaders: make(map[*peer.Peer]*piecedownloader.PieceDownloader),
+		downloadSpeed:    metrics.NewEWMA1(),
+		log:              logger.New("torrent"),
+	}
+	tor.config.MaxPeerDial = 0
+	tor.config.MaxBadPieces = 0
+	tor.piecePicker = piecepicker.New(tor.pieces, tor.config.EndgameParallelDownloadsPerPiece, tor.log)
+	tor.piecePicker.SetEndgame(true)
+
+	// Both blocks are requested from both peers. Peer B has already sent the second block.
+	for _, pe := range []*peer.Peer{peerA, peerB} {
+		pd := piecedownloader.New(&tor.pieces[0], pe, make([]byte, 2*piece.BlockSize))
+		pd.RequestBlocks(2)
+		tor.pieceDownloaders[pe] = pd
+		tor.piecePicker.RequestedPeers(0)[pe] = struct{}{}
+	}
+	tor.pieceDownloaders[peerB].GotBlock(&tor.pieces[0].Blocks[1], make([]byte, piece.BlockSize))
+
+	// First block from peer A completes the piece in downloader of peer B.
+	tor.handlePieceMessage(peer.PieceMessage{
+		Peer: peerA,
+		Piece: peerreader.Piece{
+			PieceMessage: peerprotocol.PieceMessage{Index: 0, Begin: 0},
+			Data:         make([]byte, piece.BlockSize),
+		},
+	})
+
+	// Request for the shared block is cancelled at peer B.
+	msg := readCancel(t, remoteB)
+	if msg.Index != 0 || msg.Begin != 0 || msg.Length != piece.BlockSize {
+		t.Fatalf("unexpected cancel message: %+v", msg)
+	}
+	// Piece fails the hash check and the peer that sent the last block is disconnected.
+	if n := tor.bytesWasted.HashFailed; n != 2*piece.BlockSize {
+		t.Fatalf("unexpected hash failed bytes: %d", n)
+	}
+	if _, ok := tor.peers[peerA]; ok {
+		t.Fatal("peer sent the last block is not disconnected")
+	}
+	if _, ok := tor.peers[peerB]; !ok {
+		t.Fatal("peer that the piece is shared with is disconnected")
+	}
+}
@@ -0,0 +1,157 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cenkalti/rain/internal/metainfo"
+)
+
+// Errors returned from Session and Torrent methods. Some of them are returned as a typed error that contains
+// more details. Typed errors have an Is method so they can be checked with errors.Is on Go 1.13 and later.
+// On older versions use a type assertion for them.
+var (
+	// ErrInvalidConfig is returned from New if a Config field has an invalid value.
+	// The returned error is a *InvalidConfigError.
+	ErrInvalidConfig = errors.New("invalid config")
+	// ErrDatabaseLocked is returned from New if resume database is opened by another process.
+	ErrDatabaseLocked = errors.New("resume database is locked by another process")
+	// ErrNoFreePort is returned when all ports between PortBegin and PortEnd are in use and AllowEphemeralPorts is not set.
+	// The returned error is a *NoFreePortError.
+	ErrNoFreePort = errors.New("no free port")
+	// ErrUnsupportedScheme is returned from AddURI if scheme of the URI is not http, https or magnet.
+	// The returned error is a *UnsupportedSchemeError.
+	ErrUnsupportedScheme = errors.New("unsupported uri scheme")
+	// ErrInvalidMetainfo is returned when a torrent file cannot be parsed.
+	// The returned error is a *InvalidMetainfoError.
+	ErrInvalidMetainfo = errors.New("invalid metainfo")
+	// ErrUnsupportedTorrentVersion is returned when adding a torrent that is for BitTorrent v2 only.
+	// The returned error is a *InvalidMetainfoError. Hybrid torrents are downloaded from the v1 swarm.
+	ErrUnsupportedTorrentVersion = metainfo.ErrUnsupportedTorrentVersion
+	// ErrDHTDisabled is returned from AddURI if a bare info hash is given while DHT is disabled.
+	ErrDHTDisabled = errors.New("DHT must be enabled to add torrent by info hash")
+	// ErrTorrentAlreadyExists is returned from AddTorrent and AddURI if a torrent with the same info hash is in Session.
+	// The existing torrent is returned with the error.
+	ErrTorrentAlreadyExists = errors.New("torrent already exists")
+	// ErrTorrentExists is the same error as ErrTorrentAlreadyExists.
+	ErrTorrentExists = ErrTorrentAlreadyExists
+	// ErrTorrentNotFound is returned when there is no torrent with the given ID in Session.
+	ErrTorrentNotFound = errors.New("torrent not found")
+	// ErrNoMetadata is returned from Torrent methods that need info dict before it is downloaded from peers.
+	ErrNoMetadata = errors.New("metadata is not downloaded yet")
+	// ErrNoTrackers is returned from Torrent.Scrape if the torrent does not have any trackers.
+	ErrNoTrackers = errors.New("torrent has no trackers")
+	// ErrTorrentClosed is returned from Torrent methods after the torrent is removed or Session is closed.
+	ErrTorrentClosed = errors.New("torrent is closed")
+	// ErrTorrentStopped is returned from Torrent methods that need the torrent to be running.
+	ErrTorrentStopped = errors.New("torrent is stopped")
+	// ErrTorrentNotStopped is returned from Torrent methods that move files if the torrent is not stopped.
+	ErrTorrentNotStopped = errors.New("torrent must be stopped")
+	// ErrTrackerDNS is set as Tracker.Error if the hostname of the tracker cannot be resolved.
+	// The error is a *TrackerDNSError.
+	ErrTrackerDNS = errors.New("cannot resolve tracker host")
+	// ErrStopAnnounce is returned from Session.Close if the stopped event could not be announced to some trackers
+	// in StopAnnounceTimeout. The returned error is a *StopAnnounceError.
+	ErrStopAnnounce = errors.New("stopped event is not announced")
+)
+
+// InvalidConfigError is returned from New if a Config field has an invalid value.
+type InvalidConfigError struct {
+	Reason string
+}
+
+func (e *InvalidConfigError) Error() string {
+	return "invalid config: " + e.Reason
+}
+
+// Is returns true if target is ErrInvalidConfig.
+func (e *InvalidConfigError) Is(target error) bool {
+	return target == ErrInvalidConfig
+}
+
+// NoFreePortError is returned when a port cannot be assigned to a new torrent.
+type NoFreePortError struct {
+	// Port range in Config.
+	Begin, End uint16
+	// Number of ports that are used by other processes.
+	Bad int
+}
+
+func (e *NoFreePortError) Error() string {
+	return fmt.Sprintf("no free port: all %d ports in range [%d, %d) are used by torrents (%d by other processes), widen the range with PortBegin and PortEnd in config", e.End-e.Begin, e.Begin, e.End, e.Bad)
+}
+
+// Is returns true if target is ErrNoFreePort.
+func (e *NoFreePortError) Is(target error) bool {
+	return target == ErrNoFreePort
+}
+
+// UnsupportedSchemeError is returned from AddURI if the URI cannot be added.
+type UnsupportedSchemeError struct {
+	Scheme string
+}
+
+func (e *UnsupportedSchemeError) Error() string {
+	return "unsupported uri scheme: " + e.Scheme
+}
+
+// Is returns true if target is ErrUnsupportedScheme.
+func (e *UnsupportedSchemeError) Is(target error) bool {
+	return target == ErrUnsupportedScheme
+}
+
+// InvalidMetainfoError is returned when a torrent file cannot be parsed.
+type InvalidMetainfoError struct {
+	Err error
+}
+
+func (e *InvalidMetainfoError) Error() string {
+	return "invalid metainfo: " + e.Err.Error()
+}
+
+// Unwrap returns the error returned from the parser.
+func (e *InvalidMetainfoError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true if target is ErrInvalidMetainfo.
+func (e *InvalidMetainfoError) Is(target error) bool {
+	return target == ErrInvalidMetainfo
+}
+
+// TrackerDNSError is set as Tracker.Error if the hostname of the tracker cannot be resolved
+// and there is no cached address for it. The tracker is retried with backoff.
+type TrackerDNSError struct {
+	Host string
+	Err  error
+}
+
+func (e *TrackerDNSError) Error() string {
+	return "cannot resolve tracker host " + e.Host + ": " + e.Err.Error()
+}
+
+// Unwrap returns the error returned from the resolver.
+func (e *TrackerDNSError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true if target is ErrTrackerDNS.
+func (e *TrackerDNSError) Is(target error) bool {
+	return target == ErrTrackerDNS
+}
+
+// StopAnnounceError is returned from Session.Close if the stopped event could not be announced to some trackers.
+type StopAnnounceError struct {
+	// URLs of trackers that have not received the stopped event.
+	Trackers []string
+}
+
+func (e *StopAnnounceError) Error() string {
+	return "stopped event is not announced to trackers: " + strings.Join(e.Trackers, ", ")
+}
+
+// Is returns true if target is ErrStopAnnounce.
+func (e *StopAnnounceError) Is(target error) bool {
+	return target == ErrStopAnnounce
+}
@@ -0,0 +1,106 @@
+package session
+
+import (
+	"time"
+
+	"github.com/cenkalti/rain/internal/announcer"
+)
+
+const (
+	// eventBufferSize is the capacity of the channel returned from Session.Events.
+	eventBufferSize = 1000
+	// torrentEventBufferSize is the capacity of the channel returned from Torrent.Events.
+	torrentEventBufferSize = 100
+	// trackerStatusBufferSize is the capacity of the channel that announcers send tracker status changes.
+	// Announcers do not block on it because the torrent loop calls announcer methods synchronously.
+	trackerStatusBufferSize = 10
+)
+
+// EventType is the kind of an Event.
+type EventType int
+
+const (
+	// EventTrackerWorking is sent when a tracker responds successfully after failing.
+	EventTrackerWorking EventType = iota
+	// EventTrackerFailing is sent when an announce to a tracker fails after it was working or not contacted yet.
+	EventTrackerFailing
+	// EventSeedGoalReached is sent when a torrent is stopped because it has reached its seed ratio or time limit.
+	EventSeedGoalReached
+	// EventAdded is sent when a new torrent is added to the session. It is not sent for torrents loaded from resume data.
+	EventAdded
+	// EventStarted is sent when a torrent is started.
+	EventStarted
+	// EventStopped is sent when a torrent has stopped and announced the stop to trackers.
+	// Error is set if the torrent is stopped because of an error.
+	EventStopped
+	// EventMetadataDownloaded is sent when the info dictionary of a magnet link is downloaded from peers.
+	EventMetadataDownloaded
+	// EventPieceVerified is sent when a downloaded piece passes the hash check and is written to disk.
+	// It is sent for every piece, so it can fill the channel of a slow consumer and cause other events to be dropped.
+	EventPieceVerified
+	// EventCompleted is sent when all pieces of a torrent are downloaded.
+	// It is also sent when the torrent is found complete after verification.
+	EventCompleted
+	// EventError is sent when a torrent is stopping because of an error.
+	EventError
+	// EventRemoved is sent when a torrent is removed from the session.
+	EventRemoved
+	// EventStatsReset is sent when the statistics of a torrent are reset with Torrent.ResetStats.
+	EventStatsReset
+)
+
+// Event is a notification about a change in a torrent.
+type Event struct {
+	Type EventType
+	// ID of the torrent that the event belongs to.
+	TorrentID string
+	Time      time.Time
+	// URL of the tracker for tracker events.
+	Tracker string
+	// Index of the piece for EventPieceVerified.
+	Piece uint32
+	// The reason of the failure for EventTrackerFailing, EventError and EventStopped.
+	Error error
+}
+
+// Events returns a channel that receives events of all torrents in the session.
+// The channel is buffered and never closed. If the buffer is full, new events are dropped,
+// so slow consumers may miss some events.
+func (s *Session) Events() <-chan Event {
+	return s.events
+}
+
+// Events returns a channel that receives events of the torrent.
+// Like Session.Events, the channel is buffered and never closed, and new events are dropped if the buffer is full.
+// Events are delivered to both channels independently, a full channel does not cause drops in the other.
+// The buffer holds 100 events, which is less than the number of EventPieceVerified events of most torrents.
+// Consumers that must not miss EventCompleted should keep receiving from the channel or check Torrent.Stats.
+func (t *Torrent) Events() <-chan Event {
+	return t.torrent.torrentEvents
+}
+
+// sendEvent sends the event without blocking the torrent. The event is dropped if the channel is full.
+func (t *torrent) sendEvent(e Event) {
+	e.TorrentID = t.id
+	e.Time = time.Now()
+	select {
+	case t.events <- e:
+	default:
+		t.log.Debugln("event dropped:", e.Type)
+	}
+	select {
+	case t.torrentEvents <- e:
+	default:
+	}
+}
+
+func (t *torrent) handleTrackerStatusChange(sc announcer.StatusChange) {
+	e := Event{Tracker: sc.Tracker.URL()}
+	if sc.Status == announcer.Working {
+		e.Type = EventTrackerWorking
+	} else {
+		e.Type = EventTrackerFailing
+		e.Error = trackerError(sc.Error)
+	}
+	t.sendEvent(e)
+}
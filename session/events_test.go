@@ -0,0 +1,105 @@
+package session
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/metainfo"
+)
+
+func TestTorrentEvents(t *testing.T) {
+	where, err := ioutil.TempDir("", "rain-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(where)
+
+	cmd := exec.Command("cp", "-R", filepath.Join(torrentDataDir, torrentName), where)
+	err = cmd.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	mi, err := metainfo.New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt := options{
+		Info: mi.Info,
+	}
+	tor, err := opt.NewTorrent(mi.Info.Hash[:], newFileStorage(t, where))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tor.Close()
+
+	nextEvent := func(expected EventType) {
+		t.Helper()
+		select {
+		case e := <-tor.torrentEvents:
+			if e.Type != expected {
+				t.Fatalf("unexpected event: %d, expected %d", e.Type, expected)
+			}
+			if e.Time.IsZero() {
+				t.Fatal("event time is not set")
+			}
+		case <-time.After(timeout):
+			t.Fatalf("event is not sent: %d", expected)
+		}
+	}
+
+	// Existing data is verified after start.
+	tor.Start()
+	nextEvent(EventStarted)
+	nextEvent(EventCompleted)
+	tor.Stop()
+	nextEvent(EventStopped)
+	tor.Start()
+	nextEvent(EventStarted)
+	select {
+	case e := <-tor.torrentEvents:
+		t.Fatalf("unexpected event: %d", e.Type)
+	default:
+	}
+}
+
+func TestSendEventFullChannel(t *testing.T) {
+	tor := &torrent{
+		id:            "test",
+		events:        make(chan Event, 1),
+		torrentEvents: make(chan Event, 2),
+		log:           logger.New("test"),
+	}
+	tor.sendEvent(Event{Type: EventPieceVerified, Piece: 0})
+	tor.sendEvent(Event{Type: EventPieceVerified, Piece: 1})
+	tor.sendEvent(Event{Type: EventCompleted})
+
+	// Session channel is full after the first event. Torrent channel still receives the second one.
+	if e := <-tor.events; e.Type != EventPieceVerified || e.Piece != 0 || e.TorrentID != "test" {
+		t.Fatalf("unexpected event in session channel: %+v", e)
+	}
+	if len(tor.events) != 0 {
+		t.Fatal("event is not dropped from full session channel")
+	}
+	if e := <-tor.torrentEvents; e.Piece != 0 {
+		t.Fatalf("unexpected event in torrent channel: %+v", e)
+	}
+	if e := <-tor.torrentEvents; e.Piece != 1 {
+		t.Fatalf("unexpected event in torrent channel: %+v", e)
+	}
+	// Consumers that are slower than piece downloads may miss EventCompleted.
+	if len(tor.torrentEvents) != 0 {
+		t.Fatal("event is not dropped from full torrent channel")
+	}
+}
@@ -0,0 +1,147 @@
+package session
+
+import "fmt"
+
+// File is a file in the torrent.
+type File struct {
+	// Path of the file relative to storage root.
+	Path string
+	// Length of the file in bytes.
+	Length int64
+	// Position of the file in the concatenated torrent data.
+	Offset int64
+	// Download priority of the file.
+	Priority Priority
+}
+
+type filesRequest struct {
+	Response chan filesResponse
+}
+
+type filesResponse struct {
+	Files []File
+	Error error
+}
+
+type filePriorityRequest struct {
+	Index    int
+	Priority Priority
+	Response chan error
+}
+
+// Files returns the list of files in the torrent.
+func (t *torrent) Files() ([]File, error) {
+	req := filesRequest{Response: make(chan filesResponse, 1)}
+	select {
+	case t.filesCommandC <- req:
+	case <-t.doneC:
+		return nil, ErrTorrentClosed
+	}
+	select {
+	case resp := <-req.Response:
+		return resp.Files, resp.Error
+	case <-t.doneC:
+		return nil, ErrTorrentClosed
+	}
+}
+
+// SetFilePriority changes the download priority of the file at index.
+func (t *torrent) SetFilePriority(index int, priority Priority) error {
+	req := filePriorityRequest{Index: index, Priority: priority, Response: make(chan error, 1)}
+	select {
+	case t.filePriorityCommandC <- req:
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+	select {
+	case err := <-req.Response:
+		return err
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+}
+
+func (t *torrent) getFiles() ([]File, error) {
+	if t.info == nil {
+		return nil, ErrNoMetadata
+	}
+	paths := t.getFilePaths()
+	infoFiles := t.info.GetFiles()
+	files := make([]File, len(infoFiles))
+	var offset int64
+	for i, f := range infoFiles {
+		files[i] = File{
+			Path:     paths[i],
+			Length:   f.Length,
+			Offset:   offset,
+			Priority: t.filePriority(i),
+		}
+		offset += f.Length
+	}
+	return files, nil
+}
+
+func (t *torrent) filePriority(index int) Priority {
+	if t.filePriorities == nil {
+		return PriorityNormal
+	}
+	return Priority(t.filePriorities[index])
+}
+
+// setFilePriority saves the priority of the file and updates the priorities of pieces that contain data of the file.
+// A piece is downloaded with the highest priority of the files it contains, so pieces shared with
+// files that are not skipped are still downloaded.
+func (t *torrent) setFilePriority(index int, priority Priority) error {
+	if t.info == nil {
+		return ErrNoMetadata
+	}
+	numFiles := len(t.info.GetFiles())
+	if index < 0 || index >= numFiles {
+		return fmt.Errorf("invalid file index: %d", index)
+	}
+	if priority > PriorityHigh {
+		return fmt.Errorf("invalid priority: %d", priority)
+	}
+	if t.filePriorities == nil {
+		t.filePriorities = make([]uint8, numFiles)
+		for i := range t.filePriorities {
+			t.filePriorities[i] = uint8(PriorityNormal)
+		}
+	}
+	t.filePriorities[index] = uint8(priority)
+	if t.resume != nil {
+		err := t.resume.WriteFilePriorities(t.filePriorities)
+		if err != nil {
+			return err
+		}
+	}
+	first, last, err := t.info.PieceRange(index)
+	if err != nil {
+		// Empty files do not have any pieces.
+		return nil
+	}
+	priorities := make(map[int]Priority, last-first+1)
+	for i := first; i <= last; i++ {
+		priorities[int(i)] = PrioritySkip
+	}
+	for j := 0; j < numFiles; j++ {
+		f, l, err := t.info.PieceRange(j)
+		if err != nil || l < first || f > last {
+			continue
+		}
+		p := t.filePriority(j)
+		for i := maxUint32(f, first); i <= l && i <= last; i++ {
+			if p > priorities[int(i)] {
+				priorities[int(i)] = p
+			}
+		}
+	}
+	return t.setPiecePriorities(priorities)
+}
+
+func maxUint32(a, b uint32) uint32 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,54 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/zeebo/bencode"
+)
+
+func TestSetFilePrioritySharedPiece(t *testing.T) {
+	// Piece 1 contains the end of file "a" and the beginning of file "b".
+	b, err := bencode.EncodeBytes(map[string]interface{}{
+		"name":         "shared",
+		"piece length": 16 * 1024,
+		"pieces":       string(make([]byte, 3*20)),
+		"files": []map[string]interface{}{
+			{"length": 20000, "path": []string{"a"}},
+			{"length": 20000, "path": []string{"b"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := metainfo.NewInfo(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor := &torrent{info: info}
+
+	assertPriorities := func(expected ...Priority) {
+		t.Helper()
+		for i, p := range expected {
+			if Priority(tor.piecePriorities[i]) != p {
+				t.Fatalf("priority of piece #%d is %d, expected %d", i, tor.piecePriorities[i], p)
+			}
+		}
+	}
+	if err = tor.setFilePriority(0, PrioritySkip); err != nil {
+		t.Fatal(err)
+	}
+	assertPriorities(PrioritySkip, PriorityNormal, PriorityNormal)
+	if err = tor.setFilePriority(1, PriorityHigh); err != nil {
+		t.Fatal(err)
+	}
+	assertPriorities(PrioritySkip, PriorityHigh, PriorityHigh)
+	if err = tor.setFilePriority(0, PriorityHigh); err != nil {
+		t.Fatal(err)
+	}
+	if err = tor.setFilePriority(1, PrioritySkip); err != nil {
+		t.Fatal(err)
+	}
+	// Shared piece is still downloaded with the priority of file "a".
+	assertPriorities(PriorityHigh, PriorityHigh, PrioritySkip)
+}
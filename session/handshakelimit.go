@@ -0,0 +1,38 @@
+package session
+
+import "time"
+
+// halfOpenRetryInterval is the time to wait before dialing again when half-open connection slots
+// are used by other torrents.
+const halfOpenRetryInterval = time.Second
+
+// handshakeSemaphore limits the number of handshakes running at the same time in all torrents.
+// A nil semaphore does not limit.
+type handshakeSemaphore chan struct{}
+
+func newHandshakeSemaphore(n int) handshakeSemaphore {
+	if n <= 0 {
+		return nil
+	}
+	return make(handshakeSemaphore, n)
+}
+
+// tryAcquire takes a slot without blocking. Returns false if all slots are in use.
+func (s handshakeSemaphore) tryAcquire() bool {
+	if s == nil {
+		return true
+	}
+	select {
+	case s <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s handshakeSemaphore) release() {
+	if s == nil {
+		return
+	}
+	<-s
+}
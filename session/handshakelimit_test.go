@@ -0,0 +1,126 @@
+package session
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/addrlist"
+	"github.com/cenkalti/rain/internal/handshaker/outgoinghandshaker"
+	"github.com/cenkalti/rain/internal/logger"
+)
+
+func TestHandshakeSemaphore(t *testing.T) {
+	s := newHandshakeSemaphore(1)
+	if !s.tryAcquire() {
+		t.Fatal("first slot must be acquired")
+	}
+	if s.tryAcquire() {
+		t.Fatal("semaphore is full")
+	}
+	s.release()
+	if !s.tryAcquire() {
+		t.Fatal("released slot must be acquired")
+	}
+	var unlimited handshakeSemaphore
+	for i := 0; i < 10; i++ {
+		if !unlimited.tryAcquire() {
+			t.Fatal("nil semaphore must not limit")
+		}
+	}
+}
+
+func TestHalfOpenLimitShared(t *testing.T) {
+	// Accepted connections are never answered so handshakes stay in progress.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	addr := l.Addr().(*net.TCPAddr)
+
+	halfOpen := newHandshakeSemaphore(1)
+	newTorrent := func() *torrent {
+		cfg := DefaultConfig
+		cfg.DialInterval = 0
+		var clientIP net.IP
+		tor := &torrent{
+			config:                    cfg,
+			halfOpen:                  halfOpen,
+			addrList:                  addrlist.New(cfg.MaxPeerAddresses, nil, 0, &clientIP),
+			connectedPeerIPs:          make(map[string]struct{}),
+			outgoingHandshakers:       make(map[*outgoinghandshaker.OutgoingHandshaker]addrlist.PeerSource),
+			outgoingHandshakerResultC: make(chan *outgoinghandshaker.OutgoingHandshaker),
+			log:                       logger.New("test"),
+		}
+		tor.addrList.Push([]*net.TCPAddr{addr}, addrlist.Manual)
+		return tor
+	}
+	t1, t2 := newTorrent(), newTorrent()
+
+	t1.dialAddresses()
+	if len(t1.outgoingHandshakers) != 1 {
+		t.Fatalf("unexpected number of handshakers: %d", len(t1.outgoingHandshakers))
+	}
+	t2.dialAddresses()
+	if len(t2.outgoingHandshakers) != 0 {
+		t.Fatal("limit is not shared between torrents")
+	}
+	if t2.dialTimer == nil {
+		t.Fatal("dial is not retried later")
+	}
+	t2.stopDialTimer()
+
+	t1.stopOutgoingHandshakers()
+	t2.dialAddresses()
+	if len(t2.outgoingHandshakers) != 1 {
+		t.Fatal("slot is not released")
+	}
+	t2.stopOutgoingHandshakers()
+}
+
+func TestDialInterval(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	addr := l.Addr().(*net.TCPAddr)
+
+	cfg := DefaultConfig
+	cfg.DialInterval = time.Hour
+	var clientIP net.IP
+	tor := &torrent{
+		config:                    cfg,
+		addrList:                  addrlist.New(cfg.MaxPeerAddresses, nil, 0, &clientIP),
+		connectedPeerIPs:          make(map[string]struct{}),
+		outgoingHandshakers:       make(map[*outgoinghandshaker.OutgoingHandshaker]addrlist.PeerSource),
+		outgoingHandshakerResultC: make(chan *outgoinghandshaker.OutgoingHandshaker),
+		log:                       logger.New("test"),
+		lastDial:                  time.Now(),
+	}
+	tor.addrList.Push([]*net.TCPAddr{addr, {IP: net.IPv4(127, 0, 0, 2), Port: addr.Port}}, addrlist.Manual)
+
+	tor.dialAddresses()
+	if len(tor.outgoingHandshakers) != 0 {
+		t.Fatal("address is dialed before dial interval")
+	}
+	if tor.dialTimer == nil {
+		t.Fatal("dial is not scheduled")
+	}
+	tor.stopDialTimer()
+
+	// Only one address is dialed, the next one waits for the interval.
+	tor.lastDial = time.Time{}
+	tor.dialAddresses()
+	if len(tor.outgoingHandshakers) != 1 {
+		t.Fatalf("unexpected number of handshakers: %d", len(tor.outgoingHandshakers))
+	}
+	if n := tor.addrList.Len(); n != 1 {
+		t.Fatalf("unexpected number of addresses: %d", n)
+	}
+	if tor.dialTimer == nil {
+		t.Fatal("next dial is not scheduled")
+	}
+	tor.stopOutgoingHandshakers()
+}
@@ -0,0 +1,55 @@
+package session
+
+import "time"
+
+// Interval for checking idle torrents.
+const idleCheckInterval = time.Minute
+
+func (s *Session) startIdleRemover() {
+	if s.config.RemoveIdleAfter <= 0 {
+		return
+	}
+	go s.idleRemover()
+}
+
+// idleRemover removes completed torrents that have not transferred any data for Config.RemoveIdleAfter.
+func (s *Session) idleRemover() {
+	startedAt := time.Now()
+	ticker := time.NewTicker(idleCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.removeIdleTorrents(startedAt)
+		case <-s.closeC:
+			return
+		}
+	}
+}
+
+// removeIdleTorrents removes the seeding torrents that are idle for Config.RemoveIdleAfter.
+// Time before startedAt is not counted because torrents cannot transfer data while the session is not running.
+func (s *Session) removeIdleTorrents(startedAt time.Time) {
+	for _, t := range s.ListTorrents() {
+		stats := t.Stats()
+		if stats.Status != Seeding {
+			continue
+		}
+		last := stats.LastActivity
+		if last.IsZero() {
+			last = t.CreatedAt()
+		}
+		if last.Before(startedAt) {
+			last = startedAt
+		}
+		idle := time.Since(last)
+		if idle < s.config.RemoveIdleAfter {
+			continue
+		}
+		s.log.Infof("removing torrent %s idle for %s", t.ID(), idle)
+		err := s.removeTorrent(t.ID(), s.config.RemoveIdleKeepData)
+		if err != nil {
+			s.log.Errorln("cannot remove idle torrent:", err)
+		}
+	}
+}
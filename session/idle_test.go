@@ -0,0 +1,60 @@
+package session
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRemoveIdleTorrents(t *testing.T) {
+	s, closeSession := newTestSession(t, func(cfg *Config) {
+		cfg.RemoveIdleAfter = time.Hour
+		cfg.RemoveIdleKeepData = true
+	})
+	defer closeSession()
+
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tor, err := s.AddTorrentOptions(f, &AddOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(s.config.DataDir, tor.ID())
+	err = os.MkdirAll(dest, 0750)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = exec.Command("cp", "-R", filepath.Join(torrentDataDir, torrentName), dest).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tor.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-tor.torrent.NotifyComplete():
+	case <-time.After(timeout):
+		panic("verification did not finish")
+	}
+	// Torrent looks idle for a day, as if it is loaded from the database of a session that was not running.
+	tor.createdAt = time.Now().Add(-24 * time.Hour)
+
+	s.removeIdleTorrents(time.Now())
+	if s.GetTorrent(tor.ID()) == nil {
+		t.Fatal("torrent is removed right after session start")
+	}
+
+	s.removeIdleTorrents(time.Now().Add(-2 * time.Hour))
+	if s.GetTorrent(tor.ID()) != nil {
+		t.Fatal("idle torrent is not removed")
+	}
+	if _, err = os.Stat(filepath.Join(dest, torrentName)); err != nil {
+		t.Fatal("data of removed torrent is deleted:", err)
+	}
+}
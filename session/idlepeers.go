@@ -0,0 +1,40 @@
+package session
+
+import (
+	"time"
+
+	"github.com/cenkalti/rain/internal/peer"
+)
+
+func (t *torrent) startIdlePeerReaper() {
+	if t.config.IdleConnectionTimeout <= 0 || t.idlePeerReaperTicker != nil {
+		return
+	}
+	t.idlePeerReaperTicker = time.NewTicker(time.Minute)
+	t.idlePeerReaperTickerC = t.idlePeerReaperTicker.C
+}
+
+// reapIdlePeers closes connections to peers that no piece data has been exchanged with recently.
+func (t *torrent) reapIdlePeers() {
+	for _, pe := range t.idlePeers() {
+		pe.Logger().Debugln("closing idle peer connection")
+		t.closePeer(pe)
+		t.idlePeersReaped++
+	}
+}
+
+// idlePeers returns the peers that no piece data has been exchanged with in IdleConnectionTimeout.
+// Peers that are choked but have exchanged data recently are not idle.
+func (t *torrent) idlePeers() []*peer.Peer {
+	// Pieces cannot be exchanged before metadata is downloaded.
+	if t.info == nil {
+		return nil
+	}
+	var idle []*peer.Peer
+	for pe := range t.peers {
+		if time.Since(pe.LastDataAt) >= t.config.IdleConnectionTimeout {
+			idle = append(idle, pe)
+		}
+	}
+	return idle
+}
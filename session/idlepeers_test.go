@@ -0,0 +1,35 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/peer"
+)
+
+func TestIdlePeers(t *testing.T) {
+	idle, closeIdle := newTestPeer(t)
+	defer closeIdle()
+	recent, closeRecent := newTestPeer(t)
+	defer closeRecent()
+	idle.LastDataAt = time.Now().Add(-time.Hour)
+	// Choked peers that have exchanged data recently are kept.
+	recent.AmChoking = true
+	recent.PeerChoking = true
+
+	tor := &torrent{
+		config: DefaultConfig,
+		peers:  map[*peer.Peer]struct{}{idle: {}, recent: {}},
+	}
+	tor.config.IdleConnectionTimeout = 10 * time.Minute
+
+	if peers := tor.idlePeers(); len(peers) != 0 {
+		t.Fatal("peers are idle before metadata is downloaded")
+	}
+	tor.info = &metainfo.Info{}
+	peers := tor.idlePeers()
+	if len(peers) != 1 || peers[0] != idle {
+		t.Fatalf("unexpected idle peers: %v", peers)
+	}
+}
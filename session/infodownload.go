@@ -5,6 +5,10 @@ import (
 	"github.com/cenkalti/rain/internal/peerprotocol"
 )
 
+// Peers advertising metadata larger than this size are not used for downloading metadata.
+// Info dictionaries of real torrents are much smaller than this.
+const maxMetadataSize = 10 * 1024 * 1024
+
 func (t *torrent) nextInfoDownload() *infodownloader.InfoDownloader {
 	for pe := range t.peers {
 		if _, ok := t.infoDownloaders[pe]; ok {
@@ -16,11 +20,26 @@ func (t *torrent) nextInfoDownload() *infodownloader.InfoDownloader {
 		if pe.ExtensionHandshake.MetadataSize == 0 {
 			continue
 		}
+		if pe.ExtensionHandshake.MetadataSize > maxMetadataSize {
+			continue
+		}
+		if t.metadataFailures[pe.IP()] >= t.config.MaxMetadataFailures {
+			continue
+		}
 		_, ok := pe.ExtensionHandshake.M[peerprotocol.ExtensionKeyMetadata]
 		if !ok {
 			continue
 		}
-		return infodownloader.New(pe)
+		return infodownloader.New(pe, t.config.MetadataDownloadTimeout, t.infoDownloaderTimeoutC)
 	}
 	return nil
 }
+
+// handleMetadataFailure drops the peer that could not deliver valid metadata and continues with the next one.
+func (t *torrent) handleMetadataFailure(id *infodownloader.InfoDownloader, reason string) {
+	id.Peer.Logger().Errorln("metadata download failed:", reason)
+	t.metadataBytesWasted += id.BytesReceived
+	t.metadataFailures[id.Peer.IP()]++
+	t.closePeer(id.Peer)
+	t.startInfoDownloaders()
+}
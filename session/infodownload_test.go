@@ -0,0 +1,48 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/infodownloader"
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+)
+
+func TestNextInfoDownload(t *testing.T) {
+	pe, closeConn := newTestPeer(t)
+	defer closeConn()
+	pe.ExtensionHandshake = &peerprotocol.ExtensionHandshakeMessage{
+		M:            map[string]uint8{peerprotocol.ExtensionKeyMetadata: 1},
+		MetadataSize: 1000,
+	}
+
+	tor := &torrent{
+		config:                 DefaultConfig,
+		peers:                  map[*peer.Peer]struct{}{pe: {}},
+		infoDownloaders:        make(map[*peer.Peer]*infodownloader.InfoDownloader),
+		infoDownloaderTimeoutC: make(chan *infodownloader.InfoDownloader),
+		metadataFailures:       make(map[string]int),
+	}
+	tor.config.MetadataDownloadTimeout = time.Minute
+	tor.config.MaxMetadataFailures = 2
+
+	id := tor.nextInfoDownload()
+	if id == nil || id.Peer != pe {
+		t.Fatal("peer is not selected for metadata download")
+	}
+	id.Close()
+
+	// Peers that have failed too many times are not asked again.
+	tor.metadataFailures[pe.IP()] = 2
+	if id = tor.nextInfoDownload(); id != nil {
+		t.Fatal("peer that failed too many times is selected")
+	}
+
+	// Peers advertising huge metadata are not used.
+	delete(tor.metadataFailures, pe.IP())
+	pe.ExtensionHandshake.MetadataSize = maxMetadataSize + 1
+	if id = tor.nextInfoDownload(); id != nil {
+		t.Fatal("peer with huge metadata is selected")
+	}
+}
@@ -0,0 +1,32 @@
+package session
+
+import (
+	"net"
+
+	"github.com/cenkalti/rain/internal/lsd"
+)
+
+type lsdAnnouncer struct {
+	node     *lsd.LSD
+	infoHash [20]byte
+	port     int
+	peersC   chan []*net.TCPAddr
+}
+
+func newLSDAnnouncer(node *lsd.LSD, infoHash []byte, port int) *lsdAnnouncer {
+	a := &lsdAnnouncer{
+		node:   node,
+		port:   port,
+		peersC: make(chan []*net.TCPAddr),
+	}
+	copy(a.infoHash[:], infoHash)
+	return a
+}
+
+func (a *lsdAnnouncer) Announce() {
+	a.node.Announce(a.infoHash, a.port)
+}
+
+func (a *lsdAnnouncer) Peers() chan []*net.TCPAddr {
+	return a.peersC
+}
@@ -0,0 +1,56 @@
+package session
+
+import (
+	"errors"
+
+	"github.com/cenkalti/rain/internal/resumer"
+)
+
+type maxPeersRequest struct {
+	// Nil means the limits in config are used.
+	MaxPeers *resumer.MaxPeers
+	Response chan error
+}
+
+// SetMaxPeers sets the max number of incoming and outgoing connections of the torrent.
+func (t *torrent) SetMaxPeers(accept, dial int) error {
+	if accept < 0 || dial < 0 {
+		return errors.New("max peers cannot be negative")
+	}
+	return t.sendMaxPeers(&resumer.MaxPeers{Accept: accept, Dial: dial})
+}
+
+// ResetMaxPeers removes the connection limits set with SetMaxPeers.
+func (t *torrent) ResetMaxPeers() error {
+	return t.sendMaxPeers(nil)
+}
+
+func (t *torrent) sendMaxPeers(value *resumer.MaxPeers) error {
+	req := maxPeersRequest{MaxPeers: value, Response: make(chan error, 1)}
+	select {
+	case t.maxPeersCommandC <- req:
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+	select {
+	case err := <-req.Response:
+		return err
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+}
+
+func (t *torrent) setMaxPeers(value *resumer.MaxPeers) error {
+	if t.resume != nil {
+		err := t.resume.WriteMaxPeers(value)
+		if err != nil {
+			return err
+		}
+	}
+	t.maxPeers = value
+	// Existing connections are kept if the limits are lowered.
+	if t.errC != nil {
+		t.dialAddresses()
+	}
+	return nil
+}
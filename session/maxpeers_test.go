@@ -0,0 +1,139 @@
+package session
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/addrlist"
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/resumer"
+)
+
+func TestMaxPeerLimits(t *testing.T) {
+	tor := &torrent{config: DefaultConfig}
+	tor.config.MaxPeerAccept = 10
+	tor.config.MaxPeerDial = 20
+	tor.config.SeedPhaseMaxDial = 5
+	if tor.maxPeerAccept() != 10 || tor.maxPeerDial() != 20 {
+		t.Fatal("limits in config are not used")
+	}
+	tor.completed = true
+	if tor.maxPeerDial() != 5 {
+		t.Fatal("seed phase limit is not used")
+	}
+	tor.maxPeers = &resumer.MaxPeers{Accept: 1, Dial: 2}
+	if tor.maxPeerAccept() != 1 || tor.maxPeerDial() != 2 {
+		t.Fatal("torrent limits do not override limits in config")
+	}
+}
+
+func TestPhasePeerLimits(t *testing.T) {
+	tor := &torrent{config: DefaultConfig}
+	tor.config.MaxPeerAccept = 10
+	tor.config.MaxPeerDial = 20
+	tor.config.DownloadPhaseMaxAccept = 30
+	tor.config.DownloadPhaseMaxDial = 40
+	tor.config.SeedPhaseMaxAccept = 50
+	tor.config.SeedPhaseMaxDial = 0
+	if tor.maxPeerAccept() != 30 || tor.maxPeerDial() != 40 {
+		t.Fatal("download phase limits are not used")
+	}
+	tor.completed = true
+	if tor.maxPeerAccept() != 50 {
+		t.Fatal("seed phase accept limit is not used")
+	}
+	if tor.maxPeerDial() != 0 {
+		t.Fatal("seeds must not dial when seed phase dial limit is not set")
+	}
+	// Zero accept limits fall back to MaxPeerAccept.
+	tor.config.SeedPhaseMaxAccept = 0
+	if tor.maxPeerAccept() != 10 {
+		t.Fatal("MaxPeerAccept is not used in seed phase")
+	}
+	tor.completed = false
+	tor.config.DownloadPhaseMaxAccept = 0
+	tor.config.DownloadPhaseMaxDial = 0
+	if tor.maxPeerAccept() != 10 || tor.maxPeerDial() != 20 {
+		t.Fatal("MaxPeerAccept and MaxPeerDial are not used in download phase")
+	}
+}
+
+func TestSeedPhaseNewPeers(t *testing.T) {
+	var clientIP net.IP
+	tor := &torrent{
+		config:           DefaultConfig,
+		info:             &metainfo.Info{},
+		errC:             make(chan error, 1),
+		completed:        true,
+		connectedPeerIPs: make(map[string]struct{}),
+		log:              logger.New("test"),
+	}
+	tor.addrList = addrlist.New(tor.config.MaxPeerAddresses, nil, 0, &clientIP)
+	// Addresses are kept in the list instead of being dialed.
+	tor.config.DialInterval = time.Hour
+	tor.lastDial = time.Now()
+	defer tor.stopDialTimer()
+	addrs := []*net.TCPAddr{{IP: net.IPv4(192, 0, 2, 1), Port: 6881}}
+
+	tor.handleNewPeers(addrs, addrlist.Tracker)
+	if n := tor.addrList.Len(); n != 0 {
+		t.Fatalf("addresses are added for seed: %d", n)
+	}
+	tor.config.SeedPhaseMaxDial = 1
+	tor.handleNewPeers(addrs, addrlist.Tracker)
+	if n := tor.addrList.Len(); n != 1 {
+		t.Fatalf("addresses are not added when seed phase dial limit is set: %d", n)
+	}
+}
+
+func TestSetMaxPeers(t *testing.T) {
+	s, closeSession := newTestSession(t, nil)
+	// Session is closed and loaded again by the test. Only the temporary directory needs to be removed.
+	defer os.RemoveAll(filepath.Dir(s.config.Database))
+	closed := false
+	defer func() {
+		if !closed {
+			closeSession()
+		}
+	}()
+
+	tor, err := s.AddURIOptions("magnet:?xt=urn:btih:0102030405060708090a0b0c0d0e0f1011121314", &AddOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tor.SetMaxPeers(-1, 1); err == nil {
+		t.Fatal("negative limit must be rejected")
+	}
+	if err = tor.SetMaxPeers(3, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	// Limits are saved in the database.
+	closed = true
+	s.Close()
+	s, err = New(s.config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor = s.GetTorrent(tor.ID())
+	if mp := tor.torrent.maxPeers; mp == nil || mp.Accept != 3 || mp.Dial != 4 {
+		t.Fatalf("unexpected max peers after loading session: %v", mp)
+	}
+
+	if err = tor.ResetMaxPeers(); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	s, err = New(s.config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if mp := s.GetTorrent(tor.ID()).torrent.maxPeers; mp != nil {
+		t.Fatalf("max peers is not reset: %v", mp)
+	}
+}
@@ -3,9 +3,9 @@ package session
 import (
 	"bytes"
 	"crypto/sha1" // nolint: gosec
-	"errors"
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/cenkalti/rain/internal/addrlist"
 	"github.com/cenkalti/rain/internal/bitfield"
@@ -23,13 +23,13 @@ func (t *torrent) handlePieceMessage(pm peer.PieceMessage) {
 	pe := pm.Peer
 	if t.pieces == nil || t.bitfield == nil {
 		pe.Logger().Error("piece received but we don't have info")
-		t.resumerStats.BytesWasted += int64(len(msg.Data))
+		t.wasteBytes(int64(len(msg.Data)), &t.bytesWasted.Invalid)
 		t.closePeer(pe)
 		return
 	}
 	if msg.Index >= uint32(len(t.pieces)) {
 		pe.Logger().Errorln("invalid piece index:", msg.Index)
-		t.resumerStats.BytesWasted += int64(len(msg.Data))
+		t.wasteBytes(int64(len(msg.Data)), &t.bytesWasted.Invalid)
 		t.closePeer(pe)
 		return
 	}
@@ -37,28 +37,56 @@ func (t *torrent) handlePieceMessage(pm peer.PieceMessage) {
 	block := piece.Blocks.Find(msg.Begin, uint32(len(msg.Data)))
 	if block == nil {
 		pe.Logger().Errorln("invalid piece begin:", msg.Begin, "length:", len(msg.Data))
-		t.resumerStats.BytesWasted += int64(len(msg.Data))
+		t.wasteBytes(int64(len(msg.Data)), &t.bytesWasted.Invalid)
 		t.closePeer(pe)
 		return
 	}
 	t.downloadSpeed.Update(int64(len(msg.Data)))
+	pe.DownloadSpeed.Update(int64(len(msg.Data)))
+	t.resumerStats.LastActivity = time.Now()
+	pe.LastDataAt = t.resumerStats.LastActivity
 	t.resumerStats.BytesDownloaded += int64(len(msg.Data))
 	pe.BytesDownlaodedInChokePeriod += int64(len(msg.Data))
+	pe.Productive = true
 	pd, ok := t.pieceDownloaders[pe]
 	if !ok {
-		t.resumerStats.BytesWasted += int64(len(msg.Data))
+		if pe.PeerChoking {
+			// Block was in flight when the peer choked us and the download is stopped.
+			t.wasteBytes(int64(len(msg.Data)), &t.bytesWasted.Choked)
+		} else {
+			t.wasteBytes(int64(len(msg.Data)), &t.bytesWasted.Duplicate)
+		}
 		peerreader.PiecePool.Put(msg.Data)
 		return
 	}
 	if pd.Piece.Index != msg.Index {
-		t.resumerStats.BytesWasted += int64(len(msg.Data))
+		t.wasteBytes(int64(len(msg.Data)), &t.bytesWasted.Duplicate)
+		peerreader.PiecePool.Put(msg.Data)
+		return
+	}
+	if pd.Received(block.Index) {
+		// Block may be received from another peer in endgame mode before the cancel message is sent.
+		t.wasteBytes(int64(len(msg.Data)), &t.bytesWasted.Duplicate)
 		peerreader.PiecePool.Put(msg.Data)
 		return
 	}
 	pd.GotBlock(block, msg.Data)
+	if t.piecePicker != nil && t.piecePicker.Endgame() {
+		// Share the block with other downloaders of the same piece and cancel their requests for it.
+		for pe2 := range t.piecePicker.RequestedPeers(piece.Index) {
+			pd2, ok := t.pieceDownloaders[pe2]
+			if !ok || pd2 == pd {
+				continue
+			}
+			pd2.CancelBlock(block, msg.Data, pe)
+			if !pd.Done() && pd2.Done() {
+				pd = pd2
+			}
+		}
+	}
 	peerreader.PiecePool.Put(msg.Data)
 	if !pd.Done() {
-		pd.RequestBlocks(t.config.RequestQueueLength)
+		pd.RequestBlocks(t.requestQueueLength(pe))
 		pe.ResetSnubTimer()
 		return
 	}
@@ -68,10 +96,14 @@ func (t *torrent) handlePieceMessage(pm peer.PieceMessage) {
 
 	ok = piece.VerifyHash(pd.Buffer[:pd.Piece.Length], sha1.New()) // nolint: gosec
 	if !ok {
-		t.resumerStats.BytesWasted += int64(len(msg.Data))
-		// TODO ban peers that sent corrupt piece
+		t.wasteBytes(int64(pd.Piece.Length), &t.bytesWasted.HashFailed)
 		t.log.Error("received corrupt piece")
-		t.closePeer(pd.Peer)
+		t.handleBadPiece(pd.Contributors())
+		// The peer that sent the last block is disconnected even if banning is disabled.
+		// pd may belong to another peer in endgame mode so the sender is not pd.Peer.
+		if _, ok := t.peers[pe]; ok {
+			t.closePeer(pe)
+		}
 		t.startPieceDownloaders()
 		return
 	}
@@ -89,11 +121,8 @@ func (t *torrent) handlePieceMessage(pm peer.PieceMessage) {
 	}
 	piece.Writing = true
 
-	t.blockPieceMessages = t.pieceMessages
-	t.pieceMessages = nil
-
 	pw := piecewriter.New(piece, pd.Buffer, pd.Piece.Length)
-	go pw.Run(t.pieceWriterResultC)
+	t.startPieceWriter(pw)
 
 	t.startPieceDownloaders()
 }
@@ -108,13 +137,19 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 			break
 		}
 		if msg.Index >= t.info.NumPieces {
-			pe.Logger().Errorln("unexpected piece index:", msg.Index)
-			t.closePeer(pe)
+			t.banPeer(pe, fmt.Sprintf("have message for invalid piece index: %d", msg.Index))
 			break
 		}
 		pi := &t.pieces[msg.Index]
 		// pe.Logger().Debug("Peer ", pe.String(), " has piece #", pi.Index)
 		if t.piecePicker != nil {
+			if t.piecePicker.DoesHave(pe, pi.Index) {
+				pe.RedundantHaves++
+				if t.config.MaxRedundantHaves > 0 && pe.RedundantHaves > t.config.MaxRedundantHaves {
+					t.banPeer(pe, "too many redundant have messages")
+				}
+				break
+			}
 			t.piecePicker.HandleHave(pe, pi.Index)
 		}
 		t.updateInterestedState(pe)
@@ -172,7 +207,7 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 	case peerprotocol.UnchokeMessage:
 		pe.PeerChoking = false
 		if pd, ok := t.pieceDownloaders[pe]; ok {
-			pd.RequestBlocks(t.config.RequestQueueLength)
+			pd.RequestBlocks(t.requestQueueLength(pe))
 		}
 		t.startPieceDownloaders()
 	case peerprotocol.ChokeMessage:
@@ -203,7 +238,8 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 			break
 		}
 		pi := &t.pieces[msg.Index]
-		if pe.AmChoking {
+		// Completed pieces are served while downloading, but a piece that is not verified yet must not be sent.
+		if pe.AmChoking || !pi.Done {
 			if pe.FastExtension {
 				m := peerprotocol.RejectMessage{RequestMessage: msg}
 				pe.SendMessage(m)
@@ -249,8 +285,12 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 		pe.CancelRequest(msg)
 	case peerwriter.BlockUploaded:
 		t.uploadSpeed.Update(int64(msg.Length))
+		pe.UploadSpeed.Update(int64(msg.Length))
+		t.resumerStats.LastActivity = time.Now()
+		pe.LastDataAt = t.resumerStats.LastActivity
 		t.resumerStats.BytesUploaded += int64(msg.Length)
 		pe.BytesUploadedInChokePeriod += int64(msg.Length)
+		pe.Productive = true
 	case peerprotocol.ExtensionHandshakeMessage:
 		pe.Logger().Debugln("extension handshake received:", msg)
 		if pe.ExtensionHandshake != nil {
@@ -258,6 +298,14 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 			break
 		}
 		pe.ExtensionHandshake = &msg
+		if msg.UploadOnly != 0 {
+			pe.UploadOnly = true
+			// No need to keep connections between seeds.
+			if t.completed {
+				t.closePeer(pe)
+				break
+			}
+		}
 
 		if len(msg.YourIP) == 4 {
 			t.externalIP = net.IP(msg.YourIP)
@@ -265,13 +313,7 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 		if _, ok := msg.M[peerprotocol.ExtensionKeyMetadata]; ok {
 			t.startInfoDownloaders()
 		}
-		if t.config.PEXEnabled {
-			if _, ok := msg.M[peerprotocol.ExtensionKeyPEX]; ok {
-				if t.info != nil && t.info.Private != 1 {
-					pe.StartPEX(t.peers)
-				}
-			}
-		}
+		t.startPEX(pe)
 	case peerprotocol.ExtensionMetadataMessage:
 		switch msg.Type {
 		case peerprotocol.ExtensionMetadataMessageTypeRequest:
@@ -283,7 +325,6 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 				t.sendMetadataReject(pe, msg.Piece, extMsgID)
 				break
 			}
-			// TODO Clients MAY implement flood protection by rejecting request messages after a certain number of them have been served. Typically the number of pieces of metadata times a factor.
 			start := 16 * 1024 * msg.Piece
 			end := start + 16*1024
 			totalSize := uint32(len(t.info.Bytes))
@@ -298,6 +339,10 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 				t.sendMetadataReject(pe, msg.Piece, extMsgID)
 				break
 			}
+			if !t.allowMetadataUpload(pe, end-start) {
+				t.sendMetadataReject(pe, msg.Piece, extMsgID)
+				break
+			}
 			data := t.info.Bytes[start:end]
 			dataMsg := peerprotocol.ExtensionMetadataMessage{
 				Type:      peerprotocol.ExtensionMetadataMessageTypeData,
@@ -315,11 +360,15 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 			if !ok {
 				break
 			}
+			if msg.TotalSize != 0 && msg.TotalSize != pe.ExtensionHandshake.MetadataSize {
+				t.metadataBytesWasted += int64(len(msg.Data))
+				t.handleMetadataFailure(id, fmt.Sprintf("inconsistent metadata size: %d", msg.TotalSize))
+				break
+			}
 			err := id.GotBlock(msg.Piece, msg.Data)
 			if err != nil {
-				pe.Logger().Error(err)
-				t.closePeer(pe)
-				t.startInfoDownloaders()
+				t.metadataBytesWasted += int64(len(msg.Data))
+				t.handleMetadataFailure(id, err.Error())
 				break
 			}
 			if !id.Done() {
@@ -332,9 +381,13 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 			hash := sha1.New()                              // nolint: gosec
 			hash.Write(id.Bytes)                            // nolint: gosec
 			if !bytes.Equal(hash.Sum(nil), t.infoHash[:]) { // nolint: gosec
-				pe.Logger().Errorln("received info does not match with hash")
-				t.closePeer(id.Peer)
-				t.startInfoDownloaders()
+				// Peer has sent garbage. It is not connected again for a while and metadata is requested from other peers.
+				d := t.config.BadPeerBanDuration
+				if d < minMetadataBanDuration {
+					d = minMetadataBanDuration
+				}
+				t.tempBanPeerIP(pe.IP(), d)
+				t.handleMetadataFailure(id, "received info does not match with hash")
 				break
 			}
 			t.stopInfoDownloaders()
@@ -346,13 +399,11 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 				t.stop(err)
 				break
 			}
-			if info.Private == 1 {
-				err = errors.New("private torrent from magnet")
-				t.log.Error(err)
-				t.stop(err)
-				break
-			}
 			t.info = info
+			if t.isPrivate() {
+				t.log.Info("metadata is private, disabling DHT, LSD and PEX")
+				t.disablePublicPeerSources()
+			}
 			if t.resume != nil {
 				err = t.resume.WriteInfo(t.info.Bytes)
 				if err != nil {
@@ -361,17 +412,38 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 					t.stop(err)
 					break
 				}
+				if !t.nameSet {
+					err = t.resume.WriteName(sanitizeName(t.info.Name), false)
+					if err != nil {
+						err = fmt.Errorf("cannot write resume name: %s", err)
+						t.log.Error(err)
+						t.stop(err)
+						break
+					}
+				}
 			}
+			t.sendEvent(Event{Type: EventMetadataDownloaded})
 			t.startAllocator()
 		case peerprotocol.ExtensionMetadataMessageTypeReject:
 			id, ok := t.infoDownloaders[pe]
 			if ok {
-				t.closePeer(id.Peer)
-				t.startInfoDownloaders()
+				t.handleMetadataFailure(id, "peer rejected metadata request")
 			}
 		}
+	case peerprotocol.ExtensionUploadOnlyMessage:
+		pe.UploadOnly = msg.UploadOnly
+		if !pe.UploadOnly {
+			break
+		}
+		if t.completed {
+			t.closePeer(pe)
+			break
+		}
+		// Peer is treated like a seed so there is no point in uploading to it.
+		t.chokePeer(pe)
 	case peerprotocol.ExtensionPEXMessage:
-		if !t.config.PEXEnabled {
+		// Addresses are not accepted until metadata is downloaded because the torrent may be private.
+		if t.info == nil || !t.pexEnabled() {
 			break
 		}
 		addrs, err := tracker.DecodePeersCompact([]byte(msg.Added))
@@ -379,6 +451,7 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 			t.log.Error(err)
 			break
 		}
+		t.pexPeersDiscovered += len(addrs)
 		t.handleNewPeers(addrs, addrlist.PEX)
 	default:
 		panic(fmt.Sprintf("unhandled peer message type: %T", msg))
@@ -390,8 +463,11 @@ func (t *torrent) updateInterestedState(pe *peer.Peer) {
 		return
 	}
 	interested := false
-	if !t.completed {
+	if !t.completed && !t.seedOnly {
 		for i := uint32(0); i < t.bitfield.Len(); i++ {
+			if !t.pieceWanted(i) {
+				continue
+			}
 			weHave := t.bitfield.Test(i)
 			peerHave := t.piecePicker.DoesHave(pe, i)
 			if !weHave && peerHave {
@@ -0,0 +1,30 @@
+package session
+
+import (
+	"github.com/cenkalti/rain/internal/peer"
+)
+
+const (
+	metadataPieceSize = 16 * 1024
+
+	// A peer may request each metadata piece at most this many times.
+	// Clients MAY implement flood protection by rejecting request messages
+	// after a certain number of them have been served (BEP 9).
+	metadataRequestFactor = 2
+)
+
+// allowMetadataUpload returns true if n bytes of metadata can be sent to the peer.
+// Both the number of pieces served to a single peer and the total metadata upload rate of torrent are limited.
+func (t *torrent) allowMetadataUpload(pe *peer.Peer, n uint32) bool {
+	numPieces := (len(t.info.Bytes) + metadataPieceSize - 1) / metadataPieceSize
+	if pe.MetadataPiecesServed >= numPieces*metadataRequestFactor {
+		pe.Logger().Debugln("metadata request limit exceeded for peer")
+		return false
+	}
+	if !t.metadataUploadLimiter.Allow(int(n)) {
+		pe.Logger().Debugln("metadata upload rate limit exceeded")
+		return false
+	}
+	pe.MetadataPiecesServed++
+	return true
+}
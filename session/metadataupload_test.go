@@ -0,0 +1,48 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/ratelimit"
+)
+
+func TestAllowMetadataUploadPeerLimit(t *testing.T) {
+	pe, closeConn := newTestPeer(t)
+	defer closeConn()
+
+	tor := &torrent{
+		info: &metainfo.Info{Bytes: make([]byte, metadataPieceSize+1)},
+	}
+	// 2 pieces of metadata can be requested twice each.
+	for i := 0; i < 4; i++ {
+		if !tor.allowMetadataUpload(pe, metadataPieceSize) {
+			t.Fatalf("request %d must be allowed", i)
+		}
+	}
+	if tor.allowMetadataUpload(pe, metadataPieceSize) {
+		t.Fatal("request over limit must be rejected")
+	}
+}
+
+func TestAllowMetadataUploadRateLimit(t *testing.T) {
+	pe1, closeConn1 := newTestPeer(t)
+	defer closeConn1()
+	pe2, closeConn2 := newTestPeer(t)
+	defer closeConn2()
+
+	tor := &torrent{
+		info:                  &metainfo.Info{Bytes: make([]byte, metadataPieceSize)},
+		metadataUploadLimiter: ratelimit.New(metadataPieceSize),
+	}
+	if !tor.allowMetadataUpload(pe1, metadataPieceSize) {
+		t.Fatal("first piece must be allowed")
+	}
+	// Rate limit is shared by all peers of the torrent.
+	if tor.allowMetadataUpload(pe2, metadataPieceSize) {
+		t.Fatal("piece over rate limit must be rejected")
+	}
+	if pe2.MetadataPiecesServed != 0 {
+		t.Fatal("rejected piece must not be counted")
+	}
+}
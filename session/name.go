@@ -0,0 +1,28 @@
+package session
+
+import (
+	"strings"
+	"unicode"
+)
+
+// sanitizeName returns a version of name that is safe to use as a single path element on disk.
+// Torrent names come from untrusted sources (magnet "dn" param, info dict) so they must not be able to
+// escape the data directory with path separators or traversal sequences.
+func sanitizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r == '/' || r == '\\':
+			return '_'
+		case unicode.IsControl(r):
+			return -1
+		default:
+			return r
+		}
+	}, name)
+	name = strings.TrimSpace(name)
+	// A name consisting only of dots refers to the current or parent directory on some systems.
+	if strings.Trim(name, ".") == "" {
+		return ""
+	}
+	return name
+}
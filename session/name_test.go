@@ -0,0 +1,168 @@
+package session
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cenkalti/rain/internal/metainfo"
+)
+
+var sanitizeNameTests = []struct {
+	name     string
+	expected string
+}{
+	{"sample_torrent", "sample_torrent"},
+	{"Some Linux ISO", "Some Linux ISO"},
+	{"", ""},
+	{".", ""},
+	{"..", ""},
+	{"...", ""},
+	{"  ..  ", ""},
+	{"../../etc/passwd", ".._.._etc_passwd"},
+	{"/etc/passwd", "_etc_passwd"},
+	{"..\\..\\windows\\system32", ".._.._windows_system32"},
+	{"foo/../bar", "foo_.._bar"},
+	{"foo\x00bar", "foobar"},
+	{"foo\nbar", "foobar"},
+	{"..foo", "..foo"},
+}
+
+func TestSanitizeName(t *testing.T) {
+	for _, tc := range sanitizeNameTests {
+		if s := sanitizeName(tc.name); s != tc.expected {
+			t.Errorf("sanitizeName(%q) = %q, expected %q", tc.name, s, tc.expected)
+		}
+	}
+}
+
+func TestSetNameSingleFile(t *testing.T) {
+	where, err := ioutil.TempDir("", "rain-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(where)
+
+	tor := &torrent{
+		info:    &metainfo.Info{Name: "file.bin", Length: 1},
+		name:    "file.bin",
+		storage: newFileStorage(t, where),
+	}
+	// Name follows the file until it is set by the user.
+	err = tor.renameFile(0, "renamed.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := tor.displayName(); name != "renamed.bin" {
+		t.Fatalf("invalid name: %q", name)
+	}
+	err = tor.setName("My File")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tor.renameFile(0, "other.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := tor.displayName(); name != "My File" {
+		t.Fatalf("name set by user is overwritten: %q", name)
+	}
+	if p := tor.getFilePaths()[0]; p != "other.bin" {
+		t.Fatalf("invalid file path: %q", p)
+	}
+}
+
+func TestSavedName(t *testing.T) {
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	mi, err := metainfo.New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name     string
+		nameSet  bool
+		expected string
+	}{
+		{"", false, mi.Info.Name},
+		{sanitizeName(mi.Info.Name), false, mi.Info.Name},
+		{"custom name", true, "custom name"},
+	} {
+		opt := options{
+			Name:    tc.name,
+			NameSet: tc.nameSet,
+			Info:    mi.Info,
+		}
+		tor, err := opt.NewTorrent(mi.Info.Hash[:], newFileStorage(t, torrentDataDir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := tor.Stats().Name
+		tor.Close()
+		if name != tc.expected {
+			t.Errorf("name is %q for saved name %q, expected %q", name, tc.name, tc.expected)
+		}
+	}
+}
+
+func TestAddMagnetHostileName(t *testing.T) {
+	s, closeSession := newTestSession(t, nil)
+	defer closeSession()
+
+	for _, dn := range []string{"..%2F..%2Fetc%2Fpasswd", "..", "%2Fetc%2Fpasswd"} {
+		tor, err := s.AddURIOptions("magnet:?xt=urn:btih:0102030405060708090a0b0c0d0e0f1011121314&dn="+dn, &AddOptions{Stopped: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := tor.Name()
+		if strings.ContainsAny(name, "/\\") || name == ".." {
+			t.Errorf("unsafe torrent name: %q", name)
+		}
+		if err = s.RemoveTorrent(tor.ID()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSetNameMagnetSaved(t *testing.T) {
+	s, closeSession := newTestSession(t, nil)
+	// Session is closed and loaded again by the test. Only the temporary directory needs to be removed.
+	defer os.RemoveAll(filepath.Dir(s.config.Database))
+	closed := false
+	defer func() {
+		if !closed {
+			closeSession()
+		}
+	}()
+
+	tor, err := s.AddURIOptions("magnet:?xt=urn:btih:0102030405060708090a0b0c0d0e0f1011121314&dn=magnet", &AddOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tor.SetName("custom name")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Name set before metadata is downloaded is still set by the user after loading the session.
+	closed = true
+	s.Close()
+	s, err = New(s.config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	loaded := s.GetTorrent(tor.ID())
+	if name := loaded.Name(); name != "custom name" {
+		t.Fatalf("invalid name after loading session: %q", name)
+	}
+	if !loaded.torrent.nameSet {
+		t.Fatal("name is not marked as set by the user after loading session")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"math/rand"
 	"net"
+	"time"
 
 	"github.com/cenkalti/rain/internal/addrlist"
 	"github.com/cenkalti/rain/internal/allocator"
@@ -21,35 +22,82 @@ import (
 	"github.com/cenkalti/rain/internal/piececache"
 	"github.com/cenkalti/rain/internal/piecedownloader"
 	"github.com/cenkalti/rain/internal/piecewriter"
+	"github.com/cenkalti/rain/internal/ratelimit"
 	"github.com/cenkalti/rain/internal/resumer"
 	"github.com/cenkalti/rain/internal/storage"
 	"github.com/cenkalti/rain/internal/tracker"
 	"github.com/cenkalti/rain/internal/verifier"
+	"github.com/cenkalti/rain/internal/webseed"
 	"github.com/rcrowley/go-metrics"
 )
 
 // options for creating a new Torrent.
 type options struct {
+	// ID of the torrent in Session. Set in events sent by the torrent.
+	ID string
 	// Display name
 	Name string
+	// Name is set by the user with SetName.
+	NameSet bool
 	// Peer listen port. Random port will be picked if zero.
 	Port int
 	// HTTP and UDP trackers
 	Trackers []tracker.Tracker
+	// Web seed URLs.
+	WebSeeds []string
 	// Optional resumer that saves fast resume data.
 	Resumer resumer.Resumer
 	// Info dict of torrent file. May be nil for magnet links.
 	Info *metainfo.Info
+	// Locations of files relative to storage root. Paths are generated from Info if nil.
+	FilePaths []string
 	// Marks downloaded pieces for fast resuming. May be nil.
 	Bitfield *bitfield.Bitfield
+	// Do not upload any data to peers.
+	UploadDisabled bool
+	// Download priorities of pieces. Must have a value for each piece if not nil.
+	PiecePriorities []uint8
+	// Download priorities of files. Must have a value for each file if not nil.
+	FilePriorities []uint8
+	// Do not download any pieces. Only the pieces that are already present are uploaded.
+	SeedOnly bool
+	// Do not exchange peers with PEX messages.
+	PEXDisabled bool
+	// Seeding limits that override the limits in Config. May be nil.
+	SeedGoal *resumer.SeedGoal
+	// Connection limits that override the limits in Config. May be nil.
+	MaxPeers *resumer.MaxPeers
+	// Peers that data has been exchanged in previous runs.
+	Peers []resumer.Peer
 	// Initial stats from previous runs.
 	Stats resumer.Stats
 	// Config for downloading torrent. DefaultOptions will be used if nil.
 	Config *Config
 	// Optional DHT node
 	DHT *dhtAnnouncer
+	// Optional Local Service Discovery node
+	LSD *lsdAnnouncer
 	// Optional blocklist to prevent connection to blocked IP addresses.
 	Blocklist *blocklist.Blocklist
+	// Optional pool for hashing pieces. If nil, pieces are hashed in verifier goroutine.
+	VerifierPool *verifier.Pool
+	// Optional cache for piece read requests shared by torrents. If nil, a cache of Config.ReadCacheSize is created.
+	PieceCache *piececache.Cache
+	// Optional queue for limiting the number of torrents verifying at the same time.
+	VerifierQueue *verifier.Queue
+	// Optional limiters for bandwidth shared by all torrents.
+	DownloadLimiter *ratelimit.Limiter
+	UploadLimiter   *ratelimit.Limiter
+	// Optional semaphore limiting concurrent piece writes of all torrents.
+	WriteSemaphore chan struct{}
+	// Optional semaphore limiting concurrent incoming handshakes of all torrents.
+	Handshakes handshakeSemaphore
+	// Optional semaphore limiting half-open outgoing connections of all torrents.
+	HalfOpen handshakeSemaphore
+	// Optional channel for sending events. Events are dropped if the channel is full.
+	Events chan Event
+	// Problem found in resume data. Torrent is created in stopped state with this error.
+	Error error
 }
 
 // NewTorrent creates a new torrent that downloads the torrent with infoHash and saves the files to the storage.
@@ -69,13 +117,27 @@ func (o *options) NewTorrent(infoHash []byte, sto storage.Storage) (*torrent, er
 	copy(ih[:], infoHash)
 	t := &torrent{
 		config:                    *cfg,
+		id:                        o.ID,
 		infoHash:                  ih,
 		trackers:                  o.Trackers,
+		webseedURLs:               o.WebSeeds,
+		webseedClient:             newWebseedClient(net.ParseIP(cfg.ListenAddress)),
+		webseedDownloaders:        make(map[string]*webseed.Downloader),
+		webseedDisabledUntil:      make(map[string]time.Time),
+		webseedResultC:            make(chan *webseed.Downloader),
 		name:                      o.Name,
 		storage:                   sto,
 		port:                      o.Port,
 		resume:                    o.Resumer,
 		info:                      o.Info,
+		filePaths:                 o.FilePaths,
+		uploadDisabled:            o.UploadDisabled,
+		piecePriorities:           o.PiecePriorities,
+		filePriorities:            o.FilePriorities,
+		seedOnly:                  o.SeedOnly,
+		pexDisabled:               o.PEXDisabled,
+		seedGoal:                  o.SeedGoal,
+		maxPeers:                  o.MaxPeers,
 		bitfield:                  o.Bitfield,
 		log:                       logger.New("torrent " + logName),
 		peerDisconnectedC:         make(chan *peer.Peer),
@@ -85,6 +147,7 @@ func (o *options) NewTorrent(infoHash []byte, sto storage.Storage) (*torrent, er
 		incomingPeers:             make(map[*peer.Peer]struct{}),
 		outgoingPeers:             make(map[*peer.Peer]struct{}),
 		peersSnubbed:              make(map[*peer.Peer]struct{}),
+		snubPenalties:             make(map[string]snubPenalty),
 		pieceDownloaders:          make(map[*peer.Peer]*piecedownloader.PieceDownloader),
 		pieceDownloadersSnubbed:   make(map[*peer.Peer]*piecedownloader.PieceDownloader),
 		pieceDownloadersChoked:    make(map[*peer.Peer]*piecedownloader.PieceDownloader),
@@ -92,9 +155,14 @@ func (o *options) NewTorrent(infoHash []byte, sto storage.Storage) (*torrent, er
 		infoDownloaders:           make(map[*peer.Peer]*infodownloader.InfoDownloader),
 		infoDownloadersSnubbed:    make(map[*peer.Peer]*infodownloader.InfoDownloader),
 		pieceWriterResultC:        make(chan *piecewriter.PieceWriter),
+		pieceWriters:              make(map[*piecewriter.PieceWriter]struct{}),
+		discardedPieceWriters:     make(map[*piecewriter.PieceWriter]struct{}),
 		optimisticUnchokedPeers:   make([]*peer.Peer, 0, cfg.OptimisticUnchokedPeers),
 		completeC:                 make(chan struct{}),
 		closeC:                    make(chan chan struct{}),
+		doneC:                     make(chan struct{}),
+		webhookStopC:              make(chan struct{}),
+		metadataUploadLimiter:     ratelimit.New(cfg.MetadataUploadRateLimit),
 		startCommandC:             make(chan struct{}),
 		stopCommandC:              make(chan struct{}),
 		statsCommandC:             make(chan statsRequest),
@@ -103,13 +171,40 @@ func (o *options) NewTorrent(infoHash []byte, sto storage.Storage) (*torrent, er
 		notifyErrorCommandC:       make(chan notifyErrorCommand),
 		notifyListenCommandC:      make(chan notifyListenCommand),
 		addPeersCommandC:          make(chan []*net.TCPAddr),
+		pingCommandC:              make(chan struct{}),
+		renameCommandC:            make(chan renameRequest),
+		setNameCommandC:           make(chan renameRequest),
+		renameFileCommandC:        make(chan renameFileRequest),
+		addrListCommandC:          make(chan addrListRequest),
+		setUploadEnabledCommandC:  make(chan setUploadEnabledRequest),
+		resetStatsCommandC:        make(chan resetStatsRequest),
+		piecePrioritiesCommandC:   make(chan piecePrioritiesRequest),
+		seedOnlyCommandC:          make(chan seedOnlyRequest),
+		seedGoalCommandC:          make(chan seedGoalRequest),
+		maxPeersCommandC:          make(chan maxPeersRequest),
+		announceNowCommandC:       make(chan announceNowRequest),
+		pexCommandC:               make(chan pexRequest),
+		infoCommandC:              make(chan infoRequest),
+		scrapeResultCommandC:      make(chan ScrapeResult),
+		filesCommandC:             make(chan filesRequest),
+		filePriorityCommandC:      make(chan filePriorityRequest),
+		activeDownloadsCommandC:   make(chan activeDownloadsRequest),
+		torrentFileCommandC:       make(chan torrentFileRequest),
+		magnetCommandC:            make(chan magnetRequest),
+		verifyCommandC:            make(chan verifyRequest),
+		zeroPeersBackoff:          newZeroPeersBackoff(),
 		addrsFromTrackers:         make(chan []*net.TCPAddr),
+		trackerStatusC:            make(chan announcer.StatusChange, trackerStatusBufferSize),
+		events:                    o.Events,
+		torrentEvents:             make(chan Event, torrentEventBufferSize),
 		peerIDs:                   make(map[[20]byte]struct{}),
 		incomingConnC:             make(chan net.Conn),
 		sKeyHash:                  mse.HashSKey(ih[:]),
 		infoDownloaderResultC:     make(chan *infodownloader.InfoDownloader),
+		infoDownloaderTimeoutC:    make(chan *infodownloader.InfoDownloader),
+		metadataFailures:          make(map[string]int),
 		incomingHandshakers:       make(map[*incominghandshaker.IncomingHandshaker]struct{}),
-		outgoingHandshakers:       make(map[*outgoinghandshaker.OutgoingHandshaker]struct{}),
+		outgoingHandshakers:       make(map[*outgoinghandshaker.OutgoingHandshaker]addrlist.PeerSource),
 		incomingHandshakerResultC: make(chan *incominghandshaker.IncomingHandshaker),
 		outgoingHandshakerResultC: make(chan *outgoinghandshaker.OutgoingHandshaker),
 		announcerRequestC:         make(chan *announcer.Request),
@@ -118,16 +213,38 @@ func (o *options) NewTorrent(infoHash []byte, sto storage.Storage) (*torrent, er
 		verifierProgressC:         make(chan verifier.Progress),
 		verifierResultC:           make(chan *verifier.Verifier),
 		connectedPeerIPs:          make(map[string]struct{}),
+		bannedPeerIPs:             make(map[string]struct{}),
+		badPieces:                 make(map[string]int),
+		tempBannedPeerIPs:         make(map[string]time.Time),
 		announcersStoppedC:        make(chan struct{}),
 		dhtNode:                   o.DHT,
-		pieceCache:                piececache.New(cfg.PieceCacheSize, cfg.PieceCacheTTL),
+		lsdNode:                   o.LSD,
+		pieceCache:                o.PieceCache,
+		readSemaphore:             make(chan struct{}, cfg.DiskReadConcurrency),
 		resumerStats:              o.Stats,
+		rememberedPeers:           o.Peers,
 		blocklist:                 o.Blocklist,
+		verifierPool:              o.VerifierPool,
+		verifierQueue:             o.VerifierQueue,
+		downloadLimiter:           o.DownloadLimiter,
+		uploadLimiter:             o.UploadLimiter,
+		lastError:                 o.Error,
+		handshakes:                o.Handshakes,
+		halfOpen:                  o.HalfOpen,
+		writeSemaphore:            o.WriteSemaphore,
 		externalIP:                externalip.FirstExternalIP(),
 		downloadSpeed:             metrics.NewEWMA1(),
 		uploadSpeed:               metrics.NewEWMA1(),
 	}
+	if ip := net.ParseIP(cfg.ListenAddress); ip != nil && !ip.IsUnspecified() {
+		// Our own address is the one we are listening on. It is not dialed if received from trackers or peers.
+		t.externalIP = ip
+	}
 	t.addrList = addrlist.New(cfg.MaxPeerAddresses, o.Blocklist, o.Port, &t.externalIP)
+	t.nameSet = o.NameSet
+	if t.pieceCache == nil {
+		t.pieceCache = piececache.New(cfg.ReadCacheSize, cfg.PieceCacheTTL)
+	}
 	copy(t.peerID[:], []byte(cfg.PeerIDPrefix))
 	t.piecePool.New = func() interface{} {
 		return make([]byte, t.info.PieceLength)
@@ -139,6 +256,9 @@ func (o *options) NewTorrent(infoHash []byte, sto storage.Storage) (*torrent, er
 	if t.dhtNode != nil {
 		t.dhtPeersC = t.dhtNode.Peers()
 	}
+	if t.lsdNode != nil {
+		t.lsdPeersC = t.lsdNode.Peers()
+	}
 	go t.run()
 	return t, nil
 }
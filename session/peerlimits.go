@@ -0,0 +1,30 @@
+package session
+
+// maxPeerDial returns the max number of outgoing connections for the current phase of the torrent.
+func (t *torrent) maxPeerDial() int {
+	if t.maxPeers != nil {
+		return t.maxPeers.Dial
+	}
+	if t.completed {
+		return t.config.SeedPhaseMaxDial
+	}
+	if t.config.DownloadPhaseMaxDial > 0 {
+		return t.config.DownloadPhaseMaxDial
+	}
+	return t.config.MaxPeerDial
+}
+
+// maxPeerAccept returns the max number of incoming connections for the current phase of the torrent.
+func (t *torrent) maxPeerAccept() int {
+	if t.maxPeers != nil {
+		return t.maxPeers.Accept
+	}
+	if t.completed {
+		if t.config.SeedPhaseMaxAccept > 0 {
+			return t.config.SeedPhaseMaxAccept
+		}
+	} else if t.config.DownloadPhaseMaxAccept > 0 {
+		return t.config.DownloadPhaseMaxAccept
+	}
+	return t.config.MaxPeerAccept
+}
@@ -0,0 +1,69 @@
+package session
+
+import (
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+)
+
+type pexRequest struct {
+	Enabled  bool
+	Response chan error
+}
+
+// SetPEX enables or disables peer exchange for the torrent.
+func (t *torrent) SetPEX(enabled bool) error {
+	req := pexRequest{Enabled: enabled, Response: make(chan error, 1)}
+	select {
+	case t.pexCommandC <- req:
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+	select {
+	case err := <-req.Response:
+		return err
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+}
+
+func (t *torrent) setPEX(enabled bool) error {
+	if t.pexDisabled == !enabled {
+		return nil
+	}
+	if t.resume != nil {
+		err := t.resume.WritePEXDisabled(!enabled)
+		if err != nil {
+			return err
+		}
+	}
+	t.pexDisabled = !enabled
+	for pe := range t.peers {
+		if t.pexEnabled() {
+			t.startPEX(pe)
+		} else {
+			pe.StopPEX()
+		}
+	}
+	return nil
+}
+
+// pexEnabled returns true if peers can be exchanged with PEX messages.
+// PEX is never used for private torrents.
+// It returns true before metadata is downloaded, so callers must check t.info before exchanging peers.
+func (t *torrent) pexEnabled() bool {
+	if !t.config.PEXEnabled || t.pexDisabled {
+		return false
+	}
+	return t.info == nil || t.info.Private != 1
+}
+
+// startPEX starts sending PEX messages to the peer if it supports PEX.
+// PEX is not started until metadata is downloaded because the torrent may be private.
+func (t *torrent) startPEX(pe *peer.Peer) {
+	if t.info == nil || !t.pexEnabled() || pe.ExtensionHandshake == nil {
+		return
+	}
+	if _, ok := pe.ExtensionHandshake.M[peerprotocol.ExtensionKeyPEX]; ok {
+		pe.StartPEX(t.peers)
+	}
+}
@@ -0,0 +1,81 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cenkalti/rain/internal/metainfo"
+)
+
+func TestPEXEnabled(t *testing.T) {
+	tor := &torrent{config: DefaultConfig}
+	tor.config.PEXEnabled = true
+	if !tor.pexEnabled() {
+		t.Fatal("PEX must be enabled before metadata is downloaded")
+	}
+	tor.info = &metainfo.Info{Private: 1}
+	if tor.pexEnabled() {
+		t.Fatal("PEX must be disabled for private torrents")
+	}
+	tor.info.Private = 0
+	if !tor.pexEnabled() {
+		t.Fatal("PEX must be enabled for public torrents")
+	}
+	tor.pexDisabled = true
+	if tor.pexEnabled() {
+		t.Fatal("PEX must be disabled when disabled for torrent")
+	}
+	tor.pexDisabled = false
+	tor.config.PEXEnabled = false
+	if tor.pexEnabled() {
+		t.Fatal("PEX must be disabled when disabled in config")
+	}
+}
+
+func TestSetPEX(t *testing.T) {
+	s, closeSession := newTestSession(t, func(cfg *Config) {
+		cfg.PEXEnabled = true
+	})
+	// Session is closed and loaded again by the test. Only the temporary directory needs to be removed.
+	defer os.RemoveAll(filepath.Dir(s.config.Database))
+	closed := false
+	defer func() {
+		if !closed {
+			closeSession()
+		}
+	}()
+
+	tor, err := s.AddURIOptions("magnet:?xt=urn:btih:0102030405060708090a0b0c0d0e0f1011121314", &AddOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tor.Stats().PEX.Enabled {
+		t.Fatal("PEX is not enabled by default")
+	}
+	if err = tor.SetPEX(false); err != nil {
+		t.Fatal(err)
+	}
+	if tor.Stats().PEX.Enabled {
+		t.Fatal("PEX is not disabled")
+	}
+
+	// Setting is saved in the database.
+	closed = true
+	s.Close()
+	s, err = New(s.config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	tor = s.GetTorrent(tor.ID())
+	if tor.Stats().PEX.Enabled {
+		t.Fatal("PEX is enabled after loading session")
+	}
+	if err = tor.SetPEX(true); err != nil {
+		t.Fatal(err)
+	}
+	if !tor.Stats().PEX.Enabled {
+		t.Fatal("PEX is not enabled again")
+	}
+}
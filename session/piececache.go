@@ -2,7 +2,6 @@ package session
 
 import (
 	"encoding/binary"
-	"sync"
 
 	"github.com/cenkalti/rain/internal/piece"
 	"github.com/cenkalti/rain/internal/piececache"
@@ -11,16 +10,18 @@ import (
 type cachedPiece struct {
 	pi       *piece.Piece
 	cache    *piececache.Cache
+	infoHash [20]byte
 	readSize int64
-	m        *sync.Mutex
+	sem      chan struct{}
 }
 
 func (t *torrent) cachedPiece(pi *piece.Piece) *cachedPiece {
 	return &cachedPiece{
 		pi:       pi,
 		cache:    t.pieceCache,
+		infoHash: t.infoHash,
 		readSize: t.config.PieceReadSize,
-		m:        &t.readMutex,
+		sem:      t.readSemaphore,
 	}
 }
 
@@ -32,15 +33,17 @@ func (c *cachedPiece) ReadAt(p []byte, off int64) (n int, err error) {
 		blkEnd = c.pi.Length
 	}
 
-	key := make([]byte, 8)
-	binary.BigEndian.PutUint32(key, c.pi.Index)
-	binary.BigEndian.PutUint32(key[4:], blk)
+	// Cache is shared by torrents in Session. Keys are prefixed with info hash.
+	key := make([]byte, 28)
+	copy(key, c.infoHash[:])
+	binary.BigEndian.PutUint32(key[20:], c.pi.Index)
+	binary.BigEndian.PutUint32(key[24:], blk)
 
 	buf, err := c.cache.Get(string(key), func() ([]byte, error) {
 		b := make([]byte, blkEnd-blkBegin)
-		c.m.Lock()
+		c.sem <- struct{}{}
 		_, err = c.pi.Data.ReadAt(b, int64(blkBegin))
-		c.m.Unlock()
+		<-c.sem
 		return b, err
 	})
 	if err != nil {
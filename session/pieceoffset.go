@@ -0,0 +1,46 @@
+package session
+
+import "github.com/cenkalti/rain/internal/metainfo"
+
+type infoRequest struct {
+	Response chan *metainfo.Info
+}
+
+// getInfo returns the info dict of the torrent. Info does not change after it is set so it is safe to read outside of run loop.
+func (t *torrent) getInfo() (*metainfo.Info, error) {
+	req := infoRequest{Response: make(chan *metainfo.Info, 1)}
+	select {
+	case t.infoCommandC <- req:
+	case <-t.doneC:
+		return nil, ErrTorrentClosed
+	}
+	select {
+	case info := <-req.Response:
+		if info == nil {
+			return nil, ErrNoMetadata
+		}
+		return info, nil
+	case <-t.doneC:
+		return nil, ErrTorrentClosed
+	}
+}
+
+// PieceForOffset returns the index of the piece that contains the byte at offset in the file at fileIndex.
+func (t *torrent) PieceForOffset(fileIndex int, offset int64) (int, error) {
+	info, err := t.getInfo()
+	if err != nil {
+		return 0, err
+	}
+	index, err := info.PieceForOffset(fileIndex, offset)
+	return int(index), err
+}
+
+// PieceRange returns the indexes of the first and last pieces that contain data of the file at fileIndex.
+func (t *torrent) PieceRange(fileIndex int) (first, last int, err error) {
+	info, err := t.getInfo()
+	if err != nil {
+		return 0, 0, err
+	}
+	f, l, err := info.PieceRange(fileIndex)
+	return int(f), int(l), err
+}
@@ -0,0 +1,23 @@
+package session
+
+import "github.com/cenkalti/rain/internal/piecewriter"
+
+// startPieceWriter writes the downloaded piece to disk in a new goroutine.
+func (t *torrent) startPieceWriter(pw *piecewriter.PieceWriter) {
+	t.pieceWriters[pw] = struct{}{}
+	t.updatePieceMessages()
+	go pw.Run(t.pieceWriterResultC, t.writeSemaphore)
+}
+
+// updatePieceMessages stops receiving piece messages from peers while DiskWriteConcurrency pieces are being written
+// or a failed write is waiting to be retried. Peers block on sending until receiving is enabled again.
+func (t *torrent) updatePieceMessages() {
+	block := len(t.pieceWriters) >= t.config.DiskWriteConcurrency || len(t.pieceWriterRetries) > 0
+	if block && t.pieceMessages != nil {
+		t.blockPieceMessages = t.pieceMessages
+		t.pieceMessages = nil
+	} else if !block && t.blockPieceMessages != nil {
+		t.pieceMessages = t.blockPieceMessages
+		t.blockPieceMessages = nil
+	}
+}
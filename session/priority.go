@@ -0,0 +1,125 @@
+package session
+
+import (
+	"fmt"
+
+	"github.com/cenkalti/rain/internal/piecepicker"
+)
+
+// Priority of a piece. Pieces with higher priority are downloaded first.
+// Pieces with same priority are downloaded in rarest first order.
+type Priority uint8
+
+// Valid values for Priority. Any value between PrioritySkip and PriorityHigh can be used.
+const (
+	// Pieces with PrioritySkip are not downloaded.
+	PrioritySkip   Priority = 0
+	PriorityLow    Priority = 1
+	PriorityNormal Priority = piecepicker.DefaultPriority
+	PriorityHigh   Priority = 7
+)
+
+type piecePrioritiesRequest struct {
+	Priorities map[int]Priority
+	Response   chan error
+}
+
+// SetPiecePriorities changes the priorities of pieces at given indexes.
+func (t *torrent) SetPiecePriorities(priorities map[int]Priority) error {
+	req := piecePrioritiesRequest{Priorities: priorities, Response: make(chan error, 1)}
+	select {
+	case t.piecePrioritiesCommandC <- req:
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+	select {
+	case err := <-req.Response:
+		return err
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+}
+
+func (t *torrent) setPiecePriorities(priorities map[int]Priority) error {
+	if t.info == nil {
+		return ErrNoMetadata
+	}
+	for i, p := range priorities {
+		if i < 0 || i >= int(t.info.NumPieces) {
+			return fmt.Errorf("invalid piece index: %d", i)
+		}
+		if p > PriorityHigh {
+			return fmt.Errorf("invalid priority: %d", p)
+		}
+	}
+	if t.piecePriorities == nil {
+		t.piecePriorities = make([]uint8, t.info.NumPieces)
+		for i := range t.piecePriorities {
+			t.piecePriorities[i] = uint8(PriorityNormal)
+		}
+	}
+	for i, p := range priorities {
+		t.piecePriorities[i] = uint8(p)
+		if t.piecePicker != nil {
+			t.piecePicker.SetPriority(uint32(i), uint8(p))
+		}
+	}
+	if t.resume != nil {
+		err := t.resume.WritePiecePriorities(t.piecePriorities)
+		if err != nil {
+			return err
+		}
+	}
+	if t.bitfield != nil {
+		if t.completed && !t.wantedPiecesDone() {
+			t.uncomplete()
+		} else if !t.completed && t.pieces != nil {
+			// Remaining pieces may have been skipped.
+			if t.checkCompletion() {
+				t.notifyCompletionWebhook()
+			}
+		}
+	}
+	// Skipped pieces may have become wanted.
+	if t.status() == Downloading {
+		t.startPieceDownloaders()
+	}
+	return nil
+}
+
+// pieceWanted returns true if the piece at index is not skipped.
+func (t *torrent) pieceWanted(i uint32) bool {
+	return t.piecePriorities == nil || t.piecePriorities[i] != uint8(PrioritySkip)
+}
+
+// wantedPiecesDone returns true if all pieces except the skipped ones are downloaded.
+func (t *torrent) wantedPiecesDone() bool {
+	if t.bitfield.All() {
+		return true
+	}
+	for i := uint32(0); i < t.bitfield.Len(); i++ {
+		if !t.bitfield.Test(i) && t.pieceWanted(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// uncomplete reverts the completed state of the torrent after skipped pieces become wanted again.
+func (t *torrent) uncomplete() {
+	t.log.Info("skipped pieces are wanted, download resumes")
+	t.updateSeedDuration()
+	t.completed = false
+	t.completeC = make(chan struct{})
+	for pe := range t.peers {
+		t.sendUploadOnly(pe, false)
+		t.updateInterestedState(pe)
+	}
+}
+
+// applyPiecePriorities sets the saved priorities on a new piece picker.
+func (t *torrent) applyPiecePriorities() {
+	for i, p := range t.piecePriorities {
+		t.piecePicker.SetPriority(uint32(i), p)
+	}
+}
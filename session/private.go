@@ -0,0 +1,53 @@
+package session
+
+import "github.com/cenkalti/rain/internal/addrlist"
+
+// isPrivate returns true if the metadata of the torrent is known and it has the private flag set.
+func (t *torrent) isPrivate() bool {
+	return t.info != nil && t.info.Private == 1
+}
+
+// isPublicPeerSource returns true if peers from source must not be used by private torrents.
+func isPublicPeerSource(source addrlist.PeerSource) bool {
+	switch source {
+	case addrlist.DHT, addrlist.LSD, addrlist.PEX:
+		return true
+	default:
+		return false
+	}
+}
+
+// disablePublicPeerSources stops DHT, LSD and PEX for the torrent.
+// It is called when the downloaded metadata of a magnet link turns out to be private.
+// Peers, handshakes and addresses that are found from these sources are dropped.
+func (t *torrent) disablePublicPeerSources() {
+	if t.dhtAnnouncer != nil {
+		t.dhtAnnouncer.Close()
+		t.dhtAnnouncer = nil
+	}
+	if t.lsdAnnouncer != nil {
+		t.lsdAnnouncer.Close()
+		t.lsdAnnouncer = nil
+	}
+	for _, source := range []addrlist.PeerSource{addrlist.DHT, addrlist.LSD, addrlist.PEX} {
+		t.addrList.RemoveSource(source)
+	}
+	for oh, source := range t.outgoingHandshakers {
+		if !isPublicPeerSource(source) {
+			continue
+		}
+		oh.Close()
+		delete(t.outgoingHandshakers, oh)
+		delete(t.connectedPeerIPs, oh.Addr.IP.String())
+		t.halfOpen.release()
+	}
+	for pe := range t.peers {
+		if isPublicPeerSource(pe.Source) {
+			t.closePeer(pe)
+			continue
+		}
+		pe.StopPEX()
+	}
+	// Half-open connection slots may be freed.
+	t.dialAddresses()
+}
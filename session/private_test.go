@@ -0,0 +1,202 @@
+package session
+
+import (
+	"bytes"
+	"crypto/sha1" // nolint: gosec
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/addrlist"
+	"github.com/cenkalti/rain/internal/btconn"
+	"github.com/cenkalti/rain/internal/handshaker/outgoinghandshaker"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/zeebo/bencode"
+)
+
+func TestDisablePublicPeerSourcesClosesHandshakers(t *testing.T) {
+	// Listener accepts connections but never completes the handshake.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	cfg := DefaultConfig
+	cfg.MaxPeerDial = 0
+	cfg.DownloadPhaseMaxDial = 0
+	tor := &torrent{
+		config:              cfg,
+		addrList:            addrlist.New(cfg.MaxPeerAddresses, nil, 0, nil),
+		outgoingHandshakers: make(map[*outgoinghandshaker.OutgoingHandshaker]addrlist.PeerSource),
+		connectedPeerIPs:    make(map[string]struct{}),
+	}
+	// Results are not received, like the run loop of torrent while it is closing handshakers.
+	resultC := make(chan *outgoinghandshaker.OutgoingHandshaker)
+	addr := l.Addr().(*net.TCPAddr)
+	dhtHandshaker := outgoinghandshaker.New(addr, nil)
+	trackerHandshaker := outgoinghandshaker.New(addr, nil)
+	tor.outgoingHandshakers[dhtHandshaker] = addrlist.DHT
+	tor.outgoingHandshakers[trackerHandshaker] = addrlist.Tracker
+	for oh := range tor.outgoingHandshakers {
+		go oh.Run(timeout, timeout, [20]byte{}, [20]byte{}, resultC, ourExtensions, true, false)
+	}
+	defer trackerHandshaker.Close()
+
+	tor.disablePublicPeerSources()
+	if _, ok := tor.outgoingHandshakers[dhtHandshaker]; ok {
+		t.Fatal("handshaker from DHT is not closed")
+	}
+	if _, ok := tor.outgoingHandshakers[trackerHandshaker]; !ok {
+		t.Fatal("handshaker from tracker is closed")
+	}
+}
+
+func TestMagnetResolvesToPrivate(t *testing.T) {
+	where, err := ioutil.TempDir("", "rain-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(where)
+
+	info, err := bencode.EncodeBytes(map[string]interface{}{
+		"name":         "private",
+		"length":       1,
+		"piece length": 16 * 1024,
+		"pieces":       string(make([]byte, sha1.Size)),
+		"private":      1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ih := sha1.Sum(info) // nolint: gosec
+	cfg := DefaultConfig
+	cfg.PEXEnabled = true
+	opt := options{Config: &cfg}
+	tor, err := opt.NewTorrent(ih[:], newFileStorage(t, where))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tor.Close()
+
+	tor.Start()
+	var port int
+	select {
+	case port = <-tor.NotifyListen():
+	case err = <-tor.NotifyError():
+		t.Fatal(err)
+	case <-time.After(timeout):
+		panic("torrent is not ready")
+	}
+
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+	var peerID [20]byte
+	copy(peerID[:], "-RN0000-privatemeta1")
+	var ext [8]byte
+	copy(ext[:], ourExtensions.Bytes())
+	conn, _, _, _, err := btconn.Dial(addr, nil, timeout, timeout, false, false, ext, ih, peerID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	err = conn.SetReadDeadline(time.Now().Add(timeout))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeExtensionMessage(t, conn, peerprotocol.ExtensionMessage{
+		ExtendedMessageID: peerprotocol.ExtensionIDHandshake,
+		Payload: peerprotocol.ExtensionHandshakeMessage{
+			M: map[string]uint8{
+				peerprotocol.ExtensionKeyMetadata: peerprotocol.ExtensionIDMetadata,
+				peerprotocol.ExtensionKeyPEX:      peerprotocol.ExtensionIDPEX,
+			},
+			MetadataSize: uint32(len(info)),
+		},
+	})
+	if msg := readMetadataMessage(t, conn); msg.Type != peerprotocol.ExtensionMetadataMessageTypeRequest {
+		t.Fatalf("unexpected metadata message type: %d", msg.Type)
+	}
+
+	// Addresses from PEX are not accepted before the private flag is known.
+	pex := peerprotocol.ExtensionPEXMessage{Added: string([]byte{127, 0, 0, 2, 0, 1})}
+	writeExtensionMessage(t, conn, peerprotocol.ExtensionMessage{
+		ExtendedMessageID: peerprotocol.ExtensionIDPEX,
+		Payload:           pex,
+	})
+	// Messages are handled in order, so the PEX message is handled once the reject is received.
+	writeExtensionMessage(t, conn, peerprotocol.ExtensionMessage{
+		ExtendedMessageID: peerprotocol.ExtensionIDMetadata,
+		Payload:           peerprotocol.ExtensionMetadataMessage{Type: peerprotocol.ExtensionMetadataMessageTypeRequest},
+	})
+	if msg := readMetadataMessage(t, conn); msg.Type != peerprotocol.ExtensionMetadataMessageTypeReject {
+		t.Fatalf("unexpected metadata message type: %d", msg.Type)
+	}
+	if n := tor.Stats().PEX.Discovered; n != 0 {
+		t.Fatalf("%d peers are discovered with PEX before metadata is downloaded", n)
+	}
+
+	writeExtensionMessage(t, conn, peerprotocol.ExtensionMessage{
+		ExtendedMessageID: peerprotocol.ExtensionIDMetadata,
+		Payload: peerprotocol.ExtensionMetadataMessage{
+			Type:      peerprotocol.ExtensionMetadataMessageTypeData,
+			TotalSize: uint32(len(info)),
+			Data:      info,
+		},
+	})
+	// Addresses from PEX are not accepted after the torrent turns out to be private.
+	writeExtensionMessage(t, conn, peerprotocol.ExtensionMessage{
+		ExtendedMessageID: peerprotocol.ExtensionIDPEX,
+		Payload:           pex,
+	})
+	writeExtensionMessage(t, conn, peerprotocol.ExtensionMessage{
+		ExtendedMessageID: peerprotocol.ExtensionIDMetadata,
+		Payload:           peerprotocol.ExtensionMetadataMessage{Type: peerprotocol.ExtensionMetadataMessageTypeRequest},
+	})
+	readMetadataMessage(t, conn)
+	stats := tor.Stats()
+	if stats.PEX.Enabled {
+		t.Fatal("PEX is enabled for private torrent")
+	}
+	if stats.PEX.Discovered != 0 {
+		t.Fatalf("%d peers are discovered with PEX for private torrent", stats.PEX.Discovered)
+	}
+}
+
+// readMetadataMessage reads messages from conn until a metadata extension message is received.
+func readMetadataMessage(t *testing.T, conn net.Conn) peerprotocol.ExtensionMetadataMessage {
+	for {
+		var length uint32
+		err := binary.Read(conn, binary.BigEndian, &length)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, length)
+		_, err = io.ReadFull(conn, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if length < 2 || peerprotocol.MessageID(buf[0]) != peerprotocol.Extension || buf[1] != peerprotocol.ExtensionIDMetadata {
+			continue
+		}
+		var msg peerprotocol.ExtensionMetadataMessage
+		err = bencode.NewDecoder(bytes.NewReader(buf[2:])).Decode(&msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+}
@@ -2,6 +2,7 @@ package session
 
 import (
 	"net"
+	"time"
 )
 
 // Start downloading.
@@ -24,12 +25,16 @@ func (t *torrent) Stop() {
 
 // Close this torrent and release all resources.
 // Close must be called before discarding the torrent.
-func (t *torrent) Close() {
+// Close stops the torrent and waits for the stopped event to be announced to trackers.
+// Returns the URLs of trackers that the stopped event could not be announced to.
+func (t *torrent) Close() (failedTrackers []string) {
 	doneC := make(chan struct{})
 	select {
 	case t.closeC <- doneC:
 		<-doneC
-	default:
+		return t.stopAnnounceFailed
+	case <-t.doneC:
+		return nil
 	}
 }
 
@@ -91,13 +96,16 @@ func (t *torrent) Stats() Stats {
 	return stats
 }
 
-func (t *torrent) AddPeers(peers []*net.TCPAddr) {
+func (t *torrent) AddPeers(peers []*net.TCPAddr) error {
 	select {
 	case t.addPeersCommandC <- peers:
-	case <-t.closeC:
+		return nil
+	case <-t.doneC:
+		return ErrTorrentClosed
 	}
 }
 
+// TrackerStatus is the result of the last announce to a tracker.
 type TrackerStatus int
 
 const (
@@ -117,12 +125,28 @@ func trackerStatusToString(s TrackerStatus) string {
 	return m[s]
 }
 
+// String returns the name of the status, e.g. "Not working".
+func (s TrackerStatus) String() string {
+	return trackerStatusToString(s)
+}
+
+// Tracker contains the announce state of a tracker in the torrent.
 type Tracker struct {
-	URL      string
-	Status   TrackerStatus
+	URL string
+	// Trackers in the same tier are tried in order until one of them responds.
+	Tier int
+	// Status is Working if the tracker has responded to the last announce.
+	Status TrackerStatus
+	// Number of leechers and seeders in the swarm as reported by the tracker in the last announce response.
+	// Results of scrape requests are not included, see Torrent.Scrape.
 	Leechers int
 	Seeders  int
-	Error    error
+	// Error returned from the last announce. Nil if the tracker is working.
+	Error error
+	// Time of the last successful announce. Zero if the tracker has not responded yet.
+	LastAnnounce time.Time
+	// Time of the next scheduled announce. Zero if the tracker is not being announced to.
+	NextAnnounce time.Time
 }
 
 type trackersRequest struct {
@@ -143,17 +167,68 @@ func (t *torrent) Trackers() []Tracker {
 	return trackers
 }
 
-type Peer struct {
+// PeerStats contains information about a peer that the torrent is connected to.
+type PeerStats struct {
 	Addr net.Addr
+	// Peer ID sent in handshake.
+	ID [20]byte
+	// Client name and version sent in extension handshake.
+	// If the peer does not send it, the name is decoded from peer ID.
+	Client string
+	// Where the address of the peer is found: "tracker", "dht", "pex", "manual", "resume", "lsd" or "incoming".
+	Source string
+	// Connection is encrypted with RC4.
+	Encrypted bool
+	// Piece data download and upload speeds in bytes/sec.
+	DownloadSpeed uint
+	UploadSpeed   uint
+	// Choke and interest states in both directions.
+	AmChoking      bool
+	AmInterested   bool
+	PeerChoking    bool
+	PeerInterested bool
+	// Number of pieces that the peer has. Zero until the metadata of torrent is downloaded.
+	Pieces int
+	// Upload bandwidth in bytes/sec allocated to the peer by the unchoke algorithm.
+	// Upload to the peer does not exceed this rate.
+	// Zero if the peer is choked or upload rate is not limited.
+	UploadAllocation int64
+	// Number of messages sent to and received from the peer by message type.
+	// Nil if Config.PeerProtocolStats is not enabled.
+	MessagesSent     map[string]int64
+	MessagesReceived map[string]int64
+}
+
+// Peer is the old name of PeerStats. It is kept for backwards compatibility.
+type Peer = PeerStats
+
+type resetStatsRequest struct {
+	Response chan error
+}
+
+// ResetStats sets downloaded, uploaded, wasted bytes and seed duration of the torrent to zero.
+func (t *torrent) ResetStats() error {
+	req := resetStatsRequest{Response: make(chan error, 1)}
+	select {
+	case t.resetStatsCommandC <- req:
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+	select {
+	case err := <-req.Response:
+		return err
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
 }
 
 type peersRequest struct {
-	Response chan []Peer
+	Response chan []PeerStats
 }
 
-func (t *torrent) Peers() []Peer {
-	var peers []Peer
-	req := peersRequest{Response: make(chan []Peer, 1)}
+func (t *torrent) Peers() []PeerStats {
+	var peers []PeerStats
+	req := peersRequest{Response: make(chan []PeerStats, 1)}
 	select {
 	case t.peersCommandC <- req:
 	case <-t.closeC:
@@ -164,3 +239,57 @@ func (t *torrent) Peers() []Peer {
 	}
 	return peers
 }
+
+// AddrListStats contains the number of peer addresses that are known but not connected yet.
+// If there are few addresses, the torrent is starving for peers.
+// If there are many addresses but few connections, the torrent is limited by MaxPeerDial.
+type AddrListStats struct {
+	// Total number of addresses waiting to be connected.
+	Total int
+	// Addresses found via trackers.
+	Tracker int
+	// Addresses found via DHT node.
+	DHT int
+	// Addresses found via peer exchange.
+	PEX int
+	// Addresses found via Local Service Discovery.
+	LSD int
+	// Addresses added by user.
+	Manual int
+	// Addresses of peers remembered from previous runs.
+	Resume int
+	// Number of outgoing connections, including the ones still in handshake state.
+	Dialing int
+	// Max number of outgoing connections.
+	MaxDial int
+}
+
+type addrListRequest struct {
+	Response chan AddrListStats
+}
+
+func (t *torrent) AddrListStats() AddrListStats {
+	var stats AddrListStats
+	req := addrListRequest{Response: make(chan AddrListStats, 1)}
+	select {
+	case t.addrListCommandC <- req:
+	case <-t.doneC:
+	}
+	select {
+	case stats = <-req.Response:
+	case <-t.doneC:
+	}
+	return stats
+}
+
+// ping returns true if run loop of the torrent processes a command in timeout duration.
+func (t *torrent) ping(timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case t.pingCommandC <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
@@ -0,0 +1,19 @@
+package session
+
+// SetDownloadRateLimit changes the max number of piece data bytes per second downloaded by all torrents.
+// Zero means unlimited. The change takes effect on running torrents immediately.
+func (s *Session) SetDownloadRateLimit(bytesPerSecond int64) {
+	if bytesPerSecond < 0 {
+		bytesPerSecond = 0
+	}
+	s.downloadLimiter.SetRate(bytesPerSecond)
+}
+
+// SetUploadRateLimit changes the max number of piece data bytes per second uploaded by all torrents.
+// Zero means unlimited. The change takes effect on running torrents immediately.
+func (s *Session) SetUploadRateLimit(bytesPerSecond int64) {
+	if bytesPerSecond < 0 {
+		bytesPerSecond = 0
+	}
+	s.uploadLimiter.SetRate(bytesPerSecond)
+}
@@ -0,0 +1,101 @@
+package session
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/cenkalti/rain/internal/addrlist"
+	"github.com/cenkalti/rain/internal/resumer"
+)
+
+const (
+	// Max number of peer addresses saved in resume data.
+	maxRememberedPeers = 50
+	// Remembered peers older than this duration are not dialed.
+	rememberedPeerTTL = 7 * 24 * time.Hour
+)
+
+// dialRememberedPeers connects to the peers that data has been exchanged in previous runs,
+// so the torrent does not need to wait for trackers or DHT to find peers.
+// Blocklisted addresses are discarded by the address list.
+// It is called every time announcers are started but the peers are pushed only once after each start.
+func (t *torrent) dialRememberedPeers() {
+	if !t.config.RememberPeers || t.rememberedPeersDialed {
+		return
+	}
+	t.rememberedPeersDialed = true
+	var addrs []*net.TCPAddr
+	for _, p := range t.rememberedPeers {
+		if time.Since(p.SeenAt) > rememberedPeerTTL {
+			continue
+		}
+		addr := parseTCPAddr(p.Addr)
+		if addr == nil {
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	if len(addrs) == 0 {
+		return
+	}
+	t.log.Debugf("dialing %d remembered peers", len(addrs))
+	t.addrList.Push(addrs, addrlist.Resume)
+	t.dialAddresses()
+}
+
+// writePeers saves productive peers to resume data.
+// Only outgoing peers are saved because the port of an incoming connection is not the listen port of the peer.
+func (t *torrent) writePeers() {
+	if !t.config.RememberPeers || t.resume == nil {
+		return
+	}
+	now := time.Now()
+	peers := make([]resumer.Peer, 0, maxRememberedPeers)
+	seen := make(map[string]struct{})
+	for pe := range t.outgoingPeers {
+		if len(peers) == maxRememberedPeers {
+			break
+		}
+		if !pe.Productive {
+			continue
+		}
+		addr := pe.Addr().String()
+		peers = append(peers, resumer.Peer{Addr: addr, SeenAt: now})
+		seen[addr] = struct{}{}
+	}
+	// Fill the rest with previously remembered peers that are not connected now.
+	for _, p := range t.rememberedPeers {
+		if len(peers) == maxRememberedPeers {
+			break
+		}
+		if _, ok := seen[p.Addr]; ok {
+			continue
+		}
+		if now.Sub(p.SeenAt) > rememberedPeerTTL {
+			continue
+		}
+		peers = append(peers, p)
+	}
+	t.rememberedPeers = peers
+	err := t.resume.WritePeers(peers)
+	if err != nil {
+		t.log.Errorln("cannot write peers:", err)
+	}
+}
+
+func parseTCPAddr(s string) *net.TCPAddr {
+	host, portStr, err := net.SplitHostPort(s)
+	if err != nil {
+		return nil
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil
+	}
+	return &net.TCPAddr{IP: ip, Port: port}
+}
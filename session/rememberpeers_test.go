@@ -0,0 +1,49 @@
+package session
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/addrlist"
+	"github.com/cenkalti/rain/internal/handshaker/outgoinghandshaker"
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/resumer"
+)
+
+func TestDialRememberedPeersOnce(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	cfg := DefaultConfig
+	cfg.DialInterval = 0
+	var clientIP net.IP
+	tor := &torrent{
+		config:                    cfg,
+		addrList:                  addrlist.New(cfg.MaxPeerAddresses, nil, 0, &clientIP),
+		connectedPeerIPs:          make(map[string]struct{}),
+		outgoingHandshakers:       make(map[*outgoinghandshaker.OutgoingHandshaker]addrlist.PeerSource),
+		outgoingHandshakerResultC: make(chan *outgoinghandshaker.OutgoingHandshaker),
+		log:                       logger.New("test"),
+		rememberedPeers: []resumer.Peer{
+			{Addr: l.Addr().String(), SeenAt: time.Now()},
+			{Addr: "127.0.0.2:1", SeenAt: time.Now().Add(-2 * rememberedPeerTTL)},
+		},
+	}
+	defer tor.stopOutgoingHandshakers()
+
+	tor.dialRememberedPeers()
+	if len(tor.outgoingHandshakers) != 1 {
+		t.Fatalf("unexpected number of handshakers: %d", len(tor.outgoingHandshakers))
+	}
+	// Handshake fails and announcers are started again, e.g. after the metadata of a magnet link is downloaded.
+	tor.stopOutgoingHandshakers()
+	tor.connectedPeerIPs = make(map[string]struct{})
+	tor.dialRememberedPeers()
+	if len(tor.outgoingHandshakers) != 0 {
+		t.Fatal("remembered peer is dialed again")
+	}
+}
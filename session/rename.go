@@ -0,0 +1,206 @@
+package session
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+type renameRequest struct {
+	Name     string
+	Response chan error
+}
+
+type renameFileRequest struct {
+	Index    int
+	Name     string
+	Response chan error
+}
+
+// Rename changes the name of the torrent and moves its files on disk.
+func (t *torrent) Rename(name string) error {
+	if name == "" || sanitizeName(name) != name {
+		return errors.New("invalid name")
+	}
+	req := renameRequest{Name: name, Response: make(chan error, 1)}
+	select {
+	case t.renameCommandC <- req:
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+	select {
+	case err := <-req.Response:
+		return err
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+}
+
+// RenameFile changes the name of the file at index in torrent and moves it on disk.
+func (t *torrent) RenameFile(index int, name string) error {
+	if name == "" || sanitizeName(name) != name {
+		return errors.New("invalid name")
+	}
+	req := renameFileRequest{Index: index, Name: name, Response: make(chan error, 1)}
+	select {
+	case t.renameFileCommandC <- req:
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+	select {
+	case err := <-req.Response:
+		return err
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+}
+
+// SetName changes the display name of the torrent. Files on disk are not moved.
+func (t *torrent) SetName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("invalid name")
+	}
+	req := renameRequest{Name: name, Response: make(chan error, 1)}
+	select {
+	case t.setNameCommandC <- req:
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+	select {
+	case err := <-req.Response:
+		return err
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+}
+
+func (t *torrent) setName(name string) error {
+	if t.resume != nil {
+		err := t.resume.WriteName(name, true)
+		if err != nil {
+			return err
+		}
+	}
+	t.mName.Lock()
+	t.name = name
+	t.mName.Unlock()
+	t.nameSet = true
+	return nil
+}
+
+// infoName returns the name of the torrent from the locations of files or the info dictionary.
+func (t *torrent) infoName() string {
+	if t.filePaths != nil {
+		return rootName(t.filePaths[0])
+	}
+	return t.info.Name
+}
+
+// displayName returns the name set by the user. If there is no such name, the name in info is returned.
+func (t *torrent) displayName() string {
+	if t.info == nil || t.nameSet {
+		return t.name
+	}
+	return t.infoName()
+}
+
+// getFilePaths returns the locations of files relative to storage root.
+func (t *torrent) getFilePaths() []string {
+	if t.filePaths != nil {
+		return t.filePaths
+	}
+	files := t.info.GetFiles()
+	paths := make([]string, len(files))
+	if !t.info.MultiFile {
+		paths[0] = t.info.Name
+		return paths
+	}
+	for i, f := range files {
+		parts := append([]string{t.info.Name}, f.Path...)
+		paths[i] = filepath.Join(parts...)
+	}
+	return paths
+}
+
+// rootName returns the first element of path.
+func rootName(path string) string {
+	return strings.SplitN(path, string(filepath.Separator), 2)[0]
+}
+
+// checkRename returns an error if files of the torrent cannot be moved at the moment.
+// Renaming while files are open could cause piece writes to go to a stale path.
+func (t *torrent) checkRename() error {
+	if t.status() != Stopped {
+		return ErrTorrentNotStopped
+	}
+	if t.info == nil {
+		return ErrNoMetadata
+	}
+	return nil
+}
+
+func (t *torrent) rename(name string) error {
+	err := t.checkRename()
+	if err != nil {
+		return err
+	}
+	oldPaths := t.getFilePaths()
+	oldRoot := rootName(oldPaths[0])
+	if oldRoot == name {
+		return nil
+	}
+	newPaths := make([]string, len(oldPaths))
+	for i, p := range oldPaths {
+		newPaths[i] = name + strings.TrimPrefix(p, oldRoot)
+	}
+	err = t.storage.Rename(oldRoot, name)
+	if err != nil {
+		return err
+	}
+	return t.setFilePaths(newPaths, name)
+}
+
+func (t *torrent) renameFile(index int, name string) error {
+	err := t.checkRename()
+	if err != nil {
+		return err
+	}
+	oldPaths := t.getFilePaths()
+	if index < 0 || index >= len(oldPaths) {
+		return errors.New("invalid file index")
+	}
+	newPath := filepath.Join(filepath.Dir(oldPaths[index]), name)
+	for _, p := range oldPaths {
+		if p == newPath {
+			return errors.New("file already exists in torrent: " + newPath)
+		}
+	}
+	newPaths := make([]string, len(oldPaths))
+	copy(newPaths, oldPaths)
+	newPaths[index] = newPath
+	err = t.storage.Rename(oldPaths[index], newPath)
+	if err != nil {
+		return err
+	}
+	// Name of a single file torrent follows the file unless it is set by the user.
+	newName := t.name
+	if !t.info.MultiFile && !t.nameSet {
+		newName = name
+	}
+	return t.setFilePaths(newPaths, newName)
+}
+
+func (t *torrent) setFilePaths(paths []string, name string) error {
+	t.filePaths = paths
+	t.mName.Lock()
+	t.name = name
+	t.mName.Unlock()
+	if t.resume == nil {
+		return nil
+	}
+	err := t.resume.WriteFilePaths(paths)
+	if err != nil {
+		return err
+	}
+	return t.resume.WriteName(name, t.nameSet)
+}
@@ -0,0 +1,25 @@
+package session
+
+import (
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/piece"
+)
+
+// requestQueueLength returns the number of blocks to keep requested from the peer.
+// The queue is deep enough to hold RequestQueueTime worth of data at the current download rate of the peer,
+// so that high-latency, high-bandwidth peers are not left idle while waiting for the next request.
+// The length never exceeds the "reqq" value sent by the peer in extension handshake.
+func (t *torrent) requestQueueLength(pe *peer.Peer) int {
+	n := int(pe.DownloadSpeed.Rate() * t.config.RequestQueueTime.Seconds() / piece.BlockSize)
+	if n > t.config.MaxRequestQueueLength {
+		n = t.config.MaxRequestQueueLength
+	}
+	if n < t.config.RequestQueueLength {
+		n = t.config.RequestQueueLength
+	}
+	// Requests exceeding the limit advertised by the peer may be dropped.
+	if pe.ExtensionHandshake != nil && pe.ExtensionHandshake.Reqq > 0 && n > pe.ExtensionHandshake.Reqq {
+		n = pe.ExtensionHandshake.Reqq
+	}
+	return n
+}
@@ -0,0 +1,44 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/cenkalti/rain/internal/piece"
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestRequestQueueLength(t *testing.T) {
+	cases := []struct {
+		name   string
+		blocks int // download rate of the peer in blocks per second
+		reqq   int
+		length int
+	}{
+		{name: "slow peer", blocks: 0, length: 50},
+		{name: "fast peer", blocks: 100, length: 100},
+		{name: "very fast peer", blocks: 1000, length: 500},
+		{name: "peer limit", blocks: 1000, reqq: 250, length: 250},
+		{name: "peer limit below minimum", blocks: 0, reqq: 10, length: 10},
+		{name: "peer limit above queue", blocks: 100, reqq: 250, length: 100},
+	}
+	for _, c := range cases {
+		cfg := DefaultConfig
+		cfg.RequestQueueLength = 50
+		cfg.RequestQueueTime = time.Second
+		cfg.MaxRequestQueueLength = 500
+		tor := &torrent{config: cfg}
+		pe := &peer.Peer{
+			DownloadSpeed:      metrics.NewEWMA1(),
+			ExtensionHandshake: &peerprotocol.ExtensionHandshakeMessage{Reqq: c.reqq},
+		}
+		// First tick sets the rate to the bytes received in last 5 seconds.
+		pe.DownloadSpeed.Update(int64(c.blocks * piece.BlockSize * 5))
+		pe.DownloadSpeed.Tick()
+		if n := tor.requestQueueLength(pe); n != c.length {
+			t.Errorf("%s: unexpected request queue length: %d, want %d", c.name, n, c.length)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package session
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/resumer"
+)
+
+func TestResetStats(t *testing.T) {
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	mi, err := metainfo.New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt := options{
+		Info: mi.Info,
+		Stats: resumer.Stats{
+			BytesDownloaded: 1,
+			BytesUploaded:   2,
+			BytesWasted:     3,
+			SeededFor:       time.Hour,
+		},
+	}
+	tor, err := opt.NewTorrent(mi.Info.Hash[:], newFileStorage(t, torrentDataDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tor.Close()
+
+	if b := tor.Stats().Bytes; b.Downloaded != 1 || b.Uploaded != 2 || b.Wasted != 3 {
+		t.Fatalf("unexpected stats before reset: %+v", b)
+	}
+	err = tor.ResetStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := tor.Stats()
+	if b := stats.Bytes; b.Downloaded != 0 || b.Uploaded != 0 || b.Wasted != 0 {
+		t.Fatalf("stats are not reset: %+v", b)
+	}
+	if stats.SeededFor != 0 {
+		t.Fatalf("seed duration is not reset: %s", stats.SeededFor)
+	}
+	select {
+	case e := <-tor.torrentEvents:
+		if e.Type != EventStatsReset {
+			t.Fatalf("unexpected event: %v", e.Type)
+		}
+	default:
+		t.Fatal("event is not sent")
+	}
+}
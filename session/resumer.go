@@ -0,0 +1,21 @@
+package session
+
+import "github.com/cenkalti/rain/internal/resumer"
+
+// Types for implementing a custom resume data storage to set as Config.ResumerFactory.
+type (
+	// ResumerFactory creates a Resumer for each torrent in Session.
+	ResumerFactory = resumer.Factory
+	// Resumer saves and loads the resume data of a single torrent.
+	Resumer = resumer.Resumer
+	// ResumeSpec contains all of the resume data of a torrent.
+	ResumeSpec = resumer.Spec
+	// ResumeStats contains the transfer statistics of a torrent saved periodically.
+	ResumeStats = resumer.Stats
+	// ResumePeer is the address of a peer saved to reconnect after restart.
+	ResumePeer = resumer.Peer
+	// SeedGoal is the per-torrent limit of seeding.
+	SeedGoal = resumer.SeedGoal
+	// MaxPeers is the per-torrent limit of connections.
+	MaxPeers = resumer.MaxPeers
+)
@@ -1,9 +1,10 @@
 package session
 
 import (
+	"bytes"
 	"encoding/base64"
-	"errors"
-	"strings"
+	"encoding/hex"
+	"fmt"
 	"time"
 
 	"github.com/cenkalti/rain/internal/rpctypes"
@@ -27,9 +28,14 @@ func (h *rpcHandler) ListTorrents(args *rpctypes.ListTorrentsRequest, reply *rpc
 	return nil
 }
 
+// AddTorrent adds the torrent file given as base64 encoded string in request. ID of the new torrent is returned in reply.
 func (h *rpcHandler) AddTorrent(args *rpctypes.AddTorrentRequest, reply *rpctypes.AddTorrentResponse) error {
-	r := base64.NewDecoder(base64.StdEncoding, strings.NewReader(args.Torrent))
-	t, err := h.session.AddTorrent(r)
+	// Decode before adding so an encoding error is not reported as invalid metainfo.
+	b, err := base64.StdEncoding.DecodeString(args.Torrent)
+	if err != nil {
+		return fmt.Errorf("cannot decode torrent: %s", err)
+	}
+	t, err := h.session.AddTorrent(bytes.NewReader(b))
 	if err != nil {
 		return err
 	}
@@ -37,6 +43,11 @@ func (h *rpcHandler) AddTorrent(args *rpctypes.AddTorrentRequest, reply *rpctype
 	return nil
 }
 
+// AddTorrentBase64 adds the torrent file given as base64 encoded string in request. It is an alias of AddTorrent.
+func (h *rpcHandler) AddTorrentBase64(args *rpctypes.AddTorrentRequest, reply *rpctypes.AddTorrentResponse) error {
+	return h.AddTorrent(args, reply)
+}
+
 func (h *rpcHandler) AddURI(args *rpctypes.AddURIRequest, reply *rpctypes.AddURIResponse) error {
 	t, err := h.session.AddURI(args.URI)
 	if err != nil {
@@ -64,7 +75,7 @@ func (h *rpcHandler) RemoveTorrent(args *rpctypes.RemoveTorrentRequest, reply *r
 func (h *rpcHandler) GetTorrentStats(args *rpctypes.GetTorrentStatsRequest, reply *rpctypes.GetTorrentStatsResponse) error {
 	t := h.session.GetTorrent(args.ID)
 	if t == nil {
-		return errors.New("torrent not found")
+		return ErrTorrentNotFound
 	}
 	s := t.Stats()
 	reply.Stats = rpctypes.Stats{
@@ -90,6 +101,9 @@ func (h *rpcHandler) GetTorrentStats(args *rpctypes.GetTorrentStatsRequest, repl
 			Downloaded int64
 			Uploaded   int64
 			Wasted     int64
+
+			DownloadedOverhead int64
+			UploadedOverhead   int64
 		}{
 			Total:      s.Bytes.Total,
 			Allocated:  s.Bytes.Allocated,
@@ -98,15 +112,28 @@ func (h *rpcHandler) GetTorrentStats(args *rpctypes.GetTorrentStatsRequest, repl
 			Downloaded: s.Bytes.Downloaded,
 			Uploaded:   s.Bytes.Uploaded,
 			Wasted:     s.Bytes.Wasted,
+
+			DownloadedOverhead: s.Bytes.DownloadedOverhead,
+			UploadedOverhead:   s.Bytes.UploadedOverhead,
 		},
 		Peers: struct {
-			Total    int
-			Incoming int
-			Outgoing int
+			Total     int
+			Incoming  int
+			Outgoing  int
+			Reaped    int
+			Rejected  int
+			Blocked   int
+			Encrypted int
+			Plaintext int
 		}{
-			Total:    s.Peers.Total,
-			Incoming: s.Peers.Incoming,
-			Outgoing: s.Peers.Outgoing,
+			Total:     s.Peers.Total,
+			Incoming:  s.Peers.Incoming,
+			Outgoing:  s.Peers.Outgoing,
+			Reaped:    s.Peers.Reaped,
+			Rejected:  s.Peers.Rejected,
+			Blocked:   s.Peers.Blocked,
+			Encrypted: s.Peers.Encrypted,
+			Plaintext: s.Peers.Plaintext,
 		},
 		Handshakes: struct {
 			Total    int
@@ -122,11 +149,13 @@ func (h *rpcHandler) GetTorrentStats(args *rpctypes.GetTorrentStatsRequest, repl
 			Tracker int
 			DHT     int
 			PEX     int
+			LSD     int
 		}{
 			Total:   s.Addresses.Total,
 			Tracker: s.Addresses.Tracker,
 			DHT:     s.Addresses.DHT,
 			PEX:     s.Addresses.PEX,
+			LSD:     s.Addresses.LSD,
 		},
 		Downloads: struct {
 			Total   int
@@ -143,10 +172,19 @@ func (h *rpcHandler) GetTorrentStats(args *rpctypes.GetTorrentStatsRequest, repl
 			Total   int
 			Snubbed int
 			Running int
+			Wasted  int64
 		}{
 			Total:   s.MetadataDownloads.Total,
 			Snubbed: s.MetadataDownloads.Snubbed,
 			Running: s.MetadataDownloads.Running,
+			Wasted:  s.MetadataDownloads.Wasted,
+		},
+		PEX: struct {
+			Enabled    bool
+			Discovered int
+		}{
+			Enabled:    s.PEX.Enabled,
+			Discovered: s.PEX.Discovered,
 		},
 		Name:        s.Name,
 		Private:     s.Private,
@@ -159,6 +197,21 @@ func (h *rpcHandler) GetTorrentStats(args *rpctypes.GetTorrentStatsRequest, repl
 			Download: s.Speed.Download,
 			Upload:   s.Speed.Upload,
 		},
+		Progress:       s.Progress,
+		Warnings:       s.Warnings,
+		StatusProgress: s.StatusProgress,
+		LastActivity:   rpctypes.Time{Time: s.LastActivity},
+		Scrape: struct {
+			Seeders   int
+			Leechers  int
+			Completed int
+			Time      rpctypes.Time
+		}{
+			Seeders:   s.Scrape.Seeders,
+			Leechers:  s.Scrape.Leechers,
+			Completed: s.Scrape.Completed,
+			Time:      rpctypes.Time{Time: s.Scrape.Time},
+		},
 	}
 	if s.Error != nil {
 		errStr := s.Error.Error()
@@ -174,16 +227,20 @@ func (h *rpcHandler) GetTorrentStats(args *rpctypes.GetTorrentStatsRequest, repl
 func (h *rpcHandler) GetTorrentTrackers(args *rpctypes.GetTorrentTrackersRequest, reply *rpctypes.GetTorrentTrackersResponse) error {
 	t := h.session.GetTorrent(args.ID)
 	if t == nil {
-		return errors.New("torrent not found")
+		return ErrTorrentNotFound
 	}
 	trackers := t.Trackers()
 	reply.Trackers = make([]rpctypes.Tracker, len(trackers))
 	for i, t := range trackers {
 		reply.Trackers[i] = rpctypes.Tracker{
 			URL:      t.URL,
+			Tier:     t.Tier,
 			Status:   trackerStatusToString(t.Status),
 			Leechers: t.Leechers,
 			Seeders:  t.Seeders,
+
+			LastAnnounce: rpctypes.Time{Time: t.LastAnnounce},
+			NextAnnounce: rpctypes.Time{Time: t.NextAnnounce},
 		}
 		if t.Error != nil {
 			errStr := t.Error.Error()
@@ -196,13 +253,27 @@ func (h *rpcHandler) GetTorrentTrackers(args *rpctypes.GetTorrentTrackersRequest
 func (h *rpcHandler) GetTorrentPeers(args *rpctypes.GetTorrentPeersRequest, reply *rpctypes.GetTorrentPeersResponse) error {
 	t := h.session.GetTorrent(args.ID)
 	if t == nil {
-		return errors.New("torrent not found")
+		return ErrTorrentNotFound
 	}
 	peers := t.Peers()
 	reply.Peers = make([]rpctypes.Peer, len(peers))
 	for i, p := range peers {
 		reply.Peers[i] = rpctypes.Peer{
-			Addr: p.Addr.String(),
+			Addr:             p.Addr.String(),
+			ID:               hex.EncodeToString(p.ID[:]),
+			Client:           p.Client,
+			Source:           p.Source,
+			Encrypted:        p.Encrypted,
+			DownloadSpeed:    p.DownloadSpeed,
+			UploadSpeed:      p.UploadSpeed,
+			AmChoking:        p.AmChoking,
+			AmInterested:     p.AmInterested,
+			PeerChoking:      p.PeerChoking,
+			PeerInterested:   p.PeerInterested,
+			Pieces:           p.Pieces,
+			UploadAllocation: p.UploadAllocation,
+			MessagesSent:     p.MessagesSent,
+			MessagesReceived: p.MessagesReceived,
 		}
 	}
 	return nil
@@ -211,7 +282,7 @@ func (h *rpcHandler) GetTorrentPeers(args *rpctypes.GetTorrentPeersRequest, repl
 func (h *rpcHandler) StartTorrent(args *rpctypes.StartTorrentRequest, reply *rpctypes.StartTorrentResponse) error {
 	t := h.session.GetTorrent(args.ID)
 	if t == nil {
-		return errors.New("torrent not found")
+		return ErrTorrentNotFound
 	}
 	t.Start()
 	return nil
@@ -220,7 +291,7 @@ func (h *rpcHandler) StartTorrent(args *rpctypes.StartTorrentRequest, reply *rpc
 func (h *rpcHandler) StopTorrent(args *rpctypes.StopTorrentRequest, reply *rpctypes.StopTorrentResponse) error {
 	t := h.session.GetTorrent(args.ID)
 	if t == nil {
-		return errors.New("torrent not found")
+		return ErrTorrentNotFound
 	}
 	t.Stop()
 	return nil
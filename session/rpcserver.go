@@ -2,10 +2,12 @@ package session
 
 import (
 	"context"
+	"crypto/subtle"
 	"net"
 	"net/http"
 	"net/rpc"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cenkalti/rain/internal/logger"
@@ -22,15 +24,42 @@ func newRPCServer(ses *Session) *rpcServer {
 	h := &rpcHandler{session: ses}
 	srv := rpc.NewServer()
 	srv.RegisterName("Session", h)
+	mux := http.NewServeMux()
+	var rpcHandler http.Handler = jsonrpc2.HTTPHandler(srv)
+	if ses.config.RPCToken != "" {
+		rpcHandler = tokenAuthHandler{token: ses.config.RPCToken, handler: rpcHandler}
+	}
+	mux.Handle("/", rpcHandler)
+	if ses.config.WebUIEnabled {
+		mux.Handle("/ui/", webUIHandler{})
+	}
 	return &rpcServer{
 		rpcServer: srv,
 		httpServer: http.Server{
-			Handler: jsonrpc2.HTTPHandler(srv),
+			Handler: mux,
 		},
 		log: logger.New("rpc server"),
 	}
 }
 
+// tokenAuthHandler rejects requests that do not have the token in "Authorization: Bearer <token>" header.
+type tokenAuthHandler struct {
+	token   string
+	handler http.Handler
+}
+
+func (h tokenAuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	// Compare in constant time so the token cannot be guessed from response times.
+	if !strings.HasPrefix(auth, prefix) || subtle.ConstantTimeCompare([]byte(auth[len(prefix):]), []byte(h.token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="rain"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.handler.ServeHTTP(w, r)
+}
+
 func (s *rpcServer) Start(host string, port int) error {
 	addr := net.JoinHostPort(host, strconv.Itoa(port))
 	listener, err := net.Listen("tcp", addr)
@@ -0,0 +1,69 @@
+package session
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cenkalti/rain/internal/rpctypes"
+)
+
+func TestTokenAuthHandler(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	h := tokenAuthHandler{token: "secret", handler: next}
+	for _, tc := range []struct {
+		auth   string
+		status int
+	}{
+		{"", http.StatusUnauthorized},
+		{"secret", http.StatusUnauthorized},
+		{"Bearer", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"Bearer secretsecret", http.StatusUnauthorized},
+		{"Basic secret", http.StatusUnauthorized},
+		{"Bearer secret", http.StatusNoContent},
+	} {
+		req := httptest.NewRequest("POST", "/", nil)
+		if tc.auth != "" {
+			req.Header.Set("Authorization", tc.auth)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("status is %d for %q, expected %d", rec.Code, tc.auth, tc.status)
+		}
+		if tc.status == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("WWW-Authenticate header is not set for %q", tc.auth)
+		}
+	}
+}
+
+func TestRPCAddTorrentBase64(t *testing.T) {
+	s, closeSession := newTestSession(t, nil)
+	defer closeSession()
+
+	b, err := ioutil.ReadFile(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &rpcHandler{session: s}
+	var reply rpctypes.AddTorrentResponse
+	err = h.AddTorrentBase64(&rpctypes.AddTorrentRequest{Torrent: "not base64"}, &reply)
+	if err == nil {
+		t.Fatal("invalid encoding is accepted")
+	}
+	err = h.AddTorrentBase64(&rpctypes.AddTorrentRequest{Torrent: base64.StdEncoding.EncodeToString(b)}, &reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Torrent.InfoHash != torrentInfoHashString {
+		t.Fatalf("unexpected info hash: %s", reply.Torrent.InfoHash)
+	}
+	if s.GetTorrent(reply.Torrent.ID) == nil {
+		t.Fatal("torrent is not added to session")
+	}
+}
@@ -3,11 +3,16 @@ package session
 import (
 	"errors"
 	"fmt"
+	"math/rand"
 	"net"
+	"sort"
+	"sync/atomic"
 	"time"
 
 	"github.com/cenkalti/rain/internal/addrlist"
 	"github.com/cenkalti/rain/internal/announcer"
+	"github.com/cenkalti/rain/internal/bitfield"
+	"github.com/cenkalti/rain/internal/btconn"
 	"github.com/cenkalti/rain/internal/handshaker/incominghandshaker"
 	"github.com/cenkalti/rain/internal/handshaker/outgoinghandshaker"
 	"github.com/cenkalti/rain/internal/infodownloader"
@@ -16,17 +21,36 @@ import (
 	"github.com/cenkalti/rain/internal/peerconn"
 	"github.com/cenkalti/rain/internal/peerprotocol"
 	"github.com/cenkalti/rain/internal/piecedownloader"
+	"github.com/cenkalti/rain/internal/resumer"
 )
 
 var errClosed = errors.New("torrent is closed")
 
+const (
+	// Max number of pieces omitted from the Bitfield message when Config.LazyBitfield is set.
+	lazyBitfieldPieces = 8
+	// Duration between Have messages sent for the omitted pieces.
+	lazyHaveInterval = time.Second
+)
+
 func (t *torrent) close() {
 	// Stop if running.
 	t.stop(errClosed)
 
-	// Maybe we are in "Stopping" state. Close "stopped" event announcer.
+	// Cancel pending completion webhook.
+	close(t.webhookStopC)
+	t.webhookWG.Wait()
+
+	// Maybe we are in "Stopping" state. Wait for "stopped" event announcer before closing it.
 	if t.stoppedEventAnnouncer != nil {
+		timer := time.NewTimer(t.config.StopAnnounceTimeout)
+		select {
+		case <-t.stoppedEventAnnouncer.Announced():
+		case <-timer.C:
+		}
+		timer.Stop()
 		t.stoppedEventAnnouncer.Close()
+		t.stopAnnounceFailed = t.stoppedEventAnnouncer.Failed()
 	}
 }
 
@@ -36,6 +60,7 @@ func (t *torrent) run() {
 		select {
 		case doneC := <-t.closeC:
 			t.close()
+			close(t.doneC)
 			close(doneC)
 			return
 		case <-t.startCommandC:
@@ -48,6 +73,11 @@ func (t *torrent) run() {
 			t.errC = nil
 			t.portC = nil
 			t.log.Info("torrent has stopped")
+			t.sendEvent(Event{Type: EventStopped, Error: t.lastError})
+			if t.startAfterStop {
+				t.startAfterStop = false
+				t.start()
+			}
 		case cmd := <-t.notifyErrorCommandC:
 			cmd.errCC <- t.errC
 		case cmd := <-t.notifyListenCommandC:
@@ -58,22 +88,70 @@ func (t *torrent) run() {
 			req.Response <- t.getTrackers()
 		case req := <-t.peersCommandC:
 			req.Response <- t.getPeers()
+		case req := <-t.activeDownloadsCommandC:
+			req.Response <- t.getActiveDownloads()
+		case req := <-t.torrentFileCommandC:
+			req.Response <- t.torrentFile()
+		case req := <-t.magnetCommandC:
+			req.Response <- t.magnet()
+		case <-t.pingCommandC:
+		case req := <-t.addrListCommandC:
+			req.Response <- t.addrListStats()
+		case req := <-t.setUploadEnabledCommandC:
+			req.Response <- t.setUploadEnabled(req.Enabled)
+		case req := <-t.piecePrioritiesCommandC:
+			req.Response <- t.setPiecePriorities(req.Priorities)
+		case req := <-t.infoCommandC:
+			req.Response <- t.info
+		case r := <-t.scrapeResultCommandC:
+			t.lastScrape = r
+		case req := <-t.filesCommandC:
+			files, err := t.getFiles()
+			req.Response <- filesResponse{Files: files, Error: err}
+		case req := <-t.filePriorityCommandC:
+			req.Response <- t.setFilePriority(req.Index, req.Priority)
+		case req := <-t.seedOnlyCommandC:
+			req.Response <- t.setSeedOnly(req.SeedOnly)
+		case req := <-t.seedGoalCommandC:
+			req.Response <- t.setSeedGoal(req.Goal)
+		case req := <-t.maxPeersCommandC:
+			req.Response <- t.setMaxPeers(req.MaxPeers)
+		case req := <-t.announceNowCommandC:
+			req.Response <- t.announceNow()
+		case req := <-t.pexCommandC:
+			req.Response <- t.setPEX(req.Enabled)
+		case req := <-t.resetStatsCommandC:
+			req.Response <- t.resetStats()
+		case req := <-t.renameCommandC:
+			req.Response <- t.rename(req.Name)
+		case req := <-t.renameFileCommandC:
+			req.Response <- t.renameFile(req.Index, req.Name)
+		case req := <-t.setNameCommandC:
+			req.Response <- t.setName(req.Name)
+		case req := <-t.verifyCommandC:
+			req.Response <- t.verify()
 		case p := <-t.allocatorProgressC:
 			t.bytesAllocated = p.AllocatedSize
 		case al := <-t.allocatorResultC:
 			t.handleAllocationDone(al)
+		case <-t.verifierReadyC:
+			t.runVerifier()
 		case p := <-t.verifierProgressC:
 			t.checkedPieces = p.Checked
 		case ve := <-t.verifierResultC:
 			t.handleVerificationDone(ve)
 		case addrs := <-t.addrsFromTrackers:
 			t.handleNewPeers(addrs, addrlist.Tracker)
+		case sc := <-t.trackerStatusC:
+			t.handleTrackerStatusChange(sc)
 		case addrs := <-t.addPeersCommandC:
 			t.handleNewPeers(addrs, addrlist.Manual)
 		case addrs := <-t.dhtPeersC:
 			t.handleNewPeers(addrs, addrlist.DHT)
+		case addrs := <-t.lsdPeersC:
+			t.handleNewPeers(addrs, addrlist.LSD)
 		case conn := <-t.incomingConnC:
-			if len(t.incomingHandshakers)+len(t.incomingPeers) >= t.config.MaxPeerAccept {
+			if len(t.incomingHandshakers)+len(t.incomingPeers) >= t.maxPeerAccept() {
 				t.log.Debugln("peer limit reached, rejecting peer", conn.RemoteAddr().String())
 				conn.Close()
 				break
@@ -82,6 +160,12 @@ func (t *torrent) run() {
 			ipstr := ip.String()
 			if t.blocklist != nil && t.blocklist.Blocked(ip) {
 				t.log.Debugln("peer is blocked:", conn.RemoteAddr().String())
+				t.blockedPeers++
+				conn.Close()
+				break
+			}
+			if t.isBanned(ipstr) {
+				t.log.Debugln("peer is banned:", conn.RemoteAddr().String())
 				conn.Close()
 				break
 			}
@@ -90,6 +174,11 @@ func (t *torrent) run() {
 				conn.Close()
 				break
 			}
+			if !t.handshakes.tryAcquire() {
+				t.log.Debugln("handshake limit reached, rejecting peer", conn.RemoteAddr().String())
+				conn.Close()
+				break
+			}
 			h := incominghandshaker.New(conn)
 			t.incomingHandshakers[h] = struct{}{}
 			t.connectedPeerIPs[ipstr] = struct{}{}
@@ -100,11 +189,35 @@ func (t *torrent) run() {
 			case req.Response <- announcer.Response{Torrent: tr}:
 			case <-req.Cancel:
 			}
+		case <-t.pieceWriterRetryTimerC:
+			t.retryPieceWriters()
+		case d := <-t.webseedResultC:
+			t.handleWebseedDone(d)
+		case <-t.dialTimerC:
+			t.dialTimer = nil
+			t.dialTimerC = nil
+			t.dialAddresses()
+		case <-t.webseedRetryTimerC:
+			t.webseedRetryTimer = nil
+			t.webseedRetryTimerC = nil
+			t.startPieceDownloaders()
 		case pw := <-t.pieceWriterResultC:
 			pw.Piece.Writing = false
+			delete(t.pieceWriters, pw)
+			if _, ok := t.discardedPieceWriters[pw]; ok {
+				// The piece is checked again by the verifier.
+				delete(t.discardedPieceWriters, pw)
+				t.updatePieceMessages()
+				t.piecePool.Put(pw.Buffer)
+				break
+			}
 
-			t.pieceMessages = t.blockPieceMessages
-			t.blockPieceMessages = nil
+			if pw.Error != nil && t.config.DiskErrorPolicy == DiskErrorPolicyRetry && isTransientDiskError(pw.Error) {
+				t.log.Warningf("cannot write piece #%d, retrying in %s: %s", pw.Piece.Index, t.config.DiskErrorRetryInterval, pw.Error)
+				t.schedulePieceWriterRetry(pw)
+				break
+			}
+			t.updatePieceMessages()
 
 			t.piecePool.Put(pw.Buffer)
 			if pw.Error != nil {
@@ -116,6 +229,7 @@ func (t *torrent) run() {
 				panic("already have the piece")
 			}
 			t.bitfield.Set(pw.Piece.Index)
+			t.sendEvent(Event{Type: EventPieceVerified, Piece: pw.Piece.Index})
 			// Tell everyone that we have this piece
 			for pe := range t.peers {
 				t.updateInterestedState(pe)
@@ -126,7 +240,11 @@ func (t *torrent) run() {
 				msg := peerprotocol.HaveMessage{Index: pw.Piece.Index}
 				pe.SendMessage(msg)
 			}
+			wasCompleted := t.completed
 			completed := t.checkCompletion()
+			if completed && !wasCompleted {
+				t.notifyCompletionWebhook()
+			}
 			if t.resume != nil {
 				if completed {
 					t.writeBitfield(true)
@@ -138,13 +256,26 @@ func (t *torrent) run() {
 			t.writeBitfield(true)
 		case <-t.statsWriteTickerC:
 			t.writeStats()
+			t.writePeers()
+		case <-t.idlePeerReaperTickerC:
+			t.reapIdlePeers()
 		case <-t.speedCounterTickerC:
 			t.downloadSpeed.Tick()
 			t.uploadSpeed.Tick()
+			for pe := range t.peers {
+				pe.DownloadSpeed.Tick()
+				pe.UploadSpeed.Tick()
+			}
+			t.checkSeedGoal()
+		case id := <-t.infoDownloaderTimeoutC:
+			if t.infoDownloaders[id.Peer] == id {
+				t.handleMetadataFailure(id, "timeout")
+			}
 		case pe := <-t.peerSnubbedC:
 			// Mark slow peer as snubbed and don't select that peer in piece picker
 			pe.Snubbed = true
 			t.peersSnubbed[pe] = struct{}{}
+			t.addSnubPenalty(pe)
 			if pd, ok := t.pieceDownloaders[pe]; ok {
 				t.pieceDownloadersSnubbed[pe] = pd
 				if t.piecePicker != nil {
@@ -161,23 +292,28 @@ func (t *torrent) run() {
 			t.tickOptimisticUnchoke()
 		case ih := <-t.incomingHandshakerResultC:
 			delete(t.incomingHandshakers, ih)
+			t.handshakes.release()
 			if ih.Error != nil {
 				delete(t.connectedPeerIPs, ih.Conn.RemoteAddr().(*net.TCPAddr).IP.String())
 				break
 			}
 			log := logger.New("peer <- " + ih.Conn.RemoteAddr().String())
-			pe := peerconn.New(ih.Conn, ih.PeerID, ih.Extensions, log, t.config.PieceTimeout, t.config.PeerReadBufferSize)
-			t.startPeer(pe, t.incomingPeers)
+			pe := peerconn.New(ih.Conn, ih.PeerID, ih.Extensions, log, t.peerConnConfig())
+			t.startPeer(pe, t.incomingPeers, addrlist.Incoming, ih.Encrypted)
 		case oh := <-t.outgoingHandshakerResultC:
+			source := t.outgoingHandshakers[oh]
 			delete(t.outgoingHandshakers, oh)
+			t.halfOpen.release()
 			if oh.Error != nil {
 				delete(t.connectedPeerIPs, oh.Addr.IP.String())
 				t.dialAddresses()
 				break
 			}
 			log := logger.New("peer -> " + oh.Conn.RemoteAddr().String())
-			pe := peerconn.New(oh.Conn, oh.PeerID, oh.Extensions, log, t.config.PieceTimeout, t.config.PeerReadBufferSize)
-			t.startPeer(pe, t.outgoingPeers)
+			pe := peerconn.New(oh.Conn, oh.PeerID, oh.Extensions, log, t.peerConnConfig())
+			t.startPeer(pe, t.outgoingPeers, source, oh.Encrypted)
+			// A half-open connection slot is freed.
+			t.dialAddresses()
 		case pe := <-t.peerDisconnectedC:
 			t.closePeer(pe)
 		case pm := <-t.pieceMessages:
@@ -188,6 +324,19 @@ func (t *torrent) run() {
 	}
 }
 
+func (t *torrent) peerConnConfig() peerconn.Config {
+	return peerconn.Config{
+		PieceTimeout:    t.config.PieceTimeout,
+		ReadBufferSize:  t.config.PeerReadBufferSize,
+		WriteBufferSize: t.config.PeerWriteBufferSize,
+		ProtocolStats:   t.config.PeerProtocolStats,
+		OverheadRead:    &t.bytesOverheadDownloaded,
+		OverheadWritten: &t.bytesOverheadUploaded,
+		DownloadLimiter: t.downloadLimiter,
+		UploadLimiter:   t.uploadLimiter,
+	}
+}
+
 func (t *torrent) deferWriteBitfield() {
 	if t.resumeWriteTimer == nil {
 		t.resumeWriteTimer = time.NewTimer(t.config.BitfieldWriteInterval)
@@ -230,6 +379,9 @@ func (t *torrent) closePeer(pe *peer.Peer) {
 	}
 	t.pexDropPeer(pe.Addr())
 	t.dialAddresses()
+	if len(t.peers) == 0 {
+		t.announceOnZeroPeers()
+	}
 }
 
 func (t *torrent) closePieceDownloader(pd *piecedownloader.PieceDownloader) {
@@ -243,6 +395,7 @@ func (t *torrent) closePieceDownloader(pd *piecedownloader.PieceDownloader) {
 }
 
 func (t *torrent) closeInfoDownloader(id *infodownloader.InfoDownloader) {
+	id.Close()
 	delete(t.infoDownloaders, id.Peer)
 	delete(t.infoDownloadersSnubbed, id.Peer)
 }
@@ -253,30 +406,77 @@ func (t *torrent) handleNewPeers(addrs []*net.TCPAddr, source addrlist.PeerSourc
 	if status := t.status(); status == Stopped || status == Stopping {
 		return
 	}
-	if !t.completed {
+	if t.isPrivate() && isPublicPeerSource(source) {
+		return
+	}
+	if t.maxPeerDial() > 0 {
 		t.addrList.Push(addrs, source)
 		t.dialAddresses()
 	}
 }
 
 func (t *torrent) dialAddresses() {
-	if t.completed {
-		return
-	}
-	for len(t.outgoingPeers)+len(t.outgoingHandshakers) < t.config.MaxPeerDial {
-		addr := t.addrList.Pop()
+	for len(t.outgoingPeers)+len(t.outgoingHandshakers) < t.maxPeerDial() {
+		if wait := t.config.DialInterval - time.Since(t.lastDial); wait > 0 {
+			t.startDialTimer(wait)
+			break
+		}
+		if !t.halfOpen.tryAcquire() {
+			// Slots may be released by handshakers of other torrents.
+			t.startDialTimer(halfOpenRetryInterval)
+			break
+		}
+		addr, source := t.nextDialAddress()
 		if addr == nil {
+			t.halfOpen.release()
 			t.setNeedMorePeers(true)
 			break
 		}
+		h := outgoinghandshaker.New(addr, net.ParseIP(t.config.ListenAddress))
+		t.outgoingHandshakers[h] = source
+		t.connectedPeerIPs[addr.IP.String()] = struct{}{}
+		t.lastDial = time.Now()
+		go h.Run(t.config.PeerConnectTimeout, t.config.PeerHandshakeTimeout, t.peerID, t.infoHash, t.outgoingHandshakerResultC, ourExtensions, t.config.DisableOutgoingEncryption, t.config.ForceOutgoingEncryption)
+	}
+}
+
+// nextDialAddress pops addresses from the list until one that can be dialed is found.
+// Returns nil if the list is exhausted.
+func (t *torrent) nextDialAddress() (*net.TCPAddr, addrlist.PeerSource) {
+	for {
+		addr, source := t.addrList.Pop()
+		if addr == nil {
+			return nil, source
+		}
 		ip := addr.IP.String()
 		if _, ok := t.connectedPeerIPs[ip]; ok {
 			continue
 		}
-		h := outgoinghandshaker.New(addr)
-		t.outgoingHandshakers[h] = struct{}{}
-		t.connectedPeerIPs[ip] = struct{}{}
-		go h.Run(t.config.PeerConnectTimeout, t.config.PeerHandshakeTimeout, t.peerID, t.infoHash, t.outgoingHandshakerResultC, ourExtensions, t.config.DisableOutgoingEncryption, t.config.ForceOutgoingEncryption)
+		if t.isBanned(ip) {
+			continue
+		}
+		// Blocklist may be changed after the address is added to the list.
+		if t.blocklist != nil && t.blocklist.Blocked(addr.IP) {
+			t.blockedPeers++
+			continue
+		}
+		return addr, source
+	}
+}
+
+// startDialTimer calls dialAddresses again after d.
+func (t *torrent) startDialTimer(d time.Duration) {
+	if t.dialTimer == nil {
+		t.dialTimer = time.NewTimer(d)
+		t.dialTimerC = t.dialTimer.C
+	}
+}
+
+func (t *torrent) stopDialTimer() {
+	if t.dialTimer != nil {
+		t.dialTimer.Stop()
+		t.dialTimer = nil
+		t.dialTimerC = nil
 	}
 }
 
@@ -287,19 +487,38 @@ func (t *torrent) setNeedMorePeers(val bool) {
 	if t.dhtAnnouncer != nil {
 		t.dhtAnnouncer.NeedMorePeers(val)
 	}
+	if t.lsdAnnouncer != nil {
+		t.lsdAnnouncer.NeedMorePeers(val)
+	}
 }
 
 // Process messages received while we don't have metadata yet.
 func (t *torrent) processQueuedMessages() {
 	for pe := range t.peers {
 		for _, msg := range pe.Messages {
+			// Peer may be closed while handling a previous message.
+			if _, ok := t.peers[pe]; !ok {
+				break
+			}
 			pm := peer.Message{Peer: pe, Message: msg}
 			t.handlePeerMessage(pm)
 		}
+		pe.Messages = nil
 	}
 }
 
-func (t *torrent) startPeer(p *peerconn.Conn, peers map[*peer.Peer]struct{}) {
+func (t *torrent) startPeer(p *peerconn.Conn, peers map[*peer.Peer]struct{}, source addrlist.PeerSource, encrypted bool) {
+	atomic.AddInt64(&t.bytesOverheadDownloaded, btconn.HandshakeSize)
+	atomic.AddInt64(&t.bytesOverheadUploaded, btconn.HandshakeSize)
+	if t.isBannedClient(p.ID()) {
+		p.Logger().Debugln("rejecting peer with banned client:", p.Client())
+		p.CloseConn()
+		delete(t.connectedPeerIPs, p.IP())
+		t.tempBanPeerIP(p.IP(), t.config.BannedClientDuration)
+		t.rejectedPeers++
+		t.dialAddresses()
+		return
+	}
 	t.pexAddPeer(p.Addr())
 	_, ok := t.peerIDs[p.ID()]
 	if ok {
@@ -311,19 +530,21 @@ func (t *torrent) startPeer(p *peerconn.Conn, peers map[*peer.Peer]struct{}) {
 	}
 	t.peerIDs[p.ID()] = struct{}{}
 
-	pe := peer.New(p, t.config.RequestTimeout)
+	pe := peer.New(p, source, t.config.RequestTimeout)
+	pe.SnubPenalty = t.getSnubPenalty(p.IP())
+	pe.Encrypted = encrypted
 	t.peers[pe] = struct{}{}
 	peers[pe] = struct{}{}
 	go pe.Run(t.messages, t.pieceMessages, t.peerSnubbedC, t.peerDisconnectedC)
 
 	t.sendFirstMessage(pe)
-	if len(t.peers) <= 4 {
+	if len(t.peers) <= 4 && !t.uploadDisabled {
 		t.unchokePeer(pe)
 	}
 }
 
 func (t *torrent) pexAddPeer(addr *net.TCPAddr) {
-	if !t.config.PEXEnabled {
+	if !t.pexEnabled() {
 		return
 	}
 	for pe := range t.peers {
@@ -334,7 +555,7 @@ func (t *torrent) pexAddPeer(addr *net.TCPAddr) {
 }
 
 func (t *torrent) pexDropPeer(addr *net.TCPAddr) {
-	if !t.config.PEXEnabled {
+	if !t.pexEnabled() {
 		return
 	}
 	for pe := range t.peers {
@@ -346,7 +567,9 @@ func (t *torrent) pexDropPeer(addr *net.TCPAddr) {
 
 func (t *torrent) sendFirstMessage(p *peer.Peer) {
 	bf := t.bitfield
-	if p.FastExtension && bf != nil && bf.All() {
+	if t.config.LazyBitfield && bf != nil && bf.Count() > 0 {
+		t.sendLazyBitfield(p, bf)
+	} else if p.FastExtension && bf != nil && bf.All() {
 		msg := peerprotocol.HaveAllMessage{}
 		p.SendMessage(msg)
 	} else if p.FastExtension && (bf == nil || bf != nil && bf.Count() == 0) {
@@ -362,7 +585,9 @@ func (t *torrent) sendFirstMessage(p *peer.Peer) {
 	if t.info != nil {
 		metadataSize = t.info.InfoSize
 	}
-	extHandshakeMsg := peerprotocol.NewExtensionHandshake(metadataSize, t.config.ExtensionHandshakeClientVersion, p.Addr().IP)
+	// Seeds that send lazy bitfield do not tell the peer that they are not going to download.
+	uploadOnly := t.completed && !t.config.LazyBitfield
+	extHandshakeMsg := peerprotocol.NewExtensionHandshake(metadataSize, t.config.ExtensionHandshakeClientVersion, p.Addr().IP, uploadOnly)
 	msg := peerprotocol.ExtensionMessage{
 		ExtendedMessageID: peerprotocol.ExtensionIDHandshake,
 		Payload:           extHandshakeMsg,
@@ -370,7 +595,31 @@ func (t *torrent) sendFirstMessage(p *peer.Peer) {
 	p.SendMessage(msg)
 }
 
+// sendLazyBitfield sends a Bitfield message with a few random pieces omitted
+// and sends Have messages for the omitted pieces later.
+func (t *torrent) sendLazyBitfield(p *peer.Peer, bf *bitfield.Bitfield) {
+	lazy := bf.Copy()
+	var have []uint32
+	for i := uint32(0); i < bf.Len(); i++ {
+		if bf.Test(i) {
+			have = append(have, i)
+		}
+	}
+	rand.Shuffle(len(have), func(i, j int) { have[i], have[j] = have[j], have[i] })
+	if len(have) > lazyBitfieldPieces {
+		have = have[:lazyBitfieldPieces]
+	}
+	for _, i := range have {
+		lazy.Clear(i)
+	}
+	msg := peerprotocol.BitfieldMessage{Data: lazy.Bytes()}
+	p.SendMessage(msg)
+	p.SendHavesLater(have, lazyHaveInterval)
+}
+
 func (t *torrent) chokePeer(pe *peer.Peer) {
+	pe.UploadAllocation = 0
+	pe.SetUploadRate(0)
 	if !pe.AmChoking {
 		pe.AmChoking = true
 		msg := peerprotocol.ChokeMessage{}
@@ -390,31 +639,98 @@ func (t *torrent) checkCompletion() bool {
 	if t.completed {
 		return true
 	}
-	if !t.bitfield.All() {
+	if !t.wantedPiecesDone() {
 		return false
 	}
 	t.log.Info("download completed")
 	t.completed = true
 	close(t.completeC)
-	for h := range t.outgoingHandshakers {
-		h.Close()
+	t.sendEvent(Event{Type: EventCompleted})
+	if t.maxPeerDial() == 0 {
+		t.stopOutgoingHandshakers()
+		t.addrList.Reset()
 	}
-	t.outgoingHandshakers = make(map[*outgoinghandshaker.OutgoingHandshaker]struct{})
+	var uninterested []*peer.Peer
 	for pe := range t.peers {
-		if !pe.PeerInterested {
+		switch {
+		case pe.UploadOnly:
 			t.closePeer(pe)
+		case !pe.PeerInterested:
+			uninterested = append(uninterested, pe)
+		default:
+			t.sendUploadOnly(pe, true)
 		}
 	}
-	t.addrList.Reset()
+	// Keep the connections to fastest peers so they can be served quickly if they become interested later.
+	keep, drop := splitFastestPeers(uninterested, t.config.KeepUninterestedPeers)
+	for _, pe := range keep {
+		t.sendUploadOnly(pe, true)
+	}
+	for _, pe := range drop {
+		t.closePeer(pe)
+	}
 	for _, pd := range t.pieceDownloaders {
 		t.closePieceDownloader(pd)
 		pd.CancelPending()
 	}
-	t.piecePicker = nil
+	// Skipped pieces may become wanted later. Piece picker keeps the pieces of connected peers until then.
+	if t.bitfield.All() {
+		t.piecePicker = nil
+	}
 	t.updateSeedDuration()
 	return true
 }
 
+// splitFastestPeers returns at most n peers that we have downloaded most from in last choke period and the rest.
+func splitFastestPeers(peers []*peer.Peer, n int) (fastest, rest []*peer.Peer) {
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].BytesDownlaodedInChokePeriod > peers[j].BytesDownlaodedInChokePeriod
+	})
+	if n > len(peers) {
+		n = len(peers)
+	}
+	if n < 0 {
+		n = 0
+	}
+	return peers[:n], peers[n:]
+}
+
+// sendUploadOnly tells the peer whether we are going to download any more pieces.
+func (t *torrent) sendUploadOnly(pe *peer.Peer, uploadOnly bool) {
+	if t.config.LazyBitfield {
+		return
+	}
+	if pe.ExtensionHandshake == nil {
+		return
+	}
+	extID, ok := pe.ExtensionHandshake.M[peerprotocol.ExtensionKeyUploadOnly]
+	if !ok {
+		return
+	}
+	msg := peerprotocol.ExtensionMessage{
+		ExtendedMessageID: extID,
+		Payload:           peerprotocol.ExtensionUploadOnlyMessage{UploadOnly: uploadOnly},
+	}
+	pe.SendMessage(msg)
+}
+
+// resetStats clears the counters that are accumulated since the torrent is added and saves them.
+// Last activity time is kept because it is not a counter.
+func (t *torrent) resetStats() error {
+	// Seed duration is counted from now on.
+	t.updateSeedDuration()
+	t.resumerStats = resumer.Stats{LastActivity: t.resumerStats.LastActivity}
+	t.bytesWasted = WastedBreakdown{}
+	if t.resume != nil {
+		err := t.resume.WriteStats(t.resumerStats)
+		if err != nil {
+			return err
+		}
+	}
+	t.sendEvent(Event{Type: EventStatsReset})
+	return nil
+}
+
 func (t *torrent) writeStats() {
 	t.updateSeedDuration()
 	if t.resume != nil {
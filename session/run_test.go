@@ -0,0 +1,64 @@
+package session
+
+import (
+	"net"
+	"testing"
+
+	"github.com/cenkalti/rain/internal/addrlist"
+	"github.com/cenkalti/rain/internal/blocklist"
+	"github.com/cenkalti/rain/internal/peer"
+)
+
+func TestSplitFastestPeers(t *testing.T) {
+	newPeers := func() []*peer.Peer {
+		return []*peer.Peer{
+			{BytesDownlaodedInChokePeriod: 10},
+			{BytesDownlaodedInChokePeriod: 30},
+			{BytesDownlaodedInChokePeriod: 20},
+		}
+	}
+	fastest, rest := splitFastestPeers(newPeers(), 2)
+	if len(fastest) != 2 || fastest[0].BytesDownlaodedInChokePeriod != 30 || fastest[1].BytesDownlaodedInChokePeriod != 20 {
+		t.Fatalf("unexpected fastest peers: %v", fastest)
+	}
+	if len(rest) != 1 || rest[0].BytesDownlaodedInChokePeriod != 10 {
+		t.Fatalf("unexpected rest of peers: %v", rest)
+	}
+	if fastest, rest = splitFastestPeers(newPeers(), 0); len(fastest) != 0 || len(rest) != 3 {
+		t.Fatal("all peers must be dropped when none is kept")
+	}
+	if fastest, rest = splitFastestPeers(newPeers(), 5); len(fastest) != 3 || len(rest) != 0 {
+		t.Fatal("all peers must be kept when limit is larger than number of peers")
+	}
+}
+
+func TestNextDialAddressBlocked(t *testing.T) {
+	var clientIP net.IP
+	tor := &torrent{
+		config:           DefaultConfig,
+		addrList:         addrlist.New(DefaultConfig.MaxPeerAddresses, nil, 0, &clientIP),
+		connectedPeerIPs: make(map[string]struct{}),
+		blocklist:        blocklist.New(),
+	}
+	tor.addrList.Push([]*net.TCPAddr{
+		{IP: net.IPv4(10, 0, 0, 1), Port: 6881},
+		{IP: net.IPv4(10, 0, 0, 2), Port: 6881},
+		{IP: net.IPv4(192, 168, 0, 1), Port: 6881},
+	}, addrlist.Tracker)
+	// Range is blocked after the addresses are added to the list.
+	if err := tor.blocklist.Add("10.0.0.0/8"); err != nil {
+		t.Fatal(err)
+	}
+
+	addr, _ := tor.nextDialAddress()
+	if addr == nil || !addr.IP.Equal(net.IPv4(192, 168, 0, 1)) {
+		t.Fatalf("unexpected address: %v", addr)
+	}
+	if addr, _ = tor.nextDialAddress(); addr != nil {
+		t.Fatalf("blocked address is dialed: %v", addr)
+	}
+	// Reported as Stats.Peers.Blocked.
+	if tor.blockedPeers != 2 {
+		t.Fatalf("unexpected blocked peers: %d", tor.blockedPeers)
+	}
+}
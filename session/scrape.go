@@ -0,0 +1,79 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/rain/internal/tracker"
+)
+
+// ScrapeResult contains the swarm statistics reported by trackers.
+type ScrapeResult struct {
+	// Number of peers that have the complete torrent.
+	Seeders int
+	// Number of peers that are still downloading.
+	Leechers int
+	// Number of times the torrent is downloaded completely.
+	Completed int
+	// Time of the scrape. Zero if the trackers are not scraped yet.
+	Time time.Time
+}
+
+// Scrape asks all trackers of the torrent for the number of peers in the swarm.
+// Because the swarms of trackers overlap, the maximum of values reported by trackers is returned.
+// Error is returned only if none of the trackers responds successfully.
+// The result is also included in Stats.
+func (t *torrent) Scrape() (ScrapeResult, error) {
+	if len(t.trackers) == 0 {
+		return ScrapeResult{}, ErrNoTrackers
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), t.config.TrackerHTTPTimeout)
+	defer cancel()
+
+	var (
+		m       sync.Mutex
+		wg      sync.WaitGroup
+		result  ScrapeResult
+		success bool
+		lastErr error
+	)
+	for _, trk := range t.trackers {
+		wg.Add(1)
+		go func(trk tracker.Tracker) {
+			defer wg.Done()
+			results, err := trk.Scrape(ctx, [][20]byte{t.infoHash})
+			m.Lock()
+			defer m.Unlock()
+			if err != nil {
+				t.log.Debugln("cannot scrape tracker:", trk.URL(), err.Error())
+				lastErr = err
+				return
+			}
+			success = true
+			r := results[t.infoHash]
+			result.Seeders = maxInt(result.Seeders, int(r.Seeders))
+			result.Leechers = maxInt(result.Leechers, int(r.Leechers))
+			result.Completed = maxInt(result.Completed, int(r.Completed))
+		}(trk)
+	}
+	wg.Wait()
+	if !success {
+		return ScrapeResult{}, lastErr
+	}
+	result.Time = time.Now()
+
+	select {
+	case t.scrapeResultCommandC <- result:
+	case <-t.doneC:
+		return ScrapeResult{}, ErrTorrentClosed
+	}
+	return result, nil
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
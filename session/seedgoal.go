@@ -0,0 +1,81 @@
+package session
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cenkalti/rain/internal/resumer"
+)
+
+type seedGoalRequest struct {
+	Goal     resumer.SeedGoal
+	Response chan error
+}
+
+// SetSeedGoal sets the ratio and duration limits of seeding for the torrent.
+func (t *torrent) SetSeedGoal(ratio float64, duration time.Duration) error {
+	if ratio < 0 || duration < 0 {
+		return errors.New("seed goal cannot be negative")
+	}
+	req := seedGoalRequest{Goal: resumer.SeedGoal{Ratio: ratio, Duration: duration}, Response: make(chan error, 1)}
+	select {
+	case t.seedGoalCommandC <- req:
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+	select {
+	case err := <-req.Response:
+		return err
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+}
+
+func (t *torrent) setSeedGoal(goal resumer.SeedGoal) error {
+	if t.resume != nil {
+		err := t.resume.WriteSeedGoal(goal)
+		if err != nil {
+			return err
+		}
+	}
+	t.seedGoal = &goal
+	t.checkSeedGoal()
+	return nil
+}
+
+// seedLimits returns the ratio and duration limits of the torrent. Zero means no limit.
+func (t *torrent) seedLimits() (ratio float64, duration time.Duration) {
+	if t.seedGoal != nil {
+		return t.seedGoal.Ratio, t.seedGoal.Duration
+	}
+	return t.config.SeedRatioLimit, t.config.SeedTimeLimit
+}
+
+// checkSeedGoal stops the torrent if it has uploaded or seeded enough.
+// Ratio is not checked for torrents that have not downloaded any bytes because it is undefined.
+func (t *torrent) checkSeedGoal() {
+	if t.status() != Seeding {
+		return
+	}
+	ratio, duration := t.seedLimits()
+	if ratio == 0 && duration == 0 {
+		return
+	}
+	t.updateSeedDuration()
+	st := t.resumerStats
+	ratioReached := ratio > 0 && st.BytesDownloaded > 0 && float64(st.BytesUploaded)/float64(st.BytesDownloaded) >= ratio
+	durationReached := duration > 0 && st.SeededFor >= duration
+	if !ratioReached && !durationReached {
+		return
+	}
+	t.log.Infof("seed goal reached (uploaded: %d, downloaded: %d, seeded for: %s)", st.BytesUploaded, st.BytesDownloaded, st.SeededFor)
+	// Saves the stopped state like Torrent.Stop so the torrent is not started when the session is loaded again.
+	if t.resume != nil {
+		err := t.resume.WriteStarted(false)
+		if err != nil {
+			t.log.Errorln("cannot write stopped state:", err)
+		}
+	}
+	t.stop(nil)
+	t.sendEvent(Event{Type: EventSeedGoalReached})
+}
@@ -0,0 +1,80 @@
+package session
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/resumer"
+	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
+)
+
+func TestSeedGoalSavesStoppedState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rain-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := bolt.Open(filepath.Join(dir, "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	mi, err := metainfo.New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := boltdbresumer.New(db, []byte("torrents"), []byte("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = res.Write(&resumer.Spec{InfoHash: mi.Info.Hash[:], Port: 6881, Started: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opt := options{
+		Info:    mi.Info,
+		Resumer: res,
+	}
+	tor, err := opt.NewTorrent(mi.Info.Hash[:], newFileStorage(t, torrentDataDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tor.Close()
+
+	tor.Start()
+	select {
+	case <-tor.NotifyComplete():
+	case err = <-tor.NotifyError():
+		t.Fatal(err)
+	case <-time.After(timeout):
+		panic("verification did not finish")
+	}
+
+	err = tor.SetSeedGoal(0, time.Nanosecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := tor.Stats(); s.Status != Stopping && s.Status != Stopped {
+		t.Fatalf("torrent is not stopped, status: %s", s.Status)
+	}
+	spec, err := res.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Started {
+		t.Fatal("stopped state is not saved")
+	}
+}
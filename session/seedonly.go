@@ -0,0 +1,47 @@
+package session
+
+type seedOnlyRequest struct {
+	SeedOnly bool
+	Response chan error
+}
+
+// SetSeedOnly enables or disables seed-only mode.
+func (t *torrent) SetSeedOnly(seedOnly bool) error {
+	req := seedOnlyRequest{SeedOnly: seedOnly, Response: make(chan error, 1)}
+	select {
+	case t.seedOnlyCommandC <- req:
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+	select {
+	case err := <-req.Response:
+		return err
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+}
+
+func (t *torrent) setSeedOnly(seedOnly bool) error {
+	if t.seedOnly == seedOnly {
+		return nil
+	}
+	if t.resume != nil {
+		err := t.resume.WriteSeedOnly(seedOnly)
+		if err != nil {
+			return err
+		}
+	}
+	t.seedOnly = seedOnly
+	if t.seedOnly {
+		t.stopPiecedownloaders()
+		t.stopWebseedDownloaders()
+	}
+	// Peers are told that we are not interested anymore, or interested again.
+	for pe := range t.peers {
+		t.updateInterestedState(pe)
+	}
+	if !t.seedOnly && t.errC != nil {
+		t.startPieceDownloaders()
+	}
+	return nil
+}
@@ -2,15 +2,15 @@
 package session
 
 import (
-	"bytes"
 	"encoding/base64"
-	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sync"
 	"time"
 
@@ -18,18 +18,26 @@ import (
 	"github.com/cenkalti/rain/internal/bitfield"
 	"github.com/cenkalti/rain/internal/blocklist"
 	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/lsd"
 	"github.com/cenkalti/rain/internal/magnet"
 	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/piececache"
+	"github.com/cenkalti/rain/internal/ratelimit"
 	"github.com/cenkalti/rain/internal/resumer"
 	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
-	"github.com/cenkalti/rain/internal/storage/filestorage"
+	"github.com/cenkalti/rain/internal/storage"
 	"github.com/cenkalti/rain/internal/tracker"
+	"github.com/cenkalti/rain/internal/tracker/tiertracker"
 	"github.com/cenkalti/rain/internal/trackermanager"
+	"github.com/cenkalti/rain/internal/verifier"
 	"github.com/mitchellh/go-homedir"
 	"github.com/nictuku/dht"
 	"github.com/satori/go.uuid"
 )
 
+// Peer read and write buffers cannot be smaller than this value.
+const minPeerBufferSize = 4 * 1024
+
 var (
 	sessionBucket         = []byte("session")
 	torrentsBucket        = []byte("torrents")
@@ -40,11 +48,33 @@ var (
 type Session struct {
 	config         Config
 	db             *bolt.DB
+	resumers       resumer.Factory
 	log            logger.Logger
 	dht            *dht.DHT
+	lsd            *lsd.LSD
 	blocklist      *blocklist.Blocklist
 	trackerManager *trackermanager.TrackerManager
+	verifierPool   *verifier.Pool
+	pieceCache     *piececache.Cache
+	verifierQueue  *verifier.Queue
 	closeC         chan struct{}
+	// Closed when the watch dir goroutine exits. Nil if Config.WatchDir is not set.
+	watchDirDoneC chan struct{}
+
+	// Limiters shared by peer connections of all torrents.
+	downloadLimiter *ratelimit.Limiter
+	uploadLimiter   *ratelimit.Limiter
+
+	// Limits incoming handshakes of all torrents.
+	handshakes handshakeSemaphore
+	// Limits outgoing connections in connecting or handshake state of all torrents.
+	halfOpen handshakeSemaphore
+
+	// Limits piece writes of all torrents. Nil if Config.ParallelWrites is zero.
+	writeSemaphore chan struct{}
+
+	// Events of all torrents are sent to this channel.
+	events chan Event
 
 	mPeerRequests   sync.Mutex
 	dhtPeerRequests map[dht.InfoHash]struct{}
@@ -55,6 +85,10 @@ type Session struct {
 
 	mPorts         sync.Mutex
 	availablePorts map[uint16]struct{}
+	// Ports that are found to be used by other processes.
+	badPorts map[uint16]struct{}
+	// Ports out of configured range that are assigned to torrents by the operating system.
+	ephemeralPorts map[uint16]struct{}
 
 	rpc *rpcServer
 }
@@ -62,7 +96,81 @@ type Session struct {
 // New returns a pointer to new Rain BitTorrent client.
 func New(cfg Config) (*Session, error) {
 	if cfg.PortBegin >= cfg.PortEnd {
-		return nil, errors.New("invalid port range")
+		return nil, &InvalidConfigError{Reason: "invalid port range"}
+	}
+	if len(cfg.PeerIDPrefix) != 8 || cfg.PeerIDPrefix[0] != '-' || cfg.PeerIDPrefix[7] != '-' {
+		return nil, &InvalidConfigError{Reason: fmt.Sprintf("peer id prefix must be 8 bytes in Azureus style: %q", cfg.PeerIDPrefix)}
+	}
+	if cfg.ListenAddress != "" && net.ParseIP(cfg.ListenAddress) == nil {
+		return nil, &InvalidConfigError{Reason: fmt.Sprintf("invalid listen address: %q", cfg.ListenAddress)}
+	}
+	if cfg.PeerReadBufferSize < minPeerBufferSize {
+		return nil, &InvalidConfigError{Reason: fmt.Sprintf("peer read buffer size must be at least %d bytes", minPeerBufferSize)}
+	}
+	if cfg.PeerWriteBufferSize < minPeerBufferSize {
+		return nil, &InvalidConfigError{Reason: fmt.Sprintf("peer write buffer size must be at least %d bytes", minPeerBufferSize)}
+	}
+	if cfg.DownloadRateLimit < 0 || cfg.UploadRateLimit < 0 {
+		return nil, &InvalidConfigError{Reason: "rate limits cannot be negative"}
+	}
+	if cfg.DiskReadConcurrency < 1 || cfg.DiskWriteConcurrency < 1 {
+		return nil, &InvalidConfigError{Reason: "disk read and write concurrency must be at least 1"}
+	}
+	if cfg.HashWorkers < 0 {
+		return nil, &InvalidConfigError{Reason: "hash workers cannot be negative"}
+	}
+	if cfg.EndgameThreshold < 0 {
+		return nil, &InvalidConfigError{Reason: "endgame threshold cannot be negative"}
+	}
+	if cfg.ParallelWrites < 0 {
+		return nil, &InvalidConfigError{Reason: "parallel writes cannot be negative"}
+	}
+	if cfg.MaxHalfOpenConnections < 0 || cfg.DialInterval < 0 {
+		return nil, &InvalidConfigError{Reason: "half-open connection limit and dial interval cannot be negative"}
+	}
+	if cfg.BitfieldWriteInterval < 0 || cfg.StatsWriteInterval < 0 {
+		return nil, &InvalidConfigError{Reason: "resume write intervals cannot be negative"}
+	}
+	if cfg.BitfieldWriteInterval == 0 {
+		cfg.BitfieldWriteInterval = DefaultConfig.BitfieldWriteInterval
+	}
+	if cfg.StatsWriteInterval == 0 {
+		cfg.StatsWriteInterval = DefaultConfig.StatsWriteInterval
+	}
+	if cfg.TrackerStopTimeout < 0 || cfg.StopAnnounceTimeout < 0 {
+		return nil, &InvalidConfigError{Reason: "stop announce timeouts cannot be negative"}
+	}
+	if cfg.TrackerStopTimeout == 0 {
+		cfg.TrackerStopTimeout = DefaultConfig.TrackerStopTimeout
+	}
+	if cfg.StopAnnounceTimeout == 0 {
+		cfg.StopAnnounceTimeout = DefaultConfig.StopAnnounceTimeout
+	}
+	if cfg.TrackerMaxRetryInterval < 0 {
+		return nil, &InvalidConfigError{Reason: "tracker max retry interval cannot be negative"}
+	}
+	if cfg.TrackerMaxRetryInterval == 0 {
+		cfg.TrackerMaxRetryInterval = DefaultConfig.TrackerMaxRetryInterval
+	}
+	if cfg.SeedRatioLimit < 0 || cfg.SeedTimeLimit < 0 {
+		return nil, &InvalidConfigError{Reason: "seed limits cannot be negative"}
+	}
+	if cfg.DiskErrorPolicy != DiskErrorPolicyStop && cfg.DiskErrorPolicy != DiskErrorPolicyRetry {
+		return nil, &InvalidConfigError{Reason: fmt.Sprintf("invalid disk error policy: %q", cfg.DiskErrorPolicy)}
+	}
+	if cfg.CompletionWebhookURL != "" && cfg.CompletionWebhookTimeout <= 0 {
+		return nil, &InvalidConfigError{Reason: "completion webhook timeout must be positive"}
+	}
+	switch cfg.FileAllocation {
+	case FileAllocationNone, FileAllocationFull, FileAllocationFalloc:
+	default:
+		return nil, &InvalidConfigError{Reason: fmt.Sprintf("invalid file allocation mode: %q", cfg.FileAllocation)}
+	}
+	if cfg.PieceCacheSize < 0 || cfg.ReadCacheSize < 0 {
+		return nil, &InvalidConfigError{Reason: "read cache size cannot be negative"}
+	}
+	if cfg.PieceCacheSize != 0 {
+		cfg.ReadCacheSize = cfg.PieceCacheSize
 	}
 	err := setNoFile(cfg.MaxOpenFiles)
 	if err != nil {
@@ -76,6 +184,10 @@ func New(cfg Config) (*Session, error) {
 	if err != nil {
 		return nil, err
 	}
+	cfg.WatchDir, err = homedir.Expand(cfg.WatchDir)
+	if err != nil {
+		return nil, err
+	}
 	err = os.MkdirAll(filepath.Dir(cfg.Database), 0750)
 	if err != nil {
 		return nil, err
@@ -83,7 +195,7 @@ func New(cfg Config) (*Session, error) {
 	l := logger.New("session")
 	db, err := bolt.Open(cfg.Database, 0640, &bolt.Options{Timeout: time.Second})
 	if err == bolt.ErrTimeout {
-		return nil, errors.New("resume database is locked by another process")
+		return nil, ErrDatabaseLocked
 	} else if err != nil {
 		return nil, err
 	}
@@ -92,32 +204,27 @@ func New(cfg Config) (*Session, error) {
 			db.Close()
 		}
 	}()
-	var ids []string
 	err = db.Update(func(tx *bolt.Tx) error {
-		b, err2 := tx.CreateBucketIfNotExists(sessionBucket)
-		if err2 != nil {
-			return err2
-		}
-		b, err2 = tx.CreateBucketIfNotExists(torrentsBucket)
-		if err2 != nil {
-			return err2
-		}
-		return b.ForEach(func(k, _ []byte) error {
-			ids = append(ids, string(k))
-			return nil
-		})
+		_, err2 := tx.CreateBucketIfNotExists(sessionBucket)
+		return err2
 	})
 	if err != nil {
 		return nil, err
 	}
+	resumers := cfg.ResumerFactory
+	if resumers == nil {
+		resumers, err = boltdbresumer.NewFactory(db, torrentsBucket)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ids, err := resumers.IDs()
+	if err != nil {
+		return nil, err
+	}
 	var dhtNode *dht.DHT
 	if cfg.DHTEnabled {
-		dhtConfig := dht.NewConfig()
-		dhtConfig.Address = cfg.DHTAddress
-		dhtConfig.Port = int(cfg.DHTPort)
-		dhtConfig.DHTRouters = "router.bittorrent.com:6881,dht.transmissionbt.com:6881,router.utorrent.com:6881,dht.libtorrent.org:25401,dht.aelitis.com:6881"
-		dhtConfig.SaveRoutingTable = false
-		dhtNode, err = dht.New(dhtConfig)
+		dhtNode, err = dht.New(newDHTConfig(&cfg))
 		if err != nil {
 			return nil, err
 		}
@@ -126,21 +233,53 @@ func New(cfg Config) (*Session, error) {
 			return nil, err
 		}
 	}
+	var lsdNode *lsd.LSD
+	if cfg.LSDEnabled {
+		lsdNode, err = lsd.New(net.ParseIP(cfg.ListenAddress), logger.New("lsd"))
+		if err != nil {
+			return nil, err
+		}
+	}
 	ports := make(map[uint16]struct{})
 	for p := cfg.PortBegin; p < cfg.PortEnd; p++ {
 		ports[p] = struct{}{}
 	}
+	hashWorkers := cfg.HashWorkers
+	if hashWorkers == 0 {
+		hashWorkers = runtime.NumCPU()
+	}
+	var verifierQueue *verifier.Queue
+	if cfg.VerifierConcurrency > 0 {
+		verifierQueue = verifier.NewQueue(cfg.VerifierConcurrency)
+	}
+	var writeSemaphore chan struct{}
+	if cfg.ParallelWrites > 0 {
+		writeSemaphore = make(chan struct{}, cfg.ParallelWrites)
+	}
 	bl := blocklist.New()
 	c := &Session{
 		config:             cfg,
 		db:                 db,
+		resumers:           resumers,
 		blocklist:          bl,
-		trackerManager:     trackermanager.New(bl),
+		trackerManager:     trackermanager.New(bl, cfg.TrackerDNSCacheTTL, net.ParseIP(cfg.ListenAddress)),
+		verifierPool:       verifier.NewPool(hashWorkers),
+		pieceCache:         piececache.New(cfg.ReadCacheSize, cfg.PieceCacheTTL),
+		verifierQueue:      verifierQueue,
+		downloadLimiter:    ratelimit.New(cfg.DownloadRateLimit),
+		uploadLimiter:      ratelimit.New(cfg.UploadRateLimit),
+		handshakes:         newHandshakeSemaphore(cfg.MaxConcurrentHandshakes),
+		halfOpen:           newHandshakeSemaphore(cfg.MaxHalfOpenConnections),
+		writeSemaphore:     writeSemaphore,
+		events:             make(chan Event, eventBufferSize),
 		log:                l,
 		torrents:           make(map[string]*Torrent),
 		torrentsByInfoHash: make(map[dht.InfoHash][]*Torrent),
 		availablePorts:     ports,
+		badPorts:           make(map[uint16]struct{}),
+		ephemeralPorts:     make(map[uint16]struct{}),
 		dht:                dhtNode,
+		lsd:                lsdNode,
 		closeC:             make(chan struct{}),
 	}
 	err = c.startBlocklistReloader()
@@ -151,10 +290,19 @@ func New(cfg Config) (*Session, error) {
 		c.dhtPeerRequests = make(map[dht.InfoHash]struct{})
 		go c.processDHTResults()
 	}
+	if cfg.LSDEnabled {
+		go c.processLSDResults()
+	}
 	err = c.loadExistingTorrents(ids)
 	if err != nil {
 		return nil, err
 	}
+	c.startWatchdog()
+	c.startIdleRemover()
+	err = c.startWatchDir()
+	if err != nil {
+		return nil, err
+	}
 	if c.config.RPCHost != "" {
 		c.rpc = newRPCServer(c)
 		err = c.rpc.Start(c.config.RPCHost, c.config.RPCPort)
@@ -192,6 +340,31 @@ func (s *Session) processDHTResults() {
 	}
 }
 
+func (s *Session) processLSDResults() {
+	for {
+		select {
+		case p := <-s.lsd.Peers():
+			s.m.RLock()
+			torrents := s.torrentsByInfoHash[dht.InfoHash(p.InfoHash[:])]
+			s.m.RUnlock()
+			for _, t := range torrents {
+				// Private torrents do not have LSD announcer.
+				if t.torrent.lsdNode == nil {
+					continue
+				}
+				select {
+				case t.torrent.lsdNode.peersC <- []*net.TCPAddr{p.Addr}:
+				case <-t.removed:
+				case <-s.closeC:
+					return
+				}
+			}
+		case <-s.closeC:
+			return
+		}
+	}
+}
+
 func (s *Session) handleDHTtick() {
 	s.mPeerRequests.Lock()
 	defer s.mPeerRequests.Unlock()
@@ -211,45 +384,72 @@ func (s *Session) handleDHTtick() {
 	delete(s.dhtPeerRequests, ih)
 }
 
+// parseDHTPeers converts compact peer addresses to TCP addresses.
+// A compact address is 6 bytes for IPv4 and 18 bytes for IPv6, followed by the port in network byte order.
 func parseDHTPeers(peers []string) []*net.TCPAddr {
 	var addrs []*net.TCPAddr
 	for _, peer := range peers {
-		if len(peer) != 6 {
-			// only IPv4 is supported for now
+		var ipLen int
+		switch len(peer) {
+		case net.IPv4len + 2:
+			ipLen = net.IPv4len
+		case net.IPv6len + 2:
+			ipLen = net.IPv6len
+		default:
 			continue
 		}
 		addr := &net.TCPAddr{
-			IP:   net.IP(peer[:4]),
-			Port: int((uint16(peer[4]) << 8) | uint16(peer[5])),
+			IP:   net.IP(peer[:ipLen]),
+			Port: int((uint16(peer[ipLen]) << 8) | uint16(peer[ipLen+1])),
 		}
 		addrs = append(addrs, addr)
 	}
 	return addrs
 }
 
-func (s *Session) parseTrackers(trackers []string) []tracker.Tracker {
-	var ret []tracker.Tracker
-	for _, tr := range trackers {
-		t, err := s.trackerManager.Get(tr, s.config.TrackerHTTPTimeout, s.config.TrackerHTTPUserAgent)
-		if err != nil {
-			s.log.Warningln("cannot parse tracker url:", err)
-			continue
+func (s *Session) parseTrackers(tiers [][]string) []tracker.Tracker {
+	var trackerTiers [][]tracker.Tracker
+	for _, tier := range tiers {
+		var trackers []tracker.Tracker
+		for _, tr := range tier {
+			t, err := s.trackerManager.Get(tr, s.config.TrackerHTTPTimeout, s.config.TrackerHTTPUserAgent)
+			if err != nil {
+				s.log.Warningln("cannot parse tracker url:", err)
+				continue
+			}
+			trackers = append(trackers, t)
+		}
+		if len(trackers) > 0 {
+			trackerTiers = append(trackerTiers, trackers)
 		}
-		ret = append(ret, t)
+	}
+	if len(trackerTiers) == 0 {
+		return nil
+	}
+	if !s.config.TrackerAnnounceToAllTiers {
+		return []tracker.Tracker{tiertracker.New(trackerTiers)}
+	}
+	ret := make([]tracker.Tracker, len(trackerTiers))
+	for i, tier := range trackerTiers {
+		ret[i] = tiertracker.New([][]tracker.Tracker{tier})
 	}
 	return ret
 }
 
+// magnetTrackers returns the trackers in magnet link as tiers, each containing a single tracker.
+func magnetTrackers(trackers []string) [][]string {
+	tiers := make([][]string, len(trackers))
+	for i, tr := range trackers {
+		tiers[i] = []string{tr}
+	}
+	return tiers
+}
+
 func (s *Session) loadExistingTorrents(ids []string) error {
 	var loaded int
 	var started []*Torrent
 	for _, id := range ids {
-		res, err := boltdbresumer.New(s.db, torrentsBucket, []byte(id))
-		if err != nil {
-			s.log.Error(err)
-			continue
-		}
-		hasStarted, err := s.hasStarted(id)
+		res, err := s.resumers.New(id)
 		if err != nil {
 			s.log.Error(err)
 			continue
@@ -259,10 +459,15 @@ func (s *Session) loadExistingTorrents(ids []string) error {
 			s.log.Error(err)
 			continue
 		}
+		hasStarted := spec.Started
 		opt := options{
+			ID:        id,
 			Name:      spec.Name,
+			NameSet:   spec.NameSet,
 			Port:      spec.Port,
+			Peers:     spec.Peers,
 			Trackers:  s.parseTrackers(spec.Trackers),
+			WebSeeds:  spec.WebSeeds,
 			Resumer:   res,
 			Blocklist: s.blocklist,
 			Config:    &s.config,
@@ -271,32 +476,75 @@ func (s *Session) loadExistingTorrents(ids []string) error {
 				BytesUploaded:   spec.BytesUploaded,
 				BytesWasted:     spec.BytesWasted,
 				SeededFor:       spec.SeededFor,
+				LastActivity:    spec.LastActivity,
 			},
+			UploadDisabled:  spec.UploadDisabled,
+			SeedOnly:        spec.SeedOnly,
+			PEXDisabled:     spec.PEXDisabled,
+			SeedGoal:        spec.SeedGoal,
+			MaxPeers:        spec.MaxPeers,
+			VerifierPool:    s.verifierPool,
+			PieceCache:      s.pieceCache,
+			VerifierQueue:   s.verifierQueue,
+			DownloadLimiter: s.downloadLimiter,
+			UploadLimiter:   s.uploadLimiter,
+			Handshakes:      s.handshakes,
+			HalfOpen:        s.halfOpen,
+			WriteSemaphore:  s.writeSemaphore,
+			Events:          s.events,
 		}
 		var private bool
 		var ann *dhtAnnouncer
+		// Torrents with corrupt resume data are not skipped. They are loaded in stopped state with an error
+		// so they are listed and the user can decide to start or remove them.
+		var loadErr error
 		if len(spec.Info) > 0 {
 			info, err2 := metainfo.NewInfo(spec.Info)
 			if err2 != nil {
-				s.log.Error(err2)
-				continue
-			}
-			opt.Info = info
-			private = info.Private == 1
-			if len(spec.Bitfield) > 0 {
-				bf, err3 := bitfield.NewBytes(spec.Bitfield, info.NumPieces)
-				if err3 != nil {
-					s.log.Error(err3)
-					continue
+				// Metadata is downloaded from peers again when the torrent is started.
+				loadErr = fmt.Errorf("invalid info in resume data: %s", err2)
+				// Info may belong to a private torrent. DHT and LSD are not used until it is loaded with valid info.
+				private = true
+			} else {
+				// Names in info bytes are decoded again because the encoding is not a part of info dict.
+				if spec.Encoding != "" {
+					info.DecodeNames(spec.Encoding)
+				}
+				opt.Info = info
+				private = info.Private == 1
+				if len(spec.FilePaths) == len(info.GetFiles()) {
+					opt.FilePaths = spec.FilePaths
+				}
+				if len(spec.PiecePriorities) == int(info.NumPieces) {
+					opt.PiecePriorities = spec.PiecePriorities
+				}
+				if len(spec.FilePriorities) == len(info.GetFiles()) {
+					opt.FilePriorities = spec.FilePriorities
+				}
+				if len(spec.Bitfield) > 0 {
+					bf, err3 := bitfield.NewBytes(spec.Bitfield, info.NumPieces)
+					if err3 != nil {
+						// Files are verified again when the torrent is started.
+						loadErr = fmt.Errorf("invalid bitfield in resume data: %s", err3)
+					} else {
+						opt.Bitfield = bf
+					}
 				}
-				opt.Bitfield = bf
 			}
 		}
+		if loadErr != nil {
+			s.log.Errorf("torrent #%s is loaded with error: %s", id, loadErr)
+			opt.Error = loadErr
+			hasStarted = false
+		}
 		if s.config.DHTEnabled && !private {
 			ann = newDHTAnnouncer(s.dht, spec.InfoHash, spec.Port)
 			opt.DHT = ann
 		}
-		sto, err := filestorage.New(spec.Dest)
+		if s.config.LSDEnabled && !private {
+			opt.LSD = newLSDAnnouncer(s.lsd, spec.InfoHash, spec.Port)
+		}
+		sto, err := newStorage(spec.StorageType, spec.Dest)
 		if err != nil {
 			s.log.Error(err)
 			continue
@@ -307,6 +555,9 @@ func (s *Session) loadExistingTorrents(ids []string) error {
 			continue
 		}
 		delete(s.availablePorts, uint16(spec.Port))
+		if uint16(spec.Port) < s.config.PortBegin || uint16(spec.Port) >= s.config.PortEnd {
+			s.ephemeralPorts[uint16(spec.Port)] = struct{}{}
+		}
 
 		t2 := s.newTorrent(t, id, uint16(spec.Port), spec.CreatedAt, ann)
 		s.log.Debugf("loaded existing torrent: #%d %s", id, t.Name())
@@ -322,31 +573,33 @@ func (s *Session) loadExistingTorrents(ids []string) error {
 	return nil
 }
 
-func (s *Session) hasStarted(id string) (bool, error) {
-	subBucket := id
-	started := false
-	err := s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(torrentsBucket).Bucket([]byte(subBucket))
-		val := b.Get([]byte("started"))
-		if bytes.Equal(val, []byte("1")) {
-			started = true
-		}
-		return nil
-	})
-	return started, err
-}
-
+// Close stops all torrents and waits up to StopAnnounceTimeout for the stopped event to be announced to trackers.
+// If some trackers do not receive the event, the returned error is a *StopAnnounceError.
 func (s *Session) Close() error {
+	close(s.closeC)
+
 	if s.config.DHTEnabled {
 		s.dht.Stop()
 	}
+	if s.config.LSDEnabled {
+		s.lsd.Close()
+	}
+	// Watch dir must not add new torrents while torrents are being closed.
+	if s.watchDirDoneC != nil {
+		<-s.watchDirDoneC
+	}
 
 	var wg sync.WaitGroup
+	var failedTrackers []string
+	var failedTrackersM sync.Mutex
 	s.m.Lock()
 	wg.Add(len(s.torrents))
 	for _, t := range s.torrents {
 		go func(t *Torrent) {
-			t.torrent.Close()
+			failed := t.torrent.Close()
+			failedTrackersM.Lock()
+			failedTrackers = append(failedTrackers, failed...)
+			failedTrackersM.Unlock()
 			wg.Done()
 		}(t)
 	}
@@ -354,6 +607,8 @@ func (s *Session) Close() error {
 	s.torrents = nil
 	s.m.Unlock()
 
+	s.verifierPool.Close()
+
 	if s.rpc != nil {
 		err := s.rpc.Stop(s.config.RPCShutdownTimeout)
 		if err != nil {
@@ -361,7 +616,14 @@ func (s *Session) Close() error {
 		}
 	}
 
-	return s.db.Close()
+	err := s.db.Close()
+	if err != nil {
+		return err
+	}
+	if len(failedTrackers) > 0 {
+		return &StopAnnounceError{Trackers: failedTrackers}
+	}
+	return nil
 }
 
 func (s *Session) ListTorrents() []*Torrent {
@@ -374,12 +636,53 @@ func (s *Session) ListTorrents() []*Torrent {
 	return torrents
 }
 
+// StartAll starts all torrents in Session. It returns after all torrents have received the start command.
+// All torrents are tried even if some of them return error. The first error is returned.
+func (s *Session) StartAll() error {
+	return s.forEachTorrent((*Torrent).Start)
+}
+
+// StopAll stops all torrents in Session. It returns after all torrents have received the stop command.
+// Torrents may still be in Stopping state while announcing the stopped event to trackers.
+// All torrents are tried even if some of them return error. The first error is returned.
+func (s *Session) StopAll() error {
+	return s.forEachTorrent((*Torrent).Stop)
+}
+
+// forEachTorrent calls fn for all torrents. Torrents cannot be removed while fn is running.
+func (s *Session) forEachTorrent(fn func(*Torrent) error) error {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	var firstErr error
+	for _, t := range s.torrents {
+		err := fn(t)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// AddTorrent adds a new torrent from the torrent file read from r and starts it.
+// Use AddTorrentOptions with AddOptions.Stopped to add the torrent without starting.
+// If a torrent with the same info hash exists, it is returned with ErrTorrentAlreadyExists.
 func (s *Session) AddTorrent(r io.Reader) (*Torrent, error) {
+	return s.AddTorrentOptions(r, nil)
+}
+
+// AddTorrentOptions is like AddTorrent but the torrent is added with given options. Options may be nil.
+func (s *Session) AddTorrentOptions(r io.Reader, opts *AddOptions) (*Torrent, error) {
+	if opts == nil {
+		opts = &AddOptions{}
+	}
 	mi, err := metainfo.New(r)
 	if err != nil {
-		return nil, err
+		return nil, &InvalidMetainfoError{Err: err}
+	}
+	if t := s.getTorrentByInfoHash(mi.Info.Hash[:]); t != nil {
+		return t, ErrTorrentAlreadyExists
 	}
-	opt, sto, id, err := s.add()
+	opt, sto, id, err := s.add(opts)
 	if err != nil {
 		return nil, err
 	}
@@ -388,14 +691,19 @@ func (s *Session) AddTorrent(r io.Reader) (*Torrent, error) {
 			s.releasePort(uint16(opt.Port))
 		}
 	}()
-	opt.Name = mi.Info.Name
-	opt.Trackers = s.parseTrackers(mi.GetTrackers())
+	opt.Name = sanitizeName(mi.Info.Name)
+	trackers := mi.GetTrackers()
+	opt.Trackers = s.parseTrackers(trackers)
+	opt.WebSeeds = mi.URLList
 	opt.Info = mi.Info
 	var ann *dhtAnnouncer
 	if s.config.DHTEnabled && mi.Info.Private != 1 {
 		ann = newDHTAnnouncer(s.dht, mi.Info.Hash[:], opt.Port)
 		opt.DHT = ann
 	}
+	if s.config.LSDEnabled && mi.Info.Private != 1 {
+		opt.LSD = newLSDAnnouncer(s.lsd, mi.Info.Hash[:], opt.Port)
+	}
 	t, err := opt.NewTorrent(mi.Info.Hash[:], sto)
 	if err != nil {
 		return nil, err
@@ -405,57 +713,107 @@ func (s *Session) AddTorrent(r io.Reader) (*Torrent, error) {
 			t.Close()
 		}
 	}()
-	rspec := &boltdbresumer.Spec{
-		InfoHash:  t.InfoHash(),
-		Dest:      sto.Dest(),
-		Port:      opt.Port,
-		Name:      opt.Name,
-		Trackers:  mi.GetTrackers(),
-		Info:      opt.Info.Bytes,
-		CreatedAt: time.Now().UTC(),
+	rspec := &resumer.Spec{
+		InfoHash:       t.InfoHash(),
+		Dest:           sto.Dest(),
+		StorageType:    opts.Storage,
+		Port:           opt.Port,
+		Name:           opt.Name,
+		Trackers:       trackers,
+		WebSeeds:       opt.WebSeeds,
+		Info:           opt.Info.Bytes,
+		Encoding:       mi.Encoding,
+		CreatedAt:      time.Now().UTC(),
+		UploadDisabled: opt.UploadDisabled,
 	}
 	if opt.Bitfield != nil {
 		rspec.Bitfield = opt.Bitfield.Bytes()
 	}
-	err = opt.Resumer.(*boltdbresumer.Resumer).Write(rspec)
+	err = opt.Resumer.Write(rspec)
 	if err != nil {
 		return nil, err
 	}
-	t2 := s.newTorrent(t, id, uint16(opt.Port), rspec.CreatedAt, ann)
+	t2, err := s.newUniqueTorrent(t, id, uint16(opt.Port), rspec.CreatedAt, ann)
+	if err != nil {
+		return t2, err
+	}
+	t.sendEvent(Event{Type: EventAdded})
+	if opts.Stopped {
+		// Saves the stopped state so the torrent is not started when the session is loaded again.
+		return t2, t2.Stop()
+	}
 	return t2, t2.Start()
 }
 
+// AddURI adds a new torrent from a magnet link, an HTTP(S) URL of a torrent file or a bare info hash and starts it.
+// If a torrent with the same info hash exists, it is returned with ErrTorrentAlreadyExists.
 func (s *Session) AddURI(uri string) (*Torrent, error) {
+	return s.AddURIOptions(uri, nil)
+}
+
+// AddURIOptions is like AddURI but the torrent is added with given options. Options may be nil.
+func (s *Session) AddURIOptions(uri string, opts *AddOptions) (*Torrent, error) {
+	if opts == nil {
+		opts = &AddOptions{}
+	}
+	// A bare info hash is added like a magnet link without trackers, so peers can only be found via DHT.
+	if _, err := magnet.ParseInfoHash(uri); err == nil {
+		if !s.config.DHTEnabled {
+			return nil, ErrDHTDisabled
+		}
+		return s.addMagnet("magnet:?xt=urn:btih:"+uri, opts)
+	}
 	u, err := url.Parse(uri)
 	if err != nil {
 		return nil, err
 	}
 	switch u.Scheme {
 	case "http", "https":
-		return s.addURL(uri)
+		return s.addURL(uri, opts)
 	case "magnet":
-		return s.addMagnet(uri)
+		return s.addMagnet(uri, opts)
 	default:
-		return nil, errors.New("unsupported uri scheme: " + u.Scheme)
+		return nil, &UnsupportedSchemeError{Scheme: u.Scheme}
 	}
 }
 
-func (s *Session) addURL(u string) (*Torrent, error) {
+func (s *Session) addURL(u string, opts *AddOptions) (*Torrent, error) {
 	resp, err := http.Get(u)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	return s.AddTorrent(resp.Body)
+	return s.AddTorrentOptions(resp.Body, opts)
 }
 
-func (s *Session) addMagnet(link string) (*Torrent, error) {
+// AddInfoHash adds a new torrent by its info hash and starts it. hash must be 40 (hex encoded) or 32 (base32 encoded) characters.
+// Metadata and peers are found via trackers and DHT, as if the torrent is added with a magnet link.
+// ErrDHTDisabled is returned if no trackers are given while DHT is disabled.
+func (s *Session) AddInfoHash(hash string, trackers []string) (*Torrent, error) {
+	ih, err := magnet.ParseInfoHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(trackers) == 0 && !s.config.DHTEnabled {
+		return nil, ErrDHTDisabled
+	}
+	return s.addMagnetInfo(&magnet.Magnet{InfoHash: ih, Trackers: trackers}, &AddOptions{})
+}
+
+func (s *Session) addMagnet(link string, opts *AddOptions) (*Torrent, error) {
 	ma, err := magnet.New(link)
 	if err != nil {
 		return nil, err
 	}
-	opt, sto, id, err := s.add()
+	return s.addMagnetInfo(ma, opts)
+}
+
+func (s *Session) addMagnetInfo(ma *magnet.Magnet, opts *AddOptions) (*Torrent, error) {
+	if t := s.getTorrentByInfoHash(ma.InfoHash[:]); t != nil {
+		return t, ErrTorrentAlreadyExists
+	}
+	opt, sto, id, err := s.add(opts)
 	if err != nil {
 		return nil, err
 	}
@@ -464,13 +822,17 @@ func (s *Session) addMagnet(link string) (*Torrent, error) {
 			s.releasePort(uint16(opt.Port))
 		}
 	}()
-	opt.Name = ma.Name
-	opt.Trackers = s.parseTrackers(ma.Trackers)
+	opt.Name = sanitizeName(ma.Name)
+	trackers := magnetTrackers(ma.Trackers)
+	opt.Trackers = s.parseTrackers(trackers)
 	var ann *dhtAnnouncer
 	if s.config.DHTEnabled {
 		ann = newDHTAnnouncer(s.dht, ma.InfoHash[:], opt.Port)
 		opt.DHT = ann
 	}
+	if s.config.LSDEnabled {
+		opt.LSD = newLSDAnnouncer(s.lsd, ma.InfoHash[:], opt.Port)
+	}
 	t, err := opt.NewTorrent(ma.InfoHash[:], sto)
 	if err != nil {
 		return nil, err
@@ -480,23 +842,33 @@ func (s *Session) addMagnet(link string) (*Torrent, error) {
 			t.Close()
 		}
 	}()
-	rspec := &boltdbresumer.Spec{
-		InfoHash:  ma.InfoHash[:],
-		Dest:      sto.Dest(),
-		Port:      opt.Port,
-		Name:      opt.Name,
-		Trackers:  ma.Trackers,
-		CreatedAt: time.Now().UTC(),
-	}
-	err = opt.Resumer.(*boltdbresumer.Resumer).Write(rspec)
+	rspec := &resumer.Spec{
+		InfoHash:       ma.InfoHash[:],
+		Dest:           sto.Dest(),
+		StorageType:    opts.Storage,
+		Port:           opt.Port,
+		Name:           opt.Name,
+		Trackers:       trackers,
+		CreatedAt:      time.Now().UTC(),
+		UploadDisabled: opt.UploadDisabled,
+	}
+	err = opt.Resumer.Write(rspec)
 	if err != nil {
 		return nil, err
 	}
-	t2 := s.newTorrent(t, id, uint16(opt.Port), rspec.CreatedAt, ann)
+	t2, err := s.newUniqueTorrent(t, id, uint16(opt.Port), rspec.CreatedAt, ann)
+	if err != nil {
+		return t2, err
+	}
+	t.sendEvent(Event{Type: EventAdded})
+	if opts.Stopped {
+		// Saves the stopped state so the torrent is not started when the session is loaded again.
+		return t2, t2.Stop()
+	}
 	return t2, t2.Start()
 }
 
-func (s *Session) add() (*options, *filestorage.FileStorage, string, error) {
+func (s *Session) add(opts *AddOptions) (*options, storage.Storage, string, error) {
 	port, err := s.getPort()
 	if err != nil {
 		return nil, nil, "", err
@@ -508,23 +880,45 @@ func (s *Session) add() (*options, *filestorage.FileStorage, string, error) {
 	}()
 	u1 := uuid.NewV1()
 	id := base64.RawURLEncoding.EncodeToString(u1[:])
-	res, err := boltdbresumer.New(s.db, torrentsBucket, []byte(id))
+	res, err := s.resumers.New(id)
 	if err != nil {
 		return nil, nil, "", err
 	}
 	dest := filepath.Join(s.config.DataDir, id)
-	sto, err := filestorage.New(dest)
+	sto, err := newStorage(opts.Storage, dest)
 	if err != nil {
 		return nil, nil, "", err
 	}
 	return &options{
-		Port:      int(port),
-		Resumer:   res,
-		Blocklist: s.blocklist,
-		Config:    &s.config,
+		ID:              id,
+		Port:            int(port),
+		Resumer:         res,
+		Blocklist:       s.blocklist,
+		Config:          &s.config,
+		VerifierPool:    s.verifierPool,
+		PieceCache:      s.pieceCache,
+		VerifierQueue:   s.verifierQueue,
+		DownloadLimiter: s.downloadLimiter,
+		UploadLimiter:   s.uploadLimiter,
+		Handshakes:      s.handshakes,
+		HalfOpen:        s.halfOpen,
+		WriteSemaphore:  s.writeSemaphore,
+		Events:          s.events,
+		UploadDisabled:  s.config.NoUpload,
 	}, sto, id, nil
 }
 
+// getTorrentByInfoHash returns the torrent with the info hash or nil if there is no such torrent in Session.
+func (s *Session) getTorrentByInfoHash(infoHash []byte) *Torrent {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	torrents := s.torrentsByInfoHash[dht.InfoHash(infoHash)]
+	if len(torrents) == 0 {
+		return nil
+	}
+	return torrents[0]
+}
+
 func (s *Session) newTorrent(t *torrent, id string, port uint16, createdAt time.Time, ann *dhtAnnouncer) *Torrent {
 	t2 := &Torrent{
 		session:      s,
@@ -537,25 +931,190 @@ func (s *Session) newTorrent(t *torrent, id string, port uint16, createdAt time.
 	}
 	s.m.Lock()
 	defer s.m.Unlock()
-	s.torrents[id] = t2
-	ih := dht.InfoHash(t.InfoHash())
-	s.torrentsByInfoHash[ih] = append(s.torrentsByInfoHash[ih], t2)
+	s.insertTorrent(t2)
 	return t2
 }
 
+// newUniqueTorrent is like newTorrent but the torrent is not added if there is another torrent with the same info hash.
+// The existing torrent is returned with ErrTorrentAlreadyExists and the resume data of the new torrent is deleted.
+// Check and insert are done under the same lock, so concurrent adds of the same torrent cannot create duplicates.
+func (s *Session) newUniqueTorrent(t *torrent, id string, port uint16, createdAt time.Time, ann *dhtAnnouncer) (*Torrent, error) {
+	t2 := &Torrent{
+		session:      s,
+		torrent:      t,
+		id:           id,
+		port:         port,
+		createdAt:    createdAt,
+		dhtAnnouncer: ann,
+		removed:      make(chan struct{}),
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	if torrents := s.torrentsByInfoHash[dht.InfoHash(t.InfoHash())]; len(torrents) > 0 {
+		err := s.resumers.Delete(id)
+		if err != nil {
+			s.log.Error(err)
+		}
+		return torrents[0], ErrTorrentAlreadyExists
+	}
+	s.insertTorrent(t2)
+	return t2, nil
+}
+
+// insertTorrent adds the torrent to the maps of Session. s.m must be locked for writing.
+func (s *Session) insertTorrent(t *Torrent) {
+	s.torrents[t.id] = t
+	ih := dht.InfoHash(t.torrent.InfoHash())
+	s.torrentsByInfoHash[ih] = append(s.torrentsByInfoHash[ih], t)
+}
+
 func (s *Session) getPort() (uint16, error) {
 	s.mPorts.Lock()
 	defer s.mPorts.Unlock()
 	for p := range s.availablePorts {
 		delete(s.availablePorts, p)
+		if !canListenPort(s.listenIP(), p) {
+			s.log.Warningf("port %d is used by another process, skipping", p)
+			s.badPorts[p] = struct{}{}
+			continue
+		}
 		return p, nil
 	}
-	return 0, errors.New("no free port")
+	// Other processes may have released the ports since they are checked.
+	for p := range s.badPorts {
+		if canListenPort(s.listenIP(), p) {
+			delete(s.badPorts, p)
+			return p, nil
+		}
+	}
+	if s.config.AllowEphemeralPorts {
+		for i := 0; i < maxEphemeralPortAttempts; i++ {
+			p, err := ephemeralPort(s.listenIP())
+			if err != nil {
+				s.log.Errorln("cannot get ephemeral port:", err.Error())
+				break
+			}
+			if !s.takeEphemeralPort(p) {
+				continue
+			}
+			s.log.Infof("all ports in range are used, listening port %d assigned by the system", p)
+			return p, nil
+		}
+	}
+	return 0, &NoFreePortError{Begin: s.config.PortBegin, End: s.config.PortEnd, Bad: len(s.badPorts)}
+}
+
+// takeEphemeralPort removes the port assigned by the operating system from the free and bad port sets
+// if it is in configured range, or adds it to the ephemeral port set otherwise. Returns false if the port is
+// assigned to another torrent, which may happen if the torrent is stopped and not listening. s.mPorts must be locked.
+func (s *Session) takeEphemeralPort(p uint16) bool {
+	if p < s.config.PortBegin || p >= s.config.PortEnd {
+		if _, ok := s.ephemeralPorts[p]; ok {
+			return false
+		}
+		s.ephemeralPorts[p] = struct{}{}
+		return true
+	}
+	if _, ok := s.availablePorts[p]; ok {
+		delete(s.availablePorts, p)
+		return true
+	}
+	if _, ok := s.badPorts[p]; ok {
+		delete(s.badPorts, p)
+		return true
+	}
+	return false
+}
+
+// newDHTConfig returns the config of DHT node.
+// The routing table is saved by the library to a fixed location in home directory if DHTSaveRoutingTable is set.
+func newDHTConfig(cfg *Config) *dht.Config {
+	dhtConfig := dht.NewConfig()
+	dhtConfig.Address = dhtAddress(cfg)
+	if ip := net.ParseIP(dhtConfig.Address); ip != nil && ip.To4() == nil {
+		dhtConfig.UDPProto = "udp6"
+	}
+	dhtConfig.Port = int(cfg.DHTPort)
+	dhtConfig.DHTRouters = "router.bittorrent.com:6881,dht.transmissionbt.com:6881,router.utorrent.com:6881,dht.libtorrent.org:25401,dht.aelitis.com:6881"
+	dhtConfig.SaveRoutingTable = cfg.DHTSaveRoutingTable
+	return dhtConfig
+}
+
+// dhtAddress returns the IP address that DHT node listens on.
+// Config.ListenAddress is used if DHTAddress is not changed from its default.
+func dhtAddress(cfg *Config) string {
+	if cfg.ListenAddress != "" && (cfg.DHTAddress == "" || cfg.DHTAddress == DefaultConfig.DHTAddress) {
+		return cfg.ListenAddress
+	}
+	return cfg.DHTAddress
+}
+
+// listenIP returns the IP address in Config.ListenAddress. Nil means all interfaces.
+func (s *Session) listenIP() net.IP {
+	return net.ParseIP(s.config.ListenAddress)
+}
+
+// canListenPort returns true if a TCP listener can be opened at port.
+func canListenPort(ip net.IP, port uint16) bool {
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: ip, Port: int(port)})
+	if err != nil {
+		return false
+	}
+	l.Close()
+	return true
+}
+
+// maxEphemeralPortAttempts is the number of times a port is requested from the operating system
+// when the assigned port is used by another torrent.
+const maxEphemeralPortAttempts = 10
+
+// ephemeralPort returns a free port assigned by the operating system.
+// The port is reported to trackers and DHT like the ports in configured range.
+func ephemeralPort(ip net.IP) (uint16, error) {
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: ip})
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return uint16(l.Addr().(*net.TCPAddr).Port), nil
+}
+
+// PortStats contains the usage of ports that are assigned to torrents.
+type PortStats struct {
+	// Number of ports in range PortBegin-PortEnd.
+	Total int
+	// Ports assigned to torrents.
+	Used int
+	// Ports available for new torrents.
+	Free int
+	// Ports skipped because they are used by other processes.
+	Bad int
+}
+
+// PortStats returns the number of used and free ports in session's port range.
+func (s *Session) PortStats() PortStats {
+	s.mPorts.Lock()
+	defer s.mPorts.Unlock()
+	var stats PortStats
+	stats.Total = int(s.config.PortEnd - s.config.PortBegin)
+	for p := range s.availablePorts {
+		if p >= s.config.PortBegin && p < s.config.PortEnd {
+			stats.Free++
+		}
+	}
+	stats.Bad = len(s.badPorts)
+	stats.Used = stats.Total - stats.Free - stats.Bad
+	return stats
 }
 
 func (s *Session) releasePort(port uint16) {
 	s.mPorts.Lock()
 	defer s.mPorts.Unlock()
+	// Ephemeral ports are returned to the operating system.
+	if port < s.config.PortBegin || port >= s.config.PortEnd {
+		delete(s.ephemeralPorts, port)
+		return
+	}
 	s.availablePorts[port] = struct{}{}
 }
 
@@ -566,6 +1125,11 @@ func (s *Session) GetTorrent(id string) *Torrent {
 }
 
 func (s *Session) RemoveTorrent(id string) error {
+	return s.removeTorrent(id, false)
+}
+
+// removeTorrent removes the torrent from the session and deletes its files unless keepData is true.
+func (s *Session) removeTorrent(id string, keepData bool) error {
 	s.m.Lock()
 	defer s.m.Unlock()
 	t, ok := s.torrents[id]
@@ -574,15 +1138,16 @@ func (s *Session) RemoveTorrent(id string) error {
 	}
 	close(t.removed)
 	t.torrent.Close()
+	t.torrent.sendEvent(Event{Type: EventRemoved})
 	delete(s.torrents, id)
 	delete(s.torrentsByInfoHash, dht.InfoHash(t.torrent.InfoHash()))
 	s.releasePort(t.port)
-	subBucket := id
-	err := s.db.Update(func(tx *bolt.Tx) error {
-		return tx.Bucket(torrentsBucket).DeleteBucket([]byte(subBucket))
-	})
+	err := s.resumers.Delete(id)
 	if err != nil {
 		return err
 	}
-	return os.RemoveAll(t.torrent.storage.(*filestorage.FileStorage).Dest())
+	if keepData {
+		return nil
+	}
+	return os.RemoveAll(t.torrent.storage.Dest())
 }
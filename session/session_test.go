@@ -0,0 +1,502 @@
+package session
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestSession returns a Session that keeps its database and data in a temporary directory.
+// DHT, LSD and RPC server are disabled. Config can be modified with fn before the Session is created.
+func newTestSession(t *testing.T, fn func(*Config)) (*Session, func()) {
+	dir, err := ioutil.TempDir("", "rain-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig
+	cfg.Database = filepath.Join(dir, "session.db")
+	cfg.DataDir = filepath.Join(dir, "data")
+	cfg.DHTEnabled = false
+	cfg.LSDEnabled = false
+	cfg.RPCHost = ""
+	// Raising the hard limit of open files is not permitted for unprivileged users.
+	cfg.MaxOpenFiles = 1024
+	if fn != nil {
+		fn(&cfg)
+	}
+	s, err := New(cfg)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return s, func() {
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.PortBegin, cfg.PortEnd = 6000, 5000
+	_, err := New(cfg)
+	e, ok := err.(*InvalidConfigError)
+	if !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !e.Is(ErrInvalidConfig) {
+		t.Fatal("error is not ErrInvalidConfig")
+	}
+}
+
+func TestNewNegativeHashWorkers(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.HashWorkers = -1
+	_, err := New(cfg)
+	if _, ok := err.(*InvalidConfigError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestNewPeerBufferSize(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.PeerReadBufferSize = minPeerBufferSize - 1
+	if _, err := New(cfg); err == nil {
+		t.Fatal("small read buffer is accepted")
+	} else if _, ok := err.(*InvalidConfigError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg = DefaultConfig
+	cfg.PeerWriteBufferSize = minPeerBufferSize - 1
+	if _, err := New(cfg); err == nil {
+		t.Fatal("small write buffer is accepted")
+	} else if _, ok := err.(*InvalidConfigError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestNewNegativeParallelWrites(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.ParallelWrites = -1
+	if _, err := New(cfg); err == nil {
+		t.Fatal("negative parallel writes is accepted")
+	} else if _, ok := err.(*InvalidConfigError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestNewResumeWriteIntervals(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.StatsWriteInterval = -time.Second
+	if _, err := New(cfg); err == nil {
+		t.Fatal("negative stats write interval is accepted")
+	} else if _, ok := err.(*InvalidConfigError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg = DefaultConfig
+	cfg.BitfieldWriteInterval = -time.Second
+	if _, err := New(cfg); err == nil {
+		t.Fatal("negative bitfield write interval is accepted")
+	} else if _, ok := err.(*InvalidConfigError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s, closeSession := newTestSession(t, func(cfg *Config) {
+		cfg.BitfieldWriteInterval = 0
+		cfg.StatsWriteInterval = 0
+	})
+	defer closeSession()
+	if s.config.BitfieldWriteInterval != DefaultConfig.BitfieldWriteInterval {
+		t.Fatalf("unexpected bitfield write interval: %s", s.config.BitfieldWriteInterval)
+	}
+	if s.config.StatsWriteInterval != DefaultConfig.StatsWriteInterval {
+		t.Fatalf("unexpected stats write interval: %s", s.config.StatsWriteInterval)
+	}
+}
+
+func TestNewNegativeDialLimits(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.MaxHalfOpenConnections = -1
+	if _, err := New(cfg); err == nil {
+		t.Fatal("negative half-open connection limit is accepted")
+	} else if _, ok := err.(*InvalidConfigError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg = DefaultConfig
+	cfg.DialInterval = -time.Second
+	if _, err := New(cfg); err == nil {
+		t.Fatal("negative dial interval is accepted")
+	} else if _, ok := err.(*InvalidConfigError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestDHTAddress(t *testing.T) {
+	cases := []struct {
+		listen, dht, expected string
+	}{
+		{listen: "", dht: "0.0.0.0", expected: "0.0.0.0"},
+		{listen: "10.0.0.1", dht: "0.0.0.0", expected: "10.0.0.1"},
+		{listen: "10.0.0.1", dht: "", expected: "10.0.0.1"},
+		{listen: "10.0.0.1", dht: "10.0.0.2", expected: "10.0.0.2"},
+		{listen: "fd00::1", dht: "0.0.0.0", expected: "fd00::1"},
+	}
+	for _, c := range cases {
+		cfg := DefaultConfig
+		cfg.ListenAddress = c.listen
+		cfg.DHTAddress = c.dht
+		if addr := dhtAddress(&cfg); addr != c.expected {
+			t.Errorf("dhtAddress(%q, %q) = %q, want %q", c.listen, c.dht, addr, c.expected)
+		}
+	}
+}
+
+func TestNewDHTConfig(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.DHTAddress = "::1"
+	cfg.DHTPort = 1234
+	dhtConfig := newDHTConfig(&cfg)
+	if dhtConfig.SaveRoutingTable {
+		t.Fatal("routing table is saved by default")
+	}
+	if dhtConfig.Address != "::1" || dhtConfig.UDPProto != "udp6" || dhtConfig.Port != 1234 {
+		t.Fatalf("unexpected address: %s %s:%d", dhtConfig.UDPProto, dhtConfig.Address, dhtConfig.Port)
+	}
+	cfg.DHTSaveRoutingTable = true
+	if !newDHTConfig(&cfg).SaveRoutingTable {
+		t.Fatal("routing table is not saved")
+	}
+}
+
+func TestNewZeroTrackerMaxRetryInterval(t *testing.T) {
+	s, closeSession := newTestSession(t, func(cfg *Config) {
+		cfg.TrackerMaxRetryInterval = 0
+	})
+	defer closeSession()
+	if s.config.TrackerMaxRetryInterval != DefaultConfig.TrackerMaxRetryInterval {
+		t.Fatal("zero tracker max retry interval must be replaced with default")
+	}
+}
+
+func TestPieceCacheSizeAlias(t *testing.T) {
+	s, closeSession := newTestSession(t, func(cfg *Config) {
+		cfg.PieceCacheSize = 1 << 20
+	})
+	defer closeSession()
+	if s.config.ReadCacheSize != 1<<20 {
+		t.Fatalf("PieceCacheSize is not used as ReadCacheSize: %d", s.config.ReadCacheSize)
+	}
+}
+
+func TestTakeEphemeralPort(t *testing.T) {
+	s := &Session{
+		availablePorts: map[uint16]struct{}{6001: {}},
+		badPorts:       map[uint16]struct{}{6002: {}},
+		ephemeralPorts: make(map[uint16]struct{}),
+	}
+	s.config.PortBegin, s.config.PortEnd = 6000, 6003
+	if !s.takeEphemeralPort(7000) {
+		t.Fatal("port out of range must be taken")
+	}
+	if s.takeEphemeralPort(7000) {
+		t.Fatal("port out of range that is assigned to another torrent must not be taken")
+	}
+	s.releasePort(7000)
+	if _, ok := s.ephemeralPorts[7000]; ok {
+		t.Fatal("released port is not removed from ephemeral ports")
+	}
+	if _, ok := s.availablePorts[7000]; ok {
+		t.Fatal("port out of range is added to available ports")
+	}
+	if !s.takeEphemeralPort(7000) {
+		t.Fatal("released port must be taken")
+	}
+	if !s.takeEphemeralPort(6001) {
+		t.Fatal("free port must be taken")
+	}
+	if _, ok := s.availablePorts[6001]; ok {
+		t.Fatal("taken port is not removed from available ports")
+	}
+	if !s.takeEphemeralPort(6002) {
+		t.Fatal("bad port released by other process must be taken")
+	}
+	if _, ok := s.badPorts[6002]; ok {
+		t.Fatal("taken port is not removed from bad ports")
+	}
+	if s.takeEphemeralPort(6000) {
+		t.Fatal("port of another torrent must not be taken")
+	}
+}
+
+func TestPortStats(t *testing.T) {
+	s, closeSession := newTestSession(t, func(cfg *Config) {
+		cfg.PortBegin, cfg.PortEnd = 47000, 47002
+		cfg.AllowEphemeralPorts = false
+	})
+	defer closeSession()
+
+	if ps := s.PortStats(); ps.Total != 2 || ps.Free != 2 || ps.Used != 0 {
+		t.Fatalf("unexpected port stats: %+v", ps)
+	}
+	var ids []string
+	for _, uri := range []string{
+		"magnet:?xt=urn:btih:0102030405060708090a0b0c0d0e0f1011121314",
+		"magnet:?xt=urn:btih:1102030405060708090a0b0c0d0e0f1011121314",
+	} {
+		tor, err := s.AddURIOptions(uri, &AddOptions{Stopped: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, tor.ID())
+	}
+	// Ports that are used by other processes are counted as bad.
+	if ps := s.PortStats(); ps.Free != 0 || ps.Used+ps.Bad != 2 {
+		t.Fatalf("unexpected port stats: %+v", ps)
+	}
+	_, err := s.AddURIOptions("magnet:?xt=urn:btih:2102030405060708090a0b0c0d0e0f1011121314", &AddOptions{Stopped: true})
+	if _, ok := err.(*NoFreePortError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = s.RemoveTorrent(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if ps := s.PortStats(); ps.Free != 1 {
+		t.Fatalf("port is not released: %+v", ps)
+	}
+}
+
+func TestAddTorrentConcurrently(t *testing.T) {
+	s, closeSession := newTestSession(t, nil)
+	defer closeSession()
+
+	const n = 10
+	var wg sync.WaitGroup
+	torrents := make([]*Torrent, n)
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			f, err := os.Open(torrentFile)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer f.Close()
+			torrents[i], errs[i] = s.AddTorrentOptions(f, &AddOptions{Stopped: true})
+		}(i)
+	}
+	wg.Wait()
+
+	var added int
+	for i, err := range errs {
+		switch err {
+		case nil:
+			added++
+		case ErrTorrentAlreadyExists:
+		default:
+			t.Fatal(err)
+		}
+		if torrents[i] == nil || torrents[i] != torrents[0] {
+			t.Fatal("different torrent is returned")
+		}
+	}
+	if added != 1 {
+		t.Fatalf("torrent is added %d times", added)
+	}
+	if l := len(s.ListTorrents()); l != 1 {
+		t.Fatalf("unexpected number of torrents in session: %d", l)
+	}
+}
+
+func TestStopAnnounceError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("event") == "stopped" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("d8:intervali1800e5:peers0:e")) // nolint: errcheck
+	}))
+	defer srv.Close()
+
+	s, closeSession := newTestSession(t, func(cfg *Config) {
+		cfg.StopAnnounceTimeout = 0
+	})
+	// Session is closed by the test. Only the temporary directory needs to be removed.
+	defer os.RemoveAll(filepath.Dir(s.config.Database))
+	closed := false
+	defer func() {
+		if !closed {
+			closeSession()
+		}
+	}()
+	if s.config.StopAnnounceTimeout != DefaultConfig.StopAnnounceTimeout {
+		t.Fatal("zero stop announce timeout must be replaced with default")
+	}
+
+	trackerURL := srv.URL + "/announce"
+	tor, err := s.AddURI("magnet:?xt=urn:btih:0102030405060708090a0b0c0d0e0f1011121314&tr=" + trackerURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Stopped event is only sent to trackers that the torrent is announced to.
+	for deadline := time.Now().Add(10 * time.Second); ; {
+		trackers := tor.Trackers()
+		if len(trackers) == 1 && trackers[0].Status == Working {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("torrent is not announced")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	closed = true
+	err = s.Close()
+	e, ok := err.(*StopAnnounceError)
+	if !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !e.Is(ErrStopAnnounce) {
+		t.Fatal("error is not ErrStopAnnounce")
+	}
+	if len(e.Trackers) != 1 || e.Trackers[0] != trackerURL {
+		t.Fatalf("unexpected failed trackers: %v", e.Trackers)
+	}
+}
+
+func TestNewNegativeStopAnnounceTimeout(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.StopAnnounceTimeout = -1
+	_, err := New(cfg)
+	if _, ok := err.(*InvalidConfigError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAddInfoHash(t *testing.T) {
+	s, closeSession := newTestSession(t, nil)
+	defer closeSession()
+
+	if _, err := s.AddInfoHash("F60CC95E3566AF84C1AB223FD4CE80FA88E6438A", nil); err != ErrDHTDisabled {
+		t.Fatalf("unexpected error without trackers while DHT is disabled: %v", err)
+	}
+	if _, err := s.AddInfoHash("F60CC95E", []string{"http://127.0.0.1:1/announce"}); err == nil {
+		t.Fatal("invalid info hash must be rejected")
+	}
+	tor, err := s.AddInfoHash("F60CC95E3566AF84C1AB223FD4CE80FA88E6438A", []string{"http://127.0.0.1:1/announce"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ih := tor.InfoHash().String(); ih != "f60cc95e3566af84c1ab223fd4ce80fa88e6438a" {
+		t.Fatalf("unexpected info hash: %s", ih)
+	}
+	if trackers := tor.Trackers(); len(trackers) != 1 || trackers[0].URL != "http://127.0.0.1:1/announce" {
+		t.Fatalf("unexpected trackers: %v", trackers)
+	}
+	// Same info hash in base32 encoding.
+	tor2, err := s.AddInfoHash("6YGMSXRVM2XYJQNLEI75JTUA7KEOMQ4K", []string{"http://127.0.0.1:1/announce"})
+	if err != ErrTorrentAlreadyExists || tor2 != tor {
+		t.Fatalf("existing torrent is not returned: %v", err)
+	}
+}
+
+func TestLoadInvalidInfoWithoutDHT(t *testing.T) {
+	s, closeSession := newTestSession(t, func(cfg *Config) {
+		cfg.DHTEnabled = true
+		cfg.DHTAddress = "127.0.0.1"
+		cfg.DHTPort = 0
+	})
+	// Session is closed and loaded again by the test. Only the temporary directory needs to be removed.
+	defer os.RemoveAll(filepath.Dir(s.config.Database))
+	closed := false
+	defer func() {
+		if !closed {
+			closeSession()
+		}
+	}()
+
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tor, err := s.AddTorrentOptions(f, &AddOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tor.dhtAnnouncer == nil {
+		t.Fatal("DHT is not used for public torrent")
+	}
+	res, err := s.resumers.New(tor.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = res.WriteInfo([]byte("invalid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Info that cannot be parsed may belong to a private torrent.
+	closed = true
+	s.Close()
+	s, err = New(s.config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	loaded := s.GetTorrent(tor.ID())
+	if loaded.dhtAnnouncer != nil || loaded.torrent.dhtNode != nil {
+		t.Fatal("DHT is used for torrent with invalid info")
+	}
+}
+
+func TestStartAllStopAll(t *testing.T) {
+	s, closeSession := newTestSession(t, nil)
+	defer closeSession()
+
+	var torrents []*Torrent
+	for _, uri := range []string{
+		"magnet:?xt=urn:btih:0102030405060708090a0b0c0d0e0f1011121314",
+		"magnet:?xt=urn:btih:1102030405060708090a0b0c0d0e0f1011121314",
+	} {
+		tor, err := s.AddURIOptions(uri, &AddOptions{Stopped: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		torrents = append(torrents, tor)
+	}
+
+	if err := s.StartAll(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tor := range torrents {
+		if status := tor.Stats().Status; status != DownloadingMetadata {
+			t.Fatalf("torrent is %s after StartAll", status)
+		}
+	}
+
+	// Individual commands may be sent at the same time.
+	doneC := make(chan error)
+	go func() {
+		doneC <- torrents[0].Start()
+	}()
+	if err := s.StopAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-doneC; err != nil {
+		t.Fatal(err)
+	}
+	if err := torrents[0].Stop(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tor := range torrents {
+		if status := tor.Stats().Status; status != Stopped && status != Stopping {
+			t.Fatalf("torrent is %s after StopAll", status)
+		}
+	}
+}
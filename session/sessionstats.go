@@ -0,0 +1,70 @@
+package session
+
+import "expvar"
+
+// SessionStats contains statistics about all torrents in Session.
+type SessionStats struct {
+	Torrents struct {
+		// Number of torrents in Session.
+		Total int
+		// Number of torrents in each status.
+		ByStatus map[TorrentStatus]int
+	}
+	// Number of peers that are connected to all torrents.
+	Peers int
+	// Sum of download and upload speeds of torrents in bytes/s.
+	Speed struct {
+		Download uint
+		Upload   uint
+	}
+	DHT struct {
+		// Number of nodes in the DHT routing table. Zero if DHT is disabled.
+		Nodes int
+	}
+	Bytes struct {
+		// Bytes of piece data downloaded from and uploaded to peers.
+		Downloaded int64
+		Uploaded   int64
+		// Bytes of protocol messages excluding piece data. See Stats.Bytes.DownloadedOverhead.
+		DownloadedOverhead int64
+		UploadedOverhead   int64
+	}
+	// Cache for piece read requests that is shared by all torrents.
+	ReadCache struct {
+		// Number of bytes in cache. Max size is Config.ReadCacheSize.
+		Size int64
+		// Number of reads served from cache and read from disk.
+		Hits   int64
+		Misses int64
+	}
+}
+
+// Stats returns statistics summed over all torrents in Session.
+func (s *Session) Stats() SessionStats {
+	var stats SessionStats
+	stats.Torrents.ByStatus = make(map[TorrentStatus]int)
+	// Session lock is not held while waiting for torrents, so a busy torrent does not block other Session methods.
+	for _, t := range s.ListTorrents() {
+		ts := t.torrent.Stats()
+		stats.Torrents.Total++
+		stats.Torrents.ByStatus[ts.Status]++
+		stats.Peers += ts.Peers.Total
+		stats.Speed.Download += ts.Speed.Download
+		stats.Speed.Upload += ts.Speed.Upload
+		stats.Bytes.Downloaded += ts.Bytes.Downloaded
+		stats.Bytes.Uploaded += ts.Bytes.Uploaded
+		stats.Bytes.DownloadedOverhead += ts.Bytes.DownloadedOverhead
+		stats.Bytes.UploadedOverhead += ts.Bytes.UploadedOverhead
+	}
+	cs := s.pieceCache.Stats()
+	stats.ReadCache.Size = cs.Size
+	stats.ReadCache.Hits = cs.Hits
+	stats.ReadCache.Misses = cs.Misses
+	if s.dht != nil {
+		// DHT package does not have an API for the routing table size. It is published as an expvar.
+		if v, ok := expvar.Get("totalNodes").(*expvar.Int); ok {
+			stats.DHT.Nodes = int(v.Value())
+		}
+	}
+	return stats
+}
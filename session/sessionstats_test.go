@@ -0,0 +1,104 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatsDoesNotHoldLock(t *testing.T) {
+	s, closeSession := newTestSession(t, nil)
+	defer closeSession()
+
+	// Run loop of this torrent is not running, so its Stats blocks until the request is served by the test.
+	busy := &torrent{
+		statsCommandC: make(chan statsRequest),
+		closeC:        make(chan chan struct{}),
+	}
+	s.m.Lock()
+	s.torrents["busy"] = &Torrent{torrent: busy, removed: make(chan struct{})}
+	s.m.Unlock()
+
+	statsDoneC := make(chan SessionStats)
+	go func() {
+		statsDoneC <- s.Stats()
+	}()
+	req := <-busy.statsCommandC
+
+	lockedC := make(chan struct{})
+	go func() {
+		s.m.Lock()
+		delete(s.torrents, "busy")
+		s.m.Unlock()
+		close(lockedC)
+	}()
+	select {
+	case <-lockedC:
+	case <-time.After(time.Second):
+		t.Fatal("session lock is held while waiting for torrent stats")
+	}
+
+	req.Response <- Stats{Status: Downloading}
+	stats := <-statsDoneC
+	if stats.Torrents.Total != 1 || stats.Torrents.ByStatus[Downloading] != 1 {
+		t.Fatalf("unexpected stats: %+v", stats.Torrents)
+	}
+}
+
+func TestStatsSum(t *testing.T) {
+	s, closeSession := newTestSession(t, nil)
+	defer closeSession()
+
+	addTorrent := func(id string, ts Stats) {
+		tor := &torrent{
+			statsCommandC: make(chan statsRequest),
+			closeC:        make(chan chan struct{}),
+		}
+		go func() {
+			req := <-tor.statsCommandC
+			req.Response <- ts
+		}()
+		s.m.Lock()
+		s.torrents[id] = &Torrent{torrent: tor, removed: make(chan struct{})}
+		s.m.Unlock()
+	}
+	var seeding, downloading Stats
+	seeding.Status = Seeding
+	seeding.Peers.Total = 2
+	seeding.Speed.Upload = 100
+	seeding.Bytes.Uploaded = 1000
+	seeding.Bytes.UploadedOverhead = 10
+	downloading.Status = Downloading
+	downloading.Peers.Total = 3
+	downloading.Speed.Download = 200
+	downloading.Speed.Upload = 50
+	downloading.Bytes.Downloaded = 2000
+	downloading.Bytes.Uploaded = 500
+	downloading.Bytes.DownloadedOverhead = 20
+	addTorrent("seeding", seeding)
+	addTorrent("downloading", downloading)
+	// Run loops of fake torrents are not running, so they cannot be closed with the session.
+	defer func() {
+		s.m.Lock()
+		delete(s.torrents, "seeding")
+		delete(s.torrents, "downloading")
+		s.m.Unlock()
+	}()
+
+	stats := s.Stats()
+	if stats.Torrents.Total != 2 || stats.Torrents.ByStatus[Seeding] != 1 || stats.Torrents.ByStatus[Downloading] != 1 {
+		t.Fatalf("unexpected torrent counts: %+v", stats.Torrents)
+	}
+	if stats.Peers != 5 {
+		t.Fatalf("unexpected peers: %d", stats.Peers)
+	}
+	if stats.Speed.Download != 200 || stats.Speed.Upload != 150 {
+		t.Fatalf("unexpected speed: %+v", stats.Speed)
+	}
+	if stats.Bytes.Downloaded != 2000 || stats.Bytes.Uploaded != 1500 || stats.Bytes.DownloadedOverhead != 20 || stats.Bytes.UploadedOverhead != 10 {
+		t.Fatalf("unexpected bytes: %+v", stats.Bytes)
+	}
+	// DHT is disabled in test session.
+	if stats.DHT.Nodes != 0 {
+		t.Fatalf("unexpected DHT nodes: %d", stats.DHT.Nodes)
+	}
+}
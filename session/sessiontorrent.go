@@ -2,9 +2,11 @@ package session
 
 import (
 	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
 	"time"
 
-	"github.com/boltdb/bolt"
 	"github.com/nictuku/dht"
 )
 
@@ -47,24 +49,177 @@ func (t *Torrent) Stats() Stats {
 	return t.torrent.Stats()
 }
 
+// Trackers returns the trackers of the torrent with the result of their last announce.
+// Trackers in a tier that are not announced to yet are returned with NotContactedYet status.
 func (t *Torrent) Trackers() []Tracker {
 	return t.torrent.Trackers()
 }
 
-func (t *Torrent) Peers() []Peer {
+// Peers returns the list of connected peers with their transfer speeds and protocol states.
+func (t *Torrent) Peers() []PeerStats {
 	return t.torrent.Peers()
 }
 
+// ActiveDownloads returns the pieces that are being downloaded, the peers they are downloaded from and their progress.
+func (t *Torrent) ActiveDownloads() []ActiveDownload {
+	return t.torrent.ActiveDownloads()
+}
+
+// WriteTorrent writes the torrent file to w.
+// Torrents added with magnet links can be written after the metadata is downloaded from peers.
+func (t *Torrent) WriteTorrent(w io.Writer) error {
+	return t.torrent.WriteTorrent(w)
+}
+
+// Magnet returns a magnet link with the info hash, name and trackers of the torrent.
+func (t *Torrent) Magnet() string {
+	return t.torrent.Magnet()
+}
+
+// AnnounceNow asks trackers and DHT for new peers without waiting for the announce interval.
+// Trackers and DHT that have been announced in their min interval are announced at the end of min interval.
+// Returns ErrTorrentStopped if the torrent is not running.
+func (t *Torrent) AnnounceNow() error {
+	return t.torrent.AnnounceNow()
+}
+
+// Scrape asks all trackers of the torrent for the number of seeders, leechers and completed downloads in the swarm.
+// The maximum of values reported by trackers is returned and also included in Stats.
+func (t *Torrent) Scrape() (ScrapeResult, error) {
+	return t.torrent.Scrape()
+}
+
+// SetPiecePriorities changes the download priorities of pieces. Keys of the map are piece indexes.
+// Pieces that are not in the map keep their current priority. Pieces with PrioritySkip are not downloaded
+// and the torrent completes when all other pieces are downloaded. Priorities are saved in resume data.
+func (t *Torrent) SetPiecePriorities(priorities map[int]Priority) error {
+	return t.torrent.SetPiecePriorities(priorities)
+}
+
+// ResetStats sets downloaded, uploaded, wasted bytes and seed duration of the torrent to zero.
+// New values are saved in resume data and EventStatsReset is sent. Trackers see the reset values in next announce.
+func (t *Torrent) ResetStats() error {
+	return t.torrent.ResetStats()
+}
+
+// Verify hashes all pieces on disk again and downloads the ones that fail the check.
+// Running torrent is restarted; a stopped torrent is verified on next start.
+// Progress is reported in Stats.Pieces.Checked.
+func (t *Torrent) Verify() error {
+	return t.torrent.Verify()
+}
+
+// Rename changes the name of the torrent and moves its files on disk.
+// Torrent must be stopped before renaming, otherwise ErrTorrentNotStopped is returned.
+func (t *Torrent) Rename(name string) error {
+	return t.torrent.Rename(name)
+}
+
+// SetName changes the name of the torrent returned from Name and Stats. Setting is saved in resume data.
+// The name is kept when metadata is downloaded or the file of a single file torrent is renamed.
+// Unlike Rename, files on disk are not moved and the torrent does not need to be stopped.
+func (t *Torrent) SetName(name string) error {
+	return t.torrent.SetName(name)
+}
+
+// RenameFile changes the name of the file at index in torrent and moves it on disk.
+// Directory of the file does not change. Torrent must be stopped before renaming, otherwise ErrTorrentNotStopped is returned.
+func (t *Torrent) RenameFile(index int, name string) error {
+	return t.torrent.RenameFile(index, name)
+}
+
+// SetUploadEnabled enables or disables uploading data to peers. Setting is saved in resume data.
+// When disabled, all peers are kept choked while downloading continues.
+func (t *Torrent) SetUploadEnabled(enabled bool) error {
+	return t.torrent.SetUploadEnabled(enabled)
+}
+
+// SetSeedGoal sets the seeding limits of the torrent that override SeedRatioLimit and SeedTimeLimit in Config.
+// Setting is saved in resume data. Zero value disables the limit.
+// The torrent is stopped when either limit is reached and it is not started when the session is loaded again.
+// It is stopped again if started before the limits are raised.
+func (t *Torrent) SetSeedGoal(ratio float64, duration time.Duration) error {
+	return t.torrent.SetSeedGoal(ratio, duration)
+}
+
+// SetMaxPeers sets the max number of incoming and outgoing connections of the torrent.
+// The limits override MaxPeerAccept, MaxPeerDial and the phase limits in Config. Setting is saved in resume data.
+// Existing connections are not closed if the limits are lowered.
+func (t *Torrent) SetMaxPeers(accept, dial int) error {
+	return t.torrent.SetMaxPeers(accept, dial)
+}
+
+// ResetMaxPeers removes the limits set with SetMaxPeers, so the limits in Config are used again.
+// Existing connections are not closed if the limits are lowered.
+func (t *Torrent) ResetMaxPeers() error {
+	return t.torrent.ResetMaxPeers()
+}
+
+// SetPEX enables or disables peer exchange for the torrent. Setting is saved in resume data.
+// PEX is never used for private torrents or when PEXEnabled is not set in Config.
+func (t *Torrent) SetPEX(enabled bool) error {
+	return t.torrent.SetPEX(enabled)
+}
+
+// SetSeedOnly enables or disables seed-only mode. Setting is saved in resume data.
+// In seed-only mode no pieces are requested from peers even if the torrent is incomplete.
+// Pieces that are already downloaded or verified are still uploaded.
+func (t *Torrent) SetSeedOnly(seedOnly bool) error {
+	return t.torrent.SetSeedOnly(seedOnly)
+}
+
+// PieceForOffset returns the index of the piece that contains the byte at offset in the file at fileIndex.
+// Files are indexed in the order they appear in torrent file.
+// Returns ErrNoMetadata if the torrent is added with a magnet link and metadata is not downloaded yet.
+func (t *Torrent) PieceForOffset(fileIndex int, offset int64) (int, error) {
+	return t.torrent.PieceForOffset(fileIndex, offset)
+}
+
+// PieceRange returns the indexes of the first and last pieces that contain data of the file at fileIndex.
+// The first and last pieces may be shared with the neighbouring files. An error is returned for empty files.
+func (t *Torrent) PieceRange(fileIndex int) (first, last int, err error) {
+	return t.torrent.PieceRange(fileIndex)
+}
+
+// Files returns the list of files in the torrent with their download priorities.
+// ErrNoMetadata is returned if the info dict is not downloaded yet.
+func (t *Torrent) Files() ([]File, error) {
+	return t.torrent.Files()
+}
+
+// SetFilePriority changes the download priority of the file at index. Pieces belonging only to files with
+// PrioritySkip are not downloaded. Pieces at file boundaries are downloaded if any of their files is not skipped.
+// Priorities are saved in resume data.
+func (t *Torrent) SetFilePriority(index int, priority Priority) error {
+	return t.torrent.SetFilePriority(index, priority)
+}
+
+// AddPeers adds peer addresses in "host:port" form to the list of addresses to connect.
+// Addresses are dialed if the torrent is running and has room for outgoing connections.
+// They are discarded if the torrent is stopped.
+// No addresses are added if any of them is invalid. Returns ErrTorrentClosed if the torrent is removed.
+func (t *Torrent) AddPeers(addrs []string) error {
+	peers := make([]*net.TCPAddr, 0, len(addrs))
+	for _, s := range addrs {
+		addr, err := net.ResolveTCPAddr("tcp", s)
+		if err != nil {
+			return fmt.Errorf("invalid peer address %q: %s", s, err)
+		}
+		peers = append(peers, addr)
+	}
+	return t.torrent.AddPeers(peers)
+}
+
+func (t *Torrent) AddrListStats() AddrListStats {
+	return t.torrent.AddrListStats()
+}
+
 func (t *Torrent) Port() uint16 {
 	return t.port
 }
 
 func (t *Torrent) Start() error {
-	subBucket := t.id
-	err := t.session.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(torrentsBucket).Bucket([]byte(subBucket))
-		return b.Put([]byte("started"), []byte("1"))
-	})
+	err := t.torrent.resume.WriteStarted(true)
 	if err != nil {
 		return err
 	}
@@ -78,11 +233,7 @@ func (t *Torrent) Start() error {
 }
 
 func (t *Torrent) Stop() error {
-	subBucket := t.id
-	err := t.session.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(torrentsBucket).Bucket([]byte(subBucket))
-		return b.Put([]byte("started"), []byte("0"))
-	})
+	err := t.torrent.resume.WriteStarted(false)
 	if err != nil {
 		return err
 	}
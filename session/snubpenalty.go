@@ -0,0 +1,51 @@
+package session
+
+import (
+	"math"
+	"time"
+
+	"github.com/cenkalti/rain/internal/peer"
+)
+
+// Penalties decayed below this value are forgotten.
+const minSnubPenalty = 0.01
+
+// snubPenalty is the number of times a peer at an IP address has snubbed us,
+// decayed exponentially with a half life of Config.SnubPenaltyDecay.
+type snubPenalty struct {
+	value     float64
+	updatedAt time.Time
+}
+
+func (p snubPenalty) at(now time.Time, halfLife time.Duration) float64 {
+	return p.value * math.Exp2(-now.Sub(p.updatedAt).Seconds()/halfLife.Seconds())
+}
+
+// addSnubPenalty increases the penalty of the peer's IP so the peer is deprioritized if it reconnects.
+func (t *torrent) addSnubPenalty(pe *peer.Peer) {
+	if t.config.SnubPenaltyDecay <= 0 {
+		return
+	}
+	now := time.Now()
+	for ip, p := range t.snubPenalties {
+		if p.at(now, t.config.SnubPenaltyDecay) < minSnubPenalty {
+			delete(t.snubPenalties, ip)
+		}
+	}
+	ip := pe.IP()
+	p := snubPenalty{
+		value:     t.snubPenalties[ip].at(now, t.config.SnubPenaltyDecay) + 1,
+		updatedAt: now,
+	}
+	t.snubPenalties[ip] = p
+	pe.SnubPenalty = p.value
+}
+
+// getSnubPenalty returns the current penalty of the IP address.
+func (t *torrent) getSnubPenalty(ip string) float64 {
+	p, ok := t.snubPenalties[ip]
+	if !ok || t.config.SnubPenaltyDecay <= 0 {
+		return 0
+	}
+	return p.at(time.Now(), t.config.SnubPenaltyDecay)
+}
@@ -24,9 +24,15 @@ func (t *torrent) start() {
 	}
 
 	t.log.Info("starting torrent")
+	t.sendEvent(Event{Type: EventStarted})
 	t.errC = make(chan error, 1)
 	t.portC = make(chan int, 1)
 	t.lastError = nil
+	t.warnings = nil
+	t.rememberedPeersDialed = false
+	if t.uploadDisabled {
+		t.log.Warning(uploadDisabledWarning)
+	}
 
 	if t.info != nil {
 		if t.pieces != nil {
@@ -49,6 +55,7 @@ func (t *torrent) start() {
 
 	t.startStatsWriter()
 	t.startSpeedCounter()
+	t.startIdlePeerReaper()
 }
 
 func (t *torrent) startStatsWriter() {
@@ -67,42 +74,60 @@ func (t *torrent) startSpeedCounter() {
 	t.speedCounterTickerC = t.speedCounterTicker.C
 }
 
+// startVerifier puts the torrent in verifier queue. Verifier is run when the torrent's turn comes.
 func (t *torrent) startVerifier() {
-	if t.verifier != nil {
+	if t.verifier != nil || t.verifierTicket != nil {
 		panic("verifier exists")
 	}
+	t.verifierTicket = t.verifierQueue.Enter()
+	t.verifierReadyC = t.verifierTicket.ReadyC
+}
+
+func (t *torrent) runVerifier() {
+	t.verifierReadyC = nil
 	t.verifier = verifier.New()
-	go t.verifier.Run(t.pieces, t.verifierProgressC, t.verifierResultC)
+	go t.verifier.Run(t.pieces, t.verifierPool, t.verifierProgressC, t.verifierResultC)
 }
 
 func (t *torrent) startAllocator() {
 	if t.allocator != nil {
 		panic("allocator exists")
 	}
-	t.allocator = allocator.New()
-	go t.allocator.Run(t.info, t.storage, t.allocatorProgressC, t.allocatorResultC)
+	t.allocator = allocator.New(t.config.FileAllocation)
+	go t.allocator.Run(t.info, t.getFilePaths(), t.storage, t.allocatorProgressC, t.allocatorResultC)
 }
 
 func (t *torrent) startAnnouncers() {
 	if len(t.announcers) > 0 {
 		return
 	}
+	t.dialRememberedPeers()
 	for _, tr := range t.trackers {
-		an := announcer.NewPeriodicalAnnouncer(tr, t.config.TrackerNumWant, t.config.TrackerMinAnnounceInterval, t.announcerRequestC, t.completeC, t.addrsFromTrackers, t.log)
+		an := announcer.NewPeriodicalAnnouncer(tr, t.config.TrackerNumWant, t.config.TrackerMinAnnounceInterval, t.config.TrackerMaxRetryInterval, t.announcerRequestC, t.completeC, t.addrsFromTrackers, t.trackerStatusC, t.log)
 		t.announcers = append(t.announcers, an)
 		go an.Run()
 	}
+	if t.isPrivate() {
+		// DHT and LSD announcers are created for magnet links before the private flag is known.
+		return
+	}
 	if t.dhtNode != nil && t.dhtAnnouncer == nil {
 		t.dhtAnnouncer = announcer.NewDHTAnnouncer()
 		go t.dhtAnnouncer.Run(t.dhtNode.Announce, t.config.DHTAnnounceInterval, t.config.DHTMinAnnounceInterval, t.log)
 	}
+	if t.lsdNode != nil && t.lsdAnnouncer == nil {
+		// The announcer is not specific to DHT. It calls the function periodically.
+		t.lsdAnnouncer = announcer.NewDHTAnnouncer()
+		go t.lsdAnnouncer.Run(t.lsdNode.Announce, t.config.LSDAnnounceInterval, t.config.LSDMinAnnounceInterval, t.log)
+	}
 }
 
 func (t *torrent) startAcceptor() {
 	if t.acceptor != nil {
 		return
 	}
-	listener, err := net.ListenTCP("tcp4", &net.TCPAddr{Port: t.port})
+	// Listen on both IPv4 and IPv6 if the system supports it and no address is given in config.
+	listener, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.ParseIP(t.config.ListenAddress), Port: t.port})
 	if err != nil {
 		t.log.Warningf("cannot listen port %d: %s", t.port, err)
 	} else {
@@ -115,9 +140,14 @@ func (t *torrent) startAcceptor() {
 }
 
 func (t *torrent) startUnchokeTimers() {
+	if t.uploadDisabled {
+		return
+	}
 	if t.unchokeTimer == nil {
-		t.unchokeTimer = time.NewTicker(10 * time.Second)
+		t.unchokeTimer = time.NewTicker(unchokePeriod)
 		t.unchokeTimerC = t.unchokeTimer.C
+		// Upload limit is split between torrents that are uploading.
+		t.uploadLimiter.Join()
 	}
 	if t.optimisticUnchokeTimer == nil {
 		t.optimisticUnchokeTimer = time.NewTicker(30 * time.Second)
@@ -148,9 +178,10 @@ func (t *torrent) startPieceDownloaders() {
 	if t.pieces == nil {
 		return
 	}
-	if t.completed {
+	if t.completed || t.seedOnly {
 		return
 	}
+	t.piecePicker.SetEndgame(t.missingBlocks() < t.config.EndgameThreshold)
 	for len(t.pieceDownloaders)-len(t.pieceDownloadersChoked)-len(t.pieceDownloadersSnubbed) < t.config.ParallelPieceDownloads {
 		pi, pe := t.piecePicker.Pick()
 		if pi == nil || pe == nil {
@@ -163,7 +194,8 @@ func (t *torrent) startPieceDownloaders() {
 		}
 		t.pieceDownloaders[pd.Peer] = pd
 		pd.Peer.Downloading = true
-		pd.RequestBlocks(t.config.RequestQueueLength)
+		pd.RequestBlocks(t.requestQueueLength(pd.Peer))
 		pd.Peer.ResetSnubTimer()
 	}
+	t.startWebseedDownloaders()
 }
@@ -2,15 +2,22 @@ package session
 
 import (
 	"math"
+	"net/url"
+	"sync/atomic"
 	"time"
 
 	"github.com/cenkalti/rain/internal/addrlist"
+	"github.com/cenkalti/rain/internal/piece"
+	"github.com/cenkalti/rain/internal/tracker"
+	"github.com/cenkalti/rain/internal/tracker/tiertracker"
 )
 
 // Stats contains statistics about Torrent.
 type Stats struct {
 	// Status of the torrent.
 	Status TorrentStatus
+	// Progress of Allocating and Verifying states in range [0, 100], e.g. "Verifying 45%". Zero in other states.
+	StatusProgress float64
 	// Contains the error message if torrent is stopped unexpectedly.
 	Error  error
 	Pieces struct {
@@ -41,8 +48,16 @@ type Stats struct {
 		Uploaded int64
 		// Bytes downloaded due to duplicate/non-requested pieces.
 		Wasted int64
+		// Wasted bytes by reason. Unlike Wasted, it is not saved to the database.
+		// Counted since torrent is loaded in session.
+		WastedBreakdown WastedBreakdown
 		// Bytes allocated on storage.
 		Allocated int64
+		// Bytes of protocol messages received from peers excluding piece data. Encryption handshakes are not included.
+		// Counted since torrent is loaded in session.
+		DownloadedOverhead int64
+		// Bytes of protocol messages sent to peers excluding piece data.
+		UploadedOverhead int64
 	}
 	Peers struct {
 		// Number of peers that are connected, handshaked and ready to send and receive messages.
@@ -51,13 +66,22 @@ type Stats struct {
 		Incoming int
 		// Number of peers that we have connected to.
 		Outgoing int
+		// Number of connections closed because no piece data has been exchanged in IdleConnectionTimeout.
+		Reaped int
+		// Number of connections closed because the client of the peer is in Config.BannedClients.
+		Rejected int
+		// Number of incoming connections and outgoing dials refused because the address is in the blocklist.
+		Blocked int
+		// Number of peers that the connection is encrypted with RC4 or sent in plaintext.
+		Encrypted int
+		Plaintext int
 	}
 	Handshakes struct {
 		// Number of peers that are not handshaked yet.
 		Total int
 		// Number of incoming peers in handshake state.
 		Incoming int
-		// Number of outgoing peers in handshake state.
+		// Number of outgoing peers in handshake state. These are the half-open connections limited by MaxHalfOpenConnections.
 		Outgoing int
 	}
 	Addresses struct {
@@ -69,6 +93,8 @@ type Stats struct {
 		DHT int
 		// Peers found via peer exchange.
 		PEX int
+		// Peers found in local network.
+		LSD int
 	}
 	Downloads struct {
 		// Number of active piece downloads.
@@ -87,8 +113,16 @@ type Stats struct {
 		Snubbed int
 		// Number of peers that are being downloaded normally.
 		Running int
+		// Bytes received from peers that could not deliver valid metadata.
+		Wasted int64
+	}
+	PEX struct {
+		// Peers are exchanged with PEX messages. Always false for private torrents.
+		Enabled bool
+		// Number of peer addresses received in PEX messages.
+		Discovered int
 	}
-	// Name can change after metadata is downloaded.
+	// Name can change after metadata is downloaded unless it is set with Torrent.SetName.
 	Name string
 	// Is private torrent?
 	Private bool
@@ -96,6 +130,8 @@ type Stats struct {
 	PieceLength uint32
 	// Duration while the torrent is in Seeding status.
 	SeededFor time.Duration
+	// Last time piece data is downloaded or uploaded. Zero if no data is transferred yet.
+	LastActivity time.Time
 	// Speed is calculated as 1-minute moving average.
 	Speed struct {
 		// Downloaded bytes per second.
@@ -103,8 +139,16 @@ type Stats struct {
 		// Uploaded bytes per second.
 		Upload uint
 	}
-	// Time remaining to complete download. nil value means infinity.
+	// Percentage of completed bytes in range [0, 100]. Zero until metadata is downloaded.
+	Progress float64
+	// Time remaining to complete download, calculated from incomplete bytes and download speed.
+	// nil value means infinity: download speed is zero or metadata is not downloaded yet.
+	// Zero value means the download is complete.
 	ETA *time.Duration
+	// Non-fatal problems found while running the torrent.
+	Warnings []string
+	// Swarm statistics from the last successful call to Torrent.Scrape.
+	Scrape ScrapeResult
 }
 
 func (t *torrent) stats() Stats {
@@ -112,20 +156,36 @@ func (t *torrent) stats() Stats {
 
 	var s Stats
 	s.Status = t.status()
+	s.StatusProgress = t.statusProgress(s.Status)
 	s.Error = t.lastError
+	s.Warnings = append([]string(nil), t.warnings...)
 	s.Addresses.Total = t.addrList.Len()
 	s.Addresses.Tracker = t.addrList.LenSource(addrlist.Tracker)
 	s.Addresses.DHT = t.addrList.LenSource(addrlist.DHT)
 	s.Addresses.PEX = t.addrList.LenSource(addrlist.PEX)
+	s.PEX.Enabled = t.pexEnabled()
+	s.PEX.Discovered = t.pexPeersDiscovered
+	s.Addresses.LSD = t.addrList.LenSource(addrlist.LSD)
 	s.Handshakes.Incoming = len(t.incomingHandshakers)
 	s.Handshakes.Outgoing = len(t.outgoingHandshakers)
 	s.Handshakes.Total = len(t.incomingHandshakers) + len(t.outgoingHandshakers)
 	s.Peers.Total = len(t.peers)
 	s.Peers.Incoming = len(t.incomingPeers)
 	s.Peers.Outgoing = len(t.outgoingPeers)
+	s.Peers.Reaped = t.idlePeersReaped
+	s.Peers.Rejected = t.rejectedPeers
+	s.Peers.Blocked = t.blockedPeers
+	for pe := range t.peers {
+		if pe.Encrypted {
+			s.Peers.Encrypted++
+		} else {
+			s.Peers.Plaintext++
+		}
+	}
 	s.MetadataDownloads.Total = len(t.infoDownloaders)
 	s.MetadataDownloads.Snubbed = len(t.infoDownloadersSnubbed)
 	s.MetadataDownloads.Running = len(t.infoDownloaders) - len(t.infoDownloadersSnubbed)
+	s.MetadataDownloads.Wasted = t.metadataBytesWasted
 	s.Downloads.Total = len(t.pieceDownloaders)
 	s.Downloads.Snubbed = len(t.pieceDownloadersSnubbed)
 	s.Downloads.Choked = len(t.pieceDownloadersChoked)
@@ -134,18 +194,28 @@ func (t *torrent) stats() Stats {
 	s.Bytes.Downloaded = t.resumerStats.BytesDownloaded
 	s.Bytes.Uploaded = t.resumerStats.BytesUploaded
 	s.Bytes.Wasted = t.resumerStats.BytesWasted
+	s.Bytes.WastedBreakdown = t.bytesWasted
+	s.Bytes.DownloadedOverhead = atomic.LoadInt64(&t.bytesOverheadDownloaded)
+	s.Bytes.UploadedOverhead = atomic.LoadInt64(&t.bytesOverheadUploaded)
 	s.SeededFor = t.resumerStats.SeededFor
+	s.LastActivity = t.resumerStats.LastActivity
 	s.Bytes.Allocated = t.bytesAllocated
 	s.Pieces.Checked = t.checkedPieces
 	s.Speed.Download = uint(t.downloadSpeed.Rate())
 	s.Speed.Upload = uint(t.uploadSpeed.Rate())
+	s.Scrape = t.lastScrape
 
 	if t.info != nil {
 		s.Bytes.Total = t.info.TotalLength
 		s.Bytes.Completed = t.bytesComplete()
 		s.Bytes.Incomplete = s.Bytes.Total - s.Bytes.Completed
+		if s.Bytes.Total > 0 {
+			s.Progress = float64(s.Bytes.Completed) * 100 / float64(s.Bytes.Total)
+		} else {
+			s.Progress = 100
+		}
 
-		s.Name = t.info.Name
+		s.Name = t.displayName()
 		s.Private = (t.info.Private == 1)
 		s.PieceLength = t.info.PieceLength
 	} else {
@@ -159,8 +229,10 @@ func (t *torrent) stats() Stats {
 		s.Pieces.Have = t.bitfield.Count()
 		s.Pieces.Missing = s.Pieces.Total - s.Pieces.Have
 	}
-	bps := int64(s.Speed.Download)
-	if bps != 0 {
+	if t.info != nil && s.Bytes.Incomplete == 0 {
+		eta := time.Duration(0)
+		s.ETA = &eta
+	} else if bps := int64(s.Speed.Download); bps != 0 && t.info != nil {
 		eta := time.Duration(s.Bytes.Incomplete/bps) * time.Second
 		s.ETA = &eta
 	}
@@ -186,27 +258,142 @@ func (t *torrent) bytesComplete() int64 {
 	return n
 }
 
+// WastedBreakdown contains the number of wasted bytes by the reason of waste.
+type WastedBreakdown struct {
+	// Bytes of pieces that failed hash check.
+	HashFailed int64
+	// Bytes of blocks that are received more than once, cancelled or not requested.
+	Duplicate int64
+	// Bytes of blocks that are discarded because they are received after the peer has choked us.
+	Choked int64
+	// Bytes of blocks that are invalid for the torrent.
+	Invalid int64
+}
+
+// wasteBytes adds n to the wasted bytes in stats and to the counter of the reason.
+func (t *torrent) wasteBytes(n int64, reason *int64) {
+	t.resumerStats.BytesWasted += n
+	*reason += n
+}
+
+// missingBlocks returns the approximate number of blocks that are not downloaded yet.
+// Blocks of skipped pieces are not counted.
+func (t *torrent) missingBlocks() int {
+	var left int64
+	if t.piecePriorities == nil {
+		left = t.info.TotalLength - t.bytesComplete()
+	} else {
+		for i := range t.pieces {
+			if !t.bitfield.Test(uint32(i)) && t.pieceWanted(uint32(i)) {
+				left += int64(t.pieces[i].Length)
+			}
+		}
+	}
+	return int((left + piece.BlockSize - 1) / piece.BlockSize)
+}
+
 func (t *torrent) getTrackers() []Tracker {
 	var trackers []Tracker
+	var tierOffset int
 	for _, an := range t.announcers {
 		st := an.Stats()
-		t := Tracker{
-			URL:      an.Tracker.URL(),
-			Status:   TrackerStatus(st.Status),
-			Seeders:  st.Seeders,
-			Leechers: st.Leechers,
-			Error:    st.Error,
+		tt, ok := an.Tracker.(*tiertracker.TierTracker)
+		if !ok {
+			trackers = append(trackers, Tracker{
+				URL:      an.Tracker.URL(),
+				Tier:     tierOffset,
+				Status:   TrackerStatus(st.Status),
+				Seeders:  st.Seeders,
+				Leechers: st.Leechers,
+				Error:    trackerError(st.Error),
+
+				LastAnnounce: st.LastAnnounce,
+				NextAnnounce: st.NextAnnounce,
+			})
+			tierOffset++
+			continue
+		}
+		// Status of the announcer belongs to the tracker that is used in last announce.
+		current := tt.Current()
+		tiers := tt.Tiers()
+		for i, tier := range tiers {
+			for _, trk := range tier {
+				tr := Tracker{
+					URL:  trk.URL(),
+					Tier: tierOffset + i,
+				}
+				if trk == current {
+					tr.Status = TrackerStatus(st.Status)
+					tr.Seeders = st.Seeders
+					tr.Leechers = st.Leechers
+					tr.Error = trackerError(st.Error)
+					tr.LastAnnounce = st.LastAnnounce
+					tr.NextAnnounce = st.NextAnnounce
+				} else if err := tt.LastError(trk); err != nil {
+					tr.Status = NotWorking
+					tr.Error = trackerError(err)
+				}
+				trackers = append(trackers, tr)
+			}
 		}
-		trackers = append(trackers, t)
+		tierOffset += len(tiers)
 	}
 	return trackers
 }
 
-func (t *torrent) getPeers() []Peer {
-	var peers []Peer
+// trackerError converts DNS errors returned from tracker clients to *TrackerDNSError.
+// HTTP client wraps errors returned from the dialer in *url.Error.
+func trackerError(err error) error {
+	cause := err
+	if uerr, ok := err.(*url.Error); ok {
+		cause = uerr.Err
+	}
+	if dnsErr, ok := cause.(*tracker.DNSError); ok {
+		return &TrackerDNSError{Host: dnsErr.Host, Err: dnsErr.Err}
+	}
+	return err
+}
+
+func (t *torrent) addrListStats() AddrListStats {
+	return AddrListStats{
+		Total:   t.addrList.Len(),
+		Tracker: t.addrList.LenSource(addrlist.Tracker),
+		DHT:     t.addrList.LenSource(addrlist.DHT),
+		PEX:     t.addrList.LenSource(addrlist.PEX),
+		LSD:     t.addrList.LenSource(addrlist.LSD),
+		Manual:  t.addrList.LenSource(addrlist.Manual),
+		Resume:  t.addrList.LenSource(addrlist.Resume),
+		Dialing: len(t.outgoingPeers) + len(t.outgoingHandshakers),
+		MaxDial: t.maxPeerDial(),
+	}
+}
+
+func (t *torrent) getPeers() []PeerStats {
+	var peers []PeerStats
 	for pe := range t.peers {
-		p := Peer{
-			Addr: pe.Addr(),
+		p := PeerStats{
+			Addr:             pe.Addr(),
+			ID:               pe.ID(),
+			Source:           pe.Source.String(),
+			DownloadSpeed:    uint(pe.DownloadSpeed.Rate()),
+			UploadSpeed:      uint(pe.UploadSpeed.Rate()),
+			Encrypted:        pe.Encrypted,
+			AmChoking:        pe.AmChoking,
+			AmInterested:     pe.AmInterested,
+			PeerChoking:      pe.PeerChoking,
+			PeerInterested:   pe.PeerInterested,
+			UploadAllocation: pe.UploadAllocation,
+			MessagesSent:     pe.MessagesSent(),
+			MessagesReceived: pe.MessagesReceived(),
+		}
+		if pe.ExtensionHandshake != nil {
+			p.Client = pe.ExtensionHandshake.V
+		}
+		if p.Client == "" {
+			p.Client = pe.Client()
+		}
+		if t.piecePicker != nil {
+			p.Pieces = t.piecePicker.NumHave(pe)
 		}
 		peers = append(peers, p)
 	}
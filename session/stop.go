@@ -3,6 +3,7 @@ package session
 import (
 	"time"
 
+	"github.com/cenkalti/rain/internal/addrlist"
 	"github.com/cenkalti/rain/internal/announcer"
 	"github.com/cenkalti/rain/internal/handshaker/incominghandshaker"
 	"github.com/cenkalti/rain/internal/handshaker/outgoinghandshaker"
@@ -11,6 +12,7 @@ import (
 )
 
 func (t *torrent) stop(err error) {
+	t.startAfterStop = false
 	s := t.status()
 	if s == Stopping || s == Stopped {
 		return
@@ -20,17 +22,24 @@ func (t *torrent) stop(err error) {
 	t.lastError = err
 	if err != nil && err != errClosed {
 		t.log.Error(err)
+		t.sendEvent(Event{Type: EventError, Error: err})
 	}
 
 	t.log.Debugln("stopping acceptor")
 	t.stopAcceptor()
 
+	// Peers must be saved before they are closed.
+	t.writePeers()
+
 	t.log.Debugln("closing peer connections")
 	t.stopPeers()
 
 	t.log.Debugln("stopping piece downloaders")
 	t.stopPiecedownloaders()
 
+	t.log.Debugln("stopping web seed downloaders")
+	t.stopWebseedDownloaders()
+
 	t.log.Debugln("stopping info downloaders")
 	t.stopInfoDownloaders()
 
@@ -41,6 +50,9 @@ func (t *torrent) stop(err error) {
 	t.log.Debugln("stopping unchoke timers")
 	t.stopUnchokeTimers()
 
+	t.log.Debugln("stopping piece writer retry")
+	t.stopPieceWriterRetry()
+
 	// Closing data is necessary to cancel ongoing IO operations on files.
 	t.log.Debugln("closing open files")
 	t.closeData()
@@ -58,6 +70,7 @@ func (t *torrent) stop(err error) {
 		t.verifier.Close()
 		t.verifier = nil
 	}
+	t.releaseVerifierTicket()
 
 	t.log.Debugln("stopping outgoing handshakers")
 	t.stopOutgoingHandshakers()
@@ -67,11 +80,12 @@ func (t *torrent) stop(err error) {
 
 	t.log.Debugln("stopping stats writer")
 	t.stopStatsWriter()
+	t.stopIdlePeerReaper()
 
 	t.stopSpeedCounter()
 
 	t.log.Debugln("clearing piece cache")
-	t.pieceCache.Clear()
+	t.pieceCache.ClearPrefix(string(t.infoHash[:]))
 
 	// Stop periodical announcers first.
 	t.log.Debugln("stopping announcers")
@@ -98,6 +112,14 @@ func (t *torrent) stop(err error) {
 
 }
 
+func (t *torrent) stopIdlePeerReaper() {
+	if t.idlePeerReaperTicker != nil {
+		t.idlePeerReaperTicker.Stop()
+		t.idlePeerReaperTicker = nil
+		t.idlePeerReaperTickerC = nil
+	}
+}
+
 func (t *torrent) stopStatsWriter() {
 	t.writeStats()
 	t.seedDurationUpdatedAt = time.Time{}
@@ -115,15 +137,18 @@ func (t *torrent) stopSpeedCounter() {
 }
 
 func (t *torrent) stopOutgoingHandshakers() {
+	t.stopDialTimer()
 	for oh := range t.outgoingHandshakers {
 		oh.Close()
+		t.halfOpen.release()
 	}
-	t.outgoingHandshakers = make(map[*outgoinghandshaker.OutgoingHandshaker]struct{})
+	t.outgoingHandshakers = make(map[*outgoinghandshaker.OutgoingHandshaker]addrlist.PeerSource)
 }
 
 func (t *torrent) stopIncomingHandshakers() {
 	for ih := range t.incomingHandshakers {
 		ih.Close()
+		t.handshakes.release()
 	}
 	t.incomingHandshakers = make(map[*incominghandshaker.IncomingHandshaker]struct{})
 }
@@ -151,6 +176,10 @@ func (t *torrent) stopPeriodicalAnnouncers() {
 		t.dhtAnnouncer.Close()
 		t.dhtAnnouncer = nil
 	}
+	if t.lsdAnnouncer != nil {
+		t.lsdAnnouncer.Close()
+		t.lsdAnnouncer = nil
+	}
 }
 
 func (t *torrent) stopAcceptor() {
@@ -161,9 +190,11 @@ func (t *torrent) stopAcceptor() {
 }
 
 func (t *torrent) stopPeers() {
+	t.stoppingPeers = true
 	for p := range t.peers {
 		t.closePeer(p)
 	}
+	t.stoppingPeers = false
 }
 
 func (t *torrent) stopUnchokeTimers() {
@@ -171,6 +202,7 @@ func (t *torrent) stopUnchokeTimers() {
 		t.unchokeTimer.Stop()
 		t.unchokeTimer = nil
 		t.unchokeTimerC = nil
+		t.uploadLimiter.Leave()
 	}
 	if t.optimisticUnchokeTimer != nil {
 		t.optimisticUnchokeTimer.Stop()
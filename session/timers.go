@@ -3,14 +3,18 @@ package session
 import (
 	"math/rand"
 	"sort"
+	"time"
 
 	"github.com/cenkalti/rain/internal/peer"
 )
 
+// unchokePeriod is the interval between regular unchoke rounds.
+const unchokePeriod = 10 * time.Second
+
 func (t *torrent) tickUnchoke() {
 	peers := make([]*peer.Peer, 0, len(t.peers))
 	for pe := range t.peers {
-		if pe.PeerInterested && !pe.OptimisticUnchoked {
+		if pe.PeerInterested && !pe.OptimisticUnchoked && !pe.UploadOnly {
 			peers = append(peers, pe)
 		}
 	}
@@ -23,28 +27,63 @@ func (t *torrent) tickUnchoke() {
 			return peers[i].BytesDownlaodedInChokePeriod > peers[j].BytesDownlaodedInChokePeriod
 		})
 	}
+	// Upload rates must be read before counters are reset below.
+	uploadRates := make([]int64, len(peers))
+	for i, pe := range peers {
+		uploadRates[i] = pe.BytesUploadedInChokePeriod / int64(unchokePeriod/time.Second)
+	}
 	for pe := range t.peers {
 		pe.BytesDownlaodedInChokePeriod = 0
 		pe.BytesUploadedInChokePeriod = 0
 	}
+	limit := t.uploadRateLimit()
+	remaining := limit
 	var unchoked int
-	for _, pe := range peers {
-		if unchoked < t.config.UnchokedPeers {
-			t.unchokePeer(pe)
-			unchoked++
-			// Set optimistic flag false, so optimistic timer don't choke this peer
-			// because we have selected it based it's good download rate.
-			pe.OptimisticUnchoked = false
-		} else {
+	for i, pe := range peers {
+		if unchoked >= t.config.UnchokedPeers || (limit > 0 && remaining <= 0) {
 			t.chokePeer(pe)
+			continue
+		}
+		t.unchokePeer(pe)
+		unchoked++
+		// Set optimistic flag false, so optimistic timer don't choke this peer
+		// because we have selected it based it's good download rate.
+		pe.OptimisticUnchoked = false
+		if limit > 0 {
+			pe.UploadAllocation = allocateUpload(uploadRates[i], limit, remaining, t.config.UnchokedPeers)
+			remaining -= pe.UploadAllocation
+		} else {
+			pe.UploadAllocation = 0
 		}
+		pe.SetUploadRate(pe.UploadAllocation)
 	}
 }
 
+// allocateUpload returns the share of upload bandwidth given to a peer that drains data at rate bytes/sec.
+// Fast peers get what they can consume so bandwidth is not wasted on peers that cannot keep up.
+// Peers that have not been measured yet are given an even share of the limit so they can prove their rate.
+func allocateUpload(rate, limit, remaining int64, slots int) int64 {
+	alloc := rate
+	if fair := limit / int64(slots); alloc < fair {
+		alloc = fair
+	}
+	if alloc > remaining {
+		alloc = remaining
+	}
+	return alloc
+}
+
+// uploadRateLimit returns the upload bandwidth in bytes/sec shared among unchoked peers.
+// Session limit is split evenly between uploading torrents.
+// Zero means unlimited, in which case the number of unchoked peers is only bounded by Config.UnchokedPeers.
+func (t *torrent) uploadRateLimit() int64 {
+	return t.uploadLimiter.Share()
+}
+
 func (t *torrent) tickOptimisticUnchoke() {
 	peers := make([]*peer.Peer, 0, len(t.peers))
 	for pe := range t.peers {
-		if pe.PeerInterested && !pe.OptimisticUnchoked && pe.AmChoking {
+		if pe.PeerInterested && !pe.OptimisticUnchoked && pe.AmChoking && !pe.UploadOnly {
 			peers = append(peers, pe)
 		}
 	}
@@ -0,0 +1,45 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/cenkalti/rain/internal/ratelimit"
+)
+
+func TestAllocateUpload(t *testing.T) {
+	cases := []struct {
+		rate, limit, remaining int64
+		slots                  int
+		alloc                  int64
+	}{
+		{rate: 0, limit: 1000, remaining: 1000, slots: 4, alloc: 250},
+		{rate: 600, limit: 1000, remaining: 1000, slots: 4, alloc: 600},
+		{rate: 600, limit: 1000, remaining: 400, slots: 4, alloc: 400},
+		{rate: 100, limit: 1000, remaining: 100, slots: 4, alloc: 100},
+	}
+	for _, c := range cases {
+		if alloc := allocateUpload(c.rate, c.limit, c.remaining, c.slots); alloc != c.alloc {
+			t.Errorf("allocateUpload(%d, %d, %d, %d) = %d, want %d", c.rate, c.limit, c.remaining, c.slots, alloc, c.alloc)
+		}
+	}
+}
+
+func TestUploadRateLimitSplit(t *testing.T) {
+	l := ratelimit.New(1000)
+	t1 := &torrent{uploadLimiter: l}
+	t2 := &torrent{uploadLimiter: l}
+	t1.startUnchokeTimers()
+	defer t1.stopUnchokeTimers()
+	if r := t1.uploadRateLimit(); r != 1000 {
+		t.Fatalf("single torrent must get the whole limit, got %d", r)
+	}
+	t2.startUnchokeTimers()
+	if r := t1.uploadRateLimit(); r != 500 {
+		t.Fatalf("limit must be split between torrents, got %d", r)
+	}
+	t2.stopUnchokeTimers()
+	t2.stopUnchokeTimers()
+	if r := t1.uploadRateLimit(); r != 1000 {
+		t.Fatalf("stopped torrent must release its share, got %d", r)
+	}
+}
@@ -2,9 +2,11 @@ package session
 
 import (
 	"net"
+	"net/http"
 	"sync"
 	"time"
 
+	"github.com/cenkalti/backoff"
 	"github.com/cenkalti/rain/internal/acceptor"
 	"github.com/cenkalti/rain/internal/addrlist"
 	"github.com/cenkalti/rain/internal/allocator"
@@ -22,10 +24,12 @@ import (
 	"github.com/cenkalti/rain/internal/piecedownloader"
 	"github.com/cenkalti/rain/internal/piecepicker"
 	"github.com/cenkalti/rain/internal/piecewriter"
+	"github.com/cenkalti/rain/internal/ratelimit"
 	"github.com/cenkalti/rain/internal/resumer"
 	"github.com/cenkalti/rain/internal/storage"
 	"github.com/cenkalti/rain/internal/tracker"
 	"github.com/cenkalti/rain/internal/verifier"
+	"github.com/cenkalti/rain/internal/webseed"
 	"github.com/rcrowley/go-metrics"
 )
 
@@ -41,16 +45,27 @@ func init() {
 
 // torrent connects to peers and downloads files from swarm.
 type torrent struct {
+	// Bytes of protocol messages received from and sent to peers, excluding piece data.
+	// Updated atomically by peer connections. Keep at the top of the struct for 64-bit alignment on 32-bit platforms.
+	bytesOverheadDownloaded int64
+	bytesOverheadUploaded   int64
+
 	config Config
 
+	// ID of the torrent in Session.
+	id string
+
 	// Identifies the torrent being downloaded.
 	infoHash [20]byte
 
 	// List of addresses to announce this torrent.
 	trackers []tracker.Tracker
 
-	// Name of the torrent.
-	name string
+	// Name of the torrent. Written in run loop, guarded by mName for reads from other goroutines.
+	name  string
+	mName sync.Mutex
+	// Name is set by the user with SetName. It is shown instead of the name in info.
+	nameSet bool
 
 	// Storage implementation to save the files in torrent.
 	storage storage.Storage
@@ -64,6 +79,34 @@ type torrent struct {
 	// Contains info about files in torrent. This can be nil at start for magnet downloads.
 	info *metainfo.Info
 
+	// If set, all peers are kept choked so no data is uploaded.
+	uploadDisabled bool
+
+	// Download priorities of pieces. Nil means all pieces have normal priority.
+	piecePriorities []uint8
+
+	// Download priorities of files. Nil means all files have normal priority.
+	filePriorities []uint8
+
+	// If set, no pieces are requested from peers even if the torrent is incomplete.
+	seedOnly bool
+
+	// If set, peers are not exchanged with PEX messages even if PEXEnabled is set in config.
+	pexDisabled bool
+
+	// Number of peer addresses received in PEX messages.
+	pexPeersDiscovered int
+
+	// Seeding limits set with SetSeedGoal. If nil, limits in config are used.
+	seedGoal *resumer.SeedGoal
+
+	// Connection limits set with SetMaxPeers. If nil, limits in config are used.
+	maxPeers *resumer.MaxPeers
+
+	// Locations of files relative to storage root if they are renamed by user.
+	// If nil, paths are generated from info.
+	filePaths []string
+
 	// Bitfield for pieces we have. It is created after we got info.
 	bitfield *bitfield.Bitfield
 
@@ -81,9 +124,33 @@ type torrent struct {
 	// Piece messages coming from peers are sent this channel.
 	pieceMessages chan peer.PieceMessage
 
-	// To limit parallel writes to disk, pieceMessages is set to nil when a piece has started writing to disk.
+	// To limit parallel writes to disk, pieceMessages is set to nil when DiskWriteConcurrency pieces are being written.
 	blockPieceMessages chan peer.PieceMessage
 
+	// Piece writers running.
+	pieceWriters map[*piecewriter.PieceWriter]struct{}
+	// Results of these piece writers are ignored because the bitfield is discarded by Verify while they are running.
+	discardedPieceWriters map[*piecewriter.PieceWriter]struct{}
+
+	// Web seed URLs (BEP 19). Pieces that none of the peers have are downloaded from them.
+	webseedURLs []string
+	// HTTP client for web seed requests.
+	webseedClient *http.Client
+	// Running web seed downloads keyed by URL.
+	webseedDownloaders map[string]*webseed.Downloader
+	// Web seeds that have failed are not used until the time in this map.
+	webseedDisabledUntil map[string]time.Time
+	// Piece downloaders are started again when this timer fires to retry disabled web seeds.
+	webseedRetryTimer  *time.Timer
+	webseedRetryTimerC <-chan time.Time
+	// Web seed downloaders send the result to this channel.
+	webseedResultC chan *webseed.Downloader
+
+	// Piece writers that have failed with a transient error are run again when this timer fires.
+	pieceWriterRetries     []*piecewriter.PieceWriter
+	pieceWriterRetryTimer  *time.Timer
+	pieceWriterRetryTimerC <-chan time.Time
+
 	// Other messages coming from peers are sent to this channel.
 	messages chan peer.Message
 
@@ -95,6 +162,9 @@ type torrent struct {
 	outgoingPeers map[*peer.Peer]struct{}
 	peersSnubbed  map[*peer.Peer]struct{}
 
+	// Snub penalties of IP addresses. Kept after peers disconnect so reconnecting slow peers are deprioritized.
+	snubPenalties map[string]snubPenalty
+
 	// Active piece downloads are kept in this map.
 	pieceDownloaders        map[*peer.Peer]*piecedownloader.PieceDownloader
 	pieceDownloadersSnubbed map[*peer.Peer]*piecedownloader.PieceDownloader
@@ -107,6 +177,22 @@ type torrent struct {
 	infoDownloaders        map[*peer.Peer]*infodownloader.InfoDownloader
 	infoDownloadersSnubbed map[*peer.Peer]*infodownloader.InfoDownloader
 
+	// When a metadata download does not finish in time, the downloader is sent to this channel.
+	infoDownloaderTimeoutC chan *infodownloader.InfoDownloader
+
+	// Number of failed metadata downloads keyed by peer IP.
+	// Peers that have failed too many times are not asked for metadata again.
+	metadataFailures map[string]int
+
+	// Metadata bytes received from peers that could not deliver valid metadata.
+	metadataBytesWasted int64
+
+	// Wasted piece bytes by reason. Not saved in resume data.
+	bytesWasted WastedBreakdown
+
+	// Limits metadata sent to peers to MetadataUploadRateLimit.
+	metadataUploadLimiter *ratelimit.Limiter
+
 	pieceWriterResultC chan *piecewriter.PieceWriter
 
 	// Some peers are optimistically unchoked regardless of their download rate.
@@ -115,9 +201,24 @@ type torrent struct {
 	// This channel is closed once all pieces are downloaded and verified.
 	completeC chan struct{}
 
+	// Closed when the torrent is closed to cancel the completion webhook goroutine.
+	webhookStopC chan struct{}
+	webhookWG    sync.WaitGroup
+
+	// Set while all peers are being closed in stop() so it does not trigger an announce.
+	stoppingPeers bool
+
+	// Announces made when all peers are disconnected are limited by this backoff.
+	zeroPeersBackoff      backoff.BackOff
+	lastZeroPeersAnnounce time.Time
+	zeroPeersAnnounceWait time.Duration
+
 	// True after all pieces are download, verified and written to disk.
 	completed bool
 
+	// Torrent is started again after it is stopped for verification.
+	startAfterStop bool
+
 	// If any unrecoverable error occurs, it will be sent to this channel and download will be stopped.
 	errC chan error
 
@@ -127,9 +228,15 @@ type torrent struct {
 	// Contains the last error sent to errC.
 	lastError error
 
+	// Swarm statistics received in last call to Scrape().
+	lastScrape ScrapeResult
+
 	// When Stop() is called, it will close this channel to signal run() function to stop.
 	closeC chan chan struct{}
 
+	// Closed when run() returns. Commands sent after this point are not processed.
+	doneC chan struct{}
+
 	// These are the channels for sending a message to run() loop.
 	statsCommandC        chan statsRequest        // Stats()
 	trackersCommandC     chan trackersRequest     // Trackers()
@@ -139,10 +246,40 @@ type torrent struct {
 	notifyErrorCommandC  chan notifyErrorCommand  // NotifyError()
 	notifyListenCommandC chan notifyListenCommand // NotifyListen()
 	addPeersCommandC     chan []*net.TCPAddr      // AddPeers()
+	pingCommandC         chan struct{}            // ping()
+	renameCommandC       chan renameRequest       // Rename()
+	renameFileCommandC   chan renameFileRequest   // RenameFile()
+	setNameCommandC      chan renameRequest       // SetName()
+	addrListCommandC     chan addrListRequest     // AddrListStats()
+
+	setUploadEnabledCommandC chan setUploadEnabledRequest // SetUploadEnabled()
+	resetStatsCommandC       chan resetStatsRequest       // ResetStats()
+	piecePrioritiesCommandC  chan piecePrioritiesRequest  // SetPiecePriorities()
+	seedOnlyCommandC         chan seedOnlyRequest         // SetSeedOnly()
+	seedGoalCommandC         chan seedGoalRequest         // SetSeedGoal()
+	maxPeersCommandC         chan maxPeersRequest         // SetMaxPeers()
+	infoCommandC             chan infoRequest             // getInfo()
+	scrapeResultCommandC     chan ScrapeResult            // Scrape()
+	filesCommandC            chan filesRequest            // Files()
+	filePriorityCommandC     chan filePriorityRequest     // SetFilePriority()
+	activeDownloadsCommandC  chan activeDownloadsRequest  // ActiveDownloads()
+	torrentFileCommandC      chan torrentFileRequest      // WriteTorrent()
+	magnetCommandC           chan magnetRequest           // Magnet()
+	verifyCommandC           chan verifyRequest           // Verify()
+	announceNowCommandC      chan announceNowRequest      // AnnounceNow()
+	pexCommandC              chan pexRequest              // SetPEX()
 
 	// Trackers send announce responses to this channel.
 	addrsFromTrackers chan []*net.TCPAddr
 
+	// Announcers notify when a tracker starts or stops working.
+	trackerStatusC chan announcer.StatusChange
+
+	// Events are sent to this channel without blocking. Shared by all torrents in Session.
+	events chan Event
+	// Events of this torrent only. Returned from Torrent.Events.
+	torrentEvents chan Event
+
 	// Keeps a list of peer addresses to connect.
 	addrList *addrlist.AddrList
 
@@ -165,19 +302,40 @@ type torrent struct {
 	// all periodical trackers are closed.
 	stoppedEventAnnouncer *announcer.StopAnnouncer
 
+	// URLs of trackers that the stopped event could not be announced to when the torrent is closed.
+	stopAnnounceFailed []string
+
 	// If not nil, torrent is announced to DHT periodically.
 	dhtNode      *dhtAnnouncer
 	dhtAnnouncer *announcer.DHTAnnouncer
 	dhtPeersC    chan []*net.TCPAddr
 
-	// List of peers in handshake state.
+	// If not nil, torrent is announced to local network periodically.
+	lsdNode      *lsdAnnouncer
+	lsdAnnouncer *announcer.DHTAnnouncer
+	lsdPeersC    chan []*net.TCPAddr
+
+	// List of peers in handshake state. Outgoing handshakers are mapped to the source of the dialed address.
 	incomingHandshakers map[*incominghandshaker.IncomingHandshaker]struct{}
-	outgoingHandshakers map[*outgoinghandshaker.OutgoingHandshaker]struct{}
+	outgoingHandshakers map[*outgoinghandshaker.OutgoingHandshaker]addrlist.PeerSource
+
+	// Time of the last dial. Addresses are dialed again when dialTimer fires if DialInterval has not passed yet.
+	lastDial   time.Time
+	dialTimer  *time.Timer
+	dialTimerC <-chan time.Time
 
 	// Handshake results are sent to these channels by handshakers.
 	incomingHandshakerResultC chan *incominghandshaker.IncomingHandshaker
 	outgoingHandshakerResultC chan *outgoinghandshaker.OutgoingHandshaker
 
+	// Shared by all torrents in Session to limit incoming handshakes. A slot is held for each incoming handshaker.
+	handshakes handshakeSemaphore
+	// Shared by all torrents in Session to limit half-open connections. A slot is held for each outgoing handshaker.
+	halfOpen handshakeSemaphore
+
+	// Limits piece writes of all torrents in Session. Nil means no limit.
+	writeSemaphore chan struct{}
+
 	// When metadata of the torrent downloaded completely, a message is sent to this channel.
 	infoDownloaderResultC chan *infodownloader.InfoDownloader
 
@@ -204,12 +362,39 @@ type torrent struct {
 	verifierResultC   chan *verifier.Verifier
 	checkedPieces     uint32
 
+	// Place of the torrent in verifier queue. Verifier is started when verifierReadyC is closed.
+	verifierTicket *verifier.Ticket
+	verifierReadyC chan struct{}
+
+	// Non-fatal problems found while running the torrent.
+	warnings []string
+
 	resumerStats          resumer.Stats
+	rememberedPeers       []resumer.Peer
 	seedDurationUpdatedAt time.Time
 
+	// Remembered peers are added to the address list once after each start.
+	rememberedPeersDialed bool
+
 	// Holds connected peer IPs so we don't dial/accept multiple connections to/from same IP.
 	connectedPeerIPs map[string]struct{}
 
+	// IPs of peers that are disconnected for violating the protocol. They are not dialed or accepted again.
+	bannedPeerIPs map[string]struct{}
+
+	// IPs of peers that are banned for a duration, with ban expiration times.
+	// Peers are banned temporarily if their client is in Config.BannedClients or they send too many corrupt pieces.
+	tempBannedPeerIPs map[string]time.Time
+
+	// Number of corrupt pieces that peers at each IP have sent blocks of.
+	badPieces map[string]int
+
+	// Number of peer connections closed because their client is in Config.BannedClients.
+	rejectedPeers int
+
+	// Number of incoming connections and outgoing dials refused because the address is in the blocklist.
+	blockedPeers int
+
 	// A signal sent to run() loop when announcers are stopped.
 	announcersStoppedC chan struct{}
 
@@ -224,15 +409,32 @@ type torrent struct {
 	statsWriteTicker  *time.Ticker
 	statsWriteTickerC <-chan time.Time
 
+	// Peers that have not exchanged piece data in IdleConnectionTimeout are closed at every tick.
+	idlePeerReaperTicker  *time.Ticker
+	idlePeerReaperTickerC <-chan time.Time
+
+	// Number of peer connections closed because of being idle.
+	idlePeersReaped int
+
 	// Keeps blocks read from disk in memory.
 	pieceCache *piececache.Cache
 
-	// To limit parallel disk reads.
-	readMutex sync.Mutex
+	// To limit parallel disk reads. Has a capacity of Config.DiskReadConcurrency.
+	readSemaphore chan struct{}
 
 	// Optional list of IP addresses to block.
 	blocklist *blocklist.Blocklist
 
+	// Optional pool of goroutines shared between torrents for hashing pieces during verification.
+	verifierPool *verifier.Pool
+
+	// Optional queue shared between torrents for limiting concurrent verifications.
+	verifierQueue *verifier.Queue
+
+	// Optional limiters for piece data shared between torrents.
+	downloadLimiter *ratelimit.Limiter
+	uploadLimiter   *ratelimit.Limiter
+
 	// Used to calculate canonical peer priority (BEP 40).
 	// Initialized with value found in network interfaces.
 	// Then, updated from "yourip" field in BEP 10 extension handshake message.
@@ -251,6 +453,8 @@ type torrent struct {
 // For magnet downloads name can change after metadata is downloaded but this method still returns the initial name.
 // Use Stats() method to get name in info dictionary.
 func (t *torrent) Name() string {
+	t.mName.Lock()
+	defer t.mName.Unlock()
 	return t.name
 }
 
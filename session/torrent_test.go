@@ -1,7 +1,9 @@
 package session
 
 import (
+	"encoding/binary"
 	"encoding/hex"
+	"io"
 	"io/ioutil"
 	"net"
 	"os"
@@ -11,9 +13,14 @@ import (
 	"time"
 
 	"github.com/cenkalti/log"
+	"github.com/cenkalti/rain/internal/bitfield"
+	"github.com/cenkalti/rain/internal/btconn"
 	"github.com/cenkalti/rain/internal/logger"
 	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/cenkalti/rain/internal/piece"
 	"github.com/cenkalti/rain/internal/storage/filestorage"
+	"github.com/zeebo/bencode"
 )
 
 var (
@@ -85,7 +92,9 @@ func TestDownloadMagnet(t *testing.T) {
 	}
 
 	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
-	t2.AddPeers([]*net.TCPAddr{addr})
+	if err = t2.AddPeers([]*net.TCPAddr{addr}); err != nil {
+		t.Fatal(err)
+	}
 
 	select {
 	case <-t2.NotifyComplete():
@@ -108,3 +117,648 @@ func TestDownloadMagnet(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestRenameTorrent(t *testing.T) {
+	where, err := ioutil.TempDir("", "rain-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(where)
+
+	cmd := exec.Command("cp", "-R", filepath.Join(torrentDataDir, torrentName), where)
+	err = cmd.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	mi, err := metainfo.New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt := options{
+		Info: mi.Info,
+	}
+	tor, err := opt.NewTorrent(mi.Info.Hash[:], newFileStorage(t, where))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tor.Close()
+
+	err = tor.Rename("../escape")
+	if err == nil {
+		t.Fatal("invalid name is accepted")
+	}
+	err = tor.Rename("renamed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tor.RenameFile(0, "renamed-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := append([]string{where, "renamed"}, mi.Info.Files[0].Path...)
+	parts[len(parts)-1] = "renamed-file"
+	_, err = os.Stat(filepath.Join(parts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := tor.Stats().Name; name != "renamed" {
+		t.Fatalf("invalid name: %q", name)
+	}
+
+	// Files at new paths must pass verification.
+	tor.Start()
+	select {
+	case <-tor.NotifyComplete():
+	case err = <-tor.NotifyError():
+		t.Fatal(err)
+	case <-time.After(timeout):
+		panic("verification did not finish")
+	}
+}
+
+func TestUploadWhileDownloading(t *testing.T) {
+	src, err := ioutil.TempDir("", "rain-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src)
+	dst, err := ioutil.TempDir("", "rain-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dst)
+
+	cmd := exec.Command("cp", "-R", filepath.Join(torrentDataDir, torrentName), src)
+	err = cmd.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Corrupt the last piece so the seeder has an incomplete torrent.
+	f, err := os.OpenFile(filepath.Join(src, torrentName, "data", "zero.bin"), os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.WriteAt([]byte{1}, 10*1024*1024-1)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tf, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tf.Close()
+
+	mi, err := metainfo.New(tf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt1 := options{
+		Info: mi.Info,
+	}
+	t1, err := opt1.NewTorrent(mi.Info.Hash[:], newFileStorage(t, src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer t1.Close()
+
+	opt2 := options{
+		Info: mi.Info,
+	}
+	t2, err := opt2.NewTorrent(mi.Info.Hash[:], newFileStorage(t, dst))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer t2.Close()
+
+	t1.Start()
+	t2.Start()
+
+	var port int
+	select {
+	case port = <-t1.NotifyListen():
+	case err = <-t1.NotifyError():
+		t.Fatal(err)
+	case <-time.After(timeout):
+		panic("seeder is not ready")
+	}
+
+	have := t1.Stats().Pieces.Have
+	if have == 0 || have == mi.Info.NumPieces {
+		t.Fatalf("unexpected number of pieces in seeder: %d", have)
+	}
+
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+	if err = t2.AddPeers([]*net.TCPAddr{addr}); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for t2.Stats().Pieces.Have < have {
+		if time.Now().After(deadline) {
+			t.Fatal("completed pieces are not downloaded")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if t1.Stats().Bytes.Uploaded == 0 {
+		t.Fatal("upload is not counted")
+	}
+}
+
+func TestBanPeerSendingInvalidHave(t *testing.T) {
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	mi, err := metainfo.New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt := options{
+		Info: mi.Info,
+	}
+	tor, err := opt.NewTorrent(mi.Info.Hash[:], newFileStorage(t, torrentDataDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tor.Close()
+
+	tor.Start()
+	var port int
+	select {
+	case port = <-tor.NotifyListen():
+	case err = <-tor.NotifyError():
+		t.Fatal(err)
+	case <-time.After(timeout):
+		panic("torrent is not ready")
+	}
+
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+	var peerID [20]byte
+	copy(peerID[:], "-RN0000-banpeertest1")
+	conn, _, _, _, err := btconn.Dial(addr, nil, timeout, timeout, false, false, [8]byte{}, mi.Info.Hash, peerID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Have message for a piece index that does not exist in torrent.
+	msg := []byte{0, 0, 0, 5, byte(peerprotocol.Have), 0xff, 0xff, 0xff, 0xff}
+	if _, err = conn.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	err = conn.SetReadDeadline(time.Now().Add(timeout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.Copy(ioutil.Discard, conn)
+	if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+		t.Fatal("connection is not closed by torrent")
+	}
+
+	// Banned peer cannot connect again.
+	_, _, _, _, err = btconn.Dial(addr, nil, timeout, timeout, false, false, [8]byte{}, mi.Info.Hash, peerID, nil)
+	if err == nil {
+		t.Fatal("banned peer is accepted")
+	}
+}
+
+func TestBanPeerSendingInvalidMetadata(t *testing.T) {
+	where, err := ioutil.TempDir("", "rain-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(where)
+
+	ih, err := hex.DecodeString(torrentInfoHashString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Peers sending invalid metadata are banned even if the ban duration for corrupt pieces is zero.
+	cfg := DefaultConfig
+	cfg.BadPeerBanDuration = 0
+	opt := options{Config: &cfg}
+	tor, err := opt.NewTorrent(ih, newFileStorage(t, where))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tor.Close()
+
+	tor.Start()
+	var port int
+	select {
+	case port = <-tor.NotifyListen():
+	case err = <-tor.NotifyError():
+		t.Fatal(err)
+	case <-time.After(timeout):
+		panic("torrent is not ready")
+	}
+
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+	var peerID [20]byte
+	copy(peerID[:], "-RN0000-badmetadata1")
+	var ih20 [20]byte
+	copy(ih20[:], ih)
+	var ext [8]byte
+	copy(ext[:], ourExtensions.Bytes())
+	conn, _, _, _, err := btconn.Dial(addr, nil, timeout, timeout, false, false, ext, ih20, peerID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	err = conn.SetReadDeadline(time.Now().Add(timeout))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const metadataSize = 100
+	writeExtensionMessage(t, conn, peerprotocol.ExtensionMessage{
+		ExtendedMessageID: peerprotocol.ExtensionIDHandshake,
+		Payload: peerprotocol.ExtensionHandshakeMessage{
+			M:            map[string]uint8{peerprotocol.ExtensionKeyMetadata: peerprotocol.ExtensionIDMetadata},
+			MetadataSize: metadataSize,
+		},
+	})
+
+	// Wait for the metadata request and reply with garbage.
+	for {
+		var length uint32
+		err = binary.Read(conn, binary.BigEndian, &length)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, length)
+		_, err = io.ReadFull(conn, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if length >= 2 && peerprotocol.MessageID(buf[0]) == peerprotocol.Extension && buf[1] == peerprotocol.ExtensionIDMetadata {
+			break
+		}
+	}
+	writeExtensionMessage(t, conn, peerprotocol.ExtensionMessage{
+		ExtendedMessageID: peerprotocol.ExtensionIDMetadata,
+		Payload: peerprotocol.ExtensionMetadataMessage{
+			Type:      peerprotocol.ExtensionMetadataMessageTypeData,
+			TotalSize: metadataSize,
+			Data:      make([]byte, metadataSize),
+		},
+	})
+	_, err = io.Copy(ioutil.Discard, conn)
+	if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+		t.Fatal("connection is not closed by torrent")
+	}
+
+	// Banned peer cannot connect again.
+	_, _, _, _, err = btconn.Dial(addr, nil, timeout, timeout, false, false, ext, ih20, peerID, nil)
+	if err == nil {
+		t.Fatal("banned peer is accepted")
+	}
+}
+
+func writeExtensionMessage(t *testing.T, conn net.Conn, msg peerprotocol.ExtensionMessage) {
+	payload, err := msg.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5, 5+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(1+len(payload)))
+	buf[4] = byte(peerprotocol.Extension)
+	if _, err = conn.Write(append(buf, payload...)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLazyBitfieldUploadOnly(t *testing.T) {
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	mi, err := metainfo.New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig
+	cfg.LazyBitfield = true
+	opt := options{
+		Info:   mi.Info,
+		Config: &cfg,
+	}
+	tor, err := opt.NewTorrent(mi.Info.Hash[:], newFileStorage(t, torrentDataDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tor.Close()
+
+	tor.Start()
+	var port int
+	select {
+	case port = <-tor.NotifyListen():
+	case err = <-tor.NotifyError():
+		t.Fatal(err)
+	case <-time.After(timeout):
+		panic("torrent is not ready")
+	}
+	select {
+	case <-tor.NotifyComplete():
+	case <-time.After(timeout):
+		panic("verification did not finish")
+	}
+
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+	var peerID [20]byte
+	copy(peerID[:], "-RN0000-lazybitfield")
+	var ext [8]byte
+	copy(ext[:], ourExtensions.Bytes())
+	conn, _, _, _, err := btconn.Dial(addr, nil, timeout, timeout, false, false, ext, mi.Info.Hash, peerID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	err = conn.SetReadDeadline(time.Now().Add(timeout))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Skip messages until the extension handshake.
+	for {
+		var length uint32
+		err = binary.Read(conn, binary.BigEndian, &length)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, length)
+		_, err = io.ReadFull(conn, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if length < 2 || peerprotocol.MessageID(buf[0]) != peerprotocol.Extension || buf[1] != peerprotocol.ExtensionIDHandshake {
+			continue
+		}
+		var msg peerprotocol.ExtensionHandshakeMessage
+		err = bencode.DecodeBytes(buf[2:], &msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.UploadOnly != 0 {
+			t.Fatal("upload_only is sent with lazy bitfield")
+		}
+		return
+	}
+}
+
+func TestSendLazyBitfield(t *testing.T) {
+	pe, remote := newRunningTestPeer(t)
+	defer remote.Close()
+	defer pe.Close()
+
+	const numPieces = 20
+	bf := bitfield.New(numPieces)
+	for i := uint32(0); i < numPieces; i++ {
+		bf.Set(i)
+	}
+	tor := &torrent{}
+	tor.sendLazyBitfield(pe, bf)
+
+	err := remote.SetReadDeadline(time.Now().Add(timeout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent *bitfield.Bitfield
+	for {
+		var length uint32
+		err = binary.Read(remote, binary.BigEndian, &length)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, length)
+		_, err = io.ReadFull(remote, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if length == 0 {
+			continue
+		}
+		switch peerprotocol.MessageID(buf[0]) {
+		case peerprotocol.Bitfield:
+			sent, err = bitfield.NewBytes(buf[1:], numPieces)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := sent.Count(); n != numPieces-lazyBitfieldPieces {
+				t.Fatalf("bitfield has %d pieces, expected %d", n, numPieces-lazyBitfieldPieces)
+			}
+			// Original bitfield of the torrent is not modified.
+			if !bf.All() {
+				t.Fatal("pieces are cleared from the bitfield of torrent")
+			}
+		case peerprotocol.Have:
+			if sent == nil {
+				t.Fatal("have message is sent before bitfield")
+			}
+			// Omitted pieces are sent later as Have messages.
+			index := binary.BigEndian.Uint32(buf[1:5])
+			if sent.Test(index) {
+				t.Fatalf("have message is sent for piece #%d that is in the bitfield", index)
+			}
+			return
+		}
+	}
+}
+
+func TestMissingBlocks(t *testing.T) {
+	bf := bitfield.New(3)
+	bf.Set(0)
+	tor := &torrent{
+		info: &metainfo.Info{
+			PieceLength: 2 * piece.BlockSize,
+			TotalLength: 5 * piece.BlockSize,
+			NumPieces:   3,
+		},
+		pieces: []piece.Piece{
+			{Index: 0, Length: 2 * piece.BlockSize},
+			{Index: 1, Length: 2 * piece.BlockSize},
+			{Index: 2, Length: piece.BlockSize},
+		},
+		bitfield: bf,
+	}
+	if n := tor.missingBlocks(); n != 3 {
+		t.Fatalf("unexpected number of missing blocks: %d", n)
+	}
+	tor.piecePriorities = []uint8{uint8(PriorityNormal), uint8(PrioritySkip), uint8(PriorityNormal)}
+	if n := tor.missingBlocks(); n != 1 {
+		t.Fatalf("unexpected number of missing blocks with skipped piece: %d", n)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	where, err := ioutil.TempDir("", "rain-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(where)
+
+	cmd := exec.Command("cp", "-R", filepath.Join(torrentDataDir, torrentName), where)
+	err = cmd.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	mi, err := metainfo.New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt := options{
+		Info: mi.Info,
+	}
+	tor, err := opt.NewTorrent(mi.Info.Hash[:], newFileStorage(t, where))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tor.Close()
+
+	tor.Start()
+	select {
+	case <-tor.NotifyComplete():
+	case err = <-tor.NotifyError():
+		t.Fatal(err)
+	case <-time.After(timeout):
+		panic("verification did not finish")
+	}
+
+	// Corrupt the first piece while the torrent thinks it is complete.
+	parts := append([]string{where, torrentName}, mi.Info.Files[0].Path...)
+	df, err := os.OpenFile(filepath.Join(parts...), os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = df.WriteAt([]byte("corrupt"), 0)
+	df.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tor.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		s := tor.Stats()
+		if s.Status == Downloading {
+			if s.Pieces.Missing == 0 {
+				t.Fatal("corrupt piece is not detected")
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("torrent is not restarted, status: %d", s.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSkippedPieces(t *testing.T) {
+	where, err := ioutil.TempDir("", "rain-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(where)
+
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	mi, err := metainfo.New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt1 := options{
+		Info: mi.Info,
+	}
+	t1, err := opt1.NewTorrent(mi.Info.Hash[:], newFileStorage(t, torrentDataDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer t1.Close()
+
+	opt2 := options{
+		Info: mi.Info,
+	}
+	t2, err := opt2.NewTorrent(mi.Info.Hash[:], newFileStorage(t, where))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer t2.Close()
+
+	err = t2.SetPiecePriorities(map[int]Priority{0: PrioritySkip})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t1.Start()
+	t2.Start()
+
+	var port int
+	select {
+	case port = <-t1.NotifyListen():
+	case err = <-t1.NotifyError():
+		t.Fatal(err)
+	case <-time.After(timeout):
+		panic("seeder is not ready")
+	}
+
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+	if err = t2.AddPeers([]*net.TCPAddr{addr}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-t2.NotifyComplete():
+	case err = <-t2.NotifyError():
+		t.Fatal(err)
+	case <-time.After(timeout):
+		panic("download did not finish")
+	}
+	s := t2.Stats()
+	if s.Status != Seeding {
+		t.Fatalf("unexpected status: %d", s.Status)
+	}
+	if s.Pieces.Have != mi.Info.NumPieces-1 {
+		t.Fatalf("unexpected number of pieces: %d", s.Pieces.Have)
+	}
+
+	// Torrent must continue downloading after the skipped piece becomes wanted again.
+	err = t2.SetPiecePriorities(map[int]Priority{0: PriorityNormal})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s = t2.Stats(); s.Status != Downloading {
+		t.Fatalf("unexpected status: %d", s.Status)
+	}
+	select {
+	case <-t2.NotifyComplete():
+		t.Fatal("torrent is still complete")
+	default:
+	}
+}
@@ -0,0 +1,108 @@
+package session
+
+import (
+	"io"
+
+	"github.com/cenkalti/rain/internal/magnet"
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/tracker/tiertracker"
+)
+
+type torrentFileRequest struct {
+	Response chan torrentFileResponse
+}
+
+type torrentFileResponse struct {
+	Bytes []byte
+	Error error
+}
+
+// WriteTorrent writes the torrent file that contains the info dict and trackers of the torrent to w.
+// Returns ErrNoMetadata if the torrent is added with a magnet link and the info dict is not downloaded yet.
+func (t *torrent) WriteTorrent(w io.Writer) error {
+	req := torrentFileRequest{Response: make(chan torrentFileResponse, 1)}
+	select {
+	case t.torrentFileCommandC <- req:
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+	var resp torrentFileResponse
+	select {
+	case resp = <-req.Response:
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	// Writing is done outside of the run loop because w may block.
+	_, err := w.Write(resp.Bytes)
+	return err
+}
+
+func (t *torrent) torrentFile() torrentFileResponse {
+	if t.info == nil {
+		return torrentFileResponse{Error: ErrNoMetadata}
+	}
+	b, err := metainfo.NewBytes(t.info.Bytes, t.trackerURLs(), t.webseedURLs)
+	return torrentFileResponse{Bytes: b, Error: err}
+}
+
+type magnetRequest struct {
+	Response chan string
+}
+
+// Magnet returns a magnet link that contains the info hash, name and trackers of the torrent.
+// It is available before the info dict is downloaded.
+func (t *torrent) Magnet() string {
+	req := magnetRequest{Response: make(chan string, 1)}
+	select {
+	case t.magnetCommandC <- req:
+	case <-t.doneC:
+		return t.infoHashMagnet()
+	}
+	select {
+	case s := <-req.Response:
+		return s
+	case <-t.doneC:
+		return t.infoHashMagnet()
+	}
+}
+
+// infoHashMagnet returns the magnet link with info hash only.
+// Info hash does not change so it can be called outside of run loop.
+func (t *torrent) infoHashMagnet() string {
+	m := magnet.Magnet{InfoHash: t.infoHash}
+	return m.String()
+}
+
+func (t *torrent) magnet() string {
+	m := magnet.Magnet{
+		InfoHash: t.infoHash,
+		Name:     t.displayName(),
+	}
+	for _, tier := range t.trackerURLs() {
+		m.Trackers = append(m.Trackers, tier...)
+	}
+	return m.String()
+}
+
+// trackerURLs returns the URLs of trackers as tiers.
+func (t *torrent) trackerURLs() [][]string {
+	var tiers [][]string
+	for _, trk := range t.trackers {
+		tt, ok := trk.(*tiertracker.TierTracker)
+		if !ok {
+			tiers = append(tiers, []string{trk.URL()})
+			continue
+		}
+		for _, tier := range tt.Tiers() {
+			urls := make([]string, len(tier))
+			for i, tr := range tier {
+				urls[i] = tr.URL()
+			}
+			tiers = append(tiers, urls)
+		}
+	}
+	return tiers
+}
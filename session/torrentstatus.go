@@ -1,15 +1,27 @@
 package session
 
+// TorrentStatus is the state of a torrent. Status changes in the following order:
+// Stopped -> [DownloadingMetadata ->] [VerificationQueued ->] Allocating -> Verifying -> Downloading -> Seeding -> Stopping -> Stopped.
+// A torrent may enter Stopping state from any state when it is stopped by user or because of an error.
 type TorrentStatus int
 
 const (
+	// Stopped means the torrent is not running.
 	Stopped TorrentStatus = iota
+	// DownloadingMetadata means the info dictionary is being downloaded from peers.
 	DownloadingMetadata
+	// Allocating means the files are being created on disk. Progress is in Stats.Bytes.Allocated.
 	Allocating
+	// Verifying means existing pieces are being checked. Progress is in Stats.Pieces.Checked.
 	Verifying
+	// Downloading means pieces are being downloaded from peers.
 	Downloading
+	// Seeding means all pieces are downloaded and uploaded to peers.
 	Seeding
+	// Stopping means the stopped event is being announced to trackers.
 	Stopping
+	// VerificationQueued means the torrent is waiting for other torrents to finish verification.
+	VerificationQueued
 )
 
 func torrentStatusToString(s TorrentStatus) string {
@@ -21,10 +33,16 @@ func torrentStatusToString(s TorrentStatus) string {
 		Downloading:         "Downloading",
 		Seeding:             "Seeding",
 		Stopping:            "Stopping",
+		VerificationQueued:  "Verification Queued",
 	}
 	return m[s]
 }
 
+// String returns the name of the status, e.g. "Downloading Metadata".
+func (s TorrentStatus) String() string {
+	return torrentStatusToString(s)
+}
+
 func (t *torrent) status() TorrentStatus {
 	if t.errC == nil {
 		return Stopped
@@ -38,11 +56,38 @@ func (t *torrent) status() TorrentStatus {
 	if t.verifier != nil {
 		return Verifying
 	}
+	if t.verifierTicket != nil {
+		return VerificationQueued
+	}
 	if t.completed {
 		return Seeding
 	}
+	if t.seedOnly && t.info != nil {
+		return Seeding
+	}
 	if t.info == nil {
 		return DownloadingMetadata
 	}
 	return Downloading
 }
+
+// statusProgress returns the progress of Allocating and Verifying states in range [0, 100].
+func (t *torrent) statusProgress(status TorrentStatus) float64 {
+	if t.info == nil {
+		return 0
+	}
+	switch status {
+	case Allocating:
+		if t.info.TotalLength == 0 {
+			return 100
+		}
+		return float64(t.bytesAllocated) * 100 / float64(t.info.TotalLength)
+	case Verifying:
+		if t.info.NumPieces == 0 {
+			return 100
+		}
+		return float64(t.checkedPieces) * 100 / float64(t.info.NumPieces)
+	default:
+		return 0
+	}
+}
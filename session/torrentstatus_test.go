@@ -0,0 +1,50 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/zeebo/bencode"
+)
+
+func TestStatusProgress(t *testing.T) {
+	b, err := bencode.EncodeBytes(map[string]interface{}{
+		"name":         "progress",
+		"length":       40000,
+		"piece length": 16 * 1024,
+		"pieces":       string(make([]byte, 3*20)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := metainfo.NewInfo(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tor := &torrent{}
+	if p := tor.statusProgress(Allocating); p != 0 {
+		t.Fatalf("unexpected progress without info: %f", p)
+	}
+	tor.info = info
+	tor.bytesAllocated = 10000
+	if p := tor.statusProgress(Allocating); p != 25 {
+		t.Fatalf("unexpected allocating progress: %f", p)
+	}
+	tor.checkedPieces = 3
+	if p := tor.statusProgress(Verifying); p != 100 {
+		t.Fatalf("unexpected verifying progress: %f", p)
+	}
+	if p := tor.statusProgress(Downloading); p != 0 {
+		t.Fatalf("unexpected downloading progress: %f", p)
+	}
+}
+
+func TestTorrentStatusString(t *testing.T) {
+	if s := DownloadingMetadata.String(); s != "Downloading Metadata" {
+		t.Fatalf("unexpected string: %q", s)
+	}
+	if s := VerificationQueued.String(); s != "Verification Queued" {
+		t.Fatalf("unexpected string: %q", s)
+	}
+}
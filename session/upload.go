@@ -0,0 +1,51 @@
+package session
+
+const uploadDisabledWarning = "uploading is disabled, all peers are kept choked"
+
+type setUploadEnabledRequest struct {
+	Enabled  bool
+	Response chan error
+}
+
+// SetUploadEnabled enables or disables uploading data to peers.
+func (t *torrent) SetUploadEnabled(enabled bool) error {
+	req := setUploadEnabledRequest{Enabled: enabled, Response: make(chan error, 1)}
+	select {
+	case t.setUploadEnabledCommandC <- req:
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+	select {
+	case err := <-req.Response:
+		return err
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+}
+
+func (t *torrent) setUploadEnabled(enabled bool) error {
+	if t.uploadDisabled == !enabled {
+		return nil
+	}
+	if t.resume != nil {
+		err := t.resume.WriteUploadDisabled(!enabled)
+		if err != nil {
+			return err
+		}
+	}
+	t.uploadDisabled = !enabled
+	if t.uploadDisabled {
+		// Not uploading hurts other peers in the swarm but they can still download from us once it is enabled again.
+		t.log.Warning(uploadDisabledWarning)
+		t.stopUnchokeTimers()
+		for pe := range t.peers {
+			t.chokePeer(pe)
+		}
+		t.optimisticUnchokedPeers = t.optimisticUnchokedPeers[:0]
+		return nil
+	}
+	if t.errC != nil && t.bitfield != nil && t.pieces != nil {
+		t.startUnchokeTimers()
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package session
+
+import (
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+)
+
+func TestUploadOnlyMessage(t *testing.T) {
+	pe, remote := newRunningTestPeer(t)
+	defer remote.Close()
+
+	cfg := DefaultConfig
+	cfg.MaxPeerDial = 0
+	tor := &torrent{
+		config: cfg,
+		peers:  map[*peer.Peer]struct{}{pe: {}},
+	}
+	defer func() {
+		// Peer is closed by the torrent if the test passes.
+		if _, ok := tor.peers[pe]; ok {
+			pe.Close()
+		}
+	}()
+	pe.AmChoking = false
+	tor.handlePeerMessage(peer.Message{Peer: pe, Message: peerprotocol.ExtensionUploadOnlyMessage{UploadOnly: true}})
+	if !pe.UploadOnly {
+		t.Fatal("peer is not marked as upload only")
+	}
+	// Peer is treated like a seed, so it is choked.
+	if !pe.AmChoking {
+		t.Fatal("peer is not choked")
+	}
+	err := remote.SetReadDeadline(time.Now().Add(timeout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		var length uint32
+		err = binary.Read(remote, binary.BigEndian, &length)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, length)
+		_, err = io.ReadFull(remote, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if length > 0 && peerprotocol.MessageID(buf[0]) == peerprotocol.Choke {
+			break
+		}
+	}
+
+	tor.handlePeerMessage(peer.Message{Peer: pe, Message: peerprotocol.ExtensionUploadOnlyMessage{UploadOnly: false}})
+	if pe.UploadOnly {
+		t.Fatal("peer is still upload only")
+	}
+
+	// Connections between seeds are closed.
+	tor.completed = true
+	tor.handlePeerMessage(peer.Message{Peer: pe, Message: peerprotocol.ExtensionUploadOnlyMessage{UploadOnly: true}})
+	if _, ok := tor.peers[pe]; ok {
+		t.Fatal("upload only peer is not closed when torrent is completed")
+	}
+}
@@ -7,11 +7,23 @@ import (
 	"github.com/cenkalti/rain/internal/verifier"
 )
 
+// releaseVerifierTicket lets the next torrent in verifier queue to start verifying.
+// If the torrent is still waiting in the queue, it is removed from the queue.
+func (t *torrent) releaseVerifierTicket() {
+	if t.verifierTicket == nil {
+		return
+	}
+	t.verifierTicket.Release()
+	t.verifierTicket = nil
+	t.verifierReadyC = nil
+}
+
 func (t *torrent) handleVerificationDone(ve *verifier.Verifier) {
 	if t.verifier != ve {
 		panic("invalid verifier")
 	}
 	t.verifier = nil
+	t.releaseVerifierTicket()
 
 	if ve.Error != nil {
 		t.stop(fmt.Errorf("file verification error: %s", ve.Error))
@@ -0,0 +1,56 @@
+package session
+
+type verifyRequest struct {
+	Response chan error
+}
+
+// Verify discards the bitfield and hashes all pieces on disk again.
+// Running torrent is stopped and restarted, pieces failing the check are downloaded again.
+// Stopped torrent is verified when it is started.
+func (t *torrent) Verify() error {
+	req := verifyRequest{Response: make(chan error, 1)}
+	select {
+	case t.verifyCommandC <- req:
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+	select {
+	case err := <-req.Response:
+		return err
+	case <-t.doneC:
+		return ErrTorrentClosed
+	}
+}
+
+func (t *torrent) verify() error {
+	if t.info == nil {
+		return ErrNoMetadata
+	}
+	switch t.status() {
+	case Allocating, VerificationQueued, Verifying:
+		return nil
+	}
+	running := t.errC != nil && t.stoppedEventAnnouncer == nil
+	if running {
+		// Peers and downloaders must not use the pieces while they are being checked.
+		t.stop(nil)
+	}
+	// Bitfield is written when the torrent stops. Overwrite it so pieces are checked even if session is restarted.
+	if t.resume != nil {
+		err := t.resume.WriteBitfield(nil)
+		if err != nil {
+			return err
+		}
+	}
+	// Piece writers may still be running after stop. Their results must not be applied to the new bitfield.
+	for pw := range t.pieceWriters {
+		t.discardedPieceWriters[pw] = struct{}{}
+	}
+	t.bitfield = nil
+	if t.completed {
+		t.completed = false
+		t.completeC = make(chan struct{})
+	}
+	t.startAfterStop = running
+	return nil
+}
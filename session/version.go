@@ -1,5 +1,54 @@
 package session
 
+import (
+	"strings"
+)
+
 // Version of client. Set during build.
 // "0.0.0" is the development version.
 var Version = "0.0.0"
+
+// clientName identifies the client in BEP 10 handshakes and tracker requests.
+const clientName = "Rain"
+
+// clientVersion returns the client version that is sent in BEP 10 handshake, e.g. "Rain 0.1.0".
+func clientVersion(version string) string {
+	return clientName + " " + version
+}
+
+// userAgent returns the User-Agent header that is sent to HTTP trackers, e.g. "Rain/0.1.0".
+func userAgent(version string) string {
+	return clientName + "/" + version
+}
+
+// peerIDPrefix returns an Azureus style peer id prefix for the version string.
+// For example, version "0.1.0" is converted to "-RN0100-".
+func peerIDPrefix(version string) string {
+	b := []byte("-RN0000-")
+	// Strip pre-release and build metadata.
+	if i := strings.IndexAny(version, "-+"); i != -1 {
+		version = version[:i]
+	}
+	for i, part := range strings.SplitN(version, ".", 4) {
+		var n int
+		for _, c := range part {
+			if c < '0' || c > '9' {
+				break
+			}
+			n = n*10 + int(c-'0')
+		}
+		b[3+i] = versionChar(n)
+	}
+	return string(b)
+}
+
+func versionChar(n int) byte {
+	switch {
+	case n < 10:
+		return byte('0' + n)
+	case n < 36:
+		return byte('A' + n - 10)
+	default:
+		return 'Z'
+	}
+}
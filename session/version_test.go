@@ -0,0 +1,30 @@
+package session
+
+import "testing"
+
+func TestPeerIDPrefix(t *testing.T) {
+	cases := map[string]string{
+		"0.0.0":      "-RN0000-",
+		"0.1.0":      "-RN0100-",
+		"1.12.3":     "-RN1C30-",
+		"2.0.1-rc.1": "-RN2010-",
+		"1.2.3.4.5":  "-RN1234-",
+	}
+	for version, prefix := range cases {
+		if s := peerIDPrefix(version); s != prefix {
+			t.Errorf("version: %q, expected: %q, got: %q", version, prefix, s)
+		}
+	}
+}
+
+func TestDefaultClientIdentifiers(t *testing.T) {
+	if s := DefaultConfig.PeerIDPrefix; s != peerIDPrefix(Version) {
+		t.Errorf("unexpected peer id prefix: %q", s)
+	}
+	if s := DefaultConfig.ExtensionHandshakeClientVersion; s != "Rain "+Version {
+		t.Errorf("unexpected client version: %q", s)
+	}
+	if s := DefaultConfig.TrackerHTTPUserAgent; s != "Rain/"+Version {
+		t.Errorf("unexpected user agent: %q", s)
+	}
+}
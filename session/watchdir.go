@@ -0,0 +1,109 @@
+package session
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Interval for scanning Config.WatchDir for new torrent files.
+const watchDirInterval = 5 * time.Second
+
+// Torrent files in Config.WatchDir are moved to this directory after they are added.
+const watchDirAddedDir = ".added"
+
+// AddTorrentFile adds a new torrent from the torrent file at path and starts it.
+func (s *Session) AddTorrentFile(path string) (*Torrent, error) {
+	f, err := os.Open(path) // nolint: gosec
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return s.AddTorrent(f)
+}
+
+// watchedFile is the state of a torrent file in watch directory at last scan.
+type watchedFile struct {
+	size    int64
+	modTime time.Time
+	// Adding the file has failed. It is not tried again until the file is modified.
+	failed bool
+}
+
+func (s *Session) startWatchDir() error {
+	if s.config.WatchDir == "" {
+		return nil
+	}
+	err := os.MkdirAll(filepath.Join(s.config.WatchDir, watchDirAddedDir), 0750)
+	if err != nil {
+		return err
+	}
+	s.watchDirDoneC = make(chan struct{})
+	go s.watchDir()
+	return nil
+}
+
+// watchDir adds torrent files that are put into Config.WatchDir.
+func (s *Session) watchDir() {
+	defer close(s.watchDirDoneC)
+	ticker := time.NewTicker(watchDirInterval)
+	defer ticker.Stop()
+	files := make(map[string]watchedFile)
+	for {
+		select {
+		case <-ticker.C:
+			files = s.scanWatchDir(files)
+		case <-s.closeC:
+			return
+		}
+	}
+}
+
+// scanWatchDir adds the torrent files that have not changed since the previous scan.
+// Files that are still being written are added after the writer is done, so partial files are not read.
+func (s *Session) scanWatchDir(prev map[string]watchedFile) map[string]watchedFile {
+	infos, err := ioutil.ReadDir(s.config.WatchDir)
+	if err != nil {
+		s.log.Errorln("cannot read watch dir:", err.Error())
+		return prev
+	}
+	files := make(map[string]watchedFile, len(infos))
+	for _, fi := range infos {
+		select {
+		case <-s.closeC:
+			return files
+		default:
+		}
+		if fi.IsDir() || !strings.HasSuffix(strings.ToLower(fi.Name()), ".torrent") {
+			continue
+		}
+		path := filepath.Join(s.config.WatchDir, fi.Name())
+		wf := watchedFile{size: fi.Size(), modTime: fi.ModTime()}
+		p, ok := prev[path]
+		if !ok || p.size != wf.size || !p.modTime.Equal(wf.modTime) {
+			files[path] = wf
+			continue
+		}
+		if p.failed {
+			files[path] = p
+			continue
+		}
+		_, err = s.AddTorrentFile(path)
+		if err != nil && err != ErrTorrentAlreadyExists {
+			s.log.Errorf("cannot add torrent file %s: %s", path, err)
+			wf.failed = true
+			files[path] = wf
+			continue
+		}
+		err = os.Rename(path, filepath.Join(s.config.WatchDir, watchDirAddedDir, fi.Name()))
+		if err != nil {
+			s.log.Errorf("cannot move added torrent file %s: %s", path, err)
+			// Prevent adding the same file again.
+			wf.failed = true
+			files[path] = wf
+		}
+	}
+	return files
+}
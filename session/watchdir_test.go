@@ -0,0 +1,74 @@
+package session
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScanWatchDir(t *testing.T) {
+	s, closeSession := newTestSession(t, nil)
+	defer closeSession()
+
+	// Watch dir goroutine is not started because WatchDir is empty in config. Scans are run by the test.
+	dir := filepath.Join(filepath.Dir(s.config.Database), "watch")
+	s.config.WatchDir = dir
+	err := os.MkdirAll(filepath.Join(dir, watchDirAddedDir), 0750)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "valid.torrent"), b, 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "invalid.torrent"), []byte("invalid"), 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Files are not added until they are seen unchanged in the next scan.
+	files := s.scanWatchDir(make(map[string]watchedFile))
+	if l := len(s.ListTorrents()); l != 0 {
+		t.Fatalf("torrent is added in first scan: %d", l)
+	}
+	files = s.scanWatchDir(files)
+	if l := len(s.ListTorrents()); l != 1 {
+		t.Fatalf("unexpected number of torrents: %d", l)
+	}
+	if _, err = os.Stat(filepath.Join(dir, watchDirAddedDir, "valid.torrent")); err != nil {
+		t.Fatal("added file is not moved:", err)
+	}
+	if !files[filepath.Join(dir, "invalid.torrent")].failed {
+		t.Fatal("invalid file is not marked as failed")
+	}
+	if _, err = os.Stat(filepath.Join(dir, "invalid.torrent")); err != nil {
+		t.Fatal("invalid file must stay in watch dir:", err)
+	}
+}
+
+func TestCloseWaitsWatchDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rain-watch-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, closeSession := newTestSession(t, func(cfg *Config) {
+		cfg.WatchDir = dir
+	})
+	doneC := s.watchDirDoneC
+	if doneC == nil {
+		t.Fatal("watch dir is not started")
+	}
+	closeSession()
+	select {
+	case <-doneC:
+	default:
+		t.Fatal("watch dir goroutine is running after Close")
+	}
+}
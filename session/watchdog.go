@@ -0,0 +1,61 @@
+package session
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+func (s *Session) startWatchdog() {
+	if s.config.WatchdogInterval <= 0 {
+		return
+	}
+	go s.watchdog()
+}
+
+// watchdog checks run loops of torrents periodically and logs a stack dump if any of them is stuck.
+func (s *Session) watchdog() {
+	ticker := time.NewTicker(s.config.WatchdogInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.checkTorrents()
+		case <-s.closeC:
+			return
+		}
+	}
+}
+
+func (s *Session) checkTorrents() {
+	var m sync.Mutex
+	var stuck []*Torrent
+	var wg sync.WaitGroup
+	for _, t := range s.ListTorrents() {
+		wg.Add(1)
+		go func(t *Torrent) {
+			defer wg.Done()
+			if t.torrent.ping(s.config.WatchdogTimeout) {
+				return
+			}
+			select {
+			case <-t.removed:
+				return
+			default:
+			}
+			m.Lock()
+			stuck = append(stuck, t)
+			m.Unlock()
+		}(t)
+	}
+	wg.Wait()
+	if len(stuck) == 0 {
+		return
+	}
+	for _, t := range stuck {
+		s.log.Errorf("torrent #%s is not responding for %s", t.id, s.config.WatchdogTimeout)
+	}
+	buf := make([]byte, 1<<20)
+	n := runtime.Stack(buf, true)
+	s.log.Errorf("stack dump of all goroutines:\n%s", buf[:n])
+}
@@ -0,0 +1,81 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/logger"
+)
+
+// errorRecorder is a logger that records error messages.
+type errorRecorder struct {
+	logger.Logger
+	m      sync.Mutex
+	errors []string
+}
+
+func (l *errorRecorder) Errorf(format string, args ...interface{}) {
+	l.m.Lock()
+	l.errors = append(l.errors, fmt.Sprintf(format, args...))
+	l.m.Unlock()
+}
+
+func TestPing(t *testing.T) {
+	tor := &torrent{pingCommandC: make(chan struct{})}
+	if tor.ping(10 * time.Millisecond) {
+		t.Fatal("torrent without run loop must not respond")
+	}
+	go func() { <-tor.pingCommandC }()
+	if !tor.ping(time.Second) {
+		t.Fatal("torrent must respond")
+	}
+}
+
+func TestWatchdogReportsStuckTorrent(t *testing.T) {
+	s, closeSession := newTestSession(t, func(cfg *Config) {
+		cfg.WatchdogTimeout = 100 * time.Millisecond
+	})
+	defer closeSession()
+	rec := &errorRecorder{Logger: s.log}
+	s.log = rec
+
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	running, err := s.AddTorrentOptions(f, &AddOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Run loop of this torrent is not running, so it never responds to ping.
+	s.m.Lock()
+	s.torrents["stuck"] = &Torrent{id: "stuck", torrent: &torrent{pingCommandC: make(chan struct{})}, removed: make(chan struct{})}
+	s.m.Unlock()
+	defer func() {
+		s.m.Lock()
+		delete(s.torrents, "stuck")
+		s.m.Unlock()
+	}()
+
+	s.checkTorrents()
+
+	rec.m.Lock()
+	defer rec.m.Unlock()
+	if len(rec.errors) != 2 {
+		t.Fatalf("unexpected errors: %q", rec.errors)
+	}
+	if !strings.Contains(rec.errors[0], "#stuck ") {
+		t.Fatalf("stuck torrent is not reported: %q", rec.errors[0])
+	}
+	if strings.Contains(rec.errors[0], running.ID()) {
+		t.Fatal("running torrent is reported")
+	}
+	if !strings.HasPrefix(rec.errors[1], "stack dump") {
+		t.Fatalf("stack dump is not logged: %q", rec.errors[1])
+	}
+}
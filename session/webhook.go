@@ -0,0 +1,111 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// Number of times the completion webhook is tried before giving up.
+	webhookAttempts = 3
+	// Wait time before retrying a failed webhook is multiplied with the attempt number.
+	webhookRetryInterval = 5 * time.Second
+)
+
+type completionWebhookPayload struct {
+	ID         string `json:"id"`
+	InfoHash   string `json:"info_hash"`
+	Name       string `json:"name"`
+	TotalBytes int64  `json:"total_bytes"`
+	// Directory of the torrent in DataDir.
+	Dest string `json:"dest"`
+	// Location of the file or the root directory of the torrent on disk.
+	Path string `json:"path"`
+}
+
+// notifyCompletionWebhook posts the completed torrent to Config.CompletionWebhookURL in a new goroutine.
+// It is only called when the download is completed, not when a complete torrent is loaded.
+func (t *torrent) notifyCompletionWebhook() {
+	if t.config.CompletionWebhookURL == "" {
+		return
+	}
+	name := t.info.Name
+	if t.filePaths != nil {
+		name = rootName(t.filePaths[0])
+	}
+	body, err := json.Marshal(completionWebhookPayload{
+		ID:         t.id,
+		InfoHash:   hex.EncodeToString(t.infoHash[:]),
+		Name:       name,
+		TotalBytes: t.info.TotalLength,
+		Dest:       t.storage.Dest(),
+		Path:       filepath.Join(t.storage.Dest(), name),
+	})
+	if err != nil {
+		t.log.Errorln("cannot encode webhook payload:", err.Error())
+		return
+	}
+	t.webhookWG.Add(1)
+	go t.postCompletionWebhook(t.config.CompletionWebhookURL, body)
+}
+
+// postCompletionWebhook tries to post body to url until it succeeds or attempts are exhausted.
+// It returns early when webhookStopC is closed, cancelling the request in progress.
+func (t *torrent) postCompletionWebhook(url string, body []byte) {
+	defer t.webhookWG.Done()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-t.webhookStopC:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	client := http.Client{Timeout: t.config.CompletionWebhookTimeout}
+	for i := 1; i <= webhookAttempts; i++ {
+		err := postWebhook(ctx, &client, url, body)
+		if err == nil {
+			t.log.Debugln("completion webhook is sent")
+			return
+		}
+		if ctx.Err() != nil {
+			t.log.Debugln("completion webhook is cancelled")
+			return
+		}
+		t.log.Warningf("completion webhook failed (attempt %d of %d): %s", i, webhookAttempts, err)
+		if i < webhookAttempts {
+			timer := time.NewTimer(time.Duration(i) * webhookRetryInterval)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				t.log.Debugln("completion webhook is cancelled")
+				return
+			}
+		}
+	}
+}
+
+func postWebhook(ctx context.Context, client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/logger"
+)
+
+func TestCompletionWebhookRetry(t *testing.T) {
+	requestC := make(chan []byte, webhookAttempts)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected content type: %q", r.Header.Get("Content-Type"))
+		}
+		buf := make([]byte, 64)
+		n, _ := r.Body.Read(buf)
+		requestC <- buf[:n]
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	tor := &torrent{
+		config:       DefaultConfig,
+		log:          logger.New("test"),
+		webhookStopC: make(chan struct{}),
+	}
+	tor.webhookWG.Add(1)
+	go tor.postCompletionWebhook(srv.URL, []byte(`{"id":"test"}`))
+
+	select {
+	case body := <-requestC:
+		if string(body) != `{"id":"test"}` {
+			t.Fatalf("unexpected body: %q", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook is not posted")
+	}
+
+	// Closing must not wait for the retry interval.
+	doneC := make(chan struct{})
+	go func() {
+		close(tor.webhookStopC)
+		tor.webhookWG.Wait()
+		close(doneC)
+	}()
+	select {
+	case <-doneC:
+	case <-time.After(webhookRetryInterval / 2):
+		t.Fatal("webhook goroutine is not stopped")
+	}
+	if len(requestC) != 0 {
+		t.Fatal("webhook is retried after stop")
+	}
+}
+
+func TestCompletionWebhookSuccess(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer srv.Close()
+
+	tor := &torrent{
+		config:       DefaultConfig,
+		log:          logger.New("test"),
+		webhookStopC: make(chan struct{}),
+	}
+	tor.webhookWG.Add(1)
+	tor.postCompletionWebhook(srv.URL, []byte("{}"))
+	if requests != 1 {
+		t.Fatalf("unexpected number of requests: %d", requests)
+	}
+}
@@ -0,0 +1,113 @@
+package session
+
+import (
+	"crypto/sha1" // nolint: gosec
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/cenkalti/rain/internal/piecewriter"
+	"github.com/cenkalti/rain/internal/webseed"
+)
+
+// newWebseedClient returns a HTTP client for downloading from web seeds.
+// Connections are made from localIP if it is not nil.
+func newWebseedClient(localIP net.IP) *http.Client {
+	if localIP == nil {
+		return &http.Client{}
+	}
+	dialer := &net.Dialer{
+		LocalAddr: &net.TCPAddr{IP: localIP},
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialer.DialContext,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+	}
+}
+
+// startWebseedDownloaders starts downloading pieces that none of the peers have from web seeds.
+// Only one piece is downloaded from each web seed at a time. Nothing is downloaded in seed-only mode.
+func (t *torrent) startWebseedDownloaders() {
+	if t.seedOnly || len(t.webseedURLs) == 0 || t.piecePicker == nil {
+		return
+	}
+	now := time.Now()
+	for _, u := range t.webseedURLs {
+		if _, ok := t.webseedDownloaders[u]; ok {
+			continue
+		}
+		if now.Before(t.webseedDisabledUntil[u]) {
+			continue
+		}
+		pi := t.piecePicker.PickWebSeed()
+		if pi == nil {
+			return
+		}
+		d := webseed.New(u, pi, t.piecePool.Get().([]byte))
+		t.webseedDownloaders[u] = d
+		go d.Run(t.webseedClient, t.info, t.config.WebseedDownloadTimeout, t.webseedResultC)
+	}
+}
+
+func (t *torrent) handleWebseedDone(d *webseed.Downloader) {
+	delete(t.webseedDownloaders, d.URL)
+	t.piecePicker.HandleWebSeedDone(d.Piece.Index)
+	if d.Error != nil {
+		t.log.Warningf("cannot download piece #%d from web seed %s: %s", d.Piece.Index, d.URL, d.Error)
+		t.disableWebseed(d)
+		return
+	}
+	n := int64(d.Piece.Length)
+	t.downloadSpeed.Update(n)
+	t.resumerStats.LastActivity = time.Now()
+	t.resumerStats.BytesDownloaded += n
+	if !d.Piece.VerifyHash(d.Buffer[:d.Piece.Length], sha1.New()) { // nolint: gosec
+		t.wasteBytes(n, &t.bytesWasted.HashFailed)
+		t.log.Errorf("received corrupt piece #%d from web seed %s", d.Piece.Index, d.URL)
+		t.disableWebseed(d)
+		return
+	}
+	if d.Piece.Done || d.Piece.Writing {
+		t.piecePool.Put(d.Buffer)
+		t.startWebseedDownloaders()
+		return
+	}
+	d.Piece.Writing = true
+	t.startPieceWriter(piecewriter.New(d.Piece, d.Buffer, d.Piece.Length))
+	t.startWebseedDownloaders()
+}
+
+// disableWebseed stops using the web seed for WebseedRetryInterval. The piece may be downloaded from peers in the meantime.
+func (t *torrent) disableWebseed(d *webseed.Downloader) {
+	t.piecePool.Put(d.Buffer)
+	t.webseedDisabledUntil[d.URL] = time.Now().Add(t.config.WebseedRetryInterval)
+	if t.webseedRetryTimer == nil {
+		t.webseedRetryTimer = time.NewTimer(t.config.WebseedRetryInterval)
+		t.webseedRetryTimerC = t.webseedRetryTimer.C
+	}
+	t.startPieceDownloaders()
+}
+
+func (t *torrent) stopWebseedDownloaders() {
+	if t.webseedRetryTimer != nil {
+		t.webseedRetryTimer.Stop()
+		t.webseedRetryTimer = nil
+		t.webseedRetryTimerC = nil
+	}
+	for u, d := range t.webseedDownloaders {
+		d.Close()
+		t.piecePool.Put(d.Buffer)
+		if t.piecePicker != nil {
+			t.piecePicker.HandleWebSeedDone(d.Piece.Index)
+		}
+		delete(t.webseedDownloaders, u)
+	}
+}
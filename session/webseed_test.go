@@ -0,0 +1,85 @@
+package session
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/piece"
+	"github.com/cenkalti/rain/internal/piecepicker"
+	"github.com/cenkalti/rain/internal/webseed"
+)
+
+func TestWebseedClientLocalAddr(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	ip := net.IPv4(127, 0, 0, 1)
+	tr := newWebseedClient(ip).Transport.(*http.Transport)
+	conn, err := tr.DialContext(context.Background(), "tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if laddr := conn.LocalAddr().(*net.TCPAddr); !laddr.IP.Equal(ip) {
+		t.Fatalf("connection is made from %s, want %s", laddr.IP, ip)
+	}
+
+	if c := newWebseedClient(nil); c.Transport != nil {
+		t.Fatal("default transport must be used without listen address")
+	}
+}
+
+func TestWebseedSeedOnly(t *testing.T) {
+	// Requests are not answered until they are cancelled.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	tor := &torrent{
+		config:               DefaultConfig,
+		info:                 &metainfo.Info{Name: "file", Length: 16, PieceLength: 16, TotalLength: 16, NumPieces: 1},
+		pieces:               []piece.Piece{{Index: 0, Length: 16}},
+		webseedURLs:          []string{srv.URL},
+		webseedClient:        &http.Client{},
+		webseedDownloaders:   make(map[string]*webseed.Downloader),
+		webseedDisabledUntil: make(map[string]time.Time),
+		webseedResultC:       make(chan *webseed.Downloader),
+		seedOnly:             true,
+		log:                  logger.New("torrent"),
+	}
+	tor.piecePool.New = func() interface{} { return make([]byte, 16) }
+	tor.piecePicker = piecepicker.New(tor.pieces, tor.config.EndgameParallelDownloadsPerPiece, tor.log)
+
+	tor.startWebseedDownloaders()
+	if n := len(tor.webseedDownloaders); n != 0 {
+		t.Fatalf("web seed is used in seed-only mode: %d downloaders", n)
+	}
+
+	tor.seedOnly = false
+	tor.startWebseedDownloaders()
+	if n := len(tor.webseedDownloaders); n != 1 {
+		t.Fatalf("unexpected number of web seed downloaders: %d", n)
+	}
+
+	// Running downloads are stopped when seed-only mode is enabled and the piece can be picked again later.
+	err := tor.setSeedOnly(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(tor.webseedDownloaders); n != 0 {
+		t.Fatalf("web seed downloaders are not stopped: %d", n)
+	}
+	if pi := tor.piecePicker.PickWebSeed(); pi == nil {
+		t.Fatal("piece is still marked as downloading from web seed")
+	}
+}
@@ -0,0 +1,185 @@
+package session
+
+import (
+	"net/http"
+)
+
+// webUIHandler serves a single page web UI that talks to the JSON-RPC API on the same server.
+// Assets are kept in Go source so they are compiled into the binary.
+type webUIHandler struct{}
+
+func (h webUIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/ui/" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(webUIIndex))
+}
+
+const webUIIndex = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Rain</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 4px 8px; border-bottom: 1px solid #ddd; }
+.progress { background: #eee; width: 120px; height: 12px; }
+.progress div { background: #4a4; height: 12px; }
+#error { color: #c00; }
+</style>
+</head>
+<body>
+<h1>Rain</h1>
+<form id="add-uri">
+<input id="uri" size="80" placeholder="Magnet link or torrent URL">
+<button type="submit">Add</button>
+<input id="file" type="file" accept=".torrent">
+</form>
+<p id="error"></p>
+<table>
+<thead><tr><th>Name</th><th>Status</th><th>Progress</th><th>Download</th><th>Upload</th><th>Peers</th><th></th></tr></thead>
+<tbody id="torrents"></tbody>
+</table>
+<script>
+var nextID = 1;
+// Sent if the server is configured with RPCToken. Asked to the user when the server rejects the request.
+var token = localStorage.getItem("rainToken") || "";
+
+function call(method, params) {
+	var body = {jsonrpc: "2.0", id: nextID++, method: "Session." + method, params: params || {}};
+	var headers = {"Content-Type": "application/json", "Accept": "application/json"};
+	if (token) {
+		headers["Authorization"] = "Bearer " + token;
+	}
+	return fetch("/", {
+		method: "POST",
+		headers: headers,
+		body: JSON.stringify(body)
+	}).then(function(resp) {
+		if (resp.status === 401) {
+			token = prompt("RPC token") || "";
+			localStorage.setItem("rainToken", token);
+			throw new Error("unauthorized");
+		}
+		return resp.json();
+	}).then(function(resp) {
+		if (resp.error) {
+			throw new Error(resp.error.message);
+		}
+		return resp.result;
+	});
+}
+
+function showError(err) {
+	document.getElementById("error").textContent = err ? err.message : "";
+}
+
+function formatSpeed(bps) {
+	var units = ["B/s", "KiB/s", "MiB/s", "GiB/s"];
+	var i = 0;
+	while (bps >= 1024 && i < units.length - 1) {
+		bps /= 1024;
+		i++;
+	}
+	return bps.toFixed(i === 0 ? 0 : 1) + " " + units[i];
+}
+
+function button(label, onclick) {
+	var b = document.createElement("button");
+	b.textContent = label;
+	b.onclick = function() {
+		onclick().then(refresh).catch(showError);
+	};
+	return b;
+}
+
+function cell(tr, content) {
+	var td = document.createElement("td");
+	if (typeof content === "string") {
+		td.textContent = content;
+	} else {
+		td.appendChild(content);
+	}
+	tr.appendChild(td);
+	return td;
+}
+
+function row(t, s) {
+	var tr = document.createElement("tr");
+	cell(tr, s.Name || t.Name);
+	cell(tr, s.Status);
+	var percent = s.Bytes.Total > 0 ? 100 * s.Bytes.Completed / s.Bytes.Total : 0;
+	var bar = document.createElement("div");
+	bar.className = "progress";
+	var fill = document.createElement("div");
+	fill.style.width = percent.toFixed(1) + "%";
+	fill.title = percent.toFixed(1) + "%";
+	bar.appendChild(fill);
+	cell(tr, bar);
+	cell(tr, formatSpeed(s.Speed.Download));
+	cell(tr, formatSpeed(s.Speed.Upload));
+	cell(tr, String(s.Peers.Total));
+	var actions = cell(tr, "");
+	actions.appendChild(button("Start", function() { return call("StartTorrent", {ID: t.ID}); }));
+	actions.appendChild(button("Stop", function() { return call("StopTorrent", {ID: t.ID}); }));
+	actions.appendChild(button("Remove", function() {
+		if (!confirm("Remove " + (s.Name || t.Name) + "?")) {
+			return Promise.resolve();
+		}
+		return call("RemoveTorrent", {ID: t.ID});
+	}));
+	return tr;
+}
+
+function refresh() {
+	return call("ListTorrents").then(function(result) {
+		var torrents = result.Torrents || [];
+		return Promise.all(torrents.map(function(t) {
+			return call("GetTorrentStats", {ID: t.ID}).then(function(r) { return row(t, r.Stats); });
+		}));
+	}).then(function(rows) {
+		var tbody = document.getElementById("torrents");
+		while (tbody.firstChild) {
+			tbody.removeChild(tbody.firstChild);
+		}
+		rows.forEach(function(tr) { tbody.appendChild(tr); });
+		showError(null);
+	}).catch(showError);
+}
+
+document.getElementById("add-uri").onsubmit = function(e) {
+	e.preventDefault();
+	var input = document.getElementById("uri");
+	call("AddURI", {URI: input.value}).then(function() {
+		input.value = "";
+	}).then(refresh).catch(showError);
+};
+
+document.getElementById("file").onchange = function(e) {
+	var f = e.target.files[0];
+	if (!f) {
+		return;
+	}
+	var reader = new FileReader();
+	reader.onload = function() {
+		var data = reader.result.substring(reader.result.indexOf(",") + 1);
+		call("AddTorrent", {Torrent: data}).then(function() {
+			e.target.value = "";
+		}).then(refresh).catch(showError);
+	};
+	reader.readAsDataURL(f);
+};
+
+refresh();
+setInterval(refresh, 2000);
+</script>
+</body>
+</html>
+`
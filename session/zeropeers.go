@@ -0,0 +1,47 @@
+package session
+
+import (
+	"time"
+
+	"github.com/cenkalti/backoff"
+)
+
+// If no announce is made because of losing all peers in this duration, backoff starts again from the initial interval.
+const zeroPeersBackoffResetAfter = time.Hour
+
+func newZeroPeersBackoff() backoff.BackOff {
+	return &backoff.ExponentialBackOff{
+		InitialInterval:     time.Minute,
+		RandomizationFactor: 0.5,
+		Multiplier:          2,
+		MaxInterval:         30 * time.Minute,
+		MaxElapsedTime:      0, // never stop
+		Clock:               backoff.SystemClock,
+	}
+}
+
+// announceOnZeroPeers asks trackers and DHT for new peers when all peers are disconnected.
+func (t *torrent) announceOnZeroPeers() {
+	if !t.config.AnnounceOnZeroPeers || t.stoppingPeers || t.completed {
+		return
+	}
+	if len(t.announcers) == 0 && t.dhtAnnouncer == nil {
+		return
+	}
+	now := time.Now()
+	sinceLast := now.Sub(t.lastZeroPeersAnnounce)
+	if sinceLast > zeroPeersBackoffResetAfter {
+		t.zeroPeersBackoff.Reset()
+	} else if sinceLast < t.zeroPeersAnnounceWait {
+		return
+	}
+	t.log.Debugln("all peers are disconnected, announcing now")
+	for _, an := range t.announcers {
+		an.AnnounceNow()
+	}
+	if t.dhtAnnouncer != nil {
+		t.dhtAnnouncer.AnnounceNow()
+	}
+	t.lastZeroPeersAnnounce = now
+	t.zeroPeersAnnounceWait = t.zeroPeersBackoff.NextBackOff()
+}
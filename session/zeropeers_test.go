@@ -0,0 +1,54 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/announcer"
+	"github.com/cenkalti/rain/internal/logger"
+)
+
+func TestAnnounceOnZeroPeers(t *testing.T) {
+	announceC := make(chan struct{}, 10)
+	an := announcer.NewDHTAnnouncer()
+	go an.Run(func() { announceC <- struct{}{} }, time.Hour, 0, logger.New("test"))
+	defer an.Close()
+	// First announce is made when the announcer is started.
+	<-announceC
+
+	tor := &torrent{
+		config:           DefaultConfig,
+		dhtAnnouncer:     an,
+		zeroPeersBackoff: newZeroPeersBackoff(),
+		log:              logger.New("test"),
+	}
+	tor.config.AnnounceOnZeroPeers = true
+
+	tor.announceOnZeroPeers()
+	select {
+	case <-announceC:
+	case <-time.After(timeout):
+		t.Fatal("announce is not made when all peers are disconnected")
+	}
+	if tor.zeroPeersAnnounceWait < time.Minute/2 {
+		t.Fatalf("backoff is too short: %s", tor.zeroPeersAnnounceWait)
+	}
+
+	// Next announce waits for the backoff.
+	tor.announceOnZeroPeers()
+	select {
+	case <-announceC:
+		t.Fatal("announce is made before backoff")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Seeds do not need new peers.
+	tor.lastZeroPeersAnnounce = time.Time{}
+	tor.completed = true
+	tor.announceOnZeroPeers()
+	select {
+	case <-announceC:
+		t.Fatal("announce is made for completed torrent")
+	case <-time.After(100 * time.Millisecond):
+	}
+}